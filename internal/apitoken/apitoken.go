@@ -0,0 +1,95 @@
+// Package apitoken checks Flightcrew API tokens against the Flightcrew API, so that a
+// mistyped or revoked token is caught before the tower is installed.
+package apitoken
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"flightcrew.io/cli/internal/debug"
+)
+
+const (
+	verifyPath      = "/v1/token/verify"
+	maxResponseSize = 1 << 16
+)
+
+var (
+	// Endpoint replaces `https://<host>` for every verification if it's set
+	// (e.g. `http://localhost:8080` for a stub server).
+	Endpoint = ""
+
+	// ErrInvalid is returned when the Flightcrew API rejects the token.
+	ErrInvalid = errors.New("the token is invalid or has been revoked; check it with your Flightcrew contact")
+
+	// ErrNotFound is returned when the host doesn't verify tokens, which means that it isn't
+	// the Flightcrew API (e.g. a mistyped RPC host) rather than that it's unreachable.
+	ErrNotFound = errors.New("the host doesn't verify Flightcrew API tokens; check the RPC host")
+
+	client = &http.Client{Timeout: 10 * time.Second}
+)
+
+// Organization is the Flightcrew organization that a token belongs to.
+type Organization struct {
+	Name string `json:"organizationName"`
+}
+
+// Verify checks the token against the Flightcrew API at the host (e.g. api.flightcrew.io).
+// Errors other than ErrInvalid and ErrNotFound mean that the token couldn't be checked (e.g.
+// offline or a server error), not that it's wrong.
+func Verify(host string, token string) (*Organization, error) {
+	url := strings.TrimSuffix(Endpoint, "/")
+	if len(url) == 0 {
+		url = "https://" + host
+	}
+	url += verifyPath
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("verify token: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("verify token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return nil, ErrInvalid
+	case http.StatusNotFound:
+		return nil, fmt.Errorf("%s: %w", url, ErrNotFound)
+	default:
+		return nil, fmt.Errorf("verify token: %s returned %s", url, resp.Status)
+	}
+
+	var org Organization
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(&org); err != nil {
+		return nil, fmt.Errorf("verify token: parse response: %w", err)
+	}
+	return &org, nil
+}
+
+// Check verifies the token and returns what to show about it (e.g. its organization). It
+// only fails if the token or the host is wrong, and the token is used as is if it can't be
+// checked.
+func Check(host string, token string) (string, error) {
+	org, err := Verify(host, token)
+	if errors.Is(err, ErrInvalid) || errors.Is(err, ErrNotFound) {
+		return "", err
+	} else if err != nil {
+		debug.Output("verify token got error: %v", err)
+		return "couldn't verify the token with Flightcrew, so it's used as is", nil
+	}
+
+	return fmt.Sprintf("belongs to organization '%s'", org.Name), nil
+}
@@ -0,0 +1,67 @@
+package apitoken_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"flightcrew.io/cli/internal/apitoken"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerify(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/token/verify" {
+			http.NotFound(w, r)
+			return
+		}
+		switch r.Header.Get("Authorization") {
+		case "Bearer good-token":
+			_, _ = w.Write([]byte(`{"organizationName": "Acme"}`))
+		case "Bearer flaky-token":
+			w.WriteHeader(http.StatusBadGateway)
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer server.Close()
+
+	keep := apitoken.Endpoint
+	defer func() { apitoken.Endpoint = keep }()
+	apitoken.Endpoint = server.URL + "/"
+
+	org, err := apitoken.Verify("api.flightcrew.io", "good-token")
+	require.NoError(t, err)
+	assert.Equal(t, "Acme", org.Name)
+
+	_, err = apitoken.Verify("api.flightcrew.io", "revoked-token")
+	assert.ErrorIs(t, err, apitoken.ErrInvalid)
+
+	_, err = apitoken.Verify("api.flightcrew.io", "flaky-token")
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, apitoken.ErrInvalid)
+
+	info, err := apitoken.Check("api.flightcrew.io", "good-token")
+	require.NoError(t, err)
+	assert.Equal(t, "belongs to organization 'Acme'", info)
+	info, err = apitoken.Check("api.flightcrew.io", "flaky-token")
+	require.NoError(t, err, "server errors should keep the token")
+	assert.Contains(t, info, "couldn't verify the token")
+
+	// A host without the verification is misconfigured rather than offline.
+	apitoken.Endpoint = server.URL + "/not-the-api"
+	_, err = apitoken.Verify("api.flightcrew.io", "good-token")
+	assert.ErrorIs(t, err, apitoken.ErrNotFound)
+	_, err = apitoken.Check("api.flightcrew.io", "good-token")
+	assert.ErrorIs(t, err, apitoken.ErrNotFound)
+	apitoken.Endpoint = server.URL
+
+	server.Close()
+	_, err = apitoken.Verify("api.flightcrew.io", "good-token")
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, apitoken.ErrInvalid)
+	assert.NotErrorIs(t, err, apitoken.ErrNotFound)
+	_, err = apitoken.Check("api.flightcrew.io", "good-token")
+	assert.NoError(t, err, "offline should keep the token")
+}
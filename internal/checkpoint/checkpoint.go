@@ -0,0 +1,330 @@
+package checkpoint
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"flightcrew.io/cli/internal/constants"
+	"flightcrew.io/cli/internal/controller"
+	"flightcrew.io/cli/internal/debug"
+	"flightcrew.io/cli/internal/view/command"
+)
+
+var (
+	current *stateFile
+)
+
+type stateFile struct {
+	fn string
+	// dir is where the state file is created if fn isn't set yet. It's named after the flow
+	// and what the run changes, which is only known once the run has started.
+	dir    string
+	flow   string
+	inputs controller.Inputs
+	saved  bool
+}
+
+// fileNameRE matches what isn't safe to write into the state file's name.
+var fileNameRE = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+// State is everything needed to continue a run from where it stopped.
+type State struct {
+	// Flow is the command that the state was saved from (e.g. "gcp install").
+	Flow string `json:"flow"`
+	// RecreateCommand returns to the same inputs.
+	RecreateCommand string            `json:"recreateCommand"`
+	Inputs          map[string]string `json:"inputs"`
+	Args            map[string]string `json:"args"`
+	// Redacted are the secret args (e.g. the API token) that were left out of Args, and have
+	// to be passed again when the run is resumed.
+	Redacted []string `json:"redacted,omitempty"`
+	// Files are the local files that the commands reference, since they are usually in a
+	// temporary directory that is gone by the time the run is resumed. Secrets in them are
+	// replaced by the key of their arg.
+	Files    map[string]string `json:"files,omitempty"`
+	Commands []Command         `json:"commands"`
+}
+
+// Command is a command's progress, along with a hash of the command that it was saved for,
+// so that a run is only resumed with the same commands.
+type Command struct {
+	command.Snapshot
+	// Hash is the SHA-256 of the command with its secrets redacted.
+	Hash string `json:"hash"`
+}
+
+// Enable saves the progress of the flow to the state file at every Save.
+func Enable(fn string, flow string, inputs controller.Inputs) func() {
+	current = &stateFile{
+		fn:     fn,
+		flow:   flow,
+		inputs: inputs,
+	}
+
+	return func() {
+		current = nil
+	}
+}
+
+// EnableInDir is like Enable, but creates the state file in the directory once the run has
+// started, named after the flow and what the run changes (e.g. the project and the VM).
+func EnableInDir(dir string, flow string, inputs controller.Inputs) func() {
+	disable := Enable("", flow, inputs)
+	current.dir = dir
+	return disable
+}
+
+// ResumeHint returns how to continue from the state file, or an empty string if nothing
+// has been saved yet.
+func ResumeHint() string {
+	if current == nil || !current.saved {
+		return ""
+	}
+
+	commandName := constants.CLIName
+	if len(os.Args) > 0 {
+		commandName = os.Args[0]
+	}
+	return fmt.Sprintf("%s %s --resume=%s", commandName, current.flow, current.fn)
+}
+
+// Save writes the run's progress to the state file, if it's enabled. Errors only go to the
+// debug output, since a run shouldn't stop because its progress couldn't be saved.
+func Save(run controller.Run) {
+	if current == nil {
+		return
+	}
+
+	checkpointer, ok := run.(controller.Checkpointer)
+	if !ok {
+		debug.Output("run controller for %s can't be checkpointed", current.flow)
+		return
+	}
+
+	secrets := controller.SecretValues(run)
+	redactor := controller.NewRedactor(run)
+	state := State{
+		Flow:            current.flow,
+		RecreateCommand: redactor.Replace(run.RecreateCommand()),
+		Inputs:          make(map[string]string),
+		Args:            make(map[string]string),
+	}
+	for key, value := range checkpointer.Args() {
+		if _, ok := secrets[key]; ok {
+			state.Redacted = append(state.Redacted, key)
+			continue
+		}
+		state.Args[key] = value
+	}
+	sort.Strings(state.Redacted)
+	if current.inputs != nil {
+		for _, input := range current.inputs.GetInputs() {
+			value := input.Value()
+			if redacted := redactor.Replace(value); redacted != value {
+				value = controller.Redacted
+			}
+			state.Inputs[strings.TrimSpace(input.Title)] = value
+		}
+	}
+	if filesCtl, ok := run.(controller.Files); ok {
+		secretKeys := newSecretReplacer(secrets, true)
+		state.Files = make(map[string]string)
+		for fn, contents := range filesCtl.Files() {
+			state.Files[fn] = secretKeys.Replace(contents)
+		}
+	}
+	for _, cmd := range run.Commands() {
+		snapshot := cmd.Snapshot()
+		snapshot.Log = redactor.Replace(snapshot.Log)
+		state.Commands = append(state.Commands, Command{
+			Snapshot: snapshot,
+			Hash:     hashCommand(redactor, cmd),
+		})
+	}
+
+	if len(current.fn) == 0 {
+		name := fileNameRE.ReplaceAllString(fmt.Sprintf("%s-%s", current.flow, checkpointer.StateName()), "-")
+		current.fn = filepath.Join(current.dir, strings.Trim(name, "-")+"-state.json")
+	}
+	if err := Write(current.fn, state); err != nil {
+		debug.Output("save checkpoint to %s: %v", current.fn, err)
+		return
+	}
+	current.saved = true
+}
+
+// Remove deletes the state file once the run has finished, since there's nothing left to
+// resume.
+func Remove() {
+	if current == nil || !current.saved {
+		return
+	}
+
+	if err := os.Remove(current.fn); err != nil && !errors.Is(err, os.ErrNotExist) {
+		debug.Output("remove state file %s: %v", current.fn, err)
+		return
+	}
+	current.saved = false
+}
+
+// Write (over)writes the state file. Only the current user can read it, since it has the
+// inputs of the run.
+func Write(fn string, state State) error {
+	contents, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Write to a new temporary file next to it first so that quitting in the middle doesn't
+	// leave a partial state file behind. CreateTemp makes sure that the file didn't exist
+	// already, and only the user can read it.
+	f, err := os.CreateTemp(filepath.Dir(fn), filepath.Base(fn)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	if _, err := f.Write(contents); err != nil {
+		_ = f.Close()
+		_ = os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, fn); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+func Read(fn string) (*State, error) {
+	contents, err := os.ReadFile(fn)
+	if err != nil {
+		return nil, fmt.Errorf("read state file: %w", err)
+	}
+
+	var state State
+	if err := json.Unmarshal(contents, &state); err != nil {
+		return nil, fmt.Errorf("parse state file `%s`: %w", fn, err)
+	}
+	return &state, nil
+}
+
+// Resume rebuilds the Run controller from the saved args, and restores the commands up to
+// the first one that didn't finish. That command and every command after it will run again.
+// The redacted args come from the Inputs controller instead (e.g. from flags). The returned
+// function removes the local files that were recreated.
+func (s State) Resume(ctl controller.Inputs, flow string) (controller.Run, func(), error) {
+	if s.Flow != flow {
+		return nil, nil, fmt.Errorf("state file is for `%s`, not `%s`", s.Flow, flow)
+	}
+
+	resumer, ok := ctl.(controller.Resumer)
+	if !ok {
+		return nil, nil, fmt.Errorf("%s can't be resumed", flow)
+	}
+
+	run := resumer.ResumeRunController(s.Args)
+	commands := run.Commands()
+	if len(commands) != len(s.Commands) {
+		return nil, nil, errors.New("the commands have changed since the state file was saved")
+	}
+
+	secrets := controller.SecretValues(run)
+	for _, key := range s.Redacted {
+		if _, ok := secrets[key]; !ok {
+			return nil, nil, fmt.Errorf("the state file doesn't have the secret %s, so it has to be passed again (e.g. --token)", key)
+		}
+	}
+
+	// The secrets are redacted from the hashes, so the commands are compared after checking
+	// that the secrets were passed again.
+	redactor := controller.NewRedactor(run)
+	for i, cmd := range commands {
+		if hashCommand(redactor, cmd) != s.Commands[i].Hash {
+			return nil, nil, fmt.Errorf("command %d has changed since the state file was saved: %s", i+1, redactor.Replace(cmd.Command()))
+		}
+	}
+
+	// The files are only read when the commands run, so they can be written after the Run
+	// controller is built.
+	written := make([]string, 0, len(s.Files))
+	cleanup := func() {
+		for _, fn := range written {
+			_ = os.Remove(fn)
+		}
+	}
+	secretValues := newSecretReplacer(secrets, false)
+	for fn, contents := range s.Files {
+		if err := os.MkdirAll(filepath.Dir(fn), 0700); err != nil {
+			cleanup()
+			return nil, nil, fmt.Errorf("recreate dir for `%s`: %w", fn, err)
+		}
+		if err := os.WriteFile(fn, []byte(secretValues.Replace(contents)), 0600); err != nil {
+			cleanup()
+			return nil, nil, fmt.Errorf("recreate `%s`: %w", fn, err)
+		}
+		written = append(written, fn)
+	}
+
+	for i, saved := range s.Commands {
+		if !isFinished(commands[i], saved.Snapshot) {
+			break
+		}
+		commands[i].Restore(saved.Snapshot)
+	}
+
+	return run, cleanup, nil
+}
+
+// hashCommand returns the SHA-256 of the command as it's run, with its secrets redacted so
+// that they can't be guessed from the state file.
+func hashCommand(redactor *strings.Replacer, cmd *command.Model) string {
+	sum := sha256.Sum256([]byte(redactor.Replace(cmd.Command())))
+	return hex.EncodeToString(sum[:])
+}
+
+// newSecretReplacer replaces the secret values with the keys of their args, or the other way
+// around if toKeys is false.
+func newSecretReplacer(secrets map[string]string, toKeys bool) *strings.Replacer {
+	keys := make([]string, 0, len(secrets))
+	for key := range secrets {
+		keys = append(keys, key)
+	}
+	// Replace the longest values first in case one contains another.
+	sort.Slice(keys, func(i, j int) bool {
+		return len(secrets[keys[i]]) > len(secrets[keys[j]])
+	})
+
+	replaceArgs := make([]string, 0, 2*len(keys))
+	for _, key := range keys {
+		if toKeys {
+			replaceArgs = append(replaceArgs, secrets[key], key)
+		} else {
+			replaceArgs = append(replaceArgs, key, secrets[key])
+		}
+	}
+	return strings.NewReplacer(replaceArgs...)
+}
+
+// isFinished is whether the command doesn't need to be run again. A read command that
+// failed is finished too, since that's its result and not an error.
+func isFinished(cmd *command.Model, snapshot command.Snapshot) bool {
+	switch snapshot.State {
+	case command.PassState, command.SkipState:
+		return true
+	case command.FailState:
+		return cmd.IsRead()
+	}
+	return false
+}
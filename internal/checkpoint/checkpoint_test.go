@@ -0,0 +1,189 @@
+package checkpoint
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"flightcrew.io/cli/internal/controller"
+	"flightcrew.io/cli/internal/view/command"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const tokenKey = "${API_TOKEN}"
+
+type fakeRun struct {
+	args     map[string]string
+	files    map[string]string
+	commands []*command.Model
+}
+
+func newFakeRun(args map[string]string, files map[string]string) *fakeRun {
+	return &fakeRun{
+		args:  args,
+		files: files,
+		commands: []*command.Model{
+			command.NewWriteModel(command.Opts{Command: "create role --file=" + args["${ROLE_FILE}"]}),
+			command.NewWriteModel(command.Opts{Command: "create vm"}),
+		},
+	}
+}
+
+func (r fakeRun) Commands() []*command.Model       { return r.commands }
+func (r fakeRun) RecreateCommand() string          { return "install --token=" + r.args[tokenKey] }
+func (r fakeRun) GetEndController() controller.End { return nil }
+func (r fakeRun) Args() map[string]string          { return r.args }
+func (r fakeRun) StateName() string                { return r.args["${PROJECT_ID}"] }
+func (r fakeRun) SecretArgs() map[string]string    { return map[string]string{tokenKey: ""} }
+
+func (r fakeRun) Files() map[string]string {
+	files := make(map[string]string)
+	for fn := range r.files {
+		contents, err := os.ReadFile(fn)
+		if err == nil {
+			files[fn] = string(contents)
+		}
+	}
+	return files
+}
+
+// fakeInputs only resumes runs, with its args (e.g. from flags) under the saved ones.
+type fakeInputs struct {
+	controller.Inputs
+	args map[string]string
+}
+
+func (ctl fakeInputs) ResumeRunController(args map[string]string) controller.Run {
+	merged := make(map[string]string)
+	for k, v := range ctl.args {
+		merged[k] = v
+	}
+	for k, v := range args {
+		merged[k] = v
+	}
+	return newFakeRun(merged, map[string]string{merged["${ROLE_FILE}"]: ""})
+}
+
+func TestCheckpoint(mainT *testing.T) {
+	// saveFailedRun saves a run whose first command passed and second failed, with the
+	// token in a file that it references, and returns the state file.
+	saveFailedRun := func(t *testing.T) (string, string) {
+		roleFile := filepath.Join(t.TempDir(), "role.yaml")
+		require.NoError(t, os.WriteFile(roleFile, []byte("token: secret-token\n"), 0600))
+
+		run := newFakeRun(map[string]string{
+			"${PROJECT_ID}": "my-project",
+			"${ROLE_FILE}":  roleFile,
+			tokenKey:        "secret-token",
+		}, map[string]string{roleFile: ""})
+		run.commands[0].Complete(true)
+		run.commands[1].SetOutputLog("ERROR: secret-token is invalid")
+		run.commands[1].Complete(false)
+
+		fn := filepath.Join(t.TempDir(), "state.json")
+		disable := Enable(fn, "fake install", nil)
+		t.Cleanup(disable)
+		Save(run)
+		return fn, roleFile
+	}
+
+	mainT.Run("secrets should be left out of the state file", func(t *testing.T) {
+		fn, roleFile := saveFailedRun(t)
+		assert.Contains(t, ResumeHint(), "fake install --resume="+fn)
+
+		info, err := os.Stat(fn)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+		entries, err := os.ReadDir(filepath.Dir(fn))
+		require.NoError(t, err)
+		assert.Len(t, entries, 1, "temporary file should be renamed")
+
+		contents, err := os.ReadFile(fn)
+		require.NoError(t, err)
+		assert.NotContains(t, string(contents), "secret-token")
+
+		state, err := Read(fn)
+		require.NoError(t, err)
+		assert.Equal(t, []string{tokenKey}, state.Redacted)
+		assert.NotContains(t, state.Args, tokenKey)
+		assert.Equal(t, "my-project", state.Args["${PROJECT_ID}"])
+		assert.Equal(t, "install --token=<redacted>", state.RecreateCommand)
+		assert.Equal(t, map[string]string{roleFile: "token: ${API_TOKEN}\n"}, state.Files)
+		assert.Equal(t, "ERROR: <redacted> is invalid", state.Commands[1].Log)
+	})
+
+	mainT.Run("resume should need the secrets again", func(t *testing.T) {
+		fn, roleFile := saveFailedRun(t)
+		require.NoError(t, os.Remove(roleFile))
+		state, err := Read(fn)
+		require.NoError(t, err)
+
+		_, _, err = state.Resume(fakeInputs{}, "fake upgrade")
+		require.Error(t, err)
+		_, _, err = state.Resume(fakeInputs{}, "fake install")
+		require.ErrorContains(t, err, tokenKey)
+		assert.NoFileExists(t, roleFile)
+
+		run, cleanup, err := state.Resume(fakeInputs{args: map[string]string{tokenKey: "secret-token"}}, "fake install")
+		require.NoError(t, err)
+
+		contents, err := os.ReadFile(roleFile)
+		require.NoError(t, err)
+		assert.Equal(t, "token: secret-token\n", string(contents))
+
+		commands := run.Commands()
+		assert.Equal(t, command.PassState, commands[0].State())
+		assert.Equal(t, command.NoneState, commands[1].State(), "failed write should run again")
+
+		cleanup()
+		assert.NoFileExists(t, roleFile)
+	})
+
+	mainT.Run("resume should fail if a command has changed", func(t *testing.T) {
+		fn, roleFile := saveFailedRun(t)
+		require.NoError(t, os.Remove(roleFile))
+		state, err := Read(fn)
+		require.NoError(t, err)
+
+		otherRoleFile := filepath.Join(t.TempDir(), "role.yaml")
+		state.Args["${ROLE_FILE}"] = otherRoleFile
+		_, _, err = state.Resume(fakeInputs{args: map[string]string{tokenKey: "secret-token"}}, "fake install")
+		require.ErrorContains(t, err, "command 1 has changed")
+		assert.NoFileExists(t, otherRoleFile)
+	})
+
+	mainT.Run("default state file should be named after what the run changes", func(t *testing.T) {
+		dir := t.TempDir()
+		disable := EnableInDir(dir, "gcp install", nil)
+		t.Cleanup(disable)
+		assert.Empty(t, ResumeHint(), "nothing should be saved before the run starts")
+
+		run := newFakeRun(map[string]string{"${PROJECT_ID}": "my-project", tokenKey: "secret-token"}, nil)
+		run.commands[0].Complete(false)
+		Save(run)
+
+		fn := filepath.Join(dir, "gcp-install-my-project-state.json")
+		assert.FileExists(t, fn)
+		assert.Contains(t, ResumeHint(), "gcp install --resume="+fn)
+	})
+
+	mainT.Run("state file should be removed once the run finishes", func(t *testing.T) {
+		fn, _ := saveFailedRun(t)
+		require.FileExists(t, fn)
+
+		Remove()
+		assert.NoFileExists(t, fn)
+		assert.Empty(t, ResumeHint())
+	})
+
+	mainT.Run("write should replace an existing state file", func(t *testing.T) {
+		fn := filepath.Join(t.TempDir(), "state.json")
+		require.NoError(t, os.WriteFile(fn, []byte("old"), 0600))
+
+		require.NoError(t, Write(fn, State{Flow: "fake install"}))
+		state, err := Read(fn)
+		require.NoError(t, err)
+		assert.Equal(t, "fake install", state.Flow)
+	})
+}
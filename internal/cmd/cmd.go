@@ -5,32 +5,219 @@ import (
 	"fmt"
 	"io"
 	"os"
-	"os/exec"
+	"path/filepath"
+	"strings"
 
+	"flightcrew.io/cli/internal/apitoken"
+	"flightcrew.io/cli/internal/checkpoint"
 	"flightcrew.io/cli/internal/constants"
+	"flightcrew.io/cli/internal/controller"
+	awsinstall "flightcrew.io/cli/internal/controller/aws/install"
+	"flightcrew.io/cli/internal/controller/gcp"
 	gcpinstall "flightcrew.io/cli/internal/controller/gcp/install"
+	gcpstatus "flightcrew.io/cli/internal/controller/gcp/status"
+	gcpuninstall "flightcrew.io/cli/internal/controller/gcp/uninstall"
 	gcpupgrade "flightcrew.io/cli/internal/controller/gcp/upgrade"
+	k8sinstall "flightcrew.io/cli/internal/controller/k8s/install"
 	"flightcrew.io/cli/internal/debug"
+	"flightcrew.io/cli/internal/report"
+	"flightcrew.io/cli/internal/runner"
 	"flightcrew.io/cli/internal/view"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/spf13/cobra"
 )
 
+const (
+	flagNonInteractive = "non-interactive"
+	flagYes            = "yes"
+	flagDryRun         = "dry-run"
+	flagPlanOut        = "plan-out"
+	flagResume         = "resume"
+	flagStateFile      = "state-file"
+	flagReport         = "report"
+
+	flagRecordTranscript = "record-transcript"
+	flagReplayTranscript = "replay-transcript"
+	flagFlightcrewAPI    = "flightcrew-api-endpoint"
+
+	flagUseAPI      = "use-api"
+	flagAPIEndpoint = "api-endpoint"
+)
+
 func init() {
 	gcpinstall.RegisterFlags(gcpInstallCmd)
 	gcpupgrade.RegisterFlags(gcpUpgradeCmd)
+	gcpuninstall.RegisterFlags(gcpUninstallCmd)
+	gcpstatus.RegisterFlags(gcpStatusCmd)
+	awsinstall.RegisterFlags(awsInstallCmd)
+	k8sinstall.RegisterFlags(k8sInstallCmd)
+
+	registerRunFlags(gcpInstallCmd)
+	registerRunFlags(gcpUpgradeCmd)
+	registerRunFlags(gcpUninstallCmd)
+	registerRunFlags(awsInstallCmd)
+	registerRunFlags(k8sInstallCmd)
+
+	gcpCmd.PersistentFlags().Bool(flagUseAPI, false, "Use the Google Cloud APIs with Application Default Credentials for the read-only checks instead of the gcloud CLI.")
+	gcpCmd.PersistentFlags().String(flagAPIEndpoint, "", "Send every --"+flagUseAPI+" request to this endpoint without credentials instead.")
+	_ = gcpCmd.PersistentFlags().MarkHidden(flagAPIEndpoint)
+}
+
+// registerRunFlags adds the flags that are shared by every flow that goes through
+// the Inputs -> Run -> End controllers.
+func registerRunFlags(cmd *cobra.Command) {
+	cmd.Flags().Bool(flagNonInteractive, false, "Run without the interactive terminal. Inputs come from flags, and every command that needs to be run will be run without prompting.")
+	cmd.Flags().BoolP(flagYes, "y", false, "Alias for --"+flagNonInteractive+".")
+	cmd.Flags().Bool(flagDryRun, false, "Print every command that would be run as a bash script instead of running them. Inputs come from flags.")
+	cmd.Flags().String(flagPlanOut, "", "Write the --"+flagDryRun+" script to this file instead of printing it.")
+	cmd.Flags().String(flagStateFile, "", "Save the progress of the run to this file after every command. It has your inputs, except for secrets like the API token, and is removed once the run finishes. (default: a file in your cache directory, named after the flow and what it changes, e.g. the project and the VM)")
+	cmd.Flags().String(flagResume, "", "Continue a run from the state file that it saved, starting from the first command that didn't finish.")
+	cmd.Flags().String(flagReport, "", "Write a JSON report of the run to this file, with the inputs and every command's state, output, timestamps and exit code. Secrets are redacted.")
+}
+
+// defaultStateDir is where the progress of the flow is saved if --state-file isn't set. Only
+// the user can access it, so that nobody else can read or replace the state files.
+func defaultStateDir() (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+
+	dir := filepath.Join(cacheDir, constants.CLIName)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	if err := os.Chmod(dir, 0700); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// runFlow starts the flow for the given inputs controller, either through the interactive
+// terminal or headlessly.
+func runFlow(cmd *cobra.Command, ctl controller.Inputs) error {
+	dryRun, _ := cmd.Flags().GetBool(flagDryRun)
+	planOut, _ := cmd.Flags().GetString(flagPlanOut)
+	resume, _ := cmd.Flags().GetString(flagResume)
+	if len(resume) > 0 && (dryRun || len(planOut) > 0) {
+		return fmt.Errorf("--%s can't be used with --%s or --%s", flagResume, flagDryRun, flagPlanOut)
+	}
+
+	if len(planOut) > 0 {
+		// Only the user can read the plan, since the commands may have the API token. The
+		// mode is set again in case the file already existed.
+		f, err := os.OpenFile(planOut, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0700)
+		if err != nil {
+			return fmt.Errorf("create plan file: %w", err)
+		}
+		defer f.Close()
+		if err := f.Chmod(0700); err != nil {
+			return fmt.Errorf("create plan file: %w", err)
+		}
+
+		if err := view.WritePlan(ctl, f); err != nil {
+			return err
+		}
+
+		_, _ = fmt.Fprintf(cmd.OutOrStdout(), "Plan is located at %s\n", planOut)
+		return nil
+	} else if dryRun {
+		return view.WritePlan(ctl, cmd.OutOrStdout())
+	}
+
+	// The flow is the command without the root, e.g. "gcp install".
+	flow := strings.TrimPrefix(cmd.CommandPath(), cmd.Root().Name()+" ")
+	stateFile, _ := cmd.Flags().GetString(flagStateFile)
+	if len(stateFile) == 0 {
+		stateFile = resume
+	}
+	if len(stateFile) > 0 {
+		defer checkpoint.Enable(stateFile, flow, ctl)()
+	} else if dir, err := defaultStateDir(); err != nil {
+		debug.Output("no state file for %s: %v", flow, err)
+	} else {
+		// The default state file is named after what the run changes (e.g. the project and
+		// the VM), so that runs for different ones don't overwrite each other's.
+		defer checkpoint.EnableInDir(dir, flow, ctl)()
+	}
+	if reportFile, _ := cmd.Flags().GetString(flagReport); len(reportFile) > 0 {
+		defer report.Enable(reportFile)()
+	}
+
+	nonInteractive, _ := cmd.Flags().GetBool(flagNonInteractive)
+	yes, _ := cmd.Flags().GetBool(flagYes)
+	if len(resume) > 0 {
+		state, err := checkpoint.Read(resume)
+		if err != nil {
+			return err
+		}
+
+		runCtl, cleanup, err := state.Resume(ctl, flow)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+
+		if nonInteractive || yes {
+			return view.ResumeHeadless(runCtl, cmd.OutOrStdout())
+		}
+		return runProgram(view.NewRunModel(runCtl))
+	}
+
+	if nonInteractive || yes {
+		return view.RunHeadless(ctl, cmd.OutOrStdout())
+	}
+
+	return runProgram(view.NewInputsModel(ctl))
+}
+
+func runProgram(model tea.Model) error {
+	p := tea.NewProgram(model)
+	_, err := p.Run()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	return err
 }
 
 // Do runs the command logic.
 func Do(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
 	var debugCleanup func()
+	var recorder *runner.Recorder
 	rootCmd := &cobra.Command{
 		Use:          constants.CLIName,
 		SilenceUsage: true,
-		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
 			if debugFile := cmd.Flag("debug").Value.String(); len(debugFile) > 0 {
 				debugCleanup, _ = debug.Enable(debugFile)
 			}
+
+			if replayFile := cmd.Flag(flagReplayTranscript).Value.String(); len(replayFile) > 0 {
+				scripted, err := runner.Replay(replayFile)
+				if err != nil {
+					return err
+				}
+				runner.Default = scripted
+			}
+
+			if recordFile := cmd.Flag(flagRecordTranscript).Value.String(); len(recordFile) > 0 {
+				recorder = runner.NewRecorder(runner.Default)
+				runner.Default = recorder
+			}
+
+			if endpoint := cmd.Flag(flagFlightcrewAPI).Value.String(); len(endpoint) > 0 {
+				apitoken.Endpoint = endpoint
+			}
+
+			if useAPI := cmd.Flag(flagUseAPI); useAPI != nil && useAPI.Value.String() == "true" {
+				api, err := gcp.NewAPIClient(cmd.Context(), cmd.Flag(flagAPIEndpoint).Value.String())
+				if err != nil {
+					return err
+				}
+				gcp.API = api
+			}
+			return nil
 		},
 	}
 	defer func() {
@@ -38,14 +225,36 @@ func Do(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int
 			debugCleanup()
 		}
 	}()
+	defer func() {
+		if recorder != nil {
+			recordFile := rootCmd.Flag(flagRecordTranscript).Value.String()
+			if err := recorder.WriteTranscript(recordFile); err != nil {
+				fmt.Fprintf(stderr, "write transcript `%s`: %v\n", recordFile, err)
+			}
+		}
+	}()
 
 	rootCmd.PersistentFlags().String("debug", "", "enable debug output to a temporary file")
+	rootCmd.PersistentFlags().String(flagRecordTranscript, "", "record every command that is run and its output to a file, which may contain secrets")
+	rootCmd.PersistentFlags().String(flagReplayTranscript, "", "answer every command from a file written by --"+flagRecordTranscript+" instead of running it")
+	rootCmd.PersistentFlags().String(flagFlightcrewAPI, "", "verify the API token against this endpoint (e.g. a local stub server) instead of the Flightcrew API")
+	_ = rootCmd.PersistentFlags().MarkHidden(flagRecordTranscript)
+	_ = rootCmd.PersistentFlags().MarkHidden(flagReplayTranscript)
+	_ = rootCmd.PersistentFlags().MarkHidden(flagFlightcrewAPI)
 
 	rootCmd.AddCommand(versionCmd)
 	rootCmd.AddCommand(gcpCmd)
 
 	gcpCmd.AddCommand(gcpInstallCmd)
 	gcpCmd.AddCommand(gcpUpgradeCmd)
+	gcpCmd.AddCommand(gcpUninstallCmd)
+	gcpCmd.AddCommand(gcpStatusCmd)
+
+	rootCmd.AddCommand(awsCmd)
+	awsCmd.AddCommand(awsInstallCmd)
+
+	rootCmd.AddCommand(k8sCmd)
+	k8sCmd.AddCommand(k8sInstallCmd)
 
 	rootCmd.SetArgs(args)
 	rootCmd.SetIn(stdin)
@@ -55,12 +264,12 @@ func Do(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	var err error
-	if err := rootCmd.ExecuteContext(ctx); err == nil {
+	err := rootCmd.ExecuteContext(ctx)
+	if err == nil {
 		return 0
 	}
-	if exitError, ok := err.(*exec.ExitError); ok {
-		return exitError.ExitCode()
+	if code := runner.ExitCode(err); code > 0 {
+		return code
 	}
 	return 1
 }
@@ -80,13 +289,7 @@ var gcpInstallCmd = &cobra.Command{
 		}
 		defer cleanup()
 
-		p := tea.NewProgram(view.NewInputsModel(gcpinstall.NewInputsController(env)))
-		_, err = p.Run()
-		if err != nil {
-			fmt.Println(err)
-			os.Exit(1)
-		}
-		return err
+		return runFlow(cmd, gcpinstall.NewInputsController(env))
 	},
 }
 
@@ -100,12 +303,87 @@ var gcpUpgradeCmd = &cobra.Command{
 		}
 		defer cleanup()
 
-		p := tea.NewProgram(view.NewInputsModel(gcpupgrade.NewInputsController(env)))
-		_, err = p.Run()
+		return runFlow(cmd, gcpupgrade.NewInputsController(env))
+	},
+}
+
+var gcpUninstallCmd = &cobra.Command{
+	Use:   "uninstall",
+	Short: "Remove a Flightcrew tower and the resources created for it from Google Cloud Platform (GCP).",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		env, cleanup, err := gcpuninstall.ParseFlags(cmd)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+
+		return runFlow(cmd, gcpuninstall.NewInputsController(env))
+	},
+}
+
+var gcpStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the state, version and permissions of an installed Flightcrew tower in Google Cloud Platform (GCP).",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		params, err := gcpstatus.ParseFlags(cmd)
+		if err != nil {
+			return err
+		}
+
+		status, err := gcpstatus.GetStatus(params)
 		if err != nil {
-			fmt.Println(err)
-			os.Exit(1)
+			return err
 		}
-		return err
+
+		return status.Write(cmd.OutOrStdout(), params.Output)
+	},
+}
+
+var awsCmd = &cobra.Command{
+	Use:   "aws",
+	Short: "Manage Flightcrew for Amazon Web Services (AWS).",
+}
+
+var awsInstallCmd = &cobra.Command{
+	Use:   "install",
+	Short: "Install a Flightcrew tower into Amazon Web Services (AWS) on an EC2 instance.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		env, cleanup, err := awsinstall.ParseFlags(cmd)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+
+		return runFlow(cmd, awsinstall.NewInputsController(env))
+	},
+}
+
+var k8sCmd = &cobra.Command{
+	Use:   "k8s",
+	Short: "Manage Flightcrew in a Kubernetes cluster.",
+}
+
+var k8sInstallCmd = &cobra.Command{
+	Use:   "install",
+	Short: "Install a Flightcrew tower into a Kubernetes cluster as a Deployment.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		env, cleanup, err := k8sinstall.ParseFlags(cmd)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+
+		ctl := k8sinstall.NewInputsController(env)
+		if dir := env.ManifestsDir(); len(dir) > 0 {
+			// The manifests are written once the inputs are valid, so there's nothing to run.
+			if err := view.ValidateInputs(ctl); err != nil {
+				return err
+			}
+
+			_, _ = fmt.Fprintf(cmd.OutOrStdout(), "Manifests are located in %s\nTo apply them: kubectl apply --filename=%s\n", dir, dir)
+			return nil
+		}
+
+		return runFlow(cmd, ctl)
 	},
 }
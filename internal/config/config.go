@@ -0,0 +1,96 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// File is a YAML file of flag names to values, so that the parameters of a flow can be
+// checked in instead of passed in as a long list of flags.
+//
+//	project: project-id-1234
+//	zone: us-central1-c
+//	write: true
+type File struct {
+	Path   string
+	Values map[string]string
+}
+
+// Read parses the config file, and rejects any keys that aren't in allowed.
+func Read(fn string, allowed []string) (*File, error) {
+	data, err := os.ReadFile(fn)
+	if err != nil {
+		return nil, fmt.Errorf("read config: %w", err)
+	}
+
+	raw := make(map[string]interface{})
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	if err := dec.Decode(&raw); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("parse config %s: %w", fn, err)
+	}
+
+	allowedSet := make(map[string]struct{}, len(allowed))
+	for _, key := range allowed {
+		allowedSet[key] = struct{}{}
+	}
+
+	f := &File{
+		Path:   fn,
+		Values: make(map[string]string, len(raw)),
+	}
+	unknown := make([]string, 0)
+	for key, value := range raw {
+		if _, ok := allowedSet[key]; !ok {
+			unknown = append(unknown, key)
+			continue
+		}
+
+		switch value.(type) {
+		case map[string]interface{}, []interface{}:
+			return nil, fmt.Errorf("config %s: %s should be a single value", fn, key)
+		case nil:
+			f.Values[key] = ""
+		default:
+			f.Values[key] = fmt.Sprint(value)
+		}
+	}
+
+	if len(unknown) > 0 {
+		want := append([]string{}, allowed...)
+		sort.Strings(unknown)
+		sort.Strings(want)
+		return nil, fmt.Errorf("config %s: unknown keys: %s (want one of: %s)", fn, strings.Join(unknown, ", "), strings.Join(want, ", "))
+	}
+
+	return f, nil
+}
+
+// Load reads the config file and sets the command's flags from it. Flags that were passed in
+// on the command line take precedence over the values in the file. Keys in known that aren't
+// flags of this command are ignored, so that the same file can be shared between commands.
+func Load(cmd *cobra.Command, fn string, known []string) (*File, error) {
+	f, err := Read(fn, known)
+	if err != nil {
+		return nil, err
+	}
+
+	for name, value := range f.Values {
+		if cmd.Flags().Lookup(name) == nil || cmd.Flags().Changed(name) {
+			continue
+		}
+
+		if err := cmd.Flags().Set(name, value); err != nil {
+			return nil, fmt.Errorf("config %s: invalid value for %s: %w", fn, name, err)
+		}
+	}
+
+	return f, nil
+}
@@ -0,0 +1,81 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"flightcrew.io/cli/internal/config"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeConfig(t *testing.T, contents string) string {
+	fn := filepath.Join(t.TempDir(), "tower.yaml")
+	require.NoError(t, os.WriteFile(fn, []byte(contents), 0600))
+	return fn
+}
+
+func TestRead(mainT *testing.T) {
+	known := []string{"project", "zone", "write", "gae-max-version-count"}
+
+	mainT.Run("read valid config should succeed", func(t *testing.T) {
+		f, err := config.Read(writeConfig(t, `project: project-id-1234
+zone: us-west2-a
+write: true
+gae-max-version-count: 30
+`), known)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{
+			"project":               "project-id-1234",
+			"zone":                  "us-west2-a",
+			"write":                 "true",
+			"gae-max-version-count": "30",
+		}, f.Values)
+	})
+
+	mainT.Run("read empty config should succeed", func(t *testing.T) {
+		f, err := config.Read(writeConfig(t, ``), known)
+		require.NoError(t, err)
+		assert.Empty(t, f.Values)
+	})
+
+	mainT.Run("read unknown keys should error", func(t *testing.T) {
+		_, err := config.Read(writeConfig(t, `project: project-id-1234
+projcet: typo
+`), known)
+		assert.ErrorContains(t, err, "unknown keys: projcet")
+	})
+
+	mainT.Run("read nested values should error", func(t *testing.T) {
+		_, err := config.Read(writeConfig(t, `project:
+  id: project-id-1234
+`), known)
+		assert.Error(t, err)
+	})
+
+	mainT.Run("read missing file should error", func(t *testing.T) {
+		_, err := config.Read(filepath.Join(t.TempDir(), "missing.yaml"), known)
+		assert.Error(t, err)
+	})
+}
+
+func TestLoadFlagsTakePrecedence(t *testing.T) {
+	cmd := &cobra.Command{}
+	project := cmd.Flags().String("project", "", "")
+	zone := cmd.Flags().String("zone", "us-central1-c", "")
+	write := cmd.Flags().Bool("write", false, "")
+	require.NoError(t, cmd.Flags().Parse([]string{"--project=from-flag"}))
+
+	_, err := config.Load(cmd, writeConfig(t, `project: from-file
+zone: us-west2-a
+write: true
+gae-max-version-count: 30
+`), []string{"project", "zone", "write", "gae-max-version-count"})
+	require.NoError(t, err)
+
+	assert.Equal(t, "from-flag", *project)
+	assert.Equal(t, "us-west2-a", *zone)
+	assert.True(t, *write)
+}
@@ -0,0 +1,68 @@
+package constants
+
+var (
+	// The AWS platforms are kept apart from the GCP ones since each cloud provider's
+	// flows can only manage their own platforms.
+	AWSKeyToDisplay = map[string]string{
+		AmazonEC2Key: AmazonEC2Display,
+	}
+	AWSDisplayToPlatform = map[string]string{
+		AmazonEC2Display: AmazonEC2Platform,
+	}
+	// AWSPlatformPermissions are IAM policy documents, and the Role is the policy name.
+	AWSPlatformPermissions = map[string]map[string]*Permissions{
+		AmazonEC2Platform: {
+			Read: &Permissions{
+				Role: "flightcrew-ec2-read-only",
+				Content: `{
+  "Version": "2012-10-17",
+  "Statement": [
+    {
+      "Sid": "ReadInstances",
+      "Effect": "Allow",
+      "Action": [
+        "ec2:DescribeAvailabilityZones",
+        "ec2:DescribeInstances",
+        "ec2:DescribeInstanceTypes",
+        "ec2:DescribeRegions",
+        "ec2:DescribeTags"
+      ],
+      "Resource": "*"
+    },
+    {
+      "Sid": "ReadMonitoring",
+      "Effect": "Allow",
+      "Action": [
+        "cloudwatch:GetMetricData",
+        "cloudwatch:GetMetricStatistics",
+        "cloudwatch:ListMetrics"
+      ],
+      "Resource": "*"
+    }
+  ]
+}
+`,
+			},
+
+			Write: &Permissions{
+				Role: "flightcrew-ec2-read-write",
+				Content: `{
+  "Version": "2012-10-17",
+  "Statement": [
+    {
+      "Sid": "ResizeInstances",
+      "Effect": "Allow",
+      "Action": [
+        "ec2:ModifyInstanceAttribute",
+        "ec2:StartInstances",
+        "ec2:StopInstances"
+      ],
+      "Resource": "*"
+    }
+  ]
+}
+`,
+			},
+		},
+	}
+)
@@ -8,6 +8,10 @@ const (
 
 	CLIName = "crewcli"
 
+	// APITokenEnv is the env var that exported plans read the API token from, so that it
+	// isn't written into the plan.
+	APITokenEnv = "FLIGHTCREW_API_TOKEN"
+
 	appPrefix = "https://app"
 	apiPrefix = "api"
 )
@@ -9,6 +9,10 @@ const (
 	GoogleComputeEnginePlatform = "provider:gcp/platform:compute/type:instances"
 	GoogleComputeEngineDisplay  = "Compute Engine"
 
+	AmazonEC2Key      = "ec2"
+	AmazonEC2Platform = "provider:aws/platform:ec2/type:instances"
+	AmazonEC2Display  = "EC2"
+
 	Read  = "Read"
 	Write = "Write"
 )
@@ -27,7 +31,8 @@ var (
 		GoogleAppEngineStdDisplay:  GoogleAppEngineStdPlatform,
 		GoogleComputeEngineDisplay: GoogleComputeEnginePlatform,
 	}
-	// TODO: Use a local file or get from a URL that the user provides.
+	// PlatformPermissions are the built-in IAM role definitions. `gcp install` can use a
+	// reviewed file or URL instead (e.g. --iam-role-file-read).
 	PlatformPermissions = map[string]map[string]*Permissions{
 		GoogleAppEngineStdPlatform: {
 			Read: &Permissions{
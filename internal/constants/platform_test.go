@@ -1,6 +1,7 @@
 package constants_test
 
 import (
+	"encoding/json"
 	"testing"
 
 	"flightcrew.io/cli/internal/constants"
@@ -16,3 +17,11 @@ func TestGetPlatformKey(t *testing.T) {
 	assert.Equal(t, constants.GoogleComputeEngineKey, constants.GetPlatformKey(constants.GoogleComputeEngineDisplay))
 	assert.Equal(t, constants.GoogleComputeEngineKey, constants.GetPlatformKey(constants.GoogleComputeEnginePlatform))
 }
+
+func TestAWSPlatformPermissions(t *testing.T) {
+	for platform, perms := range constants.AWSPlatformPermissions {
+		for permissions, settings := range perms {
+			assert.True(t, json.Valid([]byte(settings.Content)), "%s %s policy should be valid JSON", platform, permissions)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package aws
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+
+	"flightcrew.io/cli/internal/runner"
+)
+
+func HasAWSInPath() bool {
+	var b bytes.Buffer
+	hasAWSInPath := runner.Run(`which aws`, &b, &b) == nil
+
+	if !hasAWSInPath {
+		fmt.Printf(`The "aws" CLI tool is a pre-requisite to run this script.
+
+		If you haven't yet, please install the tool: https://docs.aws.amazon.com/cli/latest/userguide/getting-started-install.html
+
+		If you already have, please add it to your path:
+		  export PATH=<where it is>:$PATH
+
+		`)
+	}
+	return hasAWSInPath
+}
+
+// GetAccountID returns the ID of the AWS account that the CLI's credentials belong to.
+func GetAccountID() (string, error) {
+	stdout, stderr, err := runner.Output(`aws sts get-caller-identity --query Account --output text`)
+	if err != nil {
+		return "", fmt.Errorf("aws sts get-caller-identity: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	accountID := strings.TrimSpace(stdout.String())
+	if len(accountID) == 0 {
+		return "", errors.New("not found: AWS account ID")
+	}
+
+	return accountID, nil
+}
+
+// GetRegionFromEnvironment returns the default region that the CLI is configured with.
+func GetRegionFromEnvironment() (string, error) {
+	stdout, _, err := runner.Output(`aws configure get region`)
+	if err != nil {
+		return "", fmt.Errorf("aws configure get region: %w", err)
+	}
+
+	region := strings.TrimSpace(stdout.String())
+	if len(region) == 0 {
+		return "", errors.New("not found")
+	}
+
+	return region, nil
+}
@@ -0,0 +1,13 @@
+package constants
+
+var (
+	FlagToKey = map[string]string{
+		FlagRegion:         KeyRegion,
+		FlagVirtualMachine: KeyVirtualMachine,
+		FlagPlatform:       KeyPlatform,
+		FlagToken:          KeyAPIToken,
+		FlagTowerVersion:   KeyTowerVersion,
+		FlagWrite:          KeyPermissions,
+		FlagIAMRole:        KeyIAMRole,
+	}
+)
@@ -0,0 +1,12 @@
+package constants
+
+const (
+	FlagRegion         = "region"
+	FlagTowerVersion   = "version"
+	FlagToken          = "token"
+	FlagVirtualMachine = "vm"
+	FlagPlatform       = "platform"
+	FlagWrite          = "write"
+	FlagIAMRole        = "iam-role"
+	FlagConfig         = "config"
+)
@@ -0,0 +1,26 @@
+package constants
+
+const (
+	KeyAccountID       = "${AWS_ACCOUNT_ID}"
+	KeyRegion          = "${REGION}"
+	KeyTowerVersion    = "${TOWER_VERSION}"
+	KeyVirtualMachine  = "${VIRTUAL_MACHINE}"
+	KeyAPIToken        = "${API_TOKEN}"
+	KeyAPITokenFile    = "${API_TOKEN_FILE}"
+	KeyTokenParameter  = "${TOKEN_PARAMETER}"
+	KeyTokenPolicyFile = "${TOKEN_POLICY_FILE}"
+	KeyIAMRole         = "${IAM_ROLE}"
+	KeyIAMPolicyRead   = "${READ_IAM_POLICY}"
+	KeyIAMFileRead     = "${READ_IAM_FILE}"
+	KeyIAMPolicyWrite  = "${WRITE_IAM_POLICY}"
+	KeyIAMFileWrite    = "${WRITE_IAM_FILE}"
+	KeyTrustPolicyFile = "${TRUST_POLICY_FILE}"
+	KeyUserDataFile    = "${USER_DATA_FILE}"
+	KeyPermissions     = "${PERMISSIONS}"
+	KeyRPCHost         = "${RPC_HOST}"
+	KeyAppURL          = "${APP_URL}"
+	KeyPlatform        = "${PLATFORM}"
+	KeyTrafficRouter   = "${TRAFFIC_ROUTER}"
+	KeyImagePath       = "${IMAGE_PATH}"
+	KeyConfigFile      = "${CONFIG_FILE}"
+)
@@ -0,0 +1,86 @@
+package awsinstall
+
+import (
+	"bytes"
+	"strings"
+
+	"flightcrew.io/cli/internal/runner"
+	"flightcrew.io/cli/internal/style"
+	"flightcrew.io/cli/internal/view/command"
+)
+
+type EndController struct {
+	replacer       *strings.Replacer
+	endDescription string
+	commands       []*command.Model
+	vmIsUp         bool
+}
+
+func NewEndController(commands []*command.Model, replacer *strings.Replacer) *EndController {
+	return &EndController{
+		commands: commands,
+		replacer: replacer,
+	}
+}
+
+func (ctl EndController) Commands() []*command.Model {
+	return ctl.commands
+}
+
+func (ctl EndController) Name() string {
+	return "Amazon Web Services Installation"
+}
+
+func (ctl *EndController) EndDescription() string {
+	rerender := false
+	if !ctl.vmIsUp {
+		// Checks to see if the instance is running. The tower starts once the user data
+		// has installed Docker, which takes another minute or two.
+		cmd := ctl.replacer.Replace(`aws ec2 describe-instances --region="${REGION}" --filters "Name=tag:Name,Values=${VIRTUAL_MACHINE}" "Name=instance-state-name,Values=running" --query="Reservations[].Instances[].InstanceId" --output=text | grep --quiet .`)
+		var b bytes.Buffer
+		err := runner.Run(cmd, &b, &b)
+		if err == nil {
+			ctl.vmIsUp = true
+			rerender = true
+		}
+	}
+
+	if !rerender && len(ctl.endDescription) > 0 {
+		return ctl.endDescription
+	}
+
+	var link = "https://${REGION}.console.aws.amazon.com/ec2/home?region=${REGION}#Instances:tag:Name=${VIRTUAL_MACHINE}"
+	var description = `## Welcome to Flightcrew! 🕊
+
+${MESSAGE}
+
+See your instance in the console:
+http://replace.me
+
+Alternatively, check on your new instance:
+${CODE_START}
+# Look up the created instance.
+aws ec2 describe-instances --region ${REGION} --filters "Name=tag:Name,Values=${VIRTUAL_MACHINE}"
+# See the user data script's output, which installs Docker and starts the tower.
+aws ec2 get-console-output --region ${REGION} --latest --output text --instance-id <instance-id>
+${CODE_END}
+
+Once your Tower is up, head on over to ${APP_URL} to see the info your Tower collected.
+`
+
+	link = ctl.replacer.Replace(link)
+	description = ctl.replacer.Replace(description)
+	description = strings.Replace(description, "${CODE_START}", "```sh", 1)
+	description = strings.Replace(description, "${CODE_END}", "```", 1)
+	if ctl.vmIsUp {
+		description = strings.Replace(description, "${MESSAGE}", "✅ Your instance is running!", 1)
+	} else {
+		description = strings.Replace(description, "${MESSAGE}", "⏱ Your instance is still starting up.", 1)
+	}
+
+	out, _ := style.Glamour.Render(description)
+	out = strings.Replace(out, "http://replace.me", link, 1)
+	ctl.endDescription = out
+
+	return out
+}
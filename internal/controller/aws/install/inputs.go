@@ -0,0 +1,390 @@
+package awsinstall
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"flightcrew.io/cli/internal/apitoken"
+	"flightcrew.io/cli/internal/constants"
+	"flightcrew.io/cli/internal/controller"
+	"flightcrew.io/cli/internal/controller/aws"
+	aconst "flightcrew.io/cli/internal/controller/aws/constants"
+	"flightcrew.io/cli/internal/controller/gcp"
+	"flightcrew.io/cli/internal/debug"
+	"flightcrew.io/cli/internal/view/wrapinput"
+)
+
+var (
+	filenameReplacer = strings.NewReplacer(
+		".", "_",
+		"/", "_",
+		":", "_",
+		" ", "_",
+	)
+
+	regionRE = regexp.MustCompile(`^[a-z]{2}(-[a-z]+)+-[0-9]+$`)
+
+	// vmNameRE is what's allowed in an SSM parameter name, since the API token's parameter
+	// is named after the instance.
+	vmNameRE = regexp.MustCompile(`^[a-zA-Z0-9_.-]+$`)
+
+	initialInputKeys = []string{
+		aconst.KeyRegion,
+		aconst.KeyVirtualMachine,
+		aconst.KeyAPIToken,
+		aconst.KeyPlatform,
+		aconst.KeyPermissions,
+		aconst.KeyTowerVersion,
+		aconst.KeyIAMRole,
+	}
+)
+
+type InputsController struct {
+	tempDir   string
+	inputs    map[string]*wrapinput.Model
+	args      map[string]string
+	inputKeys []string
+}
+
+func NewInputsController(params Params) *InputsController {
+	ctl := &InputsController{
+		inputKeys: initialInputKeys,
+		inputs:    make(map[string]*wrapinput.Model),
+		args:      params.args,
+		tempDir:   params.tempDir,
+	}
+
+	if !contains(ctl.args, aconst.KeyVirtualMachine) {
+		ctl.args[aconst.KeyVirtualMachine] = "flightcrew-control-tower"
+	}
+	if !contains(ctl.args, aconst.KeyRegion) {
+		ctl.args[aconst.KeyRegion] = "us-east-1"
+		if region, err := aws.GetRegionFromEnvironment(); err == nil {
+			ctl.args[aconst.KeyRegion] = region
+		}
+	}
+	if !contains(ctl.args, aconst.KeyTowerVersion) {
+		ctl.args[aconst.KeyTowerVersion] = "stable"
+	}
+
+	baseURL := gcp.GetHostBaseURL("", "")
+	ctl.args[aconst.KeyAppURL] = constants.GetAppHostName(baseURL)
+	ctl.args[aconst.KeyRPCHost] = constants.GetAPIHostName(baseURL)
+	ctl.args[aconst.KeyTrafficRouter] = ""
+	ctl.args[aconst.KeyImagePath] = gcp.ImagePath
+
+	for _, key := range allKeys {
+		var input wrapinput.Model
+		maybeSetValue := func(key string) {
+			if val, ok := ctl.args[key]; ok {
+				input.SetValue(val)
+			}
+		}
+
+		switch key {
+		case aconst.KeyRegion:
+			input = wrapinput.NewFreeForm()
+			input.Freeform.Placeholder = "us-east-1"
+			input.Freeform.CharLimit = 32
+			input.Title = "Region"
+			input.Default = "us-east-1"
+			input.Required = true
+			input.HelpText = "Region is the AWS region where the (to be created) Flightcrew EC2 instance will be located."
+			maybeSetValue(aconst.KeyRegion)
+
+		case aconst.KeyVirtualMachine:
+			input = wrapinput.NewFreeForm()
+			input.Freeform.Placeholder = "flightcrew-control-tower"
+			input.Freeform.CharLimit = 64
+			input.Title = "Instance Name"
+			input.Default = "flightcrew-control-tower"
+			input.Required = true
+			input.HelpText = "Instance Name is the Name tag of the (to be created) Flightcrew EC2 instance."
+			maybeSetValue(aconst.KeyVirtualMachine)
+
+		case aconst.KeyTowerVersion:
+			input, _ = gcp.NewTowerVersionInput()
+			maybeSetValue(aconst.KeyTowerVersion)
+
+		case aconst.KeyAPIToken:
+			input = wrapinput.NewFreeForm()
+			input.Freeform.Placeholder = "api-token"
+			input.Freeform.CharLimit = 0
+			input.Title = "API Token"
+			input.Required = true
+			input.HelpText = "API token is the value provided by Flightcrew to identify your organization."
+			maybeSetValue(aconst.KeyAPIToken)
+
+		case aconst.KeyIAMRole:
+			input = wrapinput.NewFreeForm()
+			input.Title = "IAM Role"
+			input.Freeform.CharLimit = 64
+			input.Default = "flightcrew-runner"
+			input.SetValue("flightcrew-runner")
+			input.HelpText = "IAM Role is the name of the (to be created) IAM role and instance profile to run the Flightcrew Tower."
+			maybeSetValue(aconst.KeyIAMRole)
+
+		case aconst.KeyPlatform:
+			input = wrapinput.NewRadio([]string{
+				constants.AmazonEC2Display})
+			input.Title = "Platform"
+			input.HelpText = "Platform is which Amazon Web Services resources Flightcrew will read in."
+			maybeSetValue(aconst.KeyPlatform)
+
+		case aconst.KeyPermissions:
+			input = wrapinput.NewRadio([]string{
+				constants.Read,
+				constants.Write})
+			input.Title = "Permissions"
+			input.HelpText = "Permissions is whether Flightcrew will only read in your resources, or if Flightcrew can modify (if you ask us to) your resources."
+			maybeSetValue(aconst.KeyPermissions)
+
+		}
+
+		input.Blur()
+		ctl.inputs[key] = &input
+	}
+	return ctl
+}
+
+func (ctl InputsController) GetAllInputs() []*wrapinput.Model {
+	res := make([]*wrapinput.Model, 0, len(ctl.inputs))
+	for _, v := range ctl.inputs {
+		res = append(res, v)
+	}
+	return res
+}
+
+func (ctl *InputsController) Reset(inputs []*wrapinput.Model) {
+	for k := range ctl.inputs {
+		ctl.inputs[k].ResetValidation()
+	}
+}
+
+func (ctl *InputsController) Validate(inputs []*wrapinput.Model) bool {
+	hasErrors := false
+	for k, input := range ctl.inputs {
+		setError := func(err error) bool {
+			if err != nil {
+				input.SetError(err)
+				debug.Output(err.Error())
+				hasErrors = true
+				return true
+			}
+			return false
+		}
+
+		if input.Required && len(input.Value()) == 0 {
+			setError(errors.New("required"))
+			continue
+		}
+
+		switch k {
+		case aconst.KeyRegion:
+			if !regionRE.MatchString(input.Value()) {
+				setError(errors.New("must be a region (e.g. us-east-1)"))
+			}
+
+		case aconst.KeyAPIToken:
+			info, err := apitoken.Check(ctl.args[aconst.KeyRPCHost], input.Value())
+			if setError(err) {
+				break
+			}
+			input.SetInfo(info)
+
+		case aconst.KeyVirtualMachine:
+			if !vmNameRE.MatchString(input.Value()) {
+				setError(errors.New("can only have letters, numbers, `.`, `-` and `_`"))
+				break
+			}
+			ctl.args[aconst.KeyTokenParameter] = tokenParameter(input.Value())
+
+		case aconst.KeyTowerVersion:
+			version, err := gcp.GetTowerImageVersion(input.Value())
+			if setError(err) {
+				debug.Output("convert tower version got error: %v", err)
+				break
+			}
+
+			input.SetConverted(version)
+			debug.Output("convert tower version is %s", version)
+
+		case aconst.KeyPlatform:
+			displayName := input.Value()
+			platform, ok := constants.AWSDisplayToPlatform[displayName]
+			if !ok {
+				setError(errors.New("invalid platform"))
+				break
+			}
+
+			input.SetConverted(platform)
+
+		case aconst.KeyPermissions:
+			platformInput := ctl.inputs[aconst.KeyPlatform]
+			platform, ok := constants.AWSDisplayToPlatform[platformInput.Value()]
+			if !ok {
+				if _, ok := constants.AWSPlatformPermissions[platformInput.Value()]; !ok {
+					setError(errors.New("need to set platform first"))
+					break
+				} else {
+					platform = platformInput.Value()
+				}
+			}
+
+			perms, ok := constants.AWSPlatformPermissions[platform]
+			if !ok {
+				setError(errors.New("platform has no permissions"))
+				break
+			}
+
+			permission := input.Value()
+			if _, ok := perms[permission]; !ok {
+				setError(fmt.Errorf("%s permissions are not supported for platform '%s'", permission, platformInput.Value()))
+				break
+			}
+
+			var err error
+			readSettings := perms[constants.Read]
+			ctl.args[aconst.KeyIAMPolicyRead] = readSettings.Role
+			ctl.args[aconst.KeyIAMFileRead], err = ctl.writeFile(fmt.Sprintf("%s_%s", constants.Read, platform), "json", readSettings.Content)
+			if setError(err) {
+				break
+			}
+
+			if permission == constants.Write {
+				writeSettings := perms[constants.Write]
+				ctl.args[aconst.KeyTrafficRouter] = fmtEnvForReplace("TRAFFIC_ROUTER", platform)
+				ctl.args[aconst.KeyIAMPolicyWrite] = writeSettings.Role
+				ctl.args[aconst.KeyIAMFileWrite], err = ctl.writeFile(fmt.Sprintf("%s_%s", constants.Write, platform), "json", writeSettings.Content)
+				if setError(err) {
+					break
+				}
+			} else {
+				ctl.args[aconst.KeyTrafficRouter] = ""
+				ctl.args[aconst.KeyIAMPolicyWrite] = ""
+				ctl.args[aconst.KeyIAMFileWrite] = ""
+			}
+
+		}
+	}
+
+	if hasErrors {
+		return false
+	}
+
+	// The instance's user data needs every input, so it can only be written once they
+	// are all valid.
+	if err := ctl.writeInstanceFiles(); err != nil {
+		debug.Output("write instance files: %v", err)
+		ctl.inputs[aconst.KeyVirtualMachine].SetError(err)
+		return false
+	}
+
+	return true
+}
+
+func (ctl *InputsController) writeInstanceFiles() error {
+	var err error
+	ctl.args[aconst.KeyTrustPolicyFile], err = ctl.writeFile("trust_policy", "json", trustPolicy)
+	if err != nil {
+		return err
+	}
+
+	values := make(map[string]string, len(ctl.args))
+	for k, v := range ctl.args {
+		values[k] = v
+	}
+	for _, k := range ctl.inputKeys {
+		values[k] = ctl.inputs[k].Value()
+	}
+
+	ctl.args[aconst.KeyTokenPolicyFile], err = ctl.writeFile("token_policy", "json", renderTemplate(tokenPolicyTemplate, values))
+	if err != nil {
+		return err
+	}
+	ctl.args[aconst.KeyUserDataFile], err = ctl.writeFile("user_data", "sh", renderTemplate(userDataTemplate, values))
+	if err != nil {
+		return err
+	}
+
+	// The token is put in its SSM parameter from a file, so that it isn't in the commands.
+	ctl.args[aconst.KeyAPITokenFile], err = ctl.writeFile("api_token", "txt", values[aconst.KeyAPIToken])
+	return err
+}
+
+func (ctl InputsController) GetRunController() controller.Run {
+	for _, k := range ctl.inputKeys {
+		ctl.args[k] = ctl.inputs[k].Value()
+	}
+
+	return NewRunController(ctl.args)
+}
+
+// ResumeRunController rebuilds the Run controller from the args of a saved run.
+func (ctl InputsController) ResumeRunController(args map[string]string) controller.Run {
+	for k, v := range args {
+		ctl.args[k] = v
+	}
+
+	// The token file isn't saved with the other files, so it's written again from the token
+	// that was passed in.
+	if token := ctl.args[aconst.KeyAPIToken]; len(token) > 0 {
+		fn, err := ctl.writeFile("api_token", "txt", token)
+		if err != nil {
+			debug.Output("recreate api token file: %v", err)
+		} else {
+			ctl.args[aconst.KeyAPITokenFile] = fn
+		}
+	}
+
+	return NewRunController(ctl.args)
+}
+
+func (ctl InputsController) GetName() string {
+	return "Amazon Web Services Installation"
+}
+
+func (ctl *InputsController) GetInputs() []*wrapinput.Model {
+	inputs := make([]*wrapinput.Model, 0, len(ctl.inputKeys))
+	for _, k := range ctl.inputKeys {
+		inputs = append(inputs, ctl.inputs[k])
+	}
+	return inputs
+}
+
+func (ctl *InputsController) RecreateCommand() string {
+	for _, key := range ctl.inputKeys {
+		ctl.args[key] = ctl.inputs[key].Value()
+	}
+	return recreateCommand(ctl.args)
+}
+
+// tokenParameter is the SSM parameter that the instance reads the API token from.
+func tokenParameter(vm string) string {
+	return fmt.Sprintf("/flightcrew/%s/api-token", vm)
+}
+
+func contains(m map[string]string, key string) bool {
+	_, ok := m[key]
+	return ok
+}
+
+func fmtEnvForReplace(env string, value string) string {
+	return fmt.Sprintf(`
+	--env="%s=%s" \`, env, value)
+}
+
+// writeFile (over)writes the file in the temporary directory, since the user data can
+// change between validations. Only the current user can read it, since one of them is the
+// API token.
+func (ctl *InputsController) writeFile(name string, extension string, contents string) (string, error) {
+	fn := filepath.Join(ctl.tempDir, fmt.Sprintf("%s.%s", filenameReplacer.Replace(name), extension))
+	if err := os.WriteFile(fn, []byte(contents), 0600); err != nil {
+		return "", err
+	}
+	return fn, nil
+}
@@ -0,0 +1,188 @@
+package awsinstall
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"flightcrew.io/cli/internal/config"
+	"flightcrew.io/cli/internal/constants"
+	"flightcrew.io/cli/internal/controller/aws"
+	aconst "flightcrew.io/cli/internal/controller/aws/constants"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Declare the variables and then assign them in init() so that we don't have a cyclical dependency
+	// since the installCmd references these variables, but we need to first instantiate the flags.
+	tokenFlag, versionFlag, vmFlag, regionFlag, platformFlag, iamRoleFlag, configFlag *string
+	writeFlag                                                                         *bool
+)
+
+var (
+	allKeys = []string{
+		aconst.KeyRegion,
+		aconst.KeyTowerVersion,
+		aconst.KeyVirtualMachine,
+		aconst.KeyAPIToken,
+		aconst.KeyIAMRole,
+		aconst.KeyPermissions,
+		aconst.KeyPlatform,
+	}
+)
+
+type Params struct {
+	args    map[string]string
+	tempDir string
+}
+
+func RegisterFlags(cmd *cobra.Command) {
+	tokenFlag = cmd.Flags().StringP(aconst.FlagToken, "t", "", "The Flightcrew API token to identify your organization.")
+	versionFlag = cmd.Flags().StringP(aconst.FlagTowerVersion, "v", "stable", "The Flightcrew image version to install.")
+	vmFlag = cmd.Flags().String(aconst.FlagVirtualMachine, "flightcrew-control-tower", "The Name tag of the EC2 instance that will be created for the Flightcrew tower.")
+	writeFlag = cmd.Flags().BoolP(aconst.FlagWrite, "w", false, "Whether the Flightcrew tower should be read-only (false) or read-write (true).")
+	regionFlag = cmd.Flags().StringP(aconst.FlagRegion, "r", "", "The region to put your Tower in. (default: the region that the aws CLI is configured with)")
+	platformFlag = cmd.Flags().String(aconst.FlagPlatform, constants.AmazonEC2Key, "specify what type of cloud resources you want to manage. ('ec2' for EC2)")
+	iamRoleFlag = cmd.Flags().String(aconst.FlagIAMRole, "flightcrew-runner", "The name of the IAM role and instance profile that will be created to run the Flightcrew tower.")
+	configFlag = cmd.Flags().String(aconst.FlagConfig, "", "A YAML file of flag names to values. Flags passed in on the command line take precedence.")
+}
+
+func ParseFlags(cmd *cobra.Command) (Params, func(), error) {
+	if !aws.HasAWSInPath() {
+		return Params{}, nil, errors.New("aws is not in path")
+	}
+
+	accountID, err := aws.GetAccountID()
+	if err != nil {
+		return Params{}, nil, fmt.Errorf("get AWS account (are your credentials set up?): %w", err)
+	}
+
+	params := Params{
+		args: map[string]string{
+			aconst.KeyAccountID: accountID,
+		},
+	}
+
+	if len(*configFlag) > 0 {
+		if _, err := config.Load(cmd, *configFlag, allFlags()); err != nil {
+			return Params{}, nil, err
+		}
+		params.args[aconst.KeyConfigFile] = *configFlag
+	}
+
+	maybeAddEnv(params.args, aconst.KeyRegion, *regionFlag)
+	maybeAddEnv(params.args, aconst.KeyTowerVersion, *versionFlag)
+	maybeAddEnv(params.args, aconst.KeyAPIToken, *tokenFlag)
+	maybeAddEnv(params.args, aconst.KeyVirtualMachine, *vmFlag)
+	maybeAddEnv(params.args, aconst.KeyIAMRole, *iamRoleFlag)
+
+	if *writeFlag {
+		params.args[aconst.KeyPermissions] = constants.Write
+	} else {
+		params.args[aconst.KeyPermissions] = constants.Read
+	}
+
+	displayName, ok := constants.AWSKeyToDisplay[*platformFlag]
+	if !ok {
+		desired := make([]string, 0, len(constants.AWSKeyToDisplay))
+		for k := range constants.AWSKeyToDisplay {
+			desired = append(desired, k)
+		}
+		return Params{}, nil, fmt.Errorf("invalid --platform flag: %s", strings.Join(desired, ", "))
+	}
+
+	maybeAddEnv(params.args, aconst.KeyPlatform, displayName)
+
+	dir, err := os.MkdirTemp("/tmp", "flightcrew-aws-install-*")
+	if err != nil {
+		return Params{}, nil, fmt.Errorf("create temp dir for installation: %v", err)
+	}
+	params.tempDir = dir
+
+	return params, func() {
+		err = os.RemoveAll(dir)
+		if err != nil {
+			fmt.Printf("delete temporary directory `%s`: %v\n", dir, err)
+		}
+	}, nil
+}
+
+func maybeAddEnv(m map[string]string, key, value string) {
+	if len(value) > 0 {
+		m[key] = value
+	}
+}
+
+func allFlags() []string {
+	flags := make([]string, 0, len(aconst.FlagToKey))
+	for flagName := range aconst.FlagToKey {
+		flags = append(flags, flagName)
+	}
+	return flags
+}
+
+func recreateCommand(m map[string]string) string {
+	var commandName string
+	if len(os.Args) > 0 {
+		commandName = os.Args[0]
+	} else {
+		commandName = constants.CLIName
+	}
+
+	var buf strings.Builder
+	buf.WriteString(commandName)
+	buf.WriteString(" aws install")
+
+	// Only the values that differ from the config file need to be passed in as flags.
+	fileValues := make(map[string]string)
+	if fn := m[aconst.KeyConfigFile]; len(fn) > 0 {
+		buf.WriteString(" --")
+		buf.WriteString(aconst.FlagConfig)
+		buf.WriteRune('=')
+		buf.WriteString(fn)
+
+		if f, err := config.Read(fn, allFlags()); err == nil {
+			fileValues = f.Values
+		}
+	}
+
+	for flagName, keyName := range aconst.FlagToKey {
+		if val, ok := m[keyName]; ok && len(val) > 0 {
+			switch keyName {
+			case aconst.KeyPermissions:
+				if val == constants.Read {
+					val = "false"
+				} else {
+					val = "true"
+				}
+			case aconst.KeyPlatform:
+				val = getPlatformKey(val)
+			}
+
+			if fileVal, ok := fileValues[flagName]; ok && fileVal == val {
+				continue
+			} else if !ok && flagName == aconst.FlagWrite && val == "false" {
+				continue
+			}
+
+			buf.WriteString(" --")
+			buf.WriteString(flagName)
+			buf.WriteRune('=')
+			buf.WriteString(val)
+		}
+	}
+
+	return buf.String()
+}
+
+// getPlatformKey converts the platform's display name, or the platform once it has been
+// validated, back into its flag value.
+func getPlatformKey(text string) string {
+	for key, display := range constants.AWSKeyToDisplay {
+		if text == display || text == constants.AWSDisplayToPlatform[display] {
+			return key
+		}
+	}
+	return text
+}
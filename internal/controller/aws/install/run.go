@@ -0,0 +1,360 @@
+package awsinstall
+
+import (
+	"os"
+	"strings"
+
+	"flightcrew.io/cli/internal/constants"
+	"flightcrew.io/cli/internal/controller"
+	aconst "flightcrew.io/cli/internal/controller/aws/constants"
+	"flightcrew.io/cli/internal/view/command"
+)
+
+type RunController struct {
+	args     map[string]string
+	replacer *strings.Replacer
+	commands []*command.Model
+}
+
+func NewRunController(args map[string]string) *RunController {
+	commands := make([]*command.Model, 0)
+	commands = append(commands, getIAMPolicyCommands(args)...)
+	commands = append(commands, getIAMRoleCommands()...)
+	commands = append(commands, getAttachPolicyCommands(args)...)
+	commands = append(commands, getTokenParameterCommands()...)
+	commands = append(commands, getInstanceProfileCommands()...)
+	commands = append(commands, getInstanceCommands()...)
+
+	replaceArgs := make([]string, 0, 2*len(args))
+	for key, arg := range args {
+		replaceArgs = append(replaceArgs, key, arg)
+	}
+
+	replacer := strings.NewReplacer(replaceArgs...)
+	for _, cmd := range commands {
+		cmd.Replace(replacer)
+	}
+
+	return &RunController{
+		args:     args,
+		replacer: replacer,
+		commands: commands,
+	}
+}
+
+func (ctl RunController) Commands() []*command.Model {
+	return ctl.commands
+}
+
+func (ctl *RunController) GetEndController() controller.End {
+	return NewEndController(ctl.commands, ctl.replacer)
+}
+
+func (ctl RunController) RecreateCommand() string {
+	return recreateCommand(ctl.args)
+}
+
+func (ctl RunController) Args() map[string]string {
+	return ctl.args
+}
+
+// StateName is the region and the instance, which the default state file is named after.
+func (ctl RunController) StateName() string {
+	return ctl.args[aconst.KeyRegion] + "-" + ctl.args[aconst.KeyVirtualMachine]
+}
+
+// SecretArgs are the API token and the file that it's put in its SSM parameter from. Plans
+// read the token from an env var, and the file is written from the same one.
+func (ctl RunController) SecretArgs() map[string]string {
+	return map[string]string{
+		aconst.KeyAPIToken:     constants.APITokenEnv,
+		aconst.KeyAPITokenFile: "",
+	}
+}
+
+// Files returns the policy documents and user data that are referenced by the commands.
+func (ctl RunController) Files() map[string]string {
+	files := make(map[string]string)
+	for _, key := range []string{aconst.KeyIAMFileRead, aconst.KeyIAMFileWrite, aconst.KeyTrustPolicyFile, aconst.KeyTokenPolicyFile, aconst.KeyUserDataFile} {
+		fn := ctl.args[key]
+		if len(fn) == 0 {
+			continue
+		}
+
+		contents, err := os.ReadFile(fn)
+		if err != nil {
+			continue
+		}
+		files[fn] = string(contents)
+	}
+	return files
+}
+
+// SecretFiles returns the file that the API token is put in its SSM parameter from, so that
+// it isn't copied into plans with the other files.
+func (ctl RunController) SecretFiles() map[string]string {
+	files := make(map[string]string)
+	if fn := ctl.args[aconst.KeyAPITokenFile]; len(fn) > 0 {
+		files[fn] = constants.APITokenEnv
+	}
+	return files
+}
+
+func getIAMPolicyCommands(args map[string]string) []*command.Model {
+	newCheckPolicy := func(replacer *strings.Replacer) *command.Model {
+		cmd := command.NewReadModel(command.Opts{
+			Description: "Check if a ${PERMISSIONS} Flightcrew IAM policy already exists or needs to be created.",
+			Command:     `aws iam get-policy --policy-arn="arn:aws:iam::${AWS_ACCOUNT_ID}:policy/${POLICY}" >/dev/null 2>&1`,
+			Message: map[command.State]string{
+				command.PassState: "This Flightcrew IAM policy already exists.",
+				command.FailState: "No IAM policy found. Next step is to create one.",
+			},
+		})
+		cmd.Replace(replacer)
+		return cmd
+	}
+	newCreatePolicy := func(replacer *strings.Replacer, skipIfSucceed *command.Model) *command.Model {
+		cmd := command.NewWriteModel(command.Opts{
+			SkipIfSucceed: skipIfSucceed,
+			Description:   "This command creates a ${PERMISSIONS} IAM policy from `${FILE}` for the Flightcrew instance to access configs and monitoring data.\n\nhttps://docs.aws.amazon.com/IAM/latest/UserGuide/access_policies_create-cli.html",
+			Command: `aws iam create-policy \
+	--policy-name="${POLICY}" \
+	--policy-document="file://${FILE}" \
+	--description="Grants Flightcrew's Control Tower ${PERMISSIONS} access." \
+	--tags="Key=component,Value=flightcrew"`,
+			Undo: `aws iam delete-policy --policy-arn="arn:aws:iam::${AWS_ACCOUNT_ID}:policy/${POLICY}"`,
+		})
+		cmd.Replace(replacer)
+		return cmd
+	}
+
+	commands := make([]*command.Model, 0)
+
+	readReplacer := strings.NewReplacer(
+		"${POLICY}", args[aconst.KeyIAMPolicyRead],
+		"${FILE}", args[aconst.KeyIAMFileRead],
+		"${PERMISSIONS}", constants.Read)
+	checkReadPolicy := newCheckPolicy(readReplacer)
+	commands = append(commands,
+		checkReadPolicy,
+		newCreatePolicy(readReplacer, checkReadPolicy))
+
+	if args[aconst.KeyPermissions] == constants.Write {
+		writeReplacer := strings.NewReplacer(
+			"${POLICY}", args[aconst.KeyIAMPolicyWrite],
+			"${FILE}", args[aconst.KeyIAMFileWrite],
+			"${PERMISSIONS}", constants.Write)
+		checkWritePolicy := newCheckPolicy(writeReplacer)
+		commands = append(commands,
+			checkWritePolicy,
+			newCreatePolicy(writeReplacer, checkWritePolicy))
+	}
+
+	return commands
+}
+
+func getIAMRoleCommands() []*command.Model {
+	checkRole := command.NewReadModel(command.Opts{
+		Description: "Check if a Flightcrew IAM role already exists or needs to be created.",
+		Command:     `aws iam get-role --role-name="${IAM_ROLE}" >/dev/null 2>&1`,
+		Message: map[command.State]string{
+			command.PassState: "The IAM role already exists.",
+			command.FailState: "No IAM role found. Next step is to create one.",
+		},
+	})
+	return []*command.Model{
+		checkRole,
+		command.NewWriteModel(command.Opts{
+			SkipIfSucceed: checkRole,
+			Description: `This command creates an IAM role that EC2 instances can assume, and follow-up commands will attach ${PERMISSIONS} permissions.
+
+https://docs.aws.amazon.com/IAM/latest/UserGuide/id_roles_use_switch-role-ec2.html`,
+			Command: `aws iam create-role \
+	--role-name="${IAM_ROLE}" \
+	--assume-role-policy-document="file://${TRUST_POLICY_FILE}" \
+	--description="Runs Flightcrew's Control Tower instance." \
+	--tags="Key=component,Value=flightcrew"`,
+			Undo: `aws iam delete-role --role-name="${IAM_ROLE}"`,
+		}),
+	}
+}
+
+func getAttachPolicyCommands(args map[string]string) []*command.Model {
+	newCheckAttached := func(replacer *strings.Replacer) *command.Model {
+		cmd := command.NewReadModel(command.Opts{
+			Description: "Check if the ${PERMISSIONS} IAM policy is already attached to the IAM role.",
+			Command:     `aws iam list-attached-role-policies --role-name="${IAM_ROLE}" --query="AttachedPolicies[?PolicyName=='${POLICY}'].PolicyName" --output=text | grep --quiet .`,
+			Message: map[command.State]string{
+				command.PassState: "The policy is already attached.",
+				command.FailState: "The policy isn't attached. Next step is to attach it.",
+			},
+		})
+		cmd.Replace(replacer)
+		return cmd
+	}
+
+	newAttachPolicy := func(replacer *strings.Replacer, skipIfSucceed *command.Model) *command.Model {
+		cmd := command.NewWriteModel(command.Opts{
+			SkipIfSucceed: skipIfSucceed,
+			Description: `This command attaches the ${PERMISSIONS} IAM policy to the created IAM role, which grants the associated permissions to the to-be-created instance.
+
+https://docs.aws.amazon.com/cli/latest/reference/iam/attach-role-policy.html`,
+			Command: `aws iam attach-role-policy \
+	--role-name="${IAM_ROLE}" \
+	--policy-arn="arn:aws:iam::${AWS_ACCOUNT_ID}:policy/${POLICY}"`,
+			Undo: `aws iam detach-role-policy \
+	--role-name="${IAM_ROLE}" \
+	--policy-arn="arn:aws:iam::${AWS_ACCOUNT_ID}:policy/${POLICY}"`,
+		})
+		cmd.Replace(replacer)
+		return cmd
+	}
+
+	commands := make([]*command.Model, 0)
+
+	readReplacer := strings.NewReplacer(
+		"${POLICY}", aconst.KeyIAMPolicyRead,
+		"${PERMISSIONS}", constants.Read,
+	)
+	readCheck := newCheckAttached(readReplacer)
+	commands = append(commands, readCheck, newAttachPolicy(readReplacer, readCheck))
+
+	if args[aconst.KeyPermissions] == constants.Write {
+		writeReplacer := strings.NewReplacer(
+			"${POLICY}", aconst.KeyIAMPolicyWrite,
+			"${PERMISSIONS}", constants.Write,
+		)
+		writeCheck := newCheckAttached(writeReplacer)
+		commands = append(commands, writeCheck, newAttachPolicy(writeReplacer, writeCheck))
+	}
+
+	return commands
+}
+
+func getTokenParameterCommands() []*command.Model {
+	checkParameter := command.NewReadModel(command.Opts{
+		Description: "Check if the `${TOKEN_PARAMETER}` SSM parameter already has the API token.",
+		Command:     `aws ssm get-parameter --region="${REGION}" --name="${TOKEN_PARAMETER}" --with-decryption --query=Parameter.Value --output=text 2>/dev/null | tr -d '\n' | cmp --silent - "${API_TOKEN_FILE}"`,
+		Message: map[command.State]string{
+			command.PassState: "The parameter already has the API token.",
+			command.FailState: "The parameter doesn't have the API token. Next step is to put it.",
+		},
+	})
+	checkRolePolicy := command.NewReadModel(command.Opts{
+		Description: "Check if the IAM role can already read the API token's parameter.",
+		Command:     `aws iam get-role-policy --role-name="${IAM_ROLE}" --policy-name="flightcrew-api-token" >/dev/null 2>&1`,
+		Message: map[command.State]string{
+			command.PassState: "The IAM role can already read the parameter.",
+			command.FailState: "The IAM role can't read the parameter. Next step is to add a policy for it.",
+		},
+	})
+
+	return []*command.Model{
+		checkParameter,
+		command.NewWriteModel(command.Opts{
+			SkipIfSucceed: checkParameter,
+			Description: `This command puts the API token in an encrypted SSM parameter from ` + "`${API_TOKEN_FILE}`" + `, which only you can read, so that the token isn't in the command or the instance's user data. The instance reads it when it boots.
+
+https://docs.aws.amazon.com/systems-manager/latest/userguide/param-create-cli.html`,
+			Command: `aws ssm put-parameter \
+	--region="${REGION}" \
+	--name="${TOKEN_PARAMETER}" \
+	--type=SecureString \
+	--value="file://${API_TOKEN_FILE}" \
+	--overwrite`,
+			Undo: `aws ssm delete-parameter --region="${REGION}" --name="${TOKEN_PARAMETER}"`,
+		}),
+		checkRolePolicy,
+		command.NewWriteModel(command.Opts{
+			SkipIfSucceed: checkRolePolicy,
+			Description: `This command lets the IAM role read only the API token's parameter, from ` + "`${TOKEN_POLICY_FILE}`" + `.
+
+https://docs.aws.amazon.com/cli/latest/reference/iam/put-role-policy.html`,
+			Command: `aws iam put-role-policy \
+	--role-name="${IAM_ROLE}" \
+	--policy-name="flightcrew-api-token" \
+	--policy-document="file://${TOKEN_POLICY_FILE}"`,
+			Undo: `aws iam delete-role-policy --role-name="${IAM_ROLE}" --policy-name="flightcrew-api-token"`,
+		}),
+	}
+}
+
+func getInstanceProfileCommands() []*command.Model {
+	checkProfile := command.NewReadModel(command.Opts{
+		Description: "Check if a Flightcrew instance profile already exists or needs to be created.",
+		Command:     `aws iam get-instance-profile --instance-profile-name="${IAM_ROLE}" >/dev/null 2>&1`,
+		Message: map[command.State]string{
+			command.PassState: "The instance profile already exists.",
+			command.FailState: "No instance profile found. Next step is to create one.",
+		},
+	})
+	checkProfileRole := command.NewReadModel(command.Opts{
+		Description: "Check if the IAM role is already part of the instance profile.",
+		Command:     `aws iam get-instance-profile --instance-profile-name="${IAM_ROLE}" --query="InstanceProfile.Roles[?RoleName=='${IAM_ROLE}'].RoleName" --output=text 2>/dev/null | grep --quiet .`,
+		Message: map[command.State]string{
+			command.PassState: "The IAM role is already part of the instance profile.",
+			command.FailState: "The IAM role isn't part of the instance profile. Next step is to add it.",
+		},
+	})
+
+	return []*command.Model{
+		checkProfile,
+		command.NewWriteModel(command.Opts{
+			SkipIfSucceed: checkProfile,
+			Description: `This command creates an instance profile, which is how the IAM role is passed to the EC2 instance.
+
+https://docs.aws.amazon.com/IAM/latest/UserGuide/id_roles_use_switch-role-ec2_instance-profiles.html`,
+			Command: `aws iam create-instance-profile \
+	--instance-profile-name="${IAM_ROLE}" \
+	--tags="Key=component,Value=flightcrew"`,
+			Undo: `aws iam delete-instance-profile --instance-profile-name="${IAM_ROLE}"`,
+		}),
+		checkProfileRole,
+		command.NewWriteModel(command.Opts{
+			SkipIfSucceed: checkProfileRole,
+			Description:   "This command adds the IAM role to the instance profile.",
+			Command: `aws iam add-role-to-instance-profile \
+	--instance-profile-name="${IAM_ROLE}" \
+	--role-name="${IAM_ROLE}"`,
+			Undo: `aws iam remove-role-from-instance-profile \
+	--instance-profile-name="${IAM_ROLE}" \
+	--role-name="${IAM_ROLE}"`,
+		}),
+	}
+}
+
+func getInstanceCommands() []*command.Model {
+	checkInstanceExists := command.NewReadModel(command.Opts{
+		Description: "Check if a Flightcrew instance already exists or needs to be created.",
+		Command:     `aws ec2 describe-instances --region="${REGION}" --filters "Name=tag:Name,Values=${VIRTUAL_MACHINE}" "Name=instance-state-name,Values=pending,running,stopping,stopped" --query="Reservations[].Instances[].InstanceId" --output=text | grep --quiet .`,
+		Message: map[command.State]string{
+			command.PassState: "This Flightcrew instance already exists. Nothing to install.",
+			command.FailState: "No existing instance found. Next step is to create it.",
+		},
+	})
+
+	return []*command.Model{
+		checkInstanceExists,
+		command.NewWriteModel(command.Opts{
+			SkipIfSucceed: checkInstanceExists,
+			Description: `Create an Amazon Linux EC2 instance with Flightcrew's instance profile, and run the Control Tower image from its user data (` + "`${USER_DATA_FILE}`" + `).
+IAM changes can take a few seconds to reach EC2, so this first waits for the instance profile.
+
+https://docs.aws.amazon.com/cli/latest/reference/ec2/run-instances.html`,
+			Command: `aws iam wait instance-profile-exists --instance-profile-name="${IAM_ROLE}" && \
+aws ec2 run-instances \
+	--region="${REGION}" \
+	--image-id="resolve:ssm:/aws/service/ami-amazon-linux-latest/al2023-ami-kernel-default-x86_64" \
+	--instance-type="t3.micro" \
+	--iam-instance-profile="Name=${IAM_ROLE}" \
+	--user-data="file://${USER_DATA_FILE}" \
+	--metadata-options="HttpTokens=required" \
+	--tag-specifications="ResourceType=instance,Tags=[{Key=Name,Value=${VIRTUAL_MACHINE}},{Key=component,Value=flightcrew}]" \
+	--output=text \
+	--query="Instances[].InstanceId"`,
+			Undo: `aws ec2 terminate-instances \
+	--region="${REGION}" \
+	--instance-ids $(aws ec2 describe-instances --region="${REGION}" --filters "Name=tag:Name,Values=${VIRTUAL_MACHINE}" "Name=instance-state-name,Values=pending,running,stopping,stopped" --query="Reservations[].Instances[].InstanceId" --output=text)`,
+		}),
+	}
+}
@@ -0,0 +1,213 @@
+package awsinstall
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"flightcrew.io/cli/internal/apitoken"
+	"flightcrew.io/cli/internal/checkpoint"
+	"flightcrew.io/cli/internal/constants"
+	aconst "flightcrew.io/cli/internal/controller/aws/constants"
+	"flightcrew.io/cli/internal/controller/gcp"
+	"flightcrew.io/cli/internal/runner"
+	"flightcrew.io/cli/internal/view"
+	"flightcrew.io/cli/internal/view/wrapinput"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInstallFlow(mainT *testing.T) {
+	keepARS := gcp.ArtifactRegistryService
+	gcp.ArtifactRegistryService = nil
+	keepRunner := runner.Default
+	keepEndpoint := apitoken.Endpoint
+	mainT.Cleanup(func() {
+		gcp.ArtifactRegistryService = keepARS
+		runner.Default = keepRunner
+		apitoken.Endpoint = keepEndpoint
+	})
+
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "Bearer revoked-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(`{"organizationName": "Acme"}`))
+	}))
+	mainT.Cleanup(tokenServer.Close)
+	apitoken.Endpoint = tokenServer.URL
+
+	newParams := func(t *testing.T, permissions string) Params {
+		return Params{
+			args: map[string]string{
+				aconst.KeyAccountID:      "123456789012",
+				aconst.KeyRegion:         "us-west-2",
+				aconst.KeyTowerVersion:   "1.2.3",
+				aconst.KeyAPIToken:       "my-secret-token",
+				aconst.KeyVirtualMachine: "flightcrew-control-tower",
+				aconst.KeyIAMRole:        "flightcrew-runner",
+				aconst.KeyPermissions:    permissions,
+				aconst.KeyPlatform:       constants.AmazonEC2Display,
+			},
+			tempDir: t.TempDir(),
+		}
+	}
+
+	mainT.Run("fresh account should create everything", func(t *testing.T) {
+		scripted, err := runner.NewScripted(
+			runner.Response{Match: `^aws (iam get-policy|iam get-role|iam list-attached-role-policies|iam get-instance-profile|iam get-role-policy|ssm get-parameter) `, ExitCode: 1},
+			runner.Response{Match: `^aws ec2 describe-instances .*pending,running`, ExitCode: 1},
+			runner.Response{Match: `^aws `},
+		)
+		require.NoError(t, err)
+		runner.Default = scripted
+
+		ctl := NewInputsController(newParams(t, constants.Write))
+		var out bytes.Buffer
+		require.NoError(t, view.RunHeadless(ctl, &out))
+
+		expected := []string{
+			`^aws iam create-policy --policy-name="flightcrew-ec2-read-only"`,
+			`^aws iam create-policy --policy-name="flightcrew-ec2-read-write"`,
+			`^aws iam create-role --role-name="flightcrew-runner"`,
+			`^aws iam attach-role-policy --role-name="flightcrew-runner" --policy-arn="arn:aws:iam::123456789012:policy/flightcrew-ec2-read-only"`,
+			`^aws iam attach-role-policy --role-name="flightcrew-runner" --policy-arn="arn:aws:iam::123456789012:policy/flightcrew-ec2-read-write"`,
+			`^aws ssm put-parameter --region="us-west-2" --name="/flightcrew/flightcrew-control-tower/api-token" --type=SecureString --value="file://\S+/api_token\.txt" --overwrite$`,
+			`^aws iam put-role-policy --role-name="flightcrew-runner" --policy-name="flightcrew-api-token" --policy-document="file://\S+/token_policy\.json"$`,
+			`^aws iam create-instance-profile --instance-profile-name="flightcrew-runner"`,
+			`^aws iam add-role-to-instance-profile`,
+			`^aws iam wait instance-profile-exists .* aws ec2 run-instances --region="us-west-2"`,
+		}
+		i := 0
+		for _, call := range scripted.Calls {
+			if i < len(expected) && regexp.MustCompile(expected[i]).MatchString(call) {
+				i++
+			}
+		}
+		if i < len(expected) {
+			assert.Fail(t, "missing call", "no call matching %q in:\n%v", expected[i], scripted.Calls)
+		}
+
+		userData, err := os.ReadFile(ctl.args[aconst.KeyUserDataFile])
+		require.NoError(t, err)
+		assert.NotContains(t, string(userData), "my-secret-token")
+		assert.Contains(t, string(userData), `aws ssm get-parameter --region="us-west-2" --name="/flightcrew/flightcrew-control-tower/api-token" --with-decryption`)
+		assert.Contains(t, string(userData), `--env=FC_API_KEY \`)
+		assert.Contains(t, string(userData), `--env="CLOUD_PLATFORM=`+constants.AmazonEC2Platform+`"`)
+		assert.Contains(t, string(userData), `--env="TRAFFIC_ROUTER=`+constants.AmazonEC2Platform+`"`)
+		assert.Contains(t, string(userData), gcp.ImagePath+`:1.2.3`)
+
+		token, err := os.ReadFile(ctl.args[aconst.KeyAPITokenFile])
+		require.NoError(t, err)
+		assert.Equal(t, "my-secret-token", string(token))
+		tokenPolicy, err := os.ReadFile(ctl.args[aconst.KeyTokenPolicyFile])
+		require.NoError(t, err)
+		assert.Contains(t, string(tokenPolicy), `"Resource": "arn:aws:ssm:us-west-2:123456789012:parameter/flightcrew/flightcrew-control-tower/api-token"`)
+		for _, call := range scripted.Calls {
+			assert.NotContains(t, call, "my-secret-token")
+		}
+	})
+
+	mainT.Run("plan should not have the token", func(t *testing.T) {
+		scripted, err := runner.NewScripted(runner.Response{Match: `^aws `})
+		require.NoError(t, err)
+		runner.Default = scripted
+
+		var plan bytes.Buffer
+		require.NoError(t, view.WritePlan(NewInputsController(newParams(t, constants.Read)), &plan))
+		assert.NotContains(t, plan.String(), "my-secret-token")
+		assert.Regexp(t, `\(umask 077 && printf %s "\$FLIGHTCREW_API_TOKEN" > '\S+/api_token\.txt'\)`, plan.String())
+		assert.Contains(t, plan.String(), "--env=FC_API_KEY")
+	})
+
+	mainT.Run("revoked token should fail validation", func(t *testing.T) {
+		params := newParams(t, constants.Read)
+		params.args[aconst.KeyAPIToken] = "revoked-token"
+		err := view.ValidateInputs(NewInputsController(params))
+		assert.ErrorContains(t, err, "API Token: the token is invalid or has been revoked")
+	})
+
+	mainT.Run("token should show its organization", func(t *testing.T) {
+		ctl := NewInputsController(newParams(t, constants.Read))
+		require.NoError(t, view.ValidateInputs(ctl))
+		assert.Contains(t, ctl.inputs[aconst.KeyAPIToken].View(wrapinput.ViewParams{ShowValue: true}), "belongs to organization 'Acme'")
+	})
+
+	mainT.Run("invalid instance name should fail validation", func(t *testing.T) {
+		params := newParams(t, constants.Read)
+		params.args[aconst.KeyVirtualMachine] = "flightcrew tower"
+		err := view.ValidateInputs(NewInputsController(params))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "Instance Name: can only have letters")
+	})
+
+	mainT.Run("existing resources should be skipped", func(t *testing.T) {
+		scripted, err := runner.NewScripted(
+			runner.Response{Match: `^aws (iam get-policy|iam get-role|iam list-attached-role-policies|iam get-instance-profile|iam get-role-policy|ssm get-parameter) `},
+			runner.Response{Match: `^aws ec2 describe-instances `},
+		)
+		require.NoError(t, err)
+		runner.Default = scripted
+
+		var out bytes.Buffer
+		require.NoError(t, view.RunHeadless(NewInputsController(newParams(t, constants.Read)), &out))
+
+		for _, call := range scripted.Calls {
+			assert.NotRegexp(t, `^aws (iam create-|iam attach-|iam add-|iam put-|iam wait|ssm put-|ec2 run-instances)`, call)
+			assert.NotContains(t, call, "flightcrew-ec2-read-write")
+		}
+	})
+	mainT.Run("failed run should resume from the failed command", func(t *testing.T) {
+		scripted, err := runner.NewScripted(
+			runner.Response{Match: `^aws (iam get-policy|iam get-role|iam list-attached-role-policies|iam get-instance-profile|iam get-role-policy|ssm get-parameter) `},
+			runner.Response{Match: `^aws ec2 describe-instances `, ExitCode: 1},
+			runner.Response{Match: `^aws iam wait `, ExitCode: 1},
+		)
+		require.NoError(t, err)
+		runner.Default = scripted
+
+		stateFile := filepath.Join(t.TempDir(), "state.json")
+		disable := checkpoint.Enable(stateFile, "aws install", nil)
+		defer disable()
+
+		params := newParams(t, constants.Read)
+		var out bytes.Buffer
+		require.Error(t, view.RunHeadless(NewInputsController(params), &out))
+		assert.Contains(t, out.String(), "--resume="+stateFile)
+
+		// The temporary directory is usually gone by the time the run is resumed.
+		require.NoError(t, os.RemoveAll(params.tempDir))
+
+		state, err := checkpoint.Read(stateFile)
+		require.NoError(t, err)
+		_, _, err = state.Resume(NewInputsController(newParams(t, constants.Read)), "gcp install")
+		require.Error(t, err)
+
+		runCtl, cleanup, err := state.Resume(NewInputsController(newParams(t, constants.Read)), "aws install")
+		require.NoError(t, err)
+		defer cleanup()
+
+		scripted, err = runner.NewScripted(
+			runner.Response{Match: `^aws iam wait `},
+			runner.Response{Match: `^aws ec2 describe-instances `},
+		)
+		require.NoError(t, err)
+		runner.Default = scripted
+
+		out.Reset()
+		require.NoError(t, view.ResumeHeadless(runCtl, &out))
+
+		// Only the failed command and the end screen's check are run again.
+		require.Len(t, scripted.Calls, 2)
+		_, err = os.Stat(runCtl.(*RunController).args[aconst.KeyAPITokenFile])
+		assert.NoError(t, err, "token file should be recreated")
+		assert.Regexp(t, `^aws iam wait instance-profile-exists .* --user-data="file://`+regexp.QuoteMeta(params.tempDir), scripted.Calls[0])
+		_, err = os.Stat(filepath.Join(params.tempDir, "user_data.sh"))
+		assert.NoError(t, err, "user data should be recreated")
+	})
+}
@@ -0,0 +1,81 @@
+package awsinstall
+
+import "strings"
+
+// trustPolicy lets EC2 instances assume the tower's IAM role.
+const trustPolicy = `{
+  "Version": "2012-10-17",
+  "Statement": [
+    {
+      "Effect": "Allow",
+      "Principal": {"Service": "ec2.amazonaws.com"},
+      "Action": "sts:AssumeRole"
+    }
+  ]
+}
+`
+
+// tokenPolicyTemplate lets the instance read the API token from its SSM parameter. The
+// parameter is encrypted with the AWS managed key, whose key policy already lets the role
+// decrypt it through SSM.
+const tokenPolicyTemplate = `{
+  "Version": "2012-10-17",
+  "Statement": [
+    {
+      "Effect": "Allow",
+      "Action": "ssm:GetParameter",
+      "Resource": "arn:aws:ssm:${REGION}:${AWS_ACCOUNT_ID}:parameter${TOKEN_PARAMETER}"
+    }
+  ]
+}
+`
+
+// userDataTemplate installs Docker on Amazon Linux and runs the Control Tower image with
+// the same environment as the GCP `create-with-container` command. Watchtower keeps the
+// image up to date, like the startup script on the GCP VM.
+//
+// The API token is read from its SSM parameter when the instance boots, instead of being
+// in the user data, which can be read with ec2:DescribeInstanceAttribute. The instance
+// profile can take a few seconds to reach the instance, so the read is retried.
+const userDataTemplate = `#!/bin/bash
+set -e
+
+dnf install -y docker
+systemctl enable --now docker
+
+for attempt in 1 2 3 4 5 6 7 8 9 10; do
+	FC_API_KEY="$(aws ssm get-parameter --region="${REGION}" --name="${TOKEN_PARAMETER}" --with-decryption --query=Parameter.Value --output=text)" && break
+	sleep 10
+done
+if [ -z "$FC_API_KEY" ]; then
+	echo "could not read the API token from ${TOKEN_PARAMETER}" >&2
+	exit 1
+fi
+export FC_API_KEY
+
+docker run --detach --name=flightcrew-control-tower --restart=always \
+	--publish=8080:8080 \
+	--entrypoint="/ko-app/tower" \
+	--env=FC_API_KEY \
+	--env="CLOUD_PLATFORM=${PLATFORM}" \${TRAFFIC_ROUTER}
+	--env="FC_PACKAGE_VERSION=${TOWER_VERSION}" \
+	--env="METRIC_PROVIDERS=cloudwatch" \
+	--env="FC_RPC_CONNECT_HOST=${RPC_HOST}" \
+	--env="FC_RPC_CONNECT_PORT=443" \
+	--env="FC_TOWER_PORT=8080" \
+	--label="component=flightcrew" \
+	"${IMAGE_PATH}:${TOWER_VERSION}" \
+	--debug=true
+
+docker run --detach --name=watchtower --restart=always \
+	--volume=/var/run/docker.sock:/var/run/docker.sock \
+	containrrr/watchtower --interval 300 --cleanup --include-restarting
+`
+
+func renderTemplate(template string, args map[string]string) string {
+	replaceArgs := make([]string, 0, 2*len(args))
+	for key, arg := range args {
+		replaceArgs = append(replaceArgs, key, arg)
+	}
+	return strings.NewReplacer(replaceArgs...).Replace(template)
+}
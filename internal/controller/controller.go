@@ -55,6 +55,47 @@ type Run interface {
 	GetEndController() End
 }
 
+// Files can optionally be implemented by a Run controller whose commands reference local
+// files (e.g. IAM role definitions), so that the files can be recreated along with an
+// exported plan.
+type Files interface {
+	// Files returns the file contents keyed by the path that the commands reference.
+	Files() map[string]string
+}
+
+// Secrets can optionally be implemented by a Run controller whose args have secrets (e.g. the
+// API token), so that they can be kept out of what's written about the run.
+type Secrets interface {
+	// SecretArgs returns the env var that exported plans read each secret from, keyed by the
+	// arg. Secrets without an env var are redacted from plans instead.
+	SecretArgs() map[string]string
+}
+
+// SecretFiles can optionally be implemented by a Run controller whose commands read secrets
+// (e.g. the API token) from local files. Exported plans write those files from env vars, so
+// that the secrets aren't in the plan.
+type SecretFiles interface {
+	// SecretFiles returns the env var that each file's contents come from, keyed by the path
+	// that the commands reference.
+	SecretFiles() map[string]string
+}
+
+// Checkpointer can optionally be implemented by a Run controller so that its progress can be
+// saved to a state file and resumed later.
+type Checkpointer interface {
+	// Args returns the resolved values that the commands were created with.
+	Args() map[string]string
+	// StateName names what the run changes (e.g. the project and the VM), so that runs for
+	// different ones don't share the default state file.
+	StateName() string
+}
+
+// Resumer can optionally be implemented by an Inputs controller so that a Run controller can
+// be rebuilt from the args of a saved state file instead of going through the inputs again.
+type Resumer interface {
+	ResumeRunController(args map[string]string) Run
+}
+
 type End interface {
 	// Name returns the name of the flow (e.g. gcp-install) and should be safe to write into
 	// a file name.
@@ -0,0 +1,382 @@
+package gcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	appengine "google.golang.org/api/appengine/v1"
+	crm "google.golang.org/api/cloudresourcemanager/v1"
+	compute "google.golang.org/api/compute/v1"
+	"google.golang.org/api/googleapi"
+	iam "google.golang.org/api/iam/v1"
+	"google.golang.org/api/option"
+	serviceusage "google.golang.org/api/serviceusage/v1"
+)
+
+// API answers the read-only checks with the Google Cloud API clients instead of the gcloud CLI.
+// It's nil unless it has been enabled, in which case gcloud is only needed to make changes.
+var API *APIClient
+
+var errNotFound = errors.New("not found")
+
+// APIClient has the Google Cloud API clients for the read-only checks.
+type APIClient struct {
+	iam       *iam.Service
+	crm       *crm.Service
+	compute   *compute.Service
+	appengine *appengine.APIService
+	services  *serviceusage.Service
+}
+
+// NewAPIClient creates the API clients with Application Default Credentials. If endpoint
+// is set, every request goes to it without credentials instead (e.g. to a local fake server).
+func NewAPIClient(ctx context.Context, endpoint string) (*APIClient, error) {
+	withEndpoint := func(basePath string) []option.ClientOption {
+		if len(endpoint) == 0 {
+			return nil
+		}
+		return []option.ClientOption{
+			option.WithEndpoint(strings.TrimSuffix(endpoint, "/") + basePath),
+			option.WithoutAuthentication(),
+		}
+	}
+
+	iamService, err := iam.NewService(ctx, withEndpoint("/")...)
+	if err != nil {
+		return nil, fmt.Errorf("create iam client: %w", err)
+	}
+
+	crmService, err := crm.NewService(ctx, withEndpoint("/")...)
+	if err != nil {
+		return nil, fmt.Errorf("create resource manager client: %w", err)
+	}
+
+	computeService, err := compute.NewService(ctx, withEndpoint("/compute/v1/")...)
+	if err != nil {
+		return nil, fmt.Errorf("create compute client: %w", err)
+	}
+
+	appengineService, err := appengine.NewService(ctx, withEndpoint("/")...)
+	if err != nil {
+		return nil, fmt.Errorf("create app engine client: %w", err)
+	}
+
+	servicesService, err := serviceusage.NewService(ctx, withEndpoint("/")...)
+	if err != nil {
+		return nil, fmt.Errorf("create service usage client: %w", err)
+	}
+
+	return &APIClient{
+		iam:       iamService,
+		crm:       crmService,
+		compute:   computeService,
+		appengine: appengineService,
+		services:  servicesService,
+	}, nil
+}
+
+func (c *APIClient) ListProjects() ([]Project, error) {
+	projects := make([]Project, 0)
+	err := c.crm.Projects.List().Filter("lifecycleState:ACTIVE").Pages(context.Background(), func(resp *crm.ListProjectsResponse) error {
+		for _, project := range resp.Projects {
+			if len(project.ProjectId) > 0 {
+				projects = append(projects, Project{ID: project.ProjectId, Name: project.Name})
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list projects: %w", err)
+	}
+	if len(projects) == 0 {
+		return nil, errors.New("not found")
+	}
+
+	sort.Slice(projects, func(i, j int) bool {
+		return projects[i].ID < projects[j].ID
+	})
+	return projects, nil
+}
+
+func (c *APIClient) getProjectState(projectID string) (string, error) {
+	project, err := c.crm.Projects.Get(projectID).Do()
+	if err != nil {
+		return "", fmt.Errorf("get project: %w", notFound(err))
+	}
+	return project.LifecycleState, nil
+}
+
+func (c *APIClient) listEnabledServices(projectID string, services []string) (map[string]struct{}, error) {
+	names := make([]string, 0, len(services))
+	for _, service := range services {
+		names = append(names, fmt.Sprintf("projects/%s/services/%s", projectID, service))
+	}
+
+	resp, err := c.services.Services.BatchGet("projects/" + projectID).Names(names...).Do()
+	if err != nil {
+		return nil, fmt.Errorf("get services: %w", err)
+	}
+
+	enabled := make(map[string]struct{})
+	for _, service := range resp.Services {
+		if service.State == "ENABLED" && service.Config != nil {
+			enabled[service.Config.Name] = struct{}{}
+		}
+	}
+	return enabled, nil
+}
+
+func (c *APIClient) GetOrganizationID(projectID string) (string, error) {
+	resp, err := c.crm.Projects.GetAncestry(projectID, &crm.GetAncestryRequest{}).Do()
+	if err != nil {
+		return "", fmt.Errorf("get project ancestry: %w", err)
+	}
+
+	for _, ancestor := range resp.Ancestor {
+		if id := ancestor.ResourceId; id != nil && id.Type == "organization" {
+			return id.Id, nil
+		}
+	}
+
+	return "", errors.New("not found: Google organization ID")
+}
+
+func (c *APIClient) GetInstance(projectID string, zone string, vmName string) (*Instance, error) {
+	instance, err := c.compute.Instances.Get(projectID, zone, vmName).Do()
+	if err != nil {
+		return nil, fmt.Errorf("get instance: %w", notFound(err))
+	}
+
+	// The API returns the same JSON as `gcloud compute instances describe --format=json`.
+	data, err := instance.MarshalJSON()
+	if err != nil {
+		return nil, fmt.Errorf("marshal instance: %w", err)
+	}
+
+	return parseInstanceJSON(data)
+}
+
+func (c *APIClient) ListZones(projectID string) ([]Zone, error) {
+	zones := make([]Zone, 0)
+	err := c.compute.Zones.List(projectID).Pages(context.Background(), func(resp *compute.ZoneList) error {
+		for _, zone := range resp.Items {
+			zones = append(zones, Zone{
+				Name:   zone.Name,
+				Region: zone.Region[strings.LastIndex(zone.Region, "/")+1:],
+				Status: zone.Status,
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list zones: %w", err)
+	}
+
+	sort.Slice(zones, func(i, j int) bool {
+		return zones[i].Name < zones[j].Name
+	})
+	return zones, nil
+}
+
+func (c *APIClient) ListMachineTypes(projectID string, zone string) ([]MachineType, error) {
+	types := make([]MachineType, 0)
+	err := c.compute.MachineTypes.List(projectID, zone).Pages(context.Background(), func(resp *compute.MachineTypeList) error {
+		for _, machineType := range resp.Items {
+			types = append(types, MachineType{
+				Name:     machineType.Name,
+				CPUs:     machineType.GuestCpus,
+				MemoryMB: machineType.MemoryMb,
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list machine types: %w", err)
+	}
+	return types, nil
+}
+
+func (c *APIClient) GetAppEngineLocation(projectID string) (string, error) {
+	app, err := c.appengine.Apps.Get(projectID).Do()
+	if err != nil {
+		return "", fmt.Errorf("get app engine app: %w", notFound(err))
+	}
+	return app.LocationId, nil
+}
+
+func (c *APIClient) GetProjectIAMBindings(projectID string) ([]Binding, error) {
+	policy, err := c.crm.Projects.GetIamPolicy(projectID, &crm.GetIamPolicyRequest{}).Do()
+	if err != nil {
+		return nil, fmt.Errorf("get iam policy: %w", err)
+	}
+
+	bindings := make([]Binding, 0, len(policy.Bindings))
+	for _, binding := range policy.Bindings {
+		bindings = append(bindings, Binding{
+			Role:    binding.Role,
+			Members: binding.Members,
+		})
+	}
+	return bindings, nil
+}
+
+func (c *APIClient) TestIAMPermissions(resource string, permissions []string) ([]string, error) {
+	req := &crm.TestIamPermissionsRequest{Permissions: permissions}
+
+	var res *crm.TestIamPermissionsResponse
+	var err error
+	if strings.HasPrefix(resource, "organizations/") {
+		res, err = c.crm.Organizations.TestIamPermissions(resource, req).Do()
+	} else {
+		res, err = c.crm.Projects.TestIamPermissions(strings.TrimPrefix(resource, "projects/"), req).Do()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("test iam permissions: %w", err)
+	}
+	return res.Permissions, nil
+}
+
+func (c *APIClient) ServiceAccountExists(projectID string, email string) bool {
+	return c.getServiceAccount(projectID, email) == nil
+}
+
+func (c *APIClient) getServiceAccount(projectID string, email string) error {
+	name := fmt.Sprintf("projects/%s/serviceAccounts/%s", projectID, email)
+	if _, err := c.iam.Projects.ServiceAccounts.Get(name).Do(); err != nil {
+		return fmt.Errorf("get service account: %w", notFound(err))
+	}
+	return nil
+}
+
+// getRole returns the custom role under the parent (`projects/<id>` or `organizations/<id>`).
+func (c *APIClient) getRole(parent string, role string) (*iam.Role, error) {
+	name := fmt.Sprintf("%s/roles/%s", parent, role)
+
+	var res *iam.Role
+	var err error
+	if strings.HasPrefix(parent, "organizations/") {
+		res, err = c.iam.Organizations.Roles.Get(name).Do()
+	} else {
+		res, err = c.iam.Projects.Roles.Get(name).Do()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get role: %w", notFound(err))
+	}
+	return res, nil
+}
+
+// The Check functions return a check for a read command (see command.Opts) that uses
+// the API client, or nil when the API client isn't enabled so that the gcloud command is
+// run instead.
+
+// CheckRoleExists checks that the custom role exists under the parent. Like
+// `gcloud iam roles describe`, roles that have been deleted but not purged still exist.
+func CheckRoleExists(parent string, role string) func() error {
+	if API == nil {
+		return nil
+	}
+
+	return func() error {
+		_, err := API.getRole(parent, role)
+		return err
+	}
+}
+
+// CheckRoleActive checks that the custom role exists under the parent and hasn't been deleted.
+func CheckRoleActive(parent string, role string) func() error {
+	if API == nil {
+		return nil
+	}
+
+	return func() error {
+		res, err := API.getRole(parent, role)
+		if err != nil {
+			return err
+		}
+		if res.Deleted {
+			return errors.New("role is deleted")
+		}
+		return nil
+	}
+}
+
+// CheckRolePermissions checks that the custom role under the parent has exactly the
+// permissions, in any order.
+func CheckRolePermissions(parent string, role string, permissions []string) func() error {
+	if API == nil {
+		return nil
+	}
+
+	return func() error {
+		res, err := API.getRole(parent, role)
+		if err != nil {
+			return err
+		}
+
+		added, removed := DiffPermissions(
+			&RoleDefinition{IncludedPermissions: res.IncludedPermissions},
+			&RoleDefinition{IncludedPermissions: permissions})
+		if len(added) > 0 || len(removed) > 0 {
+			return fmt.Errorf("role is missing %d and has %d extra permissions", len(added), len(removed))
+		}
+		return nil
+	}
+}
+
+// CheckServiceAccountExists checks that the service account exists in the project.
+func CheckServiceAccountExists(projectID string, email string) func() error {
+	if API == nil {
+		return nil
+	}
+
+	return func() error {
+		return API.getServiceAccount(projectID, email)
+	}
+}
+
+// CheckBindingExists checks that the role (e.g. `projects/<id>/roles/<name>`) is bound to the
+// member (e.g. `serviceAccount:<email>`) in the project's IAM policy.
+func CheckBindingExists(projectID string, role string, member string) func() error {
+	if API == nil {
+		return nil
+	}
+
+	return func() error {
+		bindings, err := API.GetProjectIAMBindings(projectID)
+		if err != nil {
+			return err
+		}
+
+		for _, binding := range bindings {
+			if binding.Role == role && binding.HasMember(member) {
+				return nil
+			}
+		}
+		return fmt.Errorf("binding: %w", errNotFound)
+	}
+}
+
+// CheckInstanceExists checks that the VM instance exists.
+func CheckInstanceExists(projectID string, zone string, vmName string) func() error {
+	if API == nil {
+		return nil
+	}
+
+	return func() error {
+		_, err := API.GetInstance(projectID, zone, vmName)
+		return err
+	}
+}
+
+// notFound converts a 404 from the API into errNotFound.
+func notFound(err error) error {
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) && apiErr.Code == http.StatusNotFound {
+		return errNotFound
+	}
+	return err
+}
@@ -0,0 +1,180 @@
+package gcp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newFakeAPI serves the responses keyed by `<method> <path>`, and 404s for everything else.
+func newFakeAPI(t *testing.T, responses map[string]string) *APIClient {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		body, ok := responses[r.Method+" "+r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error": {"code": 404, "message": "not found"}}`))
+			return
+		}
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := NewAPIClient(context.Background(), server.URL)
+	require.NoError(t, err)
+
+	keepAPI := API
+	API = client
+	t.Cleanup(func() {
+		API = keepAPI
+	})
+	return client
+}
+
+func TestAPIClient(mainT *testing.T) {
+	newFakeAPI(mainT, map[string]string{
+		"GET /v1/projects":                 `{"projects": [{"projectId": "my-project", "name": "My Project"}, {"projectId": "another-project", "name": "Another"}]}`,
+		"GET /v1/projects/deleted-project": `{"projectId": "deleted-project", "lifecycleState": "DELETE_REQUESTED"}`,
+		"GET /v1/projects/my-project/services:batchGet": `{"services": [
+			{"name": "projects/123/services/compute.googleapis.com", "config": {"name": "compute.googleapis.com"}, "state": "ENABLED"},
+			{"name": "projects/123/services/iam.googleapis.com", "config": {"name": "iam.googleapis.com"}, "state": "DISABLED"}
+		]}`,
+		"POST /v1/projects/my-project:getAncestry": `{"ancestor": [
+			{"resourceId": {"type": "project", "id": "my-project"}},
+			{"resourceId": {"type": "organization", "id": "1234567890"}}
+		]}`,
+		"POST /v1/projects/my-project:getIamPolicy": `{"bindings": [
+			{"role": "organizations/1234567890/roles/flightcrew.read", "members": ["serviceAccount:runner@my-project.iam.gserviceaccount.com"]}
+		]}`,
+		"POST /v1/organizations/1234567890:testIamPermissions":                                  `{"permissions": ["iam.roles.create"]}`,
+		"POST /v1/projects/my-project:testIamPermissions":                                       `{}`,
+		"GET /v1/projects/my-project/serviceAccounts/runner@my-project.iam.gserviceaccount.com": `{"email": "runner@my-project.iam.gserviceaccount.com"}`,
+		"GET /v1/organizations/1234567890/roles/flightcrew.read":                                `{"name": "organizations/1234567890/roles/flightcrew.read", "includedPermissions": ["compute.zones.list", "compute.instances.list"]}`,
+		"GET /v1/projects/my-project/roles/flightcrew.write":                                    `{"name": "projects/my-project/roles/flightcrew.write", "deleted": true}`,
+		"GET /compute/v1/projects/my-project/zones": `{"items": [
+			{"name": "us-east1-b", "region": "https://www.googleapis.com/compute/v1/projects/my-project/regions/us-east1", "status": "UP"},
+			{"name": "europe-west1-b", "region": "https://www.googleapis.com/compute/v1/projects/my-project/regions/europe-west1", "status": "DOWN"}
+		]}`,
+		"GET /compute/v1/projects/my-project/zones/us-east1-b/machineTypes": `{"items": [
+			{"name": "e2-micro", "guestCpus": 2, "memoryMb": 1024},
+			{"name": "n1-standard-1", "guestCpus": 1, "memoryMb": 3840}
+		]}`,
+		"GET /v1/apps/my-project": `{"id": "my-project", "locationId": "europe-west"}`,
+		"GET /compute/v1/projects/my-project/zones/us-central1-c/instances/tower": `{
+			"name": "tower",
+			"status": "RUNNING",
+			"networkInterfaces": [{"networkIP": "10.128.0.2", "accessConfigs": [{"natIP": "34.1.2.3"}]}]
+		}`,
+	})
+
+	mainT.Run("project and organization should come from resource manager", func(t *testing.T) {
+		projects, err := ListProjects()
+		require.NoError(t, err)
+		assert.Equal(t, []Project{{ID: "another-project", Name: "Another"}, {ID: "my-project", Name: "My Project"}}, projects)
+
+		orgID, err := GetOrganizationID("my-project")
+		require.NoError(t, err)
+		assert.Equal(t, "1234567890", orgID)
+
+		_, err = GetOrganizationID("other-project")
+		assert.Error(t, err)
+	})
+
+	mainT.Run("project should be active and have the APIs enabled", func(t *testing.T) {
+		err := CheckProject("my-project", []Project{{ID: "my-project"}})
+		assert.EqualError(t, err, "the IAM API isn't enabled; run `gcloud services enable iam.googleapis.com --project=my-project`")
+
+		err = CheckProject("deleted-project", nil)
+		assert.EqualError(t, err, "project 'deleted-project' is DELETE_REQUESTED")
+
+		err = CheckProject("missing-project", nil)
+		assert.EqualError(t, err, "project 'missing-project' doesn't exist or you don't have access to it")
+	})
+
+	mainT.Run("permissions should be tested in organizations and projects", func(t *testing.T) {
+		granted, err := TestIAMPermissions("organizations/1234567890", []string{"iam.roles.create"})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"iam.roles.create"}, granted)
+
+		granted, err = TestIAMPermissions("projects/my-project", []string{"iam.roles.create"})
+		require.NoError(t, err)
+		assert.Empty(t, granted)
+	})
+
+	mainT.Run("instance should come from compute", func(t *testing.T) {
+		instance, err := GetInstance("my-project", "us-central1-c", "tower")
+		require.NoError(t, err)
+		assert.Equal(t, "RUNNING", instance.Status)
+		assert.Equal(t, "34.1.2.3", instance.ExternalIP)
+
+		_, err = GetInstance("my-project", "us-central1-c", "other")
+		assert.ErrorIs(t, err, errNotFound)
+	})
+
+	mainT.Run("zones, machine types and app engine region should come from compute and app engine", func(t *testing.T) {
+		zones, err := ListZones("my-project")
+		require.NoError(t, err)
+		assert.Equal(t, []Zone{
+			{Name: "europe-west1-b", Region: "europe-west1", Status: "DOWN"},
+			{Name: "us-east1-b", Region: "us-east1", Status: "UP"},
+		}, zones)
+
+		types, err := ListMachineTypes("my-project", "us-east1-b")
+		require.NoError(t, err)
+		assert.Equal(t, []MachineType{{Name: "e2-micro", CPUs: 2, MemoryMB: 1024}, {Name: "n1-standard-1", CPUs: 1, MemoryMB: 3840}}, types)
+
+		region, err := GetAppEngineRegion("my-project")
+		require.NoError(t, err)
+		assert.Equal(t, "europe-west1", region)
+
+		_, err = GetAppEngineRegion("other-project")
+		assert.ErrorIs(t, err, errNotFound)
+	})
+
+	mainT.Run("checks should pass for existing resources", func(t *testing.T) {
+		sa := "runner@my-project.iam.gserviceaccount.com"
+		assert.NoError(t, CheckRoleExists("organizations/1234567890", "flightcrew.read")())
+		assert.NoError(t, CheckRoleActive("organizations/1234567890", "flightcrew.read")())
+		assert.NoError(t, CheckServiceAccountExists("my-project", sa)())
+		assert.NoError(t, CheckBindingExists("my-project", "organizations/1234567890/roles/flightcrew.read", "serviceAccount:"+sa)())
+		assert.NoError(t, CheckInstanceExists("my-project", "us-central1-c", "tower")())
+		assert.True(t, ServiceAccountExists("my-project", sa))
+	})
+
+	mainT.Run("checks should fail for missing resources", func(t *testing.T) {
+		assert.Error(t, CheckRoleExists("projects/my-project", "flightcrew.read")())
+		assert.Error(t, CheckServiceAccountExists("my-project", "other@my-project.iam.gserviceaccount.com")())
+		assert.Error(t, CheckBindingExists("my-project", "projects/my-project/roles/flightcrew.read", "serviceAccount:runner@my-project.iam.gserviceaccount.com")())
+		assert.Error(t, CheckInstanceExists("my-project", "us-central1-c", "other")())
+	})
+
+	mainT.Run("role permissions should be compared in any order", func(t *testing.T) {
+		role, err := GetRole("organizations/1234567890", "flightcrew.read")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"compute.zones.list", "compute.instances.list"}, role.IncludedPermissions)
+
+		assert.NoError(t, CheckRolePermissions("organizations/1234567890", "flightcrew.read", []string{"compute.instances.list", "compute.zones.list"})())
+		assert.Error(t, CheckRolePermissions("organizations/1234567890", "flightcrew.read", []string{"compute.zones.list"})())
+	})
+
+	mainT.Run("deleted roles should only exist until they are purged", func(t *testing.T) {
+		assert.NoError(t, CheckRoleExists("projects/my-project", "flightcrew.write")())
+		assert.Error(t, CheckRoleActive("projects/my-project", "flightcrew.write")())
+	})
+}
+
+func TestChecksWithoutAPI(t *testing.T) {
+	keepAPI := API
+	API = nil
+	t.Cleanup(func() {
+		API = keepAPI
+	})
+
+	assert.Nil(t, CheckRoleExists("projects/my-project", "flightcrew.read"))
+	assert.Nil(t, CheckServiceAccountExists("my-project", "runner@my-project.iam.gserviceaccount.com"))
+	assert.Nil(t, CheckInstanceExists("my-project", "us-central1-c", "tower"))
+}
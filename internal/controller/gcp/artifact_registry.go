@@ -5,15 +5,14 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
-	"regexp"
 
+	"flightcrew.io/cli/internal/semver"
 	registry "google.golang.org/api/artifactregistry/v1"
 )
 
 var (
 	ArtifactRegistryService *registry.Service
 	ImagePath               = "us-west1-docker.pkg.dev/flightcrew-artifacts/client/tower"
-	versionRE               = regexp.MustCompile(`[0-9]+\.[0-9]+\.[0-9]+`)
 )
 
 const (
@@ -32,16 +31,30 @@ func init() {
 }
 
 // GetTowerImageVersion returns the associated image tag in the form of x.x.x
-// so that it can be passed into the Tower.
+// so that it can be passed into the Tower. The version can be a tag (e.g. `stable`) or a
+// semver constraint (e.g. `~2.1`), which resolves to the highest tag that matches.
 func GetTowerImageVersion(version string) (string, error) {
 	if ArtifactRegistryService == nil {
-		if versionRE.MatchString(version) {
+		if _, err := semver.Parse(version); err == nil {
 			return version, nil
 		}
+		if _, err := semver.ParseConstraint(version); err == nil {
+			return "", errors.New("want `x.x.x` format: version constraints need the image's tags, which failed to lookup")
+		}
 
 		return "", errors.New("want `x.x.x` format: failed to lookup image")
 	}
 
+	images, err := listDockerImages()
+	if err != nil {
+		return "", err
+	}
+
+	return getDesiredImageVersion(images, version)
+}
+
+// listDockerImages pages through all of the tower's images.
+func listDockerImages() ([]*registry.DockerImage, error) {
 	images := make([]*registry.DockerImage, 0)
 
 	var resp []*registry.DockerImage
@@ -50,7 +63,7 @@ func GetTowerImageVersion(version string) (string, error) {
 	for {
 		resp, pageToken, err = queryDockerImageAPI(pageToken)
 		if err != nil {
-			return "", fmt.Errorf("query docker image api: %w", err)
+			return nil, fmt.Errorf("query docker image api: %w", err)
 		}
 
 		images = append(images, resp...)
@@ -60,7 +73,7 @@ func GetTowerImageVersion(version string) (string, error) {
 		}
 	}
 
-	return getDesiredImageVersion(images, version)
+	return images, nil
 }
 
 func queryDockerImageAPI(pageToken string) ([]*registry.DockerImage, string, error) {
@@ -94,18 +107,62 @@ func getDesiredImageVersion(images []*registry.DockerImage, version string) (str
 	}
 
 	if desiredImage == nil {
-		return "", fmt.Errorf("unable to find tower version: %s", version)
+		return getConstraintImageVersion(images, version)
 	}
 
-	if versionRE.MatchString(version) {
+	if _, err := semver.Parse(version); err == nil {
 		return version, nil
 	}
 
-	for _, tag := range desiredImage.Tags {
-		if versionRE.MatchString(tag) {
-			return tag, nil
-		}
+	if tag, ok := imageVersion(desiredImage); ok {
+		return tag, nil
 	}
 
 	return "", fmt.Errorf("no valid tower version tag found from %s", version)
 }
+
+// getConstraintImageVersion returns the highest version tag that matches the constraint.
+func getConstraintImageVersion(images []*registry.DockerImage, version string) (string, error) {
+	constraint, err := semver.ParseConstraint(version)
+	if err != nil {
+		return "", fmt.Errorf("unable to find tower version: %s", version)
+	}
+
+	tags := make(map[semver.Version]string)
+	versions := make([]semver.Version, 0)
+	for _, image := range images {
+		for _, tag := range image.Tags {
+			v, err := semver.Parse(tag)
+			if err != nil {
+				continue
+			}
+			tags[v] = tag
+			versions = append(versions, v)
+		}
+	}
+
+	highest, ok := constraint.Highest(versions)
+	if !ok {
+		return "", fmt.Errorf("no tower version matches %s", version)
+	}
+	return tags[highest], nil
+}
+
+// imageVersion returns the image's x.x.x tag. Release versions are preferred over
+// prereleases (e.g. `1.2.3-rc.1`).
+func imageVersion(image *registry.DockerImage) (string, bool) {
+	var prerelease string
+	for _, tag := range image.Tags {
+		v, err := semver.Parse(tag)
+		if err != nil {
+			continue
+		}
+		if !v.IsPrerelease() {
+			return tag, true
+		}
+		if len(prerelease) == 0 {
+			prerelease = tag
+		}
+	}
+	return prerelease, len(prerelease) > 0
+}
@@ -87,4 +87,24 @@ func TestGetDesiredImageVersion(mainT *testing.T) {
 		_, err := getDesiredImageVersion(images, "labeled")
 		assert.Error(t, err)
 	})
+
+	mainT.Run("constraint should get the highest matching version", func(t *testing.T) {
+		version, err := getDesiredImageVersion(images, "~12.2")
+		assert.NoError(t, err)
+		assert.Equal(t, "12.2.123", version)
+
+		version, err = getDesiredImageVersion(images, "^0")
+		assert.NoError(t, err)
+		assert.Equal(t, "0.1.2", version)
+	})
+
+	mainT.Run("constraint should skip prereleases unless asked", func(t *testing.T) {
+		version, err := getDesiredImageVersion(images, ">=12.1 <12.2")
+		assert.Error(t, err)
+		assert.Empty(t, version)
+
+		version, err = getDesiredImageVersion(images, ">=12.1.1351-test <12.2")
+		assert.NoError(t, err)
+		assert.Equal(t, "12.1.1351-test-not-stable", version)
+	})
 }
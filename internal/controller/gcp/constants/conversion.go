@@ -9,5 +9,27 @@ var (
 		FlagTowerVersion:   KeyTowerVersion,
 		FlagZone:           KeyZone,
 		FlagWrite:          KeyPermissions,
+		FlagServiceAccount: KeyIAMServiceAccount,
+
+		FlagMonitoredProjects: KeyMonitoredProjects,
+		FlagRoleScope:         KeyRoleScope,
+		FlagTokenSecret:       KeyTokenSecret,
+
+		FlagMachineType:  KeyMachineType,
+		FlagBootDiskSize: KeyBootDiskSize,
+		FlagBootDiskType: KeyBootDiskType,
+		FlagNetwork:      KeyNetwork,
+		FlagSubnet:       KeySubnet,
+		FlagNetworkTags:  KeyNetworkTags,
+		FlagLabels:       KeyLabels,
+		FlagNoExternalIP: KeyExternalIP,
+
+		FlagIAMRoleFileRead:  KeyIAMRoleFileRead,
+		FlagIAMRoleURLRead:   KeyIAMRoleURLRead,
+		FlagIAMRoleFileWrite: KeyIAMRoleFileWrite,
+		FlagIAMRoleURLWrite:  KeyIAMRoleURLWrite,
+
+		FlagGAEMaxVersionCount: KeyGAEMaxVersionCount,
+		FlagGAEMaxVersionAge:   KeyGAEMaxVersionAge,
 	}
 )
@@ -8,4 +8,43 @@ const (
 	FlagVirtualMachine = "vm"
 	FlagPlatform       = "platform"
 	FlagWrite          = "write"
+	FlagServiceAccount = "service-account"
+	FlagDeleteRoles    = "delete-roles"
+	FlagConfirm        = "confirm"
+	FlagConfirmVersion = "confirm-version"
+	FlagConfig         = "config"
+
+	FlagMonitoredProjects = "monitored-projects"
+	FlagRoleScope         = "role-scope"
+	FlagTokenSecret       = "token-secret"
+
+	FlagMachineType  = "machine-type"
+	FlagBootDiskSize = "boot-disk-size"
+	FlagBootDiskType = "boot-disk-type"
+	FlagNetwork      = "network"
+	FlagSubnet       = "subnet"
+	FlagNetworkTags  = "tags"
+	FlagLabels       = "labels"
+	FlagNoExternalIP = "no-external-ip"
+
+	// ExternalIPEphemeral and ExternalIPNone are whether the tower's VM gets an external IP.
+	// VMs without one reach the internet through Cloud NAT, and are SSHed into through IAP.
+	ExternalIPEphemeral = "ephemeral"
+	ExternalIPNone      = "none"
+
+	// RoleScopeOrganization and RoleScopeProject are where the custom IAM roles can be
+	// created. IAM doesn't support custom roles in folders.
+	RoleScopeOrganization = "organization"
+	RoleScopeProject      = "project"
+
+	FlagIAMRoleFileRead  = "iam-role-file-read"
+	FlagIAMRoleURLRead   = "iam-role-url-read"
+	FlagIAMRoleFileWrite = "iam-role-file-write"
+	FlagIAMRoleURLWrite  = "iam-role-url-write"
+
+	FlagGAEMaxVersionCount = "gae-max-version-count"
+	FlagGAEMaxVersionAge   = "gae-max-version-age"
+
+	FlagContainerEnv       = "container-env"
+	FlagRemoveContainerEnv = "remove-container-env"
 )
@@ -1,26 +1,63 @@
 package constants
 
 const (
-	KeyProject            = "${GOOGLE_PROJECT_ID}"
-	KeyTowerVersion       = "${TOWER_VERSION}"
-	KeyZone               = "${ZONE}"
-	KeyVirtualMachine     = "${VIRTUAL_MACHINE}"
-	KeyAPIToken           = "${API_TOKEN}"
-	KeyIAMServiceAccount  = "${SERVICE_ACCOUNT}"
-	KeyIAMRoleRead        = "${READ_IAM_ROLE}"
-	KeyIAMFileRead        = "${READ_IAM_FILE}"
-	KeyIAMRoleWrite       = "${WRITE_IAM_ROLE}"
-	KeyIAMFileWrite       = "${WRITE_IAM_FILE}"
-	KeyPermissions        = "${PERMISSIONS}"
-	KeyRPCHost            = "${RPC_HOST}"
-	KeyAppURL             = "${APP_URL}"
-	KeyPlatform           = "${PLATFORM}"
-	KeyTrafficRouter      = "${TRAFFIC_ROUTER}"
-	KeyImagePath          = "${IMAGE_PATH}"
-	KeyTempDir            = "${TEMP_DIR}"
-	KeyGAEMaxVersionCount = "${GAE_MAX_VERSION_COUNT}"
-	KeyGAEMaxVersionAge   = "${GAE_MAX_VERSION_AGE}"
-	KeyProjectOrOrgFlag   = "${PROJECT_OR_ORG_FLAG}"
-	KeyProjectOrOrgSlash  = "${PROJECT_OR_ORG_SLASH}"
-	KeyVirtualMachineIP   = "${VIRTUAL_MACHINE_IP}"
+	KeyProject             = "${GOOGLE_PROJECT_ID}"
+	KeyTowerVersion        = "${TOWER_VERSION}"
+	KeyZone                = "${ZONE}"
+	KeyVirtualMachine      = "${VIRTUAL_MACHINE}"
+	KeyAPIToken            = "${API_TOKEN}"
+	KeyIAMServiceAccount   = "${SERVICE_ACCOUNT}"
+	KeyIAMRoleRead         = "${READ_IAM_ROLE}"
+	KeyIAMFileRead         = "${READ_IAM_FILE}"
+	KeyIAMRoleWrite        = "${WRITE_IAM_ROLE}"
+	KeyIAMFileWrite        = "${WRITE_IAM_FILE}"
+	KeyIAMPermissionsRead  = "${READ_IAM_PERMISSIONS}"
+	KeyIAMPermissionsWrite = "${WRITE_IAM_PERMISSIONS}"
+	KeyIAMRoleFileRead     = "${READ_IAM_ROLE_FILE}"
+	KeyIAMRoleURLRead      = "${READ_IAM_ROLE_URL}"
+	KeyIAMRoleFileWrite    = "${WRITE_IAM_ROLE_FILE}"
+	KeyIAMRoleURLWrite     = "${WRITE_IAM_ROLE_URL}"
+	KeyPermissions         = "${PERMISSIONS}"
+	KeyRPCHost             = "${RPC_HOST}"
+	KeyAppURL              = "${APP_URL}"
+	KeyPlatform            = "${PLATFORM}"
+	KeyTrafficRouter       = "${TRAFFIC_ROUTER}"
+	KeyImagePath           = "${IMAGE_PATH}"
+	KeyTempDir             = "${TEMP_DIR}"
+	KeyGAEMaxVersionCount  = "${GAE_MAX_VERSION_COUNT}"
+	KeyGAEMaxVersionAge    = "${GAE_MAX_VERSION_AGE}"
+	KeyProjectOrOrgFlag    = "${PROJECT_OR_ORG_FLAG}"
+	KeyProjectOrOrgSlash   = "${PROJECT_OR_ORG_SLASH}"
+	KeyVirtualMachineIP    = "${VIRTUAL_MACHINE_IP}"
+	KeyDeleteRoles         = "${DELETE_ROLES}"
+	KeyConfirm             = "${CONFIRM}"
+	KeyConfirmVersion      = "${CONFIRM_VERSION}"
+	KeyConfigFile          = "${CONFIG_FILE}"
+
+	KeyRoleScope         = "${ROLE_SCOPE}"
+	KeyMonitoredProjects = "${MONITORED_PROJECTS}"
+	// KeyMonitoredProjectRoles has a `<project ID>=<role parent>` pair for each monitored
+	// project (e.g. `my-project=organizations/1234`), separated by commas.
+	KeyMonitoredProjectRoles = "${MONITORED_PROJECT_ROLES}"
+
+	KeyTokenSecret  = "${TOKEN_SECRET}"
+	KeyAPITokenFile = "${API_TOKEN_FILE}"
+	// KeyTokenSecretRef is the secret version that a tower's VM reads its API token from.
+	KeyTokenSecretRef = "${TOKEN_SECRET_REF}"
+	// KeyStartupScriptFile is the VM's startup script, which reads the API token from the
+	// secret.
+	KeyStartupScriptFile = "${STARTUP_SCRIPT_FILE}"
+
+	// The shape of the tower's VM. Only the machine type is always passed to gcloud.
+	KeyMachineType  = "${MACHINE_TYPE}"
+	KeyBootDiskSize = "${BOOT_DISK_SIZE}"
+	KeyBootDiskType = "${BOOT_DISK_TYPE}"
+	KeyNetwork      = "${NETWORK}"
+	KeySubnet       = "${SUBNET}"
+	KeyNetworkTags  = "${NETWORK_TAGS}"
+	// KeyLabels are `key=value` labels (comma-separated) to add to the VM, on top of
+	// `component=flightcrew`.
+	KeyLabels = "${LABELS}"
+	// KeyExternalIP is ExternalIPEphemeral or ExternalIPNone.
+	KeyExternalIP = "${EXTERNAL_IP}"
 )
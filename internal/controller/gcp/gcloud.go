@@ -3,15 +3,18 @@ package gcp
 import (
 	"bytes"
 	"fmt"
-	"os/exec"
+	"regexp"
+	"strings"
+
+	"flightcrew.io/cli/internal/runner"
 )
 
+// shellSafeRE matches the values that can be pasted into a shell without quotes.
+var shellSafeRE = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]*$`)
+
 func HasGcloudInPath() bool {
-	cmd := exec.Command("bash", "-c", `which gcloud`)
 	var b bytes.Buffer
-	cmd.Stdout = &b
-	cmd.Stderr = &b
-	hasGcloudInPath := cmd.Run() == nil
+	hasGcloudInPath := runner.Run(`which gcloud`, &b, &b) == nil
 
 	if !hasGcloudInPath {
 		fmt.Printf(`The "gcloud" CLI tool is a pre-requisite to run this script.
@@ -25,3 +28,12 @@ func HasGcloudInPath() bool {
 	}
 	return hasGcloudInPath
 }
+
+// ShellQuote single-quotes the value if the shell would otherwise split or expand it (e.g.
+// spaces or `$`), so that flag values can be pasted back into a shell.
+func ShellQuote(value string) string {
+	if shellSafeRE.MatchString(value) {
+		return value
+	}
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
@@ -0,0 +1,17 @@
+package gcp_test
+
+import (
+	"testing"
+
+	"flightcrew.io/cli/internal/controller/gcp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShellQuote(t *testing.T) {
+	assert.Equal(t, "APPENGINE_MAX_VERSION_COUNT=30", gcp.ShellQuote("APPENGINE_MAX_VERSION_COUNT=30"))
+	assert.Equal(t, "projects/host-project/global/networks/shared-vpc", gcp.ShellQuote("projects/host-project/global/networks/shared-vpc"))
+	assert.Equal(t, "", gcp.ShellQuote(""))
+	assert.Equal(t, `'METRIC_PROVIDERS=a b'`, gcp.ShellQuote("METRIC_PROVIDERS=a b"))
+	assert.Equal(t, `'FC_RPC_CONNECT_HOST=$HOST'`, gcp.ShellQuote("FC_RPC_CONNECT_HOST=$HOST"))
+	assert.Equal(t, `'it'\''s'`, gcp.ShellQuote("it's"))
+}
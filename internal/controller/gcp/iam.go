@@ -0,0 +1,111 @@
+package gcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"flightcrew.io/cli/internal/runner"
+	crm "google.golang.org/api/cloudresourcemanager/v1"
+	"google.golang.org/api/option"
+)
+
+// Binding is a single role binding of an IAM policy.
+type Binding struct {
+	Role    string   `json:"role"`
+	Members []string `json:"members"`
+}
+
+// HasMember returns whether the member (e.g. `serviceAccount:name@project.iam.gserviceaccount.com`)
+// is part of the binding.
+func (b Binding) HasMember(member string) bool {
+	for _, m := range b.Members {
+		if m == member {
+			return true
+		}
+	}
+	return false
+}
+
+// GetProjectIAMBindings returns the role bindings of the project's IAM policy.
+func GetProjectIAMBindings(projectID string) ([]Binding, error) {
+	if API != nil {
+		return API.GetProjectIAMBindings(projectID)
+	}
+
+	cmdStr := fmt.Sprintf(`gcloud projects get-iam-policy "%s" --format=json`, projectID)
+	stdout, stderr, err := runner.Output(cmdStr)
+	if err != nil {
+		return nil, fmt.Errorf("gcloud projects get-iam-policy: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	return parseIAMPolicyJSON(stdout.Bytes())
+}
+
+func parseIAMPolicyJSON(data []byte) ([]Binding, error) {
+	var policy struct {
+		Bindings []Binding `json:"bindings"`
+	}
+	if err := json.Unmarshal(data, &policy); err != nil {
+		return nil, fmt.Errorf("parse iam policy: %w", err)
+	}
+
+	return policy.Bindings, nil
+}
+
+// ServiceAccountExists returns whether the service account with the given email exists
+// in the project.
+func ServiceAccountExists(projectID string, email string) bool {
+	if API != nil {
+		return API.ServiceAccountExists(projectID, email)
+	}
+
+	cmdStr := fmt.Sprintf(`gcloud iam service-accounts describe "%s" --project="%s"`, email, projectID)
+	return runner.Run(cmdStr, nil, nil) == nil
+}
+
+// ResourceManagerEndpoint is where the permissions are tested without the API client, since
+// gcloud has no command for testIamPermissions. It can be changed for tests.
+var ResourceManagerEndpoint = "https://cloudresourcemanager.googleapis.com/"
+
+// TestIAMPermissions returns which of the permissions the caller has on the resource
+// (`projects/<id>` or `organizations/<id>`).
+func TestIAMPermissions(resource string, permissions []string) ([]string, error) {
+	if API != nil {
+		return API.TestIAMPermissions(resource, permissions)
+	}
+
+	// gcloud's access token is read from its stdout and only sent in the request header, so
+	// that it isn't in any process args.
+	stdout, stderr, err := runner.Output("gcloud auth print-access-token")
+	if err != nil {
+		return nil, fmt.Errorf("gcloud auth print-access-token: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	service, err := crm.NewService(context.Background(),
+		option.WithEndpoint(ResourceManagerEndpoint),
+		option.WithHTTPClient(&http.Client{
+			Timeout:   30 * time.Second,
+			Transport: bearerTransport{token: strings.TrimSpace(stdout.String())},
+		}))
+	if err != nil {
+		return nil, fmt.Errorf("create resource manager client: %w", err)
+	}
+
+	client := &APIClient{crm: service}
+	return client.TestIAMPermissions(resource, permissions)
+}
+
+// bearerTransport authorizes each request with the access token.
+type bearerTransport struct {
+	token string
+}
+
+func (t bearerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+t.token)
+	return http.DefaultTransport.RoundTrip(req)
+}
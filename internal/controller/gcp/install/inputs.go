@@ -5,9 +5,11 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 
+	"flightcrew.io/cli/internal/apitoken"
 	"flightcrew.io/cli/internal/constants"
 	"flightcrew.io/cli/internal/controller"
 	"flightcrew.io/cli/internal/controller/gcp"
@@ -17,6 +19,10 @@ import (
 	"flightcrew.io/cli/internal/view/wrapinput"
 )
 
+// maxShownRoleChanges is how many of a custom role's permission changes are listed on the
+// confirm screen before the rest are counted.
+const maxShownRoleChanges = 6
+
 var (
 	filenameReplacer = strings.NewReplacer(
 		".", "_",
@@ -29,9 +35,20 @@ var (
 		gconst.KeyProject,
 		gconst.KeyVirtualMachine,
 		gconst.KeyAPIToken,
+		gconst.KeyTokenSecret,
 		gconst.KeyPlatform,
 		gconst.KeyPermissions,
+		gconst.KeyRoleScope,
+		gconst.KeyMonitoredProjects,
 		gconst.KeyZone,
+		gconst.KeyMachineType,
+		gconst.KeyBootDiskSize,
+		gconst.KeyBootDiskType,
+		gconst.KeyNetwork,
+		gconst.KeySubnet,
+		gconst.KeyNetworkTags,
+		gconst.KeyLabels,
+		gconst.KeyExternalIP,
 		gconst.KeyTowerVersion,
 		gconst.KeyIAMServiceAccount,
 	}
@@ -40,11 +57,22 @@ var (
 		gconst.KeyProject,
 		gconst.KeyVirtualMachine,
 		gconst.KeyAPIToken,
+		gconst.KeyTokenSecret,
 		gconst.KeyPlatform,
 		gconst.KeyPermissions,
 		gconst.KeyGAEMaxVersionAge,
 		gconst.KeyGAEMaxVersionCount,
+		gconst.KeyRoleScope,
+		gconst.KeyMonitoredProjects,
 		gconst.KeyZone,
+		gconst.KeyMachineType,
+		gconst.KeyBootDiskSize,
+		gconst.KeyBootDiskType,
+		gconst.KeyNetwork,
+		gconst.KeySubnet,
+		gconst.KeyNetworkTags,
+		gconst.KeyLabels,
+		gconst.KeyExternalIP,
 		gconst.KeyTowerVersion,
 		gconst.KeyIAMServiceAccount,
 	}
@@ -55,6 +83,25 @@ type InputsController struct {
 	inputs    map[string]*wrapinput.Model
 	args      map[string]string
 	inputKeys []string
+
+	// orgID is the organization of the project, or empty if it has none.
+	orgID string
+	// monitoredProjects are the other projects that the roles are bound in.
+	monitoredProjects []string
+	// roleInfos describe the custom role definitions that were passed in, if any.
+	roleInfos []string
+	// fetchedRoles are the role definitions that were downloaded by URL, so that they aren't
+	// downloaded again every time the inputs are validated.
+	fetchedRoles map[string]string
+	// projects are the projects that the user can see, or nil if they couldn't be listed.
+	projects []gcp.Project
+	// zones are the zones of zonesProject, or nil if they couldn't be listed.
+	zones        []gcp.Zone
+	zonesProject string
+	// machineTypes are the machine types of machineTypesLocation (`<project>/<zone>`), or
+	// nil if they couldn't be listed.
+	machineTypes         []gcp.MachineType
+	machineTypesLocation string
 }
 
 func NewInputsController(params Params) *InputsController {
@@ -63,27 +110,45 @@ func NewInputsController(params Params) *InputsController {
 		inputs:    make(map[string]*wrapinput.Model),
 		args:      params.args,
 		tempDir:   params.tempDir,
+
+		fetchedRoles: make(map[string]string),
 	}
 
 	if !contains(ctl.args, gconst.KeyVirtualMachine) {
 		ctl.args[gconst.KeyVirtualMachine] = "flightcrew-control-tower"
 	}
 	if !contains(ctl.args, gconst.KeyZone) {
-		ctl.args[gconst.KeyZone] = "us-central1-c"
+		ctl.args[gconst.KeyZone] = gcp.DefaultZone
 	}
 	if !contains(ctl.args, gconst.KeyTowerVersion) {
 		ctl.args[gconst.KeyTowerVersion] = "stable"
 	}
+	if !contains(ctl.args, gconst.KeyMachineType) {
+		ctl.args[gconst.KeyMachineType] = gcp.DefaultMachineType
+	}
+	if !contains(ctl.args, gconst.KeyNetworkTags) {
+		ctl.args[gconst.KeyNetworkTags] = "http-server"
+	}
+	if !contains(ctl.args, gconst.KeyExternalIP) {
+		ctl.args[gconst.KeyExternalIP] = gconst.ExternalIPEphemeral
+	}
 
 	baseURL := gcp.GetHostBaseURL("", "")
 	ctl.args[gconst.KeyAppURL] = constants.GetAppHostName(baseURL)
 	ctl.args[gconst.KeyRPCHost] = constants.GetAPIHostName(baseURL)
 	ctl.args[gconst.KeyTrafficRouter] = ""
-	ctl.args[gconst.KeyGAEMaxVersionAge] = ""
-	ctl.args[gconst.KeyGAEMaxVersionCount] = ""
+	if !contains(ctl.args, gconst.KeyGAEMaxVersionAge) {
+		ctl.args[gconst.KeyGAEMaxVersionAge] = ""
+	}
+	if !contains(ctl.args, gconst.KeyGAEMaxVersionCount) {
+		ctl.args[gconst.KeyGAEMaxVersionCount] = ""
+	}
 	ctl.args[gconst.KeyImagePath] = gcp.ImagePath
 	ctl.args[gconst.KeyProjectOrOrgFlag] = ""
 	ctl.args[gconst.KeyProjectOrOrgSlash] = ""
+	ctl.args[gconst.KeyMonitoredProjectRoles] = ""
+	ctl.args[gconst.KeyAPITokenFile] = ""
+	ctl.args[gconst.KeyStartupScriptFile] = ""
 
 	for _, key := range allKeys {
 		var input wrapinput.Model
@@ -95,16 +160,24 @@ func NewInputsController(params Params) *InputsController {
 
 		switch key {
 		case gconst.KeyProject:
+			input, ctl.projects = gcp.NewProjectInput()
+			maybeSetValue(gconst.KeyProject)
+
+		case gconst.KeyRoleScope:
+			input = wrapinput.NewRadio([]string{
+				gconst.RoleScopeOrganization,
+				gconst.RoleScopeProject})
+			input.Title = "Role Scope"
+			input.HelpText = "Role Scope is where the custom IAM roles are created. Organization roles can be shared by the projects in it, but need permission to create roles in the organization; otherwise the roles are created in the project.\nFolders aren't an option because IAM doesn't support custom roles in them."
+			maybeSetValue(gconst.KeyRoleScope)
+
+		case gconst.KeyMonitoredProjects:
 			input = wrapinput.NewFreeForm()
 			input.Freeform.CharLimit = 0
-			input.Freeform.Placeholder = "project-id-1234"
-			if project, err := gcp.GetProjectFromEnvironment(); err == nil && len(project) > 0 {
-				input.Freeform.Placeholder = project
-			}
-			input.Title = "Project ID"
-			input.HelpText = "Project ID is the unique string identifier for your Google Cloud Platform project."
-			input.Required = true
-			maybeSetValue(gconst.KeyProject)
+			input.Freeform.Placeholder = "other-project-1, other-project-2"
+			input.Title = "Monitored Projects"
+			input.HelpText = "Monitored Projects are the IDs of other projects (comma-separated) that the Tower should also have access to, e.g. for App Engine apps in several projects.\nLeave blank to only grant access to the Project ID."
+			maybeSetValue(gconst.KeyMonitoredProjects)
 
 		case gconst.KeyVirtualMachine:
 			input = wrapinput.NewFreeForm()
@@ -117,20 +190,79 @@ func NewInputsController(params Params) *InputsController {
 			maybeSetValue(gconst.KeyVirtualMachine)
 
 		case gconst.KeyZone:
-			input = wrapinput.NewFreeForm()
-			input.Freeform.Placeholder = "us-central1-c"
-			input.Freeform.CharLimit = 32
-			input.Title = "Zone"
-			input.Default = "us-central1-c"
-			input.HelpText = "Zone is the Google zone where the (to be installed) Flightcrew virtual machine instance will be located."
+			// The project input is created first, so the zones are listed for its project.
+			zoneProject := ctl.inputs[gconst.KeyProject].Value()
+			input, ctl.zones = gcp.NewZoneInput(zoneProject)
+			ctl.zonesProject = zoneProject
 			maybeSetValue(gconst.KeyZone)
 
-		case gconst.KeyTowerVersion:
+		case gconst.KeyMachineType:
+			input = wrapinput.NewFreeForm()
+			input.Freeform.Placeholder = gcp.DefaultMachineType
+			input.Freeform.CharLimit = 64
+			input.Title = "Machine Type"
+			input.Default = gcp.DefaultMachineType
+			input.HelpText = "Machine Type is the shape of the Flightcrew virtual machine instance, which has to be available in the zone."
+			maybeSetValue(gconst.KeyMachineType)
+
+		case gconst.KeyBootDiskSize:
+			input = wrapinput.NewFreeForm()
+			input.Freeform.Placeholder = "10"
+			input.Freeform.CharLimit = 5
+			input.Title = "Boot Disk Size"
+			input.HelpText = "Boot Disk Size is the size of the virtual machine's boot disk in GB.\nLeave blank for gcloud's default (10)."
+			maybeSetValue(gconst.KeyBootDiskSize)
+
+		case gconst.KeyBootDiskType:
 			input = wrapinput.NewFreeForm()
-			input.Freeform.Placeholder = "stable"
+			input.Freeform.Placeholder = strings.Join(gcp.BootDiskTypes, ", ")
 			input.Freeform.CharLimit = 32
-			input.Title = "Tower Version"
-			input.HelpText = "Tower Version is the version of the Tower image that will be installed. (recommended: `stable`)"
+			input.Title = "Boot Disk Type"
+			input.HelpText = "Boot Disk Type is the type of the virtual machine's boot disk.\nLeave blank for gcloud's default."
+			maybeSetValue(gconst.KeyBootDiskType)
+
+		case gconst.KeyNetwork:
+			input = wrapinput.NewFreeForm()
+			input.Freeform.Placeholder = "default"
+			input.Freeform.CharLimit = 0
+			input.Title = "Network"
+			input.HelpText = "Network is the VPC network of the virtual machine, as a name or projects/<host project>/global/networks/<name> for Shared VPC.\nLeave blank for the default network."
+			maybeSetValue(gconst.KeyNetwork)
+
+		case gconst.KeySubnet:
+			input = wrapinput.NewFreeForm()
+			input.Freeform.Placeholder = "default"
+			input.Freeform.CharLimit = 0
+			input.Title = "Subnetwork"
+			input.HelpText = "Subnetwork is the subnetwork of the virtual machine in the zone's region, as a name or projects/<host project>/regions/<region>/subnetworks/<name> for Shared VPC.\nLeave blank to let gcloud pick the network's subnetwork."
+			maybeSetValue(gconst.KeySubnet)
+
+		case gconst.KeyNetworkTags:
+			input = wrapinput.NewFreeForm()
+			input.Freeform.Placeholder = "http-server"
+			input.Freeform.CharLimit = 0
+			input.Title = "Network Tags"
+			input.HelpText = "Network Tags (comma-separated) are added to the virtual machine so that firewall rules can target it.\nLeave blank for no tags."
+			maybeSetValue(gconst.KeyNetworkTags)
+
+		case gconst.KeyLabels:
+			input = wrapinput.NewFreeForm()
+			input.Freeform.Placeholder = "team=platform, env=prod"
+			input.Freeform.CharLimit = 0
+			input.Title = "Labels"
+			input.HelpText = "Labels (comma-separated key=value pairs) are added to the virtual machine on top of component=flightcrew.\nLeave blank for no other labels."
+			maybeSetValue(gconst.KeyLabels)
+
+		case gconst.KeyExternalIP:
+			input = wrapinput.NewRadio([]string{
+				gconst.ExternalIPEphemeral,
+				gconst.ExternalIPNone})
+			input.Title = "External IP"
+			input.HelpText = "External IP is whether the virtual machine gets an ephemeral external IP. Without one (e.g. if an org policy blocks them), a Cloud NAT lets it reach Flightcrew, and you SSH into it through IAP."
+			maybeSetValue(gconst.KeyExternalIP)
+
+		case gconst.KeyTowerVersion:
+			input, _ = gcp.NewTowerVersionInput()
 			maybeSetValue(gconst.KeyTowerVersion)
 
 		case gconst.KeyAPIToken:
@@ -142,6 +274,14 @@ func NewInputsController(params Params) *InputsController {
 			input.HelpText = "API token is the value provided by Flightcrew to identify your organization."
 			maybeSetValue(gconst.KeyAPIToken)
 
+		case gconst.KeyTokenSecret:
+			input = wrapinput.NewFreeForm()
+			input.Freeform.Placeholder = "flightcrew-api-token"
+			input.Freeform.CharLimit = 255
+			input.Title = "Token Secret"
+			input.HelpText = "Token Secret is the name of the Secret Manager secret to store the API token in, so that it isn't in the VM's metadata.\nLeave blank to pass the token to the Tower as a container env."
+			maybeSetValue(gconst.KeyTokenSecret)
+
 		case gconst.KeyIAMServiceAccount:
 			input = wrapinput.NewFreeForm()
 			input.Title = "Service Account"
@@ -149,6 +289,7 @@ func NewInputsController(params Params) *InputsController {
 			input.Default = "flightcrew-runner"
 			input.SetValue("flightcrew-runner")
 			input.HelpText = "Service Account is the name of the (to be created) IAM service account to run the Flightcrew Tower."
+			maybeSetValue(gconst.KeyIAMServiceAccount)
 
 		case gconst.KeyPlatform:
 			input = wrapinput.NewRadio([]string{
@@ -171,12 +312,14 @@ func NewInputsController(params Params) *InputsController {
 			input.Title = "Max Version Age"
 			input.Freeform.Placeholder = "168h"
 			input.HelpText = "The Tower (App Engine + Write) will prune old versions that are receiving no traffic when they become older than this age (in h,m,s).\nLeave blank to disable."
+			maybeSetValue(gconst.KeyGAEMaxVersionAge)
 
 		case gconst.KeyGAEMaxVersionCount:
 			input = wrapinput.NewFreeForm()
 			input.Title = "Max Version Count"
 			input.Freeform.Placeholder = "30"
 			input.HelpText = "The Tower (App Engine + Write) will prune old versions that are receiving no traffic when the number of old versions exceeds this count.\nLeave blank to disable."
+			maybeSetValue(gconst.KeyGAEMaxVersionCount)
 
 		}
 
@@ -220,18 +363,107 @@ func (ctl *InputsController) Validate(inputs []*wrapinput.Model) bool {
 
 		switch k {
 		case gconst.KeyProject:
-			projectID := input.Value()
-			orgID, err := gcp.GetOrganizationID(projectID)
+			if setError(gcp.CheckProject(input.Value(), ctl.projects)) {
+				break
+			}
+
+			orgID, err := gcp.GetOrganizationID(input.Value())
 			if err != nil {
 				input.SetInfo("no organization found")
-				ctl.args[gconst.KeyProjectOrOrgFlag] = fmtFlagForReplace("project", projectID)
-				ctl.args[gconst.KeyProjectOrOrgSlash] = fmt.Sprintf(`projects/%s`, projectID)
+				ctl.orgID = ""
 			} else {
 				input.SetInfo("found organization ID '" + orgID + "'")
-				ctl.args[gconst.KeyProjectOrOrgFlag] = fmtFlagForReplace("organization", orgID)
-				ctl.args[gconst.KeyProjectOrOrgSlash] = fmt.Sprintf(`organizations/%s`, orgID)
+				ctl.orgID = orgID
+			}
+
+		case gconst.KeyMonitoredProjects:
+			projects, err := gcp.ParseMonitoredProjects(input.Value(), ctl.inputs[gconst.KeyProject].Value())
+			if setError(err) {
+				break
+			}
+
+			ctl.monitoredProjects = projects
+			input.SetConverted(strings.Join(projects, ","))
+
+		case gconst.KeyTokenSecret:
+			ctl.args[gconst.KeyAPITokenFile] = ""
+			ctl.args[gconst.KeyStartupScriptFile] = ""
+			secret := input.Value()
+			if len(secret) == 0 {
+				break
+			}
+			if setError(gcp.ValidateSecretName(secret)) {
+				break
 			}
 
+			fn, err := ctl.writeStartupScript()
+			if setError(err) {
+				break
+			}
+			ctl.args[gconst.KeyStartupScriptFile] = fn
+
+			// The token is added to the secret from a file, so that it isn't in the commands.
+			token := ctl.inputs[gconst.KeyAPIToken].Value()
+			if len(token) == 0 {
+				break
+			}
+			fn, err = ctl.createSecretFile("api_token", token)
+			if setError(err) {
+				break
+			}
+			ctl.args[gconst.KeyAPITokenFile] = fn
+			input.SetInfo(fmt.Sprintf("the VM reads the token from %s", gcp.TokenSecretRef(ctl.inputs[gconst.KeyProject].Value(), secret)))
+
+		case gconst.KeyBootDiskSize:
+			if len(input.Value()) > 0 {
+				setError(gcp.ValidateBootDiskSize(input.Value()))
+			}
+
+		case gconst.KeyBootDiskType:
+			if len(input.Value()) > 0 {
+				setError(gcp.ValidateBootDiskType(input.Value()))
+			}
+
+		case gconst.KeyNetwork:
+			if len(input.Value()) > 0 {
+				setError(gcp.ValidateNetwork(input.Value()))
+			}
+
+		case gconst.KeySubnet:
+			if len(input.Value()) > 0 {
+				setError(gcp.ValidateSubnet(input.Value(), ctl.inputs[gconst.KeyZone].Value()))
+			}
+
+		case gconst.KeyNetworkTags:
+			tags, err := gcp.ParseNetworkTags(input.Value())
+			if setError(err) {
+				break
+			}
+			input.SetConverted(strings.Join(tags, ","))
+
+		case gconst.KeyLabels:
+			labels, err := gcp.ParseLabels(input.Value())
+			if setError(err) {
+				break
+			}
+			input.SetConverted(strings.Join(labels, ","))
+
+		case gconst.KeyExternalIP:
+			if input.Value() != gconst.ExternalIPNone {
+				break
+			}
+
+			// The NAT and the firewall rule are set up in the VM's network, which gcloud would
+			// otherwise pick from the subnetwork.
+			network := ctl.inputs[gconst.KeyNetwork].Value()
+			if len(network) == 0 && len(ctl.inputs[gconst.KeySubnet].Value()) > 0 {
+				setError(errors.New("the Network is needed with a Subnetwork, to set up Cloud NAT and the IAP firewall rule in it"))
+				break
+			}
+			networkProject, networkName := gcp.NetworkLocation(network, ctl.inputs[gconst.KeyProject].Value())
+			subnetName := gcp.SubnetName(ctl.inputs[gconst.KeySubnet].Value(), network)
+			input.SetInfo(fmt.Sprintf("Cloud NAT for subnetwork '%s' in %s and the IAP firewall rule are set up in network '%s' of project '%s'", subnetName, gcp.ZoneRegion(ctl.inputs[gconst.KeyZone].Value()), networkName, networkProject))
+
 		case gconst.KeyTowerVersion:
 			version, err := gcp.GetTowerImageVersion(input.Value())
 			if setError(err) {
@@ -282,27 +514,51 @@ func (ctl *InputsController) Validate(inputs []*wrapinput.Model) bool {
 				break
 			}
 
-			var err error
+			infos := make([]string, 0, 2)
 			readSettings := perms[constants.Read]
+			readContent, info, err := ctl.getRoleContent(constants.Read, readSettings.Content)
+			if setError(err) {
+				break
+			}
+			if len(info) > 0 {
+				infos = append(infos, info)
+			}
 			ctl.args[gconst.KeyIAMRoleRead] = readSettings.Role
-			ctl.args[gconst.KeyIAMFileRead], err = ctl.createFileWithContents(platform, constants.Read, readSettings.Content, "yaml")
+			ctl.args[gconst.KeyIAMPermissionsRead], err = rolePermissions(readContent)
+			if setError(err) {
+				break
+			}
+			ctl.args[gconst.KeyIAMFileRead], err = ctl.createFileWithContents(platform, constants.Read, readContent, "yaml")
 			if setError(err) {
 				break
 			}
 
 			if permission == constants.Write {
 				writeSettings := perms[constants.Write]
+				writeContent, info, err := ctl.getRoleContent(constants.Write, writeSettings.Content)
+				if setError(err) {
+					break
+				}
+				if len(info) > 0 {
+					infos = append(infos, info)
+				}
 				ctl.args[gconst.KeyTrafficRouter] = fmtContainerEnvForReplace("TRAFFIC_ROUTER", platform)
 				ctl.args[gconst.KeyIAMRoleWrite] = writeSettings.Role
-				ctl.args[gconst.KeyIAMFileWrite], err = ctl.createFileWithContents(platform, constants.Write, writeSettings.Content, "yaml")
+				ctl.args[gconst.KeyIAMPermissionsWrite], err = rolePermissions(writeContent)
+				if setError(err) {
+					break
+				}
+				ctl.args[gconst.KeyIAMFileWrite], err = ctl.createFileWithContents(platform, constants.Write, writeContent, "yaml")
 				if setError(err) {
 					break
 				}
 			} else {
 				ctl.args[gconst.KeyTrafficRouter] = ""
 				ctl.args[gconst.KeyIAMRoleWrite] = ""
+				ctl.args[gconst.KeyIAMPermissionsWrite] = ""
 				ctl.args[gconst.KeyIAMFileWrite] = ""
 			}
+			ctl.roleInfos = infos
 
 		case gconst.KeyGAEMaxVersionCount:
 			value := input.Value()
@@ -321,7 +577,7 @@ func (ctl *InputsController) Validate(inputs []*wrapinput.Model) bool {
 			}
 
 			input.SetInfo("")
-			input.SetConverted(fmtContainerEnvForReplace("APPENGINE_MAX_VERSION_COUNT", fmt.Sprintf("%d", numMaxVersions)))
+			input.SetConverted(fmt.Sprintf("%d", numMaxVersions))
 
 		case gconst.KeyGAEMaxVersionAge:
 			value := input.Value()
@@ -341,17 +597,267 @@ func (ctl *InputsController) Validate(inputs []*wrapinput.Model) bool {
 			}
 
 			input.SetInfo(converted)
-			input.SetConverted(fmtContainerEnvForReplace("APPENGINE_MAX_VERSION_AGE", converted))
+			input.SetConverted(converted)
+
+		}
+	}
+
+	// The token is checked against the API host, which depends on the project and the VM.
+	if !ctl.verifyToken() {
+		hasErrors = true
+	}
+	if !ctl.validateZone() || !ctl.validateMachineType() {
+		hasErrors = true
+	}
 
+	// The role scope depends on the project's organization, and the existing roles are only
+	// compared once the scope and the role definitions are known.
+	if !hasErrors && !ctl.setRoleScope() {
+		hasErrors = true
+	}
+	if !hasErrors {
+		ctl.setMonitoredProjectRoles()
+
+		infos := append(ctl.roleInfos, ctl.getRoleDriftInfos()...)
+		if len(infos) > 0 {
+			ctl.inputs[gconst.KeyPermissions].SetInfo(strings.Join(infos, "; "))
 		}
 	}
 
 	return !hasErrors
 }
 
+// verifyToken shows the organization that the API token belongs to, and fails if the
+// Flightcrew API rejects it. The install goes on if the token can't be checked (e.g. offline).
+func (ctl *InputsController) verifyToken() bool {
+	input := ctl.inputs[gconst.KeyAPIToken]
+	token := input.Value()
+	if len(token) == 0 {
+		return true
+	}
+
+	info, err := apitoken.Check(ctl.args[gconst.KeyRPCHost], token)
+	if err != nil {
+		input.SetError(err)
+		debug.Output(err.Error())
+		return false
+	}
+
+	input.SetInfo(info)
+	return true
+}
+
+// validateZone checks that VMs can be created in the zone, and suggests a zone near the
+// App Engine app. The zone isn't checked if the project's zones can't be listed.
+func (ctl *InputsController) validateZone() bool {
+	input := ctl.inputs[gconst.KeyZone]
+	projectID := ctl.inputs[gconst.KeyProject].Value()
+	if len(projectID) == 0 {
+		return true
+	}
+
+	if projectID != ctl.zonesProject {
+		zones, err := gcp.ListZones(projectID)
+		if err != nil {
+			debug.Output("list zones got error: %v", err)
+		}
+		ctl.zones = zones
+		ctl.zonesProject = projectID
+		if input.Select != nil && len(zones) > 0 {
+			input.Select.SetOptions(gcp.ZoneOptions(zones))
+		}
+	}
+	if len(ctl.zones) == 0 {
+		return true
+	}
+
+	zone := gcp.FindZone(ctl.zones, input.Value())
+	if zone == nil {
+		input.SetError(fmt.Errorf("zone `%s` doesn't exist in project '%s'", input.Value(), projectID))
+		return false
+	}
+	if zone.Status != gcp.ZoneUp {
+		input.SetError(fmt.Errorf("zone `%s` is %s, so VMs can't be created in it", zone.Name, zone.Status))
+		return false
+	}
+
+	platform := ctl.inputs[gconst.KeyPlatform].Value()
+	if platform != constants.GoogleAppEngineStdPlatform && platform != constants.GoogleAppEngineStdDisplay {
+		return true
+	}
+
+	region, err := gcp.GetAppEngineRegion(projectID)
+	if err != nil {
+		debug.Output("get app engine region got error: %v", err)
+		return true
+	}
+	if zone.Region == region {
+		return true
+	}
+	if suggested := gcp.SuggestZone(ctl.zones, region); len(suggested) > 0 {
+		input.SetInfo(fmt.Sprintf("the App Engine app is in %s, so %s is closer", region, suggested))
+	}
+	return true
+}
+
+// validateMachineType checks that the machine type is available in the zone. It isn't
+// checked if the zone's machine types can't be listed.
+func (ctl *InputsController) validateMachineType() bool {
+	input := ctl.inputs[gconst.KeyMachineType]
+	projectID := ctl.inputs[gconst.KeyProject].Value()
+	zone := ctl.inputs[gconst.KeyZone].Value()
+	if len(projectID) == 0 || len(zone) == 0 {
+		return true
+	}
+
+	if location := projectID + "/" + zone; location != ctl.machineTypesLocation {
+		types, err := gcp.ListMachineTypes(projectID, zone)
+		if err != nil {
+			debug.Output("list machine types got error: %v", err)
+		}
+		ctl.machineTypes = types
+		ctl.machineTypesLocation = location
+	}
+	if len(ctl.machineTypes) == 0 {
+		return true
+	}
+
+	machineType := gcp.FindMachineType(ctl.machineTypes, input.Value())
+	if machineType == nil {
+		input.SetError(fmt.Errorf("machine type `%s` isn't available in zone `%s`", input.Value(), zone))
+		return false
+	}
+	input.SetInfo(machineType.String())
+	return true
+}
+
+// createRolePermission is what's needed to create the custom roles in the role scope.
+const createRolePermission = "iam.roles.create"
+
+// setRoleScope picks where the roles are created. Organization roles fall back to the
+// project if there is no organization, or the caller can't create roles in it.
+func (ctl *InputsController) setRoleScope() bool {
+	input := ctl.inputs[gconst.KeyRoleScope]
+	projectID := ctl.inputs[gconst.KeyProject].Value()
+
+	scope := input.Value()
+	if scope == gconst.RoleScopeOrganization {
+		if len(ctl.orgID) == 0 {
+			scope = gconst.RoleScopeProject
+			input.SetInfo("no organization found, so the roles are created in the project")
+		} else if !canCreateRoles("organizations/" + ctl.orgID) {
+			scope = gconst.RoleScopeProject
+			input.SetInfo(fmt.Sprintf("you don't have %s in organization '%s', so the roles are created in the project", createRolePermission, ctl.orgID))
+		}
+	}
+
+	if scope == gconst.RoleScopeProject && !canCreateRoles("projects/"+projectID) {
+		input.SetError(fmt.Errorf("you don't have %s in project '%s'", createRolePermission, projectID))
+		return false
+	}
+
+	if scope == gconst.RoleScopeOrganization {
+		ctl.args[gconst.KeyProjectOrOrgFlag] = fmtFlagForReplace("organization", ctl.orgID)
+		ctl.args[gconst.KeyProjectOrOrgSlash] = fmt.Sprintf(`organizations/%s`, ctl.orgID)
+	} else {
+		ctl.args[gconst.KeyProjectOrOrgFlag] = fmtFlagForReplace("project", projectID)
+		ctl.args[gconst.KeyProjectOrOrgSlash] = fmt.Sprintf(`projects/%s`, projectID)
+	}
+	input.SetConverted(scope)
+	return true
+}
+
+// canCreateRoles returns whether the caller can create roles in the resource. If that can't
+// be tested (e.g. without curl), it's assumed that they can, and gcloud will say otherwise.
+func canCreateRoles(resource string) bool {
+	granted, err := gcp.TestIAMPermissions(resource, []string{createRolePermission})
+	if err != nil {
+		debug.Output("test iam permissions in %s: %v", resource, err)
+		return true
+	}
+
+	for _, perm := range granted {
+		if perm == createRolePermission {
+			return true
+		}
+	}
+	return false
+}
+
+// setMonitoredProjectRoles picks where the roles are created for each monitored project.
+func (ctl *InputsController) setMonitoredProjectRoles() {
+	projects := gcp.GetMonitoredProjectRoles(ctl.monitoredProjects, ctl.inputs[gconst.KeyRoleScope].Value() == gconst.RoleScopeOrganization)
+
+	infos := make([]string, 0, len(projects))
+	for _, project := range projects {
+		infos = append(infos, fmt.Sprintf("%s (roles in %s)", project.ID, project.RoleParent))
+	}
+
+	ctl.args[gconst.KeyMonitoredProjectRoles] = gcp.FormatMonitoredProjectRoles(projects)
+	if len(infos) > 0 {
+		ctl.inputs[gconst.KeyMonitoredProjects].SetInfo(strings.Join(infos, ", "))
+	}
+}
+
+// getRoleDriftInfos describes how the existing roles differ from the role definitions (e.g.
+// if an older version created them with fewer permissions), since they will be updated.
+func (ctl *InputsController) getRoleDriftInfos() []string {
+	infos := make([]string, 0)
+	for _, level := range []struct {
+		permissions string
+		roleKey     string
+		permsKey    string
+	}{
+		{constants.Read, gconst.KeyIAMRoleRead, gconst.KeyIAMPermissionsRead},
+		{constants.Write, gconst.KeyIAMRoleWrite, gconst.KeyIAMPermissionsWrite},
+	} {
+		role := ctl.args[level.roleKey]
+		if len(role) == 0 {
+			continue
+		}
+
+		existing, err := gcp.GetRole(ctl.args[gconst.KeyProjectOrOrgSlash], role)
+		if err != nil {
+			debug.Output("get %s role: %v", level.permissions, err)
+			continue
+		}
+
+		if existing.Deleted {
+			infos = append(infos, fmt.Sprintf("existing %s role is deleted and will be undeleted", level.permissions))
+		}
+
+		added, removed := gcp.DiffPermissions(
+			&gcp.RoleDefinition{IncludedPermissions: existing.IncludedPermissions},
+			&gcp.RoleDefinition{IncludedPermissions: strings.Split(ctl.args[level.permsKey], ";")})
+		if len(added) == 0 && len(removed) == 0 {
+			continue
+		}
+
+		infos = append(infos, fmt.Sprintf("existing %s role will be updated: %s", level.permissions, permissionChanges(added, removed)))
+	}
+	return infos
+}
+
+// rolePermissions returns the role definition's permissions, sorted and separated by `;`
+// like gcloud lists them.
+func rolePermissions(content string) (string, error) {
+	role, err := gcp.ParseRoleDefinition([]byte(content))
+	if err != nil {
+		return "", err
+	}
+
+	perms := append([]string{}, role.IncludedPermissions...)
+	sort.Strings(perms)
+	return strings.Join(perms, ";"), nil
+}
+
 var convertDuration = timeconv.GetDurationFormatter([]string{"h", "m", "s"})
 
 func (ctl InputsController) GetRunController() controller.Run {
+	// The App Engine settings could have come from flags, but they only apply when
+	// they are one of the inputs.
+	ctl.args[gconst.KeyGAEMaxVersionCount] = ""
+	ctl.args[gconst.KeyGAEMaxVersionAge] = ""
 	for _, k := range ctl.inputKeys {
 		ctl.args[k] = ctl.inputs[k].Value()
 	}
@@ -359,6 +865,26 @@ func (ctl InputsController) GetRunController() controller.Run {
 	return NewRunController(ctl.args)
 }
 
+// ResumeRunController rebuilds the Run controller from the args of a saved run.
+func (ctl InputsController) ResumeRunController(args map[string]string) controller.Run {
+	for k, v := range args {
+		ctl.args[k] = v
+	}
+
+	// The API token file isn't saved, so it's written again from the token that was passed
+	// to resume with.
+	if len(ctl.args[gconst.KeyTokenSecret]) > 0 && len(ctl.args[gconst.KeyAPIToken]) > 0 {
+		fn, err := ctl.createSecretFile("api_token", ctl.args[gconst.KeyAPIToken])
+		if err != nil {
+			debug.Output("recreate api token file: %v", err)
+		} else {
+			ctl.args[gconst.KeyAPITokenFile] = fn
+		}
+	}
+
+	return NewRunController(ctl.args)
+}
+
 func (ctl InputsController) GetName() string {
 	return "Google Cloud Platform Installation"
 }
@@ -405,6 +931,92 @@ func fmtForReplace(value string) string {
 	%s \`, value)
 }
 
+// getRoleContent returns the role definition to create the role with: the one that was
+// passed in as a file or URL, or else the built-in one. If a custom one was passed in, the
+// info describes how its permissions differ from the built-in ones.
+func (ctl *InputsController) getRoleContent(permissions string, builtinContent string) (string, string, error) {
+	fileKey, urlKey := gconst.KeyIAMRoleFileRead, gconst.KeyIAMRoleURLRead
+	if permissions == constants.Write {
+		fileKey, urlKey = gconst.KeyIAMRoleFileWrite, gconst.KeyIAMRoleURLWrite
+	}
+
+	var source, content string
+	var err error
+	if fn := ctl.args[fileKey]; len(fn) > 0 {
+		source = fn
+		content, err = gcp.ReadRoleDefinitionFile(fn)
+	} else if url := ctl.args[urlKey]; len(url) > 0 {
+		source = url
+		if cached, ok := ctl.fetchedRoles[url]; ok {
+			content = cached
+		} else if content, err = gcp.FetchRoleDefinition(url); err == nil {
+			ctl.fetchedRoles[url] = content
+		}
+	} else {
+		return builtinContent, "", nil
+	}
+	if err != nil {
+		return "", "", err
+	}
+
+	custom, err := gcp.ParseRoleDefinition([]byte(content))
+	if err != nil {
+		return "", "", fmt.Errorf("%s: %w", source, err)
+	}
+	builtin, err := gcp.ParseRoleDefinition([]byte(builtinContent))
+	if err != nil {
+		return "", "", fmt.Errorf("built-in %s role: %w", permissions, err)
+	}
+
+	added, removed := gcp.DiffPermissions(builtin, custom)
+	return content, roleDiffInfo(permissions, source, added, removed), nil
+}
+
+// roleDiffInfo summarizes the permissions that a custom role adds to or removes from the
+// built-in role.
+func roleDiffInfo(permissions string, source string, added []string, removed []string) string {
+	if len(added) == 0 && len(removed) == 0 {
+		return fmt.Sprintf("%s role from %s has the built-in permissions", permissions, source)
+	}
+
+	return fmt.Sprintf("%s role from %s: %s", permissions, source, permissionChanges(added, removed))
+}
+
+// permissionChanges lists the added (+) and removed (-) permissions, up to a few of them.
+func permissionChanges(added []string, removed []string) string {
+	changes := make([]string, 0, len(added)+len(removed))
+	for _, perm := range added {
+		changes = append(changes, "+"+perm)
+	}
+	for _, perm := range removed {
+		changes = append(changes, "-"+perm)
+	}
+	if len(changes) > maxShownRoleChanges {
+		changes = append(changes[:maxShownRoleChanges], fmt.Sprintf("and %d more", len(changes)-maxShownRoleChanges))
+	}
+	return strings.Join(changes, ", ")
+}
+
+// createSecretFile (over)writes a file that only the user can read, since the contents may
+// change between validations.
+func (ctl *InputsController) createSecretFile(name string, contents string) (string, error) {
+	fn := filepath.Join(ctl.tempDir, name)
+	if err := os.WriteFile(fn, []byte(contents), 0600); err != nil {
+		return "", err
+	}
+	return fn, nil
+}
+
+// writeStartupScript writes the VM's startup script that reads the API token from the
+// secret.
+func (ctl *InputsController) writeStartupScript() (string, error) {
+	fn := filepath.Join(ctl.tempDir, "startup_script.sh")
+	if err := os.WriteFile(fn, []byte(tokenSecretStartupScript), 0644); err != nil {
+		return "", err
+	}
+	return fn, nil
+}
+
 func (ctl *InputsController) createFileWithContents(platform string, permissions string, contents string, extension string) (string, error) {
 	fn := filepath.Join(ctl.tempDir, fmt.Sprintf("%s.%s", filenameReplacer.Replace(fmt.Sprintf("%s_%s", permissions, platform)), extension))
 	if _, err := os.Stat(fn); err == nil {
@@ -6,6 +6,7 @@ import (
 	"os"
 	"strings"
 
+	"flightcrew.io/cli/internal/config"
 	"flightcrew.io/cli/internal/constants"
 	"flightcrew.io/cli/internal/controller/gcp"
 	gconst "flightcrew.io/cli/internal/controller/gcp/constants"
@@ -15,8 +16,13 @@ import (
 var (
 	// Declare the variables and then assign them in init() so that we don't have a cyclical dependency
 	// since the installCmd references these variables, but we need to first instantiate the flags.
-	tokenFlag, versionFlag, vmFlag, projectFlag, zoneFlag, platformFlag *string
-	writeFlag                                                           *bool
+	tokenFlag, versionFlag, vmFlag, projectFlag, zoneFlag, platformFlag, serviceAccountFlag *string
+	gaeMaxVersionCountFlag, gaeMaxVersionAgeFlag, configFlag, monitoredProjectsFlag         *string
+	roleScopeFlag, tokenSecretFlag                                                          *string
+	machineTypeFlag, bootDiskSizeFlag, bootDiskTypeFlag                                     *string
+	networkFlag, subnetFlag, networkTagsFlag, labelsFlag                                    *string
+	roleFileReadFlag, roleURLReadFlag, roleFileWriteFlag, roleURLWriteFlag                  *string
+	writeFlag, noExternalIPFlag                                                             *bool
 )
 
 var (
@@ -26,11 +32,22 @@ var (
 		gconst.KeyZone,
 		gconst.KeyVirtualMachine,
 		gconst.KeyAPIToken,
+		gconst.KeyTokenSecret,
 		gconst.KeyIAMServiceAccount,
 		gconst.KeyPermissions,
 		gconst.KeyPlatform,
+		gconst.KeyRoleScope,
+		gconst.KeyMonitoredProjects,
 		gconst.KeyGAEMaxVersionCount,
 		gconst.KeyGAEMaxVersionAge,
+		gconst.KeyMachineType,
+		gconst.KeyBootDiskSize,
+		gconst.KeyBootDiskType,
+		gconst.KeyNetwork,
+		gconst.KeySubnet,
+		gconst.KeyNetworkTags,
+		gconst.KeyLabels,
+		gconst.KeyExternalIP,
 	}
 )
 
@@ -42,15 +59,36 @@ type Params struct {
 func RegisterFlags(cmd *cobra.Command) {
 	tokenFlag = cmd.Flags().StringP(gconst.FlagToken, "t", "", "The Flightcrew API token to identify your organization.")
 	versionFlag = cmd.Flags().StringP(gconst.FlagTowerVersion, "v", "stable", "The Flightcrew image version to install.")
+	tokenSecretFlag = cmd.Flags().String(gconst.FlagTokenSecret, "", "The name of a Secret Manager secret to store the API token in, so that the tower reads it from there instead of the VM's container env.")
 	vmFlag = cmd.Flags().String(gconst.FlagVirtualMachine, "flightcrew-control-tower", "The name of the VM that will be created for the Flightcrew tower in your project.")
 	writeFlag = cmd.Flags().BoolP(gconst.FlagWrite, "w", false, "Whether the Flightcrew tower should be read-only (false) or read-write (true).")
 	projectFlag = cmd.Flags().StringP(gconst.FlagProject, "p", "", "Specify your Google Project ID.")
 	zoneFlag = cmd.Flags().StringP(gconst.FlagZone, "l", "us-central1-c", "The zone to put your Tower in.")
 	platformFlag = cmd.Flags().String(gconst.FlagPlatform, "gae_std", "specify what type of cloud resources you want to manage. ('gae_std' for App Engine, 'gce' for Compute Engine)")
+	serviceAccountFlag = cmd.Flags().String(gconst.FlagServiceAccount, "flightcrew-runner", "The name of the IAM service account that will be created to run the Flightcrew tower.")
+	roleScopeFlag = cmd.Flags().String(gconst.FlagRoleScope, gconst.RoleScopeOrganization, "Where to create the custom IAM roles. ('organization' falls back to 'project' if there is no organization or you can't create roles in it; IAM doesn't support custom roles in folders)")
+	monitoredProjectsFlag = cmd.Flags().String(gconst.FlagMonitoredProjects, "", "Other project IDs (comma-separated) that the tower's service account should also be granted the IAM roles in.")
+	gaeMaxVersionCountFlag = cmd.Flags().String(gconst.FlagGAEMaxVersionCount, "", "(App Engine + Write) Prune old versions receiving no traffic once there are more than this many.")
+	gaeMaxVersionAgeFlag = cmd.Flags().String(gconst.FlagGAEMaxVersionAge, "", "(App Engine + Write) Prune old versions receiving no traffic once they are older than this age (e.g. 1mo, 2w, 5d3h).")
+	roleFileReadFlag = cmd.Flags().String(gconst.FlagIAMRoleFileRead, "", "A YAML role definition file to use instead of the built-in read-only IAM role.")
+	roleURLReadFlag = cmd.Flags().String(gconst.FlagIAMRoleURLRead, "", "An HTTPS URL of a YAML role definition to use instead of the built-in read-only IAM role.")
+	roleFileWriteFlag = cmd.Flags().String(gconst.FlagIAMRoleFileWrite, "", "(Write) A YAML role definition file to use instead of the built-in write IAM role.")
+	roleURLWriteFlag = cmd.Flags().String(gconst.FlagIAMRoleURLWrite, "", "(Write) An HTTPS URL of a YAML role definition to use instead of the built-in write IAM role.")
+	machineTypeFlag = cmd.Flags().String(gconst.FlagMachineType, gcp.DefaultMachineType, "The machine type of the tower's VM, which has to be available in the zone.")
+	bootDiskSizeFlag = cmd.Flags().String(gconst.FlagBootDiskSize, "", "The size of the VM's boot disk in GB. (default: gcloud's default of 10)")
+	bootDiskTypeFlag = cmd.Flags().String(gconst.FlagBootDiskType, "", "The type of the VM's boot disk (e.g. pd-balanced or pd-ssd). (default: gcloud's default)")
+	networkFlag = cmd.Flags().String(gconst.FlagNetwork, "", "The VPC network of the VM, as a name or a resource path for Shared VPC. (default: the default network)")
+	subnetFlag = cmd.Flags().String(gconst.FlagSubnet, "", "The subnetwork of the VM, in the zone's region, as a name or a resource path for Shared VPC.")
+	networkTagsFlag = cmd.Flags().String(gconst.FlagNetworkTags, "http-server", "The network tags (comma-separated) of the VM, which firewall rules can target.")
+	labelsFlag = cmd.Flags().String(gconst.FlagLabels, "", "Labels (comma-separated key=value pairs) to add to the VM, on top of component=flightcrew.")
+	noExternalIPFlag = cmd.Flags().Bool(gconst.FlagNoExternalIP, false, "Create the VM without an external IP. It reaches Flightcrew through a Cloud NAT, and is SSHed into through IAP.")
+	configFlag = cmd.Flags().String(gconst.FlagConfig, "", "A YAML file of flag names to values. Flags passed in on the command line take precedence.")
 }
 
 func ParseFlags(cmd *cobra.Command) (Params, func(), error) {
-	if !gcp.HasGcloudInPath() {
+	// Changes are always made with gcloud, but the read-only checks can go through the
+	// API client without it (e.g. for --dry-run).
+	if !gcp.HasGcloudInPath() && gcp.API == nil {
 		return Params{}, nil, errors.New("gcloud is not in path")
 	}
 
@@ -58,17 +96,61 @@ func ParseFlags(cmd *cobra.Command) (Params, func(), error) {
 		args: make(map[string]string),
 	}
 
+	if len(*configFlag) > 0 {
+		if _, err := config.Load(cmd, *configFlag, allFlags()); err != nil {
+			return Params{}, nil, err
+		}
+		params.args[gconst.KeyConfigFile] = *configFlag
+	}
+
 	maybeAddEnv(params.args, gconst.KeyProject, *projectFlag)
 	maybeAddEnv(params.args, gconst.KeyZone, *zoneFlag)
 	maybeAddEnv(params.args, gconst.KeyTowerVersion, *versionFlag)
 	maybeAddEnv(params.args, gconst.KeyAPIToken, *tokenFlag)
+	maybeAddEnv(params.args, gconst.KeyTokenSecret, *tokenSecretFlag)
 	maybeAddEnv(params.args, gconst.KeyVirtualMachine, *vmFlag)
+	maybeAddEnv(params.args, gconst.KeyIAMServiceAccount, *serviceAccountFlag)
+	maybeAddEnv(params.args, gconst.KeyMonitoredProjects, *monitoredProjectsFlag)
+
+	switch *roleScopeFlag {
+	case gconst.RoleScopeOrganization, gconst.RoleScopeProject:
+		maybeAddEnv(params.args, gconst.KeyRoleScope, *roleScopeFlag)
+	case "folder":
+		return Params{}, nil, fmt.Errorf("invalid --%s flag: IAM doesn't support custom roles in folders, so use %s or %s", gconst.FlagRoleScope, gconst.RoleScopeOrganization, gconst.RoleScopeProject)
+	default:
+		return Params{}, nil, fmt.Errorf("invalid --%s flag: %s, %s", gconst.FlagRoleScope, gconst.RoleScopeOrganization, gconst.RoleScopeProject)
+	}
+	maybeAddEnv(params.args, gconst.KeyGAEMaxVersionCount, *gaeMaxVersionCountFlag)
+	maybeAddEnv(params.args, gconst.KeyGAEMaxVersionAge, *gaeMaxVersionAgeFlag)
+	maybeAddEnv(params.args, gconst.KeyMachineType, *machineTypeFlag)
+	maybeAddEnv(params.args, gconst.KeyBootDiskSize, *bootDiskSizeFlag)
+	maybeAddEnv(params.args, gconst.KeyBootDiskType, *bootDiskTypeFlag)
+	maybeAddEnv(params.args, gconst.KeyNetwork, *networkFlag)
+	maybeAddEnv(params.args, gconst.KeySubnet, *subnetFlag)
+	params.args[gconst.KeyNetworkTags] = *networkTagsFlag
+	maybeAddEnv(params.args, gconst.KeyLabels, *labelsFlag)
+
+	if len(*roleFileReadFlag) > 0 && len(*roleURLReadFlag) > 0 {
+		return Params{}, nil, fmt.Errorf("only one of --%s and --%s can be passed in", gconst.FlagIAMRoleFileRead, gconst.FlagIAMRoleURLRead)
+	}
+	if len(*roleFileWriteFlag) > 0 && len(*roleURLWriteFlag) > 0 {
+		return Params{}, nil, fmt.Errorf("only one of --%s and --%s can be passed in", gconst.FlagIAMRoleFileWrite, gconst.FlagIAMRoleURLWrite)
+	}
+	maybeAddEnv(params.args, gconst.KeyIAMRoleFileRead, *roleFileReadFlag)
+	maybeAddEnv(params.args, gconst.KeyIAMRoleURLRead, *roleURLReadFlag)
+	maybeAddEnv(params.args, gconst.KeyIAMRoleFileWrite, *roleFileWriteFlag)
+	maybeAddEnv(params.args, gconst.KeyIAMRoleURLWrite, *roleURLWriteFlag)
 
 	if *writeFlag {
 		params.args[gconst.KeyPermissions] = constants.Write
 	} else {
 		params.args[gconst.KeyPermissions] = constants.Read
 	}
+	if *noExternalIPFlag {
+		params.args[gconst.KeyExternalIP] = gconst.ExternalIPNone
+	} else {
+		params.args[gconst.KeyExternalIP] = gconst.ExternalIPEphemeral
+	}
 
 	displayName, ok := constants.KeyToDisplay[*platformFlag]
 	if !ok {
@@ -101,6 +183,14 @@ func maybeAddEnv(m map[string]string, key, value string) {
 	}
 }
 
+func allFlags() []string {
+	flags := make([]string, 0, len(gconst.FlagToKey))
+	for flagName := range gconst.FlagToKey {
+		flags = append(flags, flagName)
+	}
+	return flags
+}
+
 func recreateCommand(m map[string]string) string {
 	var commandName string
 	if len(os.Args) > 0 {
@@ -113,23 +203,49 @@ func recreateCommand(m map[string]string) string {
 	buf.WriteString(commandName)
 	buf.WriteString(" gcp install")
 
+	// Only the values that differ from the config file need to be passed in as flags.
+	fileValues := make(map[string]string)
+	if fn := m[gconst.KeyConfigFile]; len(fn) > 0 {
+		buf.WriteString(" --")
+		buf.WriteString(gconst.FlagConfig)
+		buf.WriteRune('=')
+		buf.WriteString(gcp.ShellQuote(fn))
+
+		if f, err := config.Read(fn, allFlags()); err == nil {
+			fileValues = f.Values
+		}
+	}
+
 	for flagName, keyName := range gconst.FlagToKey {
-		if val, ok := m[keyName]; ok && len(val) > 0 {
+		// Blank tags have to be passed in, since the flag's default isn't blank.
+		if val, ok := m[keyName]; ok && (len(val) > 0 || keyName == gconst.KeyNetworkTags) {
 			switch keyName {
 			case gconst.KeyPermissions:
 				if val == constants.Read {
-					continue
+					val = "false"
 				} else {
 					val = "true"
 				}
 			case gconst.KeyPlatform:
 				val = constants.GetPlatformKey(val)
+			case gconst.KeyExternalIP:
+				if val == gconst.ExternalIPNone {
+					val = "true"
+				} else {
+					val = "false"
+				}
+			}
+
+			if fileVal, ok := fileValues[flagName]; ok && fileVal == val {
+				continue
+			} else if !ok && (flagName == gconst.FlagWrite || flagName == gconst.FlagNoExternalIP) && val == "false" {
+				continue
 			}
 
 			buf.WriteString(" --")
 			buf.WriteString(flagName)
 			buf.WriteRune('=')
-			buf.WriteString(val)
+			buf.WriteString(gcp.ShellQuote(val))
 		}
 	}
 
@@ -1,14 +1,33 @@
 package gcpinstall
 
 import (
+	"fmt"
+	"os"
 	"strings"
 
 	"flightcrew.io/cli/internal/constants"
 	"flightcrew.io/cli/internal/controller"
+	"flightcrew.io/cli/internal/controller/gcp"
 	gconst "flightcrew.io/cli/internal/controller/gcp/constants"
 	"flightcrew.io/cli/internal/view/command"
 )
 
+var (
+	optionalContainerEnvs = map[string]string{
+		gconst.KeyGAEMaxVersionCount: "APPENGINE_MAX_VERSION_COUNT",
+		gconst.KeyGAEMaxVersionAge:   "APPENGINE_MAX_VERSION_AGE",
+	}
+	// optionalVMFlags are the `create-with-container` flags that are left out if they're
+	// blank, so that gcloud's defaults are used.
+	optionalVMFlags = map[string]string{
+		gconst.KeyBootDiskSize: "boot-disk-size",
+		gconst.KeyBootDiskType: "boot-disk-type",
+		gconst.KeyNetwork:      "network",
+		gconst.KeySubnet:       "subnet",
+		gconst.KeyNetworkTags:  "tags",
+	}
+)
+
 type RunController struct {
 	args     map[string]string
 	replacer *strings.Replacer
@@ -20,10 +39,23 @@ func NewRunController(args map[string]string) *RunController {
 	commands = append(commands, getIAMRoleCommands(args)...)
 	commands = append(commands, getServiceAccountCommands(args)...)
 	commands = append(commands, getBindIAMPolicyCommands(args)...)
+	commands = append(commands, getTokenSecretCommands(args)...)
+	commands = append(commands, getPrivateNetworkCommands(args)...)
 	commands = append(commands, getVMCommands(args)...)
 
 	replaceArgs := make([]string, 0, 2*len(args))
 	for key, arg := range args {
+		// The args keep the values as they were input, so convert the optional
+		// container envs into flags here.
+		if env, ok := optionalContainerEnvs[key]; ok && len(arg) > 0 {
+			arg = fmtContainerEnvForReplace(env, arg)
+		}
+		if flag, ok := optionalVMFlags[key]; ok && len(arg) > 0 {
+			arg = fmtFlagForReplace(flag, `"`+arg+`"`)
+		}
+		if key == gconst.KeyLabels && len(arg) > 0 {
+			arg = "," + arg
+		}
 		replaceArgs = append(replaceArgs, key, arg)
 	}
 
@@ -44,16 +76,81 @@ func (ctl RunController) Commands() []*command.Model {
 }
 
 func (ctl *RunController) GetEndController() controller.End {
-	return NewEndController(ctl.commands, ctl.replacer)
+	return NewEndController(ctl.commands, ctl.replacer, ctl.args[gconst.KeyExternalIP] == gconst.ExternalIPNone)
 }
 
 func (ctl RunController) RecreateCommand() string {
 	return recreateCommand(ctl.args)
 }
 
+func (ctl RunController) Args() map[string]string {
+	return ctl.args
+}
+
+// StateName is the project and the VM, which the default state file is named after.
+func (ctl RunController) StateName() string {
+	return ctl.args[gconst.KeyProject] + "-" + ctl.args[gconst.KeyVirtualMachine]
+}
+
+// SecretArgs are the API token and the file that it's added to the secret from. Plans read
+// the token from an env var, and the file is written from the same one.
+func (ctl RunController) SecretArgs() map[string]string {
+	return map[string]string{
+		gconst.KeyAPIToken:     constants.APITokenEnv,
+		gconst.KeyAPITokenFile: "",
+	}
+}
+
+// Files returns the IAM role definitions that are referenced by the create role commands,
+// and the VM's startup script if it reads the token from a secret.
+func (ctl RunController) Files() map[string]string {
+	files := make(map[string]string)
+	for _, key := range []string{gconst.KeyIAMFileRead, gconst.KeyIAMFileWrite, gconst.KeyStartupScriptFile} {
+		fn := ctl.args[key]
+		if len(fn) == 0 {
+			continue
+		}
+
+		contents, err := os.ReadFile(fn)
+		if err != nil {
+			continue
+		}
+		files[fn] = string(contents)
+	}
+	return files
+}
+
+// SecretFiles returns the file that the API token is added to the secret from, if there is
+// one, so that it isn't copied into plans with the other files.
+func (ctl RunController) SecretFiles() map[string]string {
+	files := make(map[string]string)
+	if fn := ctl.args[gconst.KeyAPITokenFile]; len(fn) > 0 {
+		files[fn] = constants.APITokenEnv
+	}
+	return files
+}
+
+// roleParentFlag is the gcloud flag for the role parent, like `${PROJECT_OR_ORG_FLAG}`.
+func roleParentFlag(parent string) string {
+	kind, id, _ := strings.Cut(parent, "/")
+	if kind == "organizations" {
+		return fmtFlagForReplace("organization", id)
+	}
+	return fmtFlagForReplace("project", id)
+}
+
 func getIAMRoleCommands(args map[string]string) []*command.Model {
-	newCheckIAMRole := func(replacer *strings.Replacer) *command.Model {
+	commands := make([]*command.Model, 0)
+	for _, parent := range gcp.RoleParents(args[gconst.KeyProjectOrOrgSlash], gcp.ParseMonitoredProjectRoles(args[gconst.KeyMonitoredProjectRoles])) {
+		commands = append(commands, getIAMRoleCommandsForParent(args, parent)...)
+	}
+	return commands
+}
+
+func getIAMRoleCommandsForParent(args map[string]string, parent string) []*command.Model {
+	newCheckIAMRole := func(replacer *strings.Replacer, role string) *command.Model {
 		cmd := command.NewReadModel(command.Opts{
+			Check:       gcp.CheckRoleExists(parent, role),
 			Description: "Check if a ${PERMISSIONS} Flightcrew IAM Role already exists or needs to be created.",
 			Command: `gcloud iam roles describe \${PROJECT_OR_ORG_FLAG}
 	"${ROLE}" >/dev/null 2>&1`,
@@ -71,31 +168,110 @@ func getIAMRoleCommands(args map[string]string) []*command.Model {
 			Description:   "This command creates a ${PERMISSIONS} IAM role from `${FILE}` for the Flightcrew VM to access configs and monitoring data.\n\nhttps://cloud.google.com/iam/docs/understanding-custom-roles",
 			Command: `gcloud iam roles create ${ROLE} \${PROJECT_OR_ORG_FLAG}
 	--file=${FILE}`,
+			Undo: `gcloud iam roles delete ${ROLE} \${PROJECT_OR_ORG_FLAG}
+	--quiet`,
+		})
+		cmd.Replace(replacer)
+		return cmd
+	}
+
+	// Roles that already exist may have been created by an older version with different
+	// permissions, or deleted by an uninstall, so bring them back in line with the definition.
+	newCheckActiveIAMRole := func(replacer *strings.Replacer, role string) *command.Model {
+		cmd := command.NewReadModel(command.Opts{
+			Check:       gcp.CheckRoleActive(parent, role),
+			Description: "Check if the ${PERMISSIONS} Flightcrew IAM Role has been deleted and needs to be undeleted.",
+			Command: `gcloud iam roles describe \${PROJECT_OR_ORG_FLAG}
+	"${ROLE}" --format="value(deleted)" 2>/dev/null | grep --quiet --invert-match "True"`,
+			Message: map[command.State]string{
+				command.PassState: "The IAM role is active.",
+				command.FailState: "The IAM role is deleted. Next step is to undelete it.",
+			},
+		})
+		cmd.Replace(replacer)
+		return cmd
+	}
+	newUndeleteIAMRole := func(replacer *strings.Replacer, skipIfSucceed *command.Model) *command.Model {
+		cmd := command.NewWriteModel(command.Opts{
+			SkipIfSucceed: skipIfSucceed,
+			Description:   "This command undeletes the ${PERMISSIONS} IAM role, which can be done within 7 days of deleting it.\n\nhttps://cloud.google.com/iam/docs/creating-custom-roles#undeleting-custom-role",
+			Command:       `gcloud iam roles undelete ${ROLE} \${PROJECT_OR_ORG_FLAG}`,
+			Undo: `gcloud iam roles delete ${ROLE} \${PROJECT_OR_ORG_FLAG}
+	--quiet`,
+		})
+		cmd.Replace(replacer)
+		return cmd
+	}
+	newCheckIAMRolePermissions := func(replacer *strings.Replacer, role string, permissions string) *command.Model {
+		cmd := command.NewReadModel(command.Opts{
+			Check:       gcp.CheckRolePermissions(parent, role, strings.Split(permissions, ";")),
+			Description: "Check if the ${PERMISSIONS} Flightcrew IAM Role has the permissions from `${FILE}`, or needs to be updated.",
+			Command: `gcloud iam roles describe \${PROJECT_OR_ORG_FLAG}
+	"${ROLE}" --format="value(includedPermissions)" 2>/dev/null | tr ';' '\n' | LC_ALL=C sort | paste -sd ';' - | grep --quiet --line-regexp --fixed-strings "${ROLE_PERMISSIONS}"`,
+			Message: map[command.State]string{
+				command.PassState: "The IAM role has the expected permissions.",
+				command.FailState: "The IAM role's permissions are different. Next step is to update it.",
+			},
+		})
+		cmd.Replace(replacer)
+		return cmd
+	}
+	// The role's current definition is saved first, so that the update can be rolled back.
+	// The etag is left out, since it changes with the update.
+	newUpdateIAMRole := func(replacer *strings.Replacer, skipIfSucceed *command.Model) *command.Model {
+		cmd := command.NewWriteModel(command.Opts{
+			SkipIfSucceed: skipIfSucceed,
+			Description:   "This command saves the ${PERMISSIONS} IAM role's current definition to `${FILE}.previous`, and updates the role to have the permissions from `${FILE}`.\n\nhttps://cloud.google.com/iam/docs/creating-custom-roles#editing-custom-role",
+			Command: `gcloud iam roles describe ${ROLE} \${PROJECT_OR_ORG_FLAG}
+	--format=yaml | grep --invert-match '^etag:' > ${FILE}.previous && \
+gcloud iam roles update ${ROLE} \${PROJECT_OR_ORG_FLAG}
+	--file=${FILE} \
+	--quiet`,
+			Undo: `gcloud iam roles update ${ROLE} \${PROJECT_OR_ORG_FLAG}
+	--file=${FILE}.previous \
+	--quiet`,
 		})
 		cmd.Replace(replacer)
 		return cmd
 	}
+	newIAMRoleCommands := func(replacer *strings.Replacer, role string, permissions string) []*command.Model {
+		checkIAMRole := newCheckIAMRole(replacer, role)
+		checkActive := newCheckActiveIAMRole(replacer, role)
+		checkPermissions := newCheckIAMRolePermissions(replacer, role, permissions)
+		return []*command.Model{
+			checkIAMRole,
+			newCreateIAMRole(replacer, checkIAMRole),
+			checkActive,
+			newUndeleteIAMRole(replacer, checkActive),
+			checkPermissions,
+			newUpdateIAMRole(replacer, checkPermissions),
+		}
+	}
+
+	// The tower project's flag is filled in with the rest of the args.
+	parentFlag := gconst.KeyProjectOrOrgFlag
+	if parent != args[gconst.KeyProjectOrOrgSlash] {
+		parentFlag = roleParentFlag(parent)
+	}
 
 	commands := make([]*command.Model, 0)
 
 	readReplacer := strings.NewReplacer(
 		"${ROLE}", args[gconst.KeyIAMRoleRead],
 		"${FILE}", args[gconst.KeyIAMFileRead],
-		"${PERMISSIONS}", constants.Read)
-	checkReadIAMRole := newCheckIAMRole(readReplacer)
-	commands = append(commands,
-		checkReadIAMRole,
-		newCreateIAMRole(readReplacer, checkReadIAMRole))
+		"${ROLE_PERMISSIONS}", args[gconst.KeyIAMPermissionsRead],
+		"${PERMISSIONS}", constants.Read,
+		gconst.KeyProjectOrOrgFlag, parentFlag)
+	commands = append(commands, newIAMRoleCommands(readReplacer, args[gconst.KeyIAMRoleRead], args[gconst.KeyIAMPermissionsRead])...)
 
 	if args[gconst.KeyPermissions] == constants.Write {
 		writeReplacer := strings.NewReplacer(
 			"${ROLE}", args[gconst.KeyIAMRoleWrite],
 			"${FILE}", args[gconst.KeyIAMFileWrite],
-			"${PERMISSIONS}", constants.Write)
-		checkWriteIAMRole := newCheckIAMRole(writeReplacer)
-		commands = append(commands,
-			checkWriteIAMRole,
-			newCreateIAMRole(writeReplacer, checkWriteIAMRole))
+			"${ROLE_PERMISSIONS}", args[gconst.KeyIAMPermissionsWrite],
+			"${PERMISSIONS}", constants.Write,
+			gconst.KeyProjectOrOrgFlag, parentFlag)
+		commands = append(commands, newIAMRoleCommands(writeReplacer, args[gconst.KeyIAMRoleWrite], args[gconst.KeyIAMPermissionsWrite])...)
 	}
 
 	return commands
@@ -103,6 +279,7 @@ func getIAMRoleCommands(args map[string]string) []*command.Model {
 
 func getServiceAccountCommands(args map[string]string) []*command.Model {
 	checkServiceAccount := command.NewReadModel(command.Opts{
+		Check:       gcp.CheckServiceAccountExists(args[gconst.KeyProject], serviceAccountEmail(args)),
 		Description: "Check if a Flightcrew service account already exists or needs to be created.",
 		Command:     `gcloud iam service-accounts describe --project="${GOOGLE_PROJECT_ID}" "${SERVICE_ACCOUNT}@${GOOGLE_PROJECT_ID}.iam.gserviceaccount.com" > /dev/null 2>&1`,
 		Message: map[command.State]string{
@@ -121,15 +298,29 @@ https://cloud.google.com/iam/docs/creating-managing-service-accounts`,
 	--project="${GOOGLE_PROJECT_ID}" \
 	--display-name="${SERVICE_ACCOUNT}" \
 	--description="Runs Flightcrew's Control Tower VM."`,
+			Undo: `gcloud iam service-accounts delete "${SERVICE_ACCOUNT}@${GOOGLE_PROJECT_ID}.iam.gserviceaccount.com" \
+	--project="${GOOGLE_PROJECT_ID}" \
+	--quiet`,
 		}),
 	}
 }
 
 func getBindIAMPolicyCommands(args map[string]string) []*command.Model {
-	newCheckPolicy := func(replacer *strings.Replacer) *command.Model {
+	commands := getBindIAMPolicyCommandsForProject(args, args[gconst.KeyProject], args[gconst.KeyProjectOrOrgSlash])
+	for _, project := range gcp.ParseMonitoredProjectRoles(args[gconst.KeyMonitoredProjectRoles]) {
+		commands = append(commands, getBindIAMPolicyCommandsForProject(args, project.ID, project.RoleParent)...)
+	}
+	return commands
+}
+
+// getBindIAMPolicyCommandsForProject binds the service account to the roles under the parent
+// (e.g. `organizations/1234`) in the project.
+func getBindIAMPolicyCommandsForProject(args map[string]string, project string, parent string) []*command.Model {
+	newCheckPolicy := func(replacer *strings.Replacer, role string) *command.Model {
 		cmd := command.NewReadModel(command.Opts{
+			Check:       gcp.CheckBindingExists(project, parent+"/roles/"+role, "serviceAccount:"+serviceAccountEmail(args)),
 			Description: "Check if IAM policy binding already exists or needs to be created.",
-			Command:     `gcloud projects get-iam-policy ${GOOGLE_PROJECT_ID} --filter="bindings.role=${PROJECT_OR_ORG_SLASH}/roles/${ROLE}"  --flatten=bindings --format="table(bindings.members,bindings.role)" | grep --quiet "'serviceAccount:${SERVICE_ACCOUNT}@${GOOGLE_PROJECT_ID}.iam.gserviceaccount.com'"`,
+			Command:     `gcloud projects get-iam-policy ${BINDING_PROJECT_ID} --filter="bindings.role=${ROLE_PARENT}/roles/${ROLE}"  --flatten=bindings --format="table(bindings.members,bindings.role)" | grep --quiet "'serviceAccount:${SERVICE_ACCOUNT}@${GOOGLE_PROJECT_ID}.iam.gserviceaccount.com'"`,
 			Message: map[command.State]string{
 				command.PassState: "Binding already exists.",
 				command.FailState: "Binding doesn't exist. Next step is to add the binding.",
@@ -145,9 +336,13 @@ func getBindIAMPolicyCommands(args map[string]string) []*command.Model {
 			Description: `This command binds the ${PERMISSIONS} IAM role to the created service account, which grants the associated permissions to Flightcrew's service account for running the to-be-created VM.
 
 https://cloud.google.com/iam/docs/granting-changing-revoking-access`,
-			Command: `gcloud projects add-iam-policy-binding "${GOOGLE_PROJECT_ID}" \
+			Command: `gcloud projects add-iam-policy-binding "${BINDING_PROJECT_ID}" \
+	--member=serviceAccount:"${SERVICE_ACCOUNT}@${GOOGLE_PROJECT_ID}.iam.gserviceaccount.com" \
+	--role="${ROLE_PARENT}/roles/${ROLE}" \
+	--condition=None`,
+			Undo: `gcloud projects remove-iam-policy-binding "${BINDING_PROJECT_ID}" \
 	--member=serviceAccount:"${SERVICE_ACCOUNT}@${GOOGLE_PROJECT_ID}.iam.gserviceaccount.com" \
-	--role="${PROJECT_OR_ORG_SLASH}/roles/${ROLE}" \
+	--role="${ROLE_PARENT}/roles/${ROLE}" \
 	--condition=None`,
 		})
 		cmd.Replace(replacer)
@@ -159,24 +354,233 @@ https://cloud.google.com/iam/docs/granting-changing-revoking-access`,
 	readReplacer := strings.NewReplacer(
 		"${ROLE}", gconst.KeyIAMRoleRead,
 		"${PERMISSIONS}", constants.Read,
+		"${BINDING_PROJECT_ID}", project,
+		"${ROLE_PARENT}", parent,
 	)
-	readCheck := newCheckPolicy(readReplacer)
+	readCheck := newCheckPolicy(readReplacer, args[gconst.KeyIAMRoleRead])
 	commands = append(commands, readCheck, newAttachPolicy(readReplacer, readCheck))
 
 	if args[gconst.KeyPermissions] == constants.Write {
 		writeReplacer := strings.NewReplacer(
 			"${ROLE}", gconst.KeyIAMRoleWrite,
 			"${PERMISSIONS}", constants.Write,
+			"${BINDING_PROJECT_ID}", project,
+			"${ROLE_PARENT}", parent,
 		)
-		writeCheck := newCheckPolicy(writeReplacer)
+		writeCheck := newCheckPolicy(writeReplacer, args[gconst.KeyIAMRoleWrite])
 		commands = append(commands, writeCheck, newAttachPolicy(writeReplacer, writeCheck))
 	}
 
 	return commands
 }
 
+// getTokenSecretCommands store the API token in Secret Manager, and let the service account
+// read it, if a secret was picked.
+func getTokenSecretCommands(args map[string]string) []*command.Model {
+	if len(args[gconst.KeyTokenSecret]) == 0 {
+		return nil
+	}
+
+	checkAPIEnabled := command.NewReadModel(command.Opts{
+		Description: "Check if the Secret Manager API is enabled in the project.",
+		Command:     `gcloud services list --enabled --project="${GOOGLE_PROJECT_ID}" --filter="config.name=secretmanager.googleapis.com" --format="value(config.name)" | grep --quiet "secretmanager"`,
+		Message: map[command.State]string{
+			command.PassState: "The Secret Manager API is enabled.",
+			command.FailState: "The Secret Manager API isn't enabled. Next step is to enable it.",
+		},
+	})
+	checkSecret := command.NewReadModel(command.Opts{
+		Description: "Check if the `${TOKEN_SECRET}` secret already exists or needs to be created.",
+		Command:     `gcloud secrets describe "${TOKEN_SECRET}" --project="${GOOGLE_PROJECT_ID}" >/dev/null 2>&1`,
+		Message: map[command.State]string{
+			command.PassState: "The secret already exists.",
+			command.FailState: "No secret found. Next step is to create it.",
+		},
+	})
+	checkVersion := command.NewReadModel(command.Opts{
+		Description: "Check if the latest version of the secret is already the API token.",
+		Command:     `gcloud secrets versions access latest --secret="${TOKEN_SECRET}" --project="${GOOGLE_PROJECT_ID}" 2>/dev/null | cmp --silent - "${API_TOKEN_FILE}"`,
+		Message: map[command.State]string{
+			command.PassState: "The secret already has the API token.",
+			command.FailState: "The secret doesn't have the API token. Next step is to add it as a new version.",
+		},
+	})
+	checkAccessor := command.NewReadModel(command.Opts{
+		Description: "Check if the service account can already read the secret.",
+		Command:     `gcloud secrets get-iam-policy "${TOKEN_SECRET}" --project="${GOOGLE_PROJECT_ID}" --flatten=bindings --filter="bindings.role=roles/secretmanager.secretAccessor" --format="value(bindings.members)" 2>/dev/null | grep --quiet "serviceAccount:${SERVICE_ACCOUNT}@${GOOGLE_PROJECT_ID}.iam.gserviceaccount.com"`,
+		Message: map[command.State]string{
+			command.PassState: "Binding already exists.",
+			command.FailState: "Binding doesn't exist. Next step is to add the binding.",
+		},
+	})
+
+	return []*command.Model{
+		checkAPIEnabled,
+		command.NewWriteModel(command.Opts{
+			SkipIfSucceed: checkAPIEnabled,
+			Description:   "This command enables the Secret Manager API, which the API token is stored in.\n\nhttps://cloud.google.com/secret-manager/docs/configuring-secret-manager",
+			Command:       `gcloud services enable secretmanager.googleapis.com --project="${GOOGLE_PROJECT_ID}"`,
+		}),
+		checkSecret,
+		command.NewWriteModel(command.Opts{
+			SkipIfSucceed: checkSecret,
+			Description:   "This command creates a secret to store the API token in, so that it isn't in the VM's metadata.\n\nhttps://cloud.google.com/secret-manager/docs/creating-and-accessing-secrets",
+			Command: `gcloud secrets create "${TOKEN_SECRET}" \
+	--project="${GOOGLE_PROJECT_ID}" \
+	--replication-policy="automatic" \
+	--labels="component=flightcrew"`,
+			Undo: `gcloud secrets delete "${TOKEN_SECRET}" \
+	--project="${GOOGLE_PROJECT_ID}" \
+	--quiet`,
+		}),
+		checkVersion,
+		command.NewWriteModel(command.Opts{
+			SkipIfSucceed: checkVersion,
+			Description:   "This command adds the API token to the secret from `${API_TOKEN_FILE}`, which only you can read, so that the token isn't in the command.",
+			Command: `gcloud secrets versions add "${TOKEN_SECRET}" \
+	--project="${GOOGLE_PROJECT_ID}" \
+	--data-file="${API_TOKEN_FILE}"`,
+		}),
+		checkAccessor,
+		command.NewWriteModel(command.Opts{
+			SkipIfSucceed: checkAccessor,
+			Description: `This command lets the service account read the secret, so that the Tower can read the API token from it.
+
+https://cloud.google.com/secret-manager/docs/manage-access-to-secrets`,
+			Command: `gcloud secrets add-iam-policy-binding "${TOKEN_SECRET}" \
+	--project="${GOOGLE_PROJECT_ID}" \
+	--member=serviceAccount:"${SERVICE_ACCOUNT}@${GOOGLE_PROJECT_ID}.iam.gserviceaccount.com" \
+	--role="roles/secretmanager.secretAccessor"`,
+			Undo: `gcloud secrets remove-iam-policy-binding "${TOKEN_SECRET}" \
+	--project="${GOOGLE_PROJECT_ID}" \
+	--member=serviceAccount:"${SERVICE_ACCOUNT}@${GOOGLE_PROJECT_ID}.iam.gserviceaccount.com" \
+	--role="roles/secretmanager.secretAccessor"`,
+		}),
+	}
+}
+
+// getPrivateNetworkCommands let a VM without an external IP reach Artifact Registry and the
+// Flightcrew API through a Cloud NAT, and be SSHed into through IAP. A NAT that the network
+// already has in the region is used as is.
+func getPrivateNetworkCommands(args map[string]string) []*command.Model {
+	if args[gconst.KeyExternalIP] != gconst.ExternalIPNone {
+		return nil
+	}
+
+	networkProject, networkName := gcp.NetworkLocation(args[gconst.KeyNetwork], args[gconst.KeyProject])
+	replacer := strings.NewReplacer(
+		"${NETWORK_PROJECT}", networkProject,
+		"${NETWORK_NAME}", networkName,
+		"${SUBNET_NAME}", gcp.SubnetName(args[gconst.KeySubnet], args[gconst.KeyNetwork]),
+		"${REGION}", gcp.ZoneRegion(args[gconst.KeyZone]),
+		"${ROUTER}", gcp.NATRouterName,
+		"${NAT}", gcp.NATName,
+		"${FIREWALL_RULE}", gcp.IAPFirewallName,
+		"${IAP_RANGE}", gcp.IAPSourceRange,
+	)
+
+	checkNATCommand := `gcloud compute routers list --project="${NETWORK_PROJECT}" --regions="${REGION}" --filter='network~/networks/${NETWORK_NAME}$ AND nats:*' --format="value(name)" | grep --quiet .`
+	checkNAT := command.NewReadModel(command.Opts{
+		Description: "Check if the `${NETWORK_NAME}` network already has a Cloud NAT in ${REGION}, or one needs to be created.",
+		Command:     checkNATCommand,
+		Message: map[command.State]string{
+			command.PassState: "The network already has a Cloud NAT.",
+			command.FailState: "No Cloud NAT found. Next step is to create one.",
+		},
+	})
+	// The router is only needed if there is no NAT yet.
+	checkRouter := command.NewReadModel(command.Opts{
+		Description: "Check if the network already has a Cloud NAT, or the Flightcrew Cloud Router for it already exists.",
+		Command:     checkNATCommand + ` || gcloud compute routers describe "${ROUTER}" --project="${NETWORK_PROJECT}" --region="${REGION}" >/dev/null 2>&1`,
+		Message: map[command.State]string{
+			command.PassState: "No Cloud Router is needed, or it already exists.",
+			command.FailState: "No Cloud Router found. Next step is to create one.",
+		},
+	})
+	checkFirewall := command.NewReadModel(command.Opts{
+		Description: "Check if the firewall rule that lets IAP SSH into the VM already exists or needs to be created.",
+		Command:     `gcloud compute firewall-rules describe "${FIREWALL_RULE}" --project="${NETWORK_PROJECT}" >/dev/null 2>&1`,
+		Message: map[command.State]string{
+			command.PassState: "The firewall rule already exists.",
+			command.FailState: "No firewall rule found. Next step is to create it.",
+		},
+	})
+
+	commands := []*command.Model{
+		checkNAT,
+		checkRouter,
+		command.NewWriteModel(command.Opts{
+			SkipIfSucceed: checkRouter,
+			Description:   "This command creates a Cloud Router for the Cloud NAT, since the VM has no external IP.\n\nhttps://cloud.google.com/nat/docs/gce-example",
+			Command: `gcloud compute routers create "${ROUTER}" \
+	--project="${NETWORK_PROJECT}" \
+	--network="${NETWORK_NAME}" \
+	--region="${REGION}"`,
+			Undo: `gcloud compute routers delete "${ROUTER}" \
+	--project="${NETWORK_PROJECT}" \
+	--region="${REGION}" \
+	--quiet`,
+		}),
+		command.NewWriteModel(command.Opts{
+			SkipIfSucceed: checkNAT,
+			Description:   "This command creates a Cloud NAT for the VM's `${SUBNET_NAME}` subnetwork, so that the VM can pull the Control Tower image from Artifact Registry and reach the Flightcrew API without an external IP. The other subnetworks in the region are left alone.\n\nhttps://cloud.google.com/nat/docs/overview",
+			Command: `gcloud compute routers nats create "${NAT}" \
+	--project="${NETWORK_PROJECT}" \
+	--router="${ROUTER}" \
+	--region="${REGION}" \
+	--auto-allocate-nat-external-ips \
+	--nat-custom-subnet-ip-ranges="${SUBNET_NAME}"`,
+			Undo: `gcloud compute routers nats delete "${NAT}" \
+	--project="${NETWORK_PROJECT}" \
+	--router="${ROUTER}" \
+	--region="${REGION}" \
+	--quiet`,
+		}),
+		checkFirewall,
+		command.NewWriteModel(command.Opts{
+			SkipIfSucceed: checkFirewall,
+			Description:   "This command lets IAP's TCP forwarding SSH into the VMs that run as Flightcrew's service account, since they can't be reached without an external IP.\n\nhttps://cloud.google.com/iap/docs/using-tcp-forwarding",
+			Command: `gcloud compute firewall-rules create "${FIREWALL_RULE}" \
+	--project="${NETWORK_PROJECT}" \
+	--network="${NETWORK_NAME}" \
+	--direction=INGRESS \
+	--action=allow \
+	--rules=tcp:22 \
+	--source-ranges="${IAP_RANGE}" \
+	--target-service-accounts="${SERVICE_ACCOUNT}@${GOOGLE_PROJECT_ID}.iam.gserviceaccount.com"`,
+			Undo: `gcloud compute firewall-rules delete "${FIREWALL_RULE}" \
+	--project="${NETWORK_PROJECT}" \
+	--quiet`,
+		}),
+	}
+	for _, cmd := range commands {
+		cmd.Replace(replacer)
+	}
+	return commands
+}
+
 func getVMCommands(args map[string]string) []*command.Model {
+	// The token is either passed in directly, or read from the secret by the startup script,
+	// which reruns the container with it.
+	tokenEnv := `
+	--container-env="FC_API_KEY=${API_TOKEN}" \`
+	metadataFlags := `--metadata=google-logging-enabled=false,startup-script=$'#!/bin/bash\n'` +
+		`$'docker system prune -af\n'` +
+		`$'docker run -d -v /var/run/docker.sock:/var/run/docker.sock containrrr/watchtower --interval 300 --cleanup --include-restarting'`
+	metadataDescription := `Disable the VM's builtin logger because it has a memory leak and allow the image to auto-update.`
+	if len(args[gconst.KeyTokenSecret]) > 0 {
+		tokenEnv = ""
+		metadataFlags = `--metadata=google-logging-enabled=false,` + gcp.TokenSecretMetadataKey + `=` + gcp.TokenSecretRef("${GOOGLE_PROJECT_ID}", "${TOKEN_SECRET}") + ` ` +
+			`--metadata-from-file=startup-script="${STARTUP_SCRIPT_FILE}"`
+		metadataDescription = `Disable the VM's builtin logger because it has a memory leak, run the Control Tower with the API token from the secret, and allow the image to auto-update.`
+	}
+	var addressFlag string
+	if args[gconst.KeyExternalIP] == gconst.ExternalIPNone {
+		addressFlag = fmtForReplace("--no-address")
+	}
+
 	checkVMExists := command.NewReadModel(command.Opts{
+		Check:       gcp.CheckInstanceExists(args[gconst.KeyProject], args[gconst.KeyZone], args[gconst.KeyVirtualMachine]),
 		Description: "Check if a Flightcrew VM already exists or needs to be created.",
 		Command: `gcloud compute instances list --format="csv(NAME,EXTERNAL_IP,STATUS)" \${PROJECT_OR_ORG_FLAG}
 	--zones=${ZONE} | awk -F "," "/${VIRTUAL_MACHINE}/ {print f(2), f(3)} function f(n){return (\$n==\"\" ? \"null\" : \$n)}" | [ $(wc -c) -gt "0" ]`,
@@ -195,30 +599,30 @@ func getVMCommands(args map[string]string) []*command.Model {
 	--project=${GOOGLE_PROJECT_ID} \
 	--container-command="/ko-app/tower" \
 	--container-image="${IMAGE_PATH}:${TOWER_VERSION}" \
-	--container-arg="--debug=true" \
-	--container-env="FC_API_KEY=${API_TOKEN}" \
+	--container-arg="--debug=true" \` + tokenEnv + `
 	--container-env="CLOUD_PLATFORM=${PLATFORM}" \${TRAFFIC_ROUTER}${GAE_MAX_VERSION_COUNT}${GAE_MAX_VERSION_AGE}
 	--container-env="FC_PACKAGE_VERSION=${TOWER_VERSION}" \
 	--container-env="METRIC_PROVIDERS=stackdriver" \
 	--container-env="FC_RPC_CONNECT_HOST=${RPC_HOST}" \
 	--container-env="FC_RPC_CONNECT_PORT=443" \
 	--container-env="FC_TOWER_PORT=8080" \
-	--labels="component=flightcrew" \
-	--machine-type="e2-micro" \
+	--labels="component=flightcrew${LABELS}" \
+	--machine-type="${MACHINE_TYPE}" \${BOOT_DISK_SIZE}${BOOT_DISK_TYPE}${NETWORK}${SUBNET}${NETWORK_TAGS}` + addressFlag + `
 	--scopes="cloud-platform" \
 	--service-account="${SERVICE_ACCOUNT}@${GOOGLE_PROJECT_ID}.iam.gserviceaccount.com" \
-	--tags="http-server" \
 	--zone="${ZONE}"`,
+			Undo: `gcloud compute instances delete ${VIRTUAL_MACHINE} \
+	--project=${GOOGLE_PROJECT_ID} \
+	--zone=${ZONE} \
+	--quiet`,
 		}),
 		command.NewWriteModel(command.Opts{
 			SkipIfSucceed: checkVMExists,
 			Command: `gcloud compute instances add-metadata ${VIRTUAL_MACHINE} ` +
 				`--project=${GOOGLE_PROJECT_ID} ` +
 				`--zone=${ZONE} ` +
-				`--metadata=google-logging-enabled=false,startup-script=$'#!/bin/bash\n'` +
-				`$'docker system prune -af\n'` +
-				`$'docker run -d -v /var/run/docker.sock:/var/run/docker.sock containrrr/watchtower --interval 300 --cleanup --include-restarting'`,
-			Description: `Disable the VM's builtin logger because it has a memory leak and allow the image to auto-update.
+				metadataFlags,
+			Description: metadataDescription + `
 
 https://serverfault.com/questions/980569/disable-fluentd-on-on-container-optimized-os-gce`,
 		}),
@@ -230,3 +634,7 @@ gcloud compute instances start ${VIRTUAL_MACHINE} --project=${GOOGLE_PROJECT_ID}
 		}),
 	}
 }
+
+func serviceAccountEmail(args map[string]string) string {
+	return fmt.Sprintf("%s@%s.iam.gserviceaccount.com", args[gconst.KeyIAMServiceAccount], args[gconst.KeyProject])
+}
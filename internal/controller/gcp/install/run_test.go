@@ -0,0 +1,821 @@
+package gcpinstall
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"flightcrew.io/cli/internal/apitoken"
+	"flightcrew.io/cli/internal/constants"
+	"flightcrew.io/cli/internal/controller/gcp"
+	gconst "flightcrew.io/cli/internal/controller/gcp/constants"
+	"flightcrew.io/cli/internal/runner"
+	"flightcrew.io/cli/internal/view"
+	"flightcrew.io/cli/internal/view/wrapinput"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInstallFlow(mainT *testing.T) {
+	keepARS := gcp.ArtifactRegistryService
+	gcp.ArtifactRegistryService = nil
+	keepRunner := runner.Default
+	keepEndpoint := apitoken.Endpoint
+	keepRMEndpoint := gcp.ResourceManagerEndpoint
+	mainT.Cleanup(func() {
+		gcp.ArtifactRegistryService = keepARS
+		runner.Default = keepRunner
+		apitoken.Endpoint = keepEndpoint
+		gcp.ResourceManagerEndpoint = keepRMEndpoint
+	})
+
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "Bearer revoked-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(`{"organizationName": "Acme"}`))
+	}))
+	mainT.Cleanup(tokenServer.Close)
+	apitoken.Endpoint = tokenServer.URL
+
+	// testIamPermissions answers with grantedPermissions for the resource, or every permission
+	// by default, if it's called with gcloud's access token.
+	var grantedPermissions map[string]string
+	rmServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer gcloud-access-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		resource := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/v1/"), ":testIamPermissions")
+		if granted, ok := grantedPermissions[resource]; ok {
+			_, _ = w.Write([]byte(granted))
+			return
+		}
+		_, _ = w.Write([]byte(`{"permissions": ["iam.roles.create"]}`))
+	}))
+	mainT.Cleanup(rmServer.Close)
+	gcp.ResourceManagerEndpoint = rmServer.URL + "/"
+	accessToken := runner.Response{Match: `^gcloud auth print-access-token$`, Stdout: "gcloud-access-token\n"}
+
+	// The Compute Engine and IAM APIs are enabled in the project.
+	enabledServices := runner.Response{Match: `^gcloud services list --enabled --project="my-project" --filter="config\.name:\(compute`, Stdout: "compute.googleapis.com\niam.googleapis.com\n"}
+
+	// The roles that were just created have the permissions from their definition.
+	createdRole := runner.Response{Match: `(?s)^gcloud iam roles describe .*--format="value\(`}
+
+	newParams := func(t *testing.T) Params {
+		return Params{
+			args: map[string]string{
+				gconst.KeyProject:           "my-project",
+				gconst.KeyTowerVersion:      "1.2.3",
+				gconst.KeyAPIToken:          "token",
+				gconst.KeyVirtualMachine:    "flightcrew-control-tower",
+				gconst.KeyZone:              "us-central1-c",
+				gconst.KeyIAMServiceAccount: "flightcrew-runner",
+				gconst.KeyPermissions:       constants.Read,
+				gconst.KeyPlatform:          constants.KeyToDisplay[constants.GoogleComputeEngineKey],
+			},
+			tempDir: t.TempDir(),
+		}
+	}
+
+	mainT.Run("fresh project should create everything", func(t *testing.T) {
+		scripted, err := runner.NewScripted(
+			runner.Response{Match: `^gcloud projects list`, Stdout: "project_id\nmy-project\n"},
+			enabledServices,
+			runner.Response{Match: `^gcloud projects get-ancestors`},
+			createdRole,
+			runner.Response{Match: `^gcloud iam roles describe`, ExitCode: 1},
+			runner.Response{Match: `^gcloud iam service-accounts describe`, ExitCode: 1},
+			runner.Response{Match: `^gcloud projects get-iam-policy`, ExitCode: 1},
+			runner.Response{Match: `^gcloud compute instances list`, ExitCode: 1},
+			runner.Response{Match: `^nc `},
+			runner.Response{Match: `^gcloud `},
+		)
+		require.NoError(t, err)
+		runner.Default = scripted
+
+		var out bytes.Buffer
+		require.NoError(t, view.RunHeadless(NewInputsController(newParams(t)), &out))
+
+		assertCalls(t, []string{
+			`^gcloud iam roles create flightcrew\.gce\.read\.only --project=my-project`,
+			`^gcloud iam service-accounts create "flightcrew-runner"`,
+			`^gcloud projects add-iam-policy-binding "my-project"`,
+			`^gcloud compute instances create-with-container flightcrew-control-tower .* --labels="component=flightcrew" --machine-type="e2-micro" --tags="http-server" --scopes=`,
+			`^gcloud compute instances add-metadata flightcrew-control-tower`,
+			`^gcloud compute instances stop flightcrew-control-tower`,
+		}, scripted.Calls)
+		assert.Contains(t, stripANSI(out.String()), "Your VM is available and running!")
+		for _, call := range scripted.Calls {
+			assert.NotRegexp(t, `^gcloud iam roles (undelete|update)`, call)
+		}
+	})
+
+	mainT.Run("plan should read the token from an env var", func(t *testing.T) {
+		scripted, err := runner.NewScripted(
+			runner.Response{Match: `^gcloud projects list`, Stdout: "project_id\nmy-project\n"},
+			enabledServices,
+			runner.Response{Match: `^gcloud `},
+		)
+		require.NoError(t, err)
+		runner.Default = scripted
+
+		params := newParams(t)
+		params.args[gconst.KeyAPIToken] = "my-secret-token"
+		var plan bytes.Buffer
+		require.NoError(t, view.WritePlan(NewInputsController(params), &plan))
+		assert.NotContains(t, plan.String(), "my-secret-token")
+		assert.Contains(t, plan.String(), `: "${FLIGHTCREW_API_TOKEN:?set FLIGHTCREW_API_TOKEN to run the commands below}"`)
+		assert.Contains(t, plan.String(), `--container-env="FC_API_KEY=${FLIGHTCREW_API_TOKEN}"`)
+	})
+
+	mainT.Run("existing resources should be skipped", func(t *testing.T) {
+		scripted, err := runner.NewScripted(
+			runner.Response{Match: `^gcloud projects list`, Stdout: "project_id\nmy-project\n"},
+			enabledServices,
+			runner.Response{Match: `^gcloud projects get-ancestors`, Stdout: "1234567890\n"},
+			runner.Response{Match: `^gcloud (iam roles describe|iam service-accounts describe|projects get-iam-policy|compute instances list)`},
+			runner.Response{Match: `^nc `, ExitCode: 1},
+		)
+		require.NoError(t, err)
+		runner.Default = scripted
+
+		var out bytes.Buffer
+		require.NoError(t, view.RunHeadless(NewInputsController(newParams(t)), &out))
+
+		for _, call := range scripted.Calls {
+			assert.NotRegexp(t, `^gcloud \S+ (roles create|service-accounts create|add-iam-policy-binding|instances create-with-container)`, call)
+		}
+		assert.Contains(t, stripANSI(out.String()), "Your VM is still starting up.")
+	})
+
+	mainT.Run("end should check the VM's SSH port in the project", func(t *testing.T) {
+		scripted, err := runner.NewScripted(runner.Response{Match: `^nc `, ExitCode: 1})
+		require.NoError(t, err)
+		runner.Default = scripted
+
+		endCtl := NewEndController(nil, strings.NewReplacer(
+			gconst.KeyProject, "my-project",
+			gconst.KeyZone, "us-central1-a",
+			gconst.KeyVirtualMachine, "flightcrew-control-tower",
+		), false)
+		assert.Contains(t, stripANSI(endCtl.EndDescription()), "Your VM is still starting up.")
+		require.Len(t, scripted.Calls, 1)
+		assert.Regexp(t, `^nc -w 1 -z \$\(gcloud compute instances list .* --project=my-project --zones=us-central1-a \| awk .*/flightcrew-control-tower/.*\) 22$`, scripted.Calls[0])
+
+		scripted, err = runner.NewScripted(runner.Response{Match: `^nc `})
+		require.NoError(t, err)
+		runner.Default = scripted
+		assert.Contains(t, stripANSI(endCtl.EndDescription()), "Your VM is available and running!")
+
+		// Once the VM is up, it isn't checked again.
+		endCtl.EndDescription()
+		assert.Len(t, scripted.Calls, 1)
+	})
+
+	mainT.Run("monitored projects should be bound to the roles too", func(t *testing.T) {
+		scripted, err := runner.NewScripted(
+			runner.Response{Match: `^gcloud projects list`, Stdout: "project_id\nmy-project\n"},
+			enabledServices,
+			runner.Response{Match: `^gcloud projects get-ancestors (my-project|other-project) `, Stdout: "1234567890\n"},
+			runner.Response{Match: `^gcloud projects get-ancestors`},
+			createdRole,
+			runner.Response{Match: `^gcloud iam roles describe`, ExitCode: 1},
+			runner.Response{Match: `^gcloud (iam service-accounts describe|compute instances list)`},
+			runner.Response{Match: `^gcloud projects get-iam-policy`, ExitCode: 1},
+			runner.Response{Match: `^nc `, ExitCode: 1},
+			runner.Response{Match: `^gcloud (iam roles create|projects add-iam-policy-binding)`},
+		)
+		require.NoError(t, err)
+		runner.Default = scripted
+
+		newMonitoredParams := func() Params {
+			params := newParams(t)
+			params.args[gconst.KeyMonitoredProjects] = "other-project, no-org-project,my-project other-project"
+			return params
+		}
+
+		ctl := NewInputsController(newMonitoredParams())
+		require.NoError(t, view.ValidateInputs(ctl))
+		input := ctl.inputs[gconst.KeyMonitoredProjects]
+		assert.Equal(t, "other-project,no-org-project", input.Value())
+		assert.Contains(t, input.View(wrapinput.ViewParams{ShowValue: true}), "other-project (roles in organizations/1234567890), no-org-project (roles in projects/no-org-project)")
+
+		var out bytes.Buffer
+		require.NoError(t, view.RunHeadless(NewInputsController(newMonitoredParams()), &out))
+		assertCalls(t, []string{
+			`^gcloud iam roles create flightcrew\.gce\.read\.only --organization=1234567890`,
+			`^gcloud iam roles create flightcrew\.gce\.read\.only --project=no-org-project`,
+			`^gcloud projects add-iam-policy-binding "my-project" .* --role="organizations/1234567890/roles/flightcrew\.gce\.read\.only"`,
+			`^gcloud projects add-iam-policy-binding "other-project" --member=serviceAccount:"flightcrew-runner@my-project\.iam\.gserviceaccount\.com" --role="organizations/1234567890/roles/flightcrew\.gce\.read\.only"`,
+			`^gcloud projects add-iam-policy-binding "no-org-project" .* --role="projects/no-org-project/roles/flightcrew\.gce\.read\.only"`,
+		}, scripted.Calls)
+
+		creates := 0
+		for _, call := range scripted.Calls {
+			if strings.HasPrefix(call, "gcloud iam roles create") {
+				creates++
+			}
+		}
+		assert.Equal(t, 2, creates)
+	})
+
+	mainT.Run("invalid monitored project should fail validation", func(t *testing.T) {
+		scripted, err := runner.NewScripted(
+			runner.Response{Match: `^gcloud projects list`, Stdout: "project_id\nmy-project\n"},
+			enabledServices,
+			runner.Response{Match: `^gcloud projects get-ancestors`},
+		)
+		require.NoError(t, err)
+		runner.Default = scripted
+
+		params := newParams(t)
+		params.args[gconst.KeyMonitoredProjects] = "other-project,Not_A_Project"
+		err = view.ValidateInputs(NewInputsController(params))
+		assert.ErrorContains(t, err, "Monitored Projects: `Not_A_Project` is not a valid project ID")
+	})
+
+	mainT.Run("token should show its organization", func(t *testing.T) {
+		scripted, err := runner.NewScripted(
+			runner.Response{Match: `^gcloud projects list`, Stdout: "project_id\nmy-project\n"},
+			enabledServices,
+			runner.Response{Match: `^gcloud projects get-ancestors`},
+		)
+		require.NoError(t, err)
+		runner.Default = scripted
+
+		ctl := NewInputsController(newParams(t))
+		require.NoError(t, view.ValidateInputs(ctl))
+		assert.Contains(t, ctl.inputs[gconst.KeyAPIToken].View(wrapinput.ViewParams{ShowValue: true}), "belongs to organization 'Acme'")
+	})
+
+	mainT.Run("revoked token should fail validation", func(t *testing.T) {
+		scripted, err := runner.NewScripted(
+			runner.Response{Match: `^gcloud projects list`, Stdout: "project_id\nmy-project\n"},
+			enabledServices,
+			runner.Response{Match: `^gcloud projects get-ancestors`},
+		)
+		require.NoError(t, err)
+		runner.Default = scripted
+
+		params := newParams(t)
+		params.args[gconst.KeyAPIToken] = "revoked-token"
+		err = view.ValidateInputs(NewInputsController(params))
+		assert.ErrorContains(t, err, "API Token: the token is invalid or has been revoked")
+	})
+
+	mainT.Run("unreachable API should still accept the token", func(t *testing.T) {
+		scripted, err := runner.NewScripted(
+			runner.Response{Match: `^gcloud projects list`, Stdout: "project_id\nmy-project\n"},
+			enabledServices,
+			runner.Response{Match: `^gcloud projects get-ancestors`},
+		)
+		require.NoError(t, err)
+		runner.Default = scripted
+
+		offline := httptest.NewServer(http.NotFoundHandler())
+		offline.Close()
+		apitoken.Endpoint = offline.URL
+		defer func() { apitoken.Endpoint = tokenServer.URL }()
+
+		ctl := NewInputsController(newParams(t))
+		require.NoError(t, view.ValidateInputs(ctl))
+		assert.Contains(t, ctl.inputs[gconst.KeyAPIToken].View(wrapinput.ViewParams{ShowValue: true}), "couldn't verify the token with Flightcrew")
+	})
+
+	mainT.Run("project should default to gcloud's project", func(t *testing.T) {
+		t.Setenv("CLOUDSDK_CORE_PROJECT", "")
+		t.Setenv("GOOGLE_CLOUD_PROJECT", "")
+		scripted, err := runner.NewScripted(
+			runner.Response{Match: `^gcloud config get-value project`, Stdout: "other-project\n"},
+			runner.Response{Match: `^gcloud projects list`, Stdout: "project_id,name\nmy-project,Mine\nother-project,Other\n"},
+		)
+		require.NoError(t, err)
+		runner.Default = scripted
+
+		params := newParams(t)
+		delete(params.args, gconst.KeyProject)
+		ctl := NewInputsController(params)
+		input := ctl.inputs[gconst.KeyProject]
+		require.NotNil(t, input.Select)
+		assert.Equal(t, "other-project", input.Value())
+	})
+
+	mainT.Run("project without the APIs should fail validation", func(t *testing.T) {
+		scripted, err := runner.NewScripted(
+			runner.Response{Match: `^gcloud projects list`, Stdout: "project_id\nmy-project\n"},
+			runner.Response{Match: `^gcloud services list --enabled`, Stdout: "iam.googleapis.com\n"},
+			runner.Response{Match: `^gcloud projects describe "missing-project"`, ExitCode: 1},
+		)
+		require.NoError(t, err)
+		runner.Default = scripted
+
+		err = view.ValidateInputs(NewInputsController(newParams(t)))
+		assert.ErrorContains(t, err, "Project ID: the Compute Engine API isn't enabled; run `gcloud services enable compute.googleapis.com --project=my-project`")
+
+		params := newParams(t)
+		params.args[gconst.KeyProject] = "missing-project"
+		err = view.ValidateInputs(NewInputsController(params))
+		assert.ErrorContains(t, err, "Project ID: project 'missing-project' doesn't exist or you don't have access to it")
+	})
+
+	zonesCSV := "name,region,status\nus-central1-a,us-central1,UP\nus-central1-c,us-central1,UP\neurope-west1-b,europe-west1,UP\neurope-west1-c,europe-west1,DOWN\n"
+
+	mainT.Run("zone should be picked from the project's zones", func(t *testing.T) {
+		scripted, err := runner.NewScripted(
+			runner.Response{Match: `^gcloud projects list`, Stdout: "project_id\nmy-project\n"},
+			enabledServices,
+			runner.Response{Match: `^gcloud projects get-ancestors`},
+			runner.Response{Match: `^gcloud compute zones list --project="my-project"`, Stdout: zonesCSV},
+		)
+		require.NoError(t, err)
+		runner.Default = scripted
+
+		ctl := NewInputsController(newParams(t))
+		input := ctl.inputs[gconst.KeyZone]
+		require.NotNil(t, input.Select)
+		assert.Equal(t, "us-central1-c", input.Value())
+		require.NoError(t, view.ValidateInputs(ctl))
+
+		params := newParams(t)
+		params.args[gconst.KeyZone] = "europe-west1-c"
+		err = view.ValidateInputs(NewInputsController(params))
+		assert.ErrorContains(t, err, "Zone: zone `europe-west1-c` is DOWN, so VMs can't be created in it")
+
+		params = newParams(t)
+		params.args[gconst.KeyZone] = "mars-north1-a"
+		err = view.ValidateInputs(NewInputsController(params))
+		assert.ErrorContains(t, err, "Zone: zone `mars-north1-a` doesn't exist in project 'my-project'")
+	})
+
+	mainT.Run("zone near the App Engine app should be suggested", func(t *testing.T) {
+		scripted, err := runner.NewScripted(
+			runner.Response{Match: `^gcloud projects list`, Stdout: "project_id\nmy-project\n"},
+			enabledServices,
+			runner.Response{Match: `^gcloud projects get-ancestors`},
+			runner.Response{Match: `^gcloud compute zones list`, Stdout: zonesCSV},
+			runner.Response{Match: `^gcloud app describe --project="my-project"`, Stdout: "europe-west\n"},
+		)
+		require.NoError(t, err)
+		runner.Default = scripted
+
+		params := newParams(t)
+		params.args[gconst.KeyPlatform] = constants.KeyToDisplay[constants.GoogleAppEngineStdKey]
+		ctl := NewInputsController(params)
+		require.NoError(t, view.ValidateInputs(ctl))
+		assert.Contains(t, ctl.inputs[gconst.KeyZone].View(wrapinput.ViewParams{ShowValue: true}), "the App Engine app is in europe-west1, so europe-west1-b is closer")
+	})
+
+	mainT.Run("vm shape should be passed to create-with-container", func(t *testing.T) {
+		scripted, err := runner.NewScripted(
+			runner.Response{Match: `^gcloud projects list`, Stdout: "project_id\nmy-project\n"},
+			enabledServices,
+			runner.Response{Match: `^gcloud projects get-ancestors`},
+			runner.Response{Match: `^gcloud compute machine-types list --project="my-project" --zones="us-central1-c"`, Stdout: "name,guest_cpus,memory_mb\ne2-micro,2,1024\ne2-small,2,2048\n"},
+			createdRole,
+			runner.Response{Match: `^gcloud iam roles describe`, ExitCode: 1},
+			runner.Response{Match: `^gcloud (iam service-accounts describe|projects get-iam-policy|compute instances list)`, ExitCode: 1},
+			runner.Response{Match: `^nc `},
+			runner.Response{Match: `^gcloud `},
+		)
+		require.NoError(t, err)
+		runner.Default = scripted
+
+		newShapeParams := func() Params {
+			params := newParams(t)
+			params.args[gconst.KeyMachineType] = "e2-small"
+			params.args[gconst.KeyBootDiskSize] = "20"
+			params.args[gconst.KeyBootDiskType] = "pd-ssd"
+			params.args[gconst.KeyNetwork] = "projects/host-project/global/networks/shared-vpc"
+			params.args[gconst.KeySubnet] = "projects/host-project/regions/us-central1/subnetworks/tower"
+			params.args[gconst.KeyNetworkTags] = ""
+			params.args[gconst.KeyLabels] = "team=platform, env=prod"
+			return params
+		}
+
+		ctl := NewInputsController(newShapeParams())
+		require.NoError(t, view.ValidateInputs(ctl))
+		assert.Contains(t, ctl.inputs[gconst.KeyMachineType].View(wrapinput.ViewParams{ShowValue: true}), "2 vCPUs, 2 GB memory")
+		recreate := ctl.RecreateCommand()
+		for _, flag := range []string{"--machine-type=e2-small", "--boot-disk-size=20", "--boot-disk-type=pd-ssd", "--network=projects/host-project/global/networks/shared-vpc", "--subnet=projects/host-project/regions/us-central1/subnetworks/tower", "--tags= ", "--labels=team=platform,env=prod"} {
+			assert.Contains(t, recreate+" ", flag)
+		}
+
+		var out bytes.Buffer
+		require.NoError(t, view.RunHeadless(NewInputsController(newShapeParams()), &out))
+		assertCalls(t, []string{
+			`^gcloud compute instances create-with-container .* --labels="component=flightcrew,team=platform,env=prod" --machine-type="e2-small" --boot-disk-size="20" --boot-disk-type="pd-ssd" --network="projects/host-project/global/networks/shared-vpc" --subnet="projects/host-project/regions/us-central1/subnetworks/tower" --scopes=`,
+		}, scripted.Calls)
+		for _, call := range scripted.Calls {
+			assert.NotContains(t, call, "--tags")
+		}
+
+		params := newParams(t)
+		params.args[gconst.KeyMachineType] = "e2-huge"
+		err = view.ValidateInputs(NewInputsController(params))
+		assert.ErrorContains(t, err, "Machine Type: machine type `e2-huge` isn't available in zone `us-central1-c`")
+	})
+
+	mainT.Run("vm without an external IP should get a NAT and IAP access", func(t *testing.T) {
+		scripted, err := runner.NewScripted(
+			runner.Response{Match: `^gcloud projects list`, Stdout: "project_id\nmy-project\n"},
+			enabledServices,
+			runner.Response{Match: `^gcloud projects get-ancestors`},
+			createdRole,
+			runner.Response{Match: `^gcloud iam roles describe`, ExitCode: 1},
+			runner.Response{Match: `^gcloud (iam service-accounts describe|projects get-iam-policy|compute instances list)`, ExitCode: 1},
+			runner.Response{Match: `^gcloud compute (routers list|routers describe|firewall-rules describe)`, ExitCode: 1},
+			runner.Response{Match: `^gcloud compute instances describe flightcrew-control-tower .*--format="value\(status\)"`},
+			runner.Response{Match: `^gcloud `},
+		)
+		require.NoError(t, err)
+		runner.Default = scripted
+
+		newPrivateParams := func() Params {
+			params := newParams(t)
+			params.args[gconst.KeyExternalIP] = gconst.ExternalIPNone
+			params.args[gconst.KeyNetwork] = "projects/host-project/global/networks/shared-vpc"
+			params.args[gconst.KeySubnet] = "projects/host-project/regions/us-central1/subnetworks/tower-subnet"
+			return params
+		}
+
+		ctl := NewInputsController(newPrivateParams())
+		require.NoError(t, view.ValidateInputs(ctl))
+		assert.Contains(t, ctl.inputs[gconst.KeyExternalIP].View(wrapinput.ViewParams{ShowValue: true}), "Cloud NAT for subnetwork 'tower-subnet' in us-central1 and the IAP firewall rule are set up in network 'shared-vpc' of project 'host-project'")
+		assert.Contains(t, ctl.RecreateCommand()+" ", " --no-external-ip=true ")
+
+		var out bytes.Buffer
+		require.NoError(t, view.RunHeadless(NewInputsController(newPrivateParams()), &out))
+		assertCalls(t, []string{
+			`^gcloud compute routers create "flightcrew-router" --project="host-project" --network="shared-vpc" --region="us-central1"`,
+			`^gcloud compute routers nats create "flightcrew-nat" --project="host-project" --router="flightcrew-router" --region="us-central1" --auto-allocate-nat-external-ips --nat-custom-subnet-ip-ranges="tower-subnet"$`,
+			`^gcloud compute firewall-rules create "flightcrew-allow-iap-ssh" --project="host-project" --network="shared-vpc" .* --rules=tcp:22 --source-ranges="35\.235\.240\.0/20" --target-service-accounts="flightcrew-runner@my-project\.iam\.gserviceaccount\.com"`,
+			`^gcloud compute instances create-with-container .* --network="projects/host-project/global/networks/shared-vpc" --subnet="projects/host-project/regions/us-central1/subnetworks/tower-subnet" --tags="http-server" --no-address --scopes=`,
+		}, scripted.Calls)
+		for _, call := range scripted.Calls {
+			assert.NotRegexp(t, `^nc `, call)
+		}
+		assert.Contains(t, stripANSI(out.String()), "--tunnel-through-iap")
+		assert.Contains(t, stripANSI(out.String()), "Your VM is available and running!")
+
+		params := newParams(t)
+		params.args[gconst.KeyExternalIP] = gconst.ExternalIPNone
+		params.args[gconst.KeySubnet] = "tower-subnet"
+		err = view.ValidateInputs(NewInputsController(params))
+		assert.ErrorContains(t, err, "External IP: the Network is needed with a Subnetwork")
+	})
+
+	mainT.Run("existing NAT should be used as is", func(t *testing.T) {
+		scripted, err := runner.NewScripted(
+			runner.Response{Match: `^gcloud projects list`, Stdout: "project_id\nmy-project\n"},
+			enabledServices,
+			runner.Response{Match: `^gcloud projects get-ancestors`},
+			createdRole,
+			runner.Response{Match: `^gcloud iam roles describe`, ExitCode: 1},
+			runner.Response{Match: `^gcloud (iam service-accounts describe|projects get-iam-policy|compute instances list)`, ExitCode: 1},
+			runner.Response{Match: `^gcloud compute routers list --project="my-project" --regions="us-central1" --filter='network~/networks/default\$ AND nats:\*'`, Stdout: "other-router\n"},
+			runner.Response{Match: `^gcloud compute (routers|firewall-rules) (list|describe)`, ExitCode: 1},
+			runner.Response{Match: `^gcloud `},
+		)
+		require.NoError(t, err)
+		runner.Default = scripted
+
+		params := newParams(t)
+		params.args[gconst.KeyExternalIP] = gconst.ExternalIPNone
+		var out bytes.Buffer
+		require.NoError(t, view.RunHeadless(NewInputsController(params), &out))
+		for _, call := range scripted.Calls {
+			assert.NotRegexp(t, `^gcloud compute (routers create|routers nats create)`, call)
+		}
+		assertCalls(t, []string{
+			`^gcloud compute firewall-rules create "flightcrew-allow-iap-ssh" --project="my-project" --network="default"`,
+			`^gcloud compute instances create-with-container .* --no-address`,
+		}, scripted.Calls)
+	})
+
+	mainT.Run("organization scope should fall back to the project without permission", func(t *testing.T) {
+		scripted, err := runner.NewScripted(
+			runner.Response{Match: `^gcloud projects list`, Stdout: "project_id\nmy-project\n"},
+			enabledServices,
+			runner.Response{Match: `^gcloud projects get-ancestors`, Stdout: "1234567890\n"},
+			accessToken,
+			createdRole,
+			runner.Response{Match: `^gcloud iam roles describe`, ExitCode: 1},
+			runner.Response{Match: `^gcloud (iam service-accounts describe|projects get-iam-policy|compute instances list)`},
+			runner.Response{Match: `^nc `, ExitCode: 1},
+			runner.Response{Match: `^gcloud iam roles create`},
+		)
+		require.NoError(t, err)
+		runner.Default = scripted
+		grantedPermissions = map[string]string{"organizations/1234567890": `{}`}
+		t.Cleanup(func() { grantedPermissions = nil })
+
+		ctl := NewInputsController(newParams(t))
+		require.NoError(t, view.ValidateInputs(ctl))
+		for _, call := range scripted.Calls {
+			assert.NotContains(t, call, "gcloud-access-token")
+		}
+		input := ctl.inputs[gconst.KeyRoleScope]
+		assert.Equal(t, gconst.RoleScopeProject, input.Value())
+		assert.Contains(t, input.View(wrapinput.ViewParams{ShowValue: true}), "you don't have iam.roles.create in organization '1234567890', so the roles are created in the project")
+		assert.Contains(t, ctl.RecreateCommand(), "--role-scope=project")
+
+		var out bytes.Buffer
+		require.NoError(t, view.RunHeadless(NewInputsController(newParams(t)), &out))
+		assertCalls(t, []string{`^gcloud iam roles create flightcrew\.gce\.read\.only --project=my-project`}, scripted.Calls)
+	})
+
+	mainT.Run("project scope without permission should fail validation", func(t *testing.T) {
+		scripted, err := runner.NewScripted(
+			runner.Response{Match: `^gcloud projects list`, Stdout: "project_id\nmy-project\n"},
+			enabledServices,
+			runner.Response{Match: `^gcloud projects get-ancestors`, Stdout: "1234567890\n"},
+			accessToken,
+		)
+		require.NoError(t, err)
+		runner.Default = scripted
+		grantedPermissions = map[string]string{"projects/my-project": `{}`}
+		t.Cleanup(func() { grantedPermissions = nil })
+
+		params := newParams(t)
+		params.args[gconst.KeyRoleScope] = gconst.RoleScopeProject
+		err = view.ValidateInputs(NewInputsController(params))
+		assert.ErrorContains(t, err, "Role Scope: you don't have iam.roles.create in project 'my-project'")
+	})
+
+	mainT.Run("token secret should keep the token out of the commands", func(t *testing.T) {
+		scripted, err := runner.NewScripted(
+			runner.Response{Match: `^gcloud projects list`, Stdout: "project_id\nmy-project\n"},
+			enabledServices,
+			runner.Response{Match: `^gcloud projects get-ancestors`},
+			runner.Response{Match: `^gcloud (iam roles describe|iam service-accounts describe|projects get-iam-policy)`},
+			runner.Response{Match: `^gcloud (services list|secrets describe|secrets versions access|secrets get-iam-policy|compute instances list)`, ExitCode: 1},
+			runner.Response{Match: `^nc `},
+			runner.Response{Match: `^gcloud `},
+		)
+		require.NoError(t, err)
+		runner.Default = scripted
+
+		params := newParams(t)
+		params.args[gconst.KeyAPIToken] = "my-secret-token"
+		params.args[gconst.KeyTokenSecret] = "flightcrew-api-token"
+		ctl := NewInputsController(params)
+
+		var out bytes.Buffer
+		require.NoError(t, view.RunHeadless(ctl, &out))
+		assertCalls(t, []string{
+			`^gcloud services enable secretmanager\.googleapis\.com --project="my-project"`,
+			`^gcloud secrets create "flightcrew-api-token" --project="my-project"`,
+			`^gcloud secrets versions add "flightcrew-api-token" --project="my-project" --data-file="\S+/api_token"`,
+			`^gcloud secrets add-iam-policy-binding "flightcrew-api-token" --project="my-project" --member=serviceAccount:"flightcrew-runner@my-project\.iam\.gserviceaccount\.com" --role="roles/secretmanager\.secretAccessor"`,
+			`^gcloud compute instances create-with-container `,
+			`^gcloud compute instances add-metadata flightcrew-control-tower .*--metadata=google-logging-enabled=false,flightcrew-token-secret=projects/my-project/secrets/flightcrew-api-token/versions/latest --metadata-from-file=startup-script="\S+/startup_script\.sh"$`,
+		}, scripted.Calls)
+		for _, call := range scripted.Calls {
+			assert.NotContains(t, call, "my-secret-token")
+			assert.NotContains(t, call, "FC_API_KEY")
+		}
+
+		// The startup script reads the token from the secret in the VM's metadata, and runs
+		// the tower with it.
+		script, err := os.ReadFile(ctl.args[gconst.KeyStartupScriptFile])
+		require.NoError(t, err)
+		assert.Contains(t, string(script), "instance/attributes/"+gcp.TokenSecretMetadataKey)
+		assert.Contains(t, string(script), "printf 'FC_API_KEY=%s\\n' \"$FC_API_KEY\" >> \"$env_file\"")
+
+		written, err := os.ReadFile(ctl.args[gconst.KeyAPITokenFile])
+		require.NoError(t, err)
+		assert.Equal(t, "my-secret-token", string(written))
+
+		// The plan writes the token file from an env var instead of having the token in it.
+		params = newParams(t)
+		params.args[gconst.KeyAPIToken] = "my-secret-token"
+		params.args[gconst.KeyTokenSecret] = "flightcrew-api-token"
+		var plan bytes.Buffer
+		require.NoError(t, view.WritePlan(NewInputsController(params), &plan))
+		assert.Regexp(t, `\(umask 077 && printf %s "\$FLIGHTCREW_API_TOKEN" > '\S+/api_token'\)`, plan.String())
+		assert.NotContains(t, plan.String(), "my-secret-token")
+		assert.Contains(t, plan.String(), " --token=<redacted>")
+	})
+
+	mainT.Run("drifted role should be updated", func(t *testing.T) {
+		scripted, err := runner.NewScripted(
+			runner.Response{Match: `^gcloud projects list`, Stdout: "project_id\nmy-project\n"},
+			enabledServices,
+			runner.Response{Match: `^gcloud projects get-ancestors`},
+			runner.Response{Match: `^gcloud iam roles describe .*--format=json`, Stdout: `{
+				"name": "projects/my-project/roles/flightcrew.gce.read.only",
+				"includedPermissions": [
+					"compute.instances.list", "compute.zones.list", "monitoring.metricDescriptors.get", "monitoring.metricDescriptors.list",
+					"monitoring.timeSeries.list", "resourcemanager.projects.get", "storage.buckets.list"
+				]
+			}`},
+			runner.Response{Match: `(?s)^gcloud iam roles describe .*--format="value\(includedPermissions\)"`, ExitCode: 1},
+			runner.Response{Match: `^gcloud (iam roles describe|iam service-accounts describe|projects get-iam-policy|compute instances list)`},
+			runner.Response{Match: `^gcloud iam roles update`},
+			runner.Response{Match: `^nc `, ExitCode: 1},
+		)
+		require.NoError(t, err)
+		runner.Default = scripted
+
+		ctl := NewInputsController(newParams(t))
+		require.NoError(t, view.ValidateInputs(ctl))
+		info := ctl.inputs[gconst.KeyPermissions].View(wrapinput.ViewParams{ShowValue: true})
+		assert.Contains(t, info, "existing Read role will be updated: +resourcemanager.projects.list, -storage.buckets.list")
+
+		var out bytes.Buffer
+		require.NoError(t, view.RunHeadless(NewInputsController(newParams(t)), &out))
+		assertCalls(t, []string{`^gcloud iam roles describe flightcrew\.gce\.read\.only --project=my-project --format=yaml \| grep --invert-match '\^etag:' > \S+\.previous && gcloud iam roles update flightcrew\.gce\.read\.only --project=my-project --file=\S+ --quiet$`}, scripted.Calls)
+		for _, call := range scripted.Calls {
+			assert.NotRegexp(t, `^gcloud iam roles (create|undelete)`, call)
+		}
+	})
+
+	mainT.Run("deleted role should be undeleted", func(t *testing.T) {
+		scripted, err := runner.NewScripted(
+			runner.Response{Match: `^gcloud projects list`, Stdout: "project_id\nmy-project\n"},
+			enabledServices,
+			runner.Response{Match: `^gcloud projects get-ancestors`},
+			runner.Response{Match: `(?s)^gcloud iam roles describe .*--format="value\(deleted\)"`, ExitCode: 1},
+			runner.Response{Match: `^gcloud (iam roles describe|iam service-accounts describe|projects get-iam-policy|compute instances list)`},
+			runner.Response{Match: `^gcloud iam roles undelete`},
+			runner.Response{Match: `^nc `, ExitCode: 1},
+		)
+		require.NoError(t, err)
+		runner.Default = scripted
+
+		var out bytes.Buffer
+		require.NoError(t, view.RunHeadless(NewInputsController(newParams(t)), &out))
+		assertCalls(t, []string{`^gcloud iam roles undelete flightcrew\.gce\.read\.only --project=my-project`}, scripted.Calls)
+		for _, call := range scripted.Calls {
+			assert.NotRegexp(t, `^gcloud iam roles (create|update)`, call)
+		}
+	})
+
+	mainT.Run("failed write should stop the flow", func(t *testing.T) {
+		scripted, err := runner.NewScripted(
+			runner.Response{Match: `^gcloud projects list`, Stdout: "project_id\nmy-project\n"},
+			enabledServices,
+			runner.Response{Match: `^gcloud projects get-ancestors`},
+			runner.Response{Match: `^gcloud iam roles describe`, ExitCode: 1},
+			runner.Response{Match: `^gcloud iam roles create`, Stderr: "PERMISSION_DENIED", ExitCode: 1},
+		)
+		require.NoError(t, err)
+		runner.Default = scripted
+
+		var out bytes.Buffer
+		assert.Error(t, view.RunHeadless(NewInputsController(newParams(t)), &out))
+		assert.Regexp(t, `^gcloud iam roles create`, scripted.Calls[len(scripted.Calls)-1])
+		assert.Contains(t, out.String(), "To return to the same values:")
+		assert.NotContains(t, out.String(), "To roll back")
+	})
+
+	mainT.Run("failed VM should list the rollback of the changes", func(t *testing.T) {
+		scripted, err := runner.NewScripted(
+			runner.Response{Match: `^gcloud projects list`, Stdout: "project_id\nmy-project\n"},
+			enabledServices,
+			runner.Response{Match: `^gcloud projects get-ancestors`},
+			createdRole,
+			runner.Response{Match: `^gcloud iam roles describe`, ExitCode: 1},
+			runner.Response{Match: `^gcloud iam service-accounts describe`},
+			runner.Response{Match: `^gcloud projects get-iam-policy`, ExitCode: 1},
+			runner.Response{Match: `^gcloud compute instances list`, ExitCode: 1},
+			runner.Response{Match: `^gcloud compute instances create-with-container`, ExitCode: 1},
+			runner.Response{Match: `^gcloud `},
+		)
+		require.NoError(t, err)
+		runner.Default = scripted
+
+		var out bytes.Buffer
+		assert.Error(t, view.RunHeadless(NewInputsController(newParams(t)), &out))
+
+		// The service account already existed, so it isn't deleted.
+		_, rollback, found := strings.Cut(out.String(), "To roll back the changes from this run:\n")
+		require.True(t, found, out.String())
+		lines := strings.Split(rollback, "\n")
+		require.GreaterOrEqual(t, len(lines), 2)
+		assert.Regexp(t, `^gcloud projects remove-iam-policy-binding "my-project"`, lines[0])
+		assert.Regexp(t, `^gcloud iam roles delete flightcrew\.gce\.read\.only --project=my-project --quiet`, lines[1])
+		assert.NotContains(t, rollback, "service-accounts delete")
+	})
+
+	mainT.Run("failed VM should roll back the role update to its previous definition", func(t *testing.T) {
+		scripted, err := runner.NewScripted(
+			runner.Response{Match: `^gcloud projects list`, Stdout: "project_id\nmy-project\n"},
+			enabledServices,
+			runner.Response{Match: `^gcloud projects get-ancestors`},
+			runner.Response{Match: `^gcloud iam roles describe .*--format=json`, Stdout: `{"includedPermissions": ["compute.instances.list"]}`},
+			runner.Response{Match: `(?s)^gcloud iam roles describe .*--format="value\(includedPermissions\)"`, ExitCode: 1},
+			runner.Response{Match: `^gcloud (iam roles describe|iam service-accounts describe|projects get-iam-policy) `},
+			runner.Response{Match: `^gcloud compute instances list`, ExitCode: 1},
+			runner.Response{Match: `^gcloud compute instances create-with-container`, ExitCode: 1},
+			runner.Response{Match: `^gcloud `},
+		)
+		require.NoError(t, err)
+		runner.Default = scripted
+
+		var out bytes.Buffer
+		assert.Error(t, view.RunHeadless(NewInputsController(newParams(t)), &out))
+
+		_, rollback, found := strings.Cut(out.String(), "To roll back the changes from this run:\n")
+		require.True(t, found, out.String())
+		update := regexp.MustCompile(`> (\S+)\.previous && gcloud iam roles update flightcrew\.gce\.read\.only --project=my-project --file=(\S+) --quiet$`)
+		var match []string
+		for _, call := range scripted.Calls {
+			if m := update.FindStringSubmatch(call); m != nil {
+				match = m
+			}
+		}
+		require.NotNil(t, match, "role should be updated")
+		assert.Equal(t, match[1], match[2], "previous definition should be saved next to the role file")
+		assert.Regexp(t, `^gcloud iam roles update flightcrew\.gce\.read\.only --project=my-project --file=`+regexp.QuoteMeta(match[1])+`\.previous --quiet\n`, rollback)
+	})
+
+	mainT.Run("custom role file should be used to create the role", func(t *testing.T) {
+		scripted, err := runner.NewScripted(
+			runner.Response{Match: `^gcloud projects list`, Stdout: "project_id\nmy-project\n"},
+			enabledServices,
+			runner.Response{Match: `^gcloud projects get-ancestors`},
+			createdRole,
+			runner.Response{Match: `^gcloud iam roles describe`, ExitCode: 1},
+			runner.Response{Match: `^gcloud (iam service-accounts describe|projects get-iam-policy|compute instances list)`},
+			runner.Response{Match: `^nc `, ExitCode: 1},
+			runner.Response{Match: `^gcloud iam roles create`},
+		)
+		require.NoError(t, err)
+		runner.Default = scripted
+
+		params := newParams(t)
+		roleFile := filepath.Join(t.TempDir(), "role.yaml")
+		custom := "title: Flightcrew GCE (Trimmed)\nstage: GA\nincludedPermissions:\n- compute.instances.get\n- compute.instances.list\n- compute.zones.list\n"
+		require.NoError(t, os.WriteFile(roleFile, []byte(custom), 0600))
+		params.args[gconst.KeyIAMRoleFileRead] = roleFile
+
+		ctl := NewInputsController(params)
+		require.NoError(t, view.ValidateInputs(ctl))
+		info := ctl.inputs[gconst.KeyPermissions].View(wrapinput.ViewParams{ShowValue: true})
+		assert.Contains(t, info, "Read role from "+roleFile+": +compute.instances.get, -monitoring.metricDescriptors.get")
+		assert.Contains(t, info, "-resourcemanager.projects.list")
+		assert.Contains(t, ctl.RecreateCommand(), "--iam-role-file-read="+roleFile)
+
+		// Validating converts the inputs in place, so run with new params.
+		params = newParams(t)
+		params.args[gconst.KeyIAMRoleFileRead] = roleFile
+		ctl = NewInputsController(params)
+		var out bytes.Buffer
+		require.NoError(t, view.RunHeadless(ctl, &out))
+		assertCalls(t, []string{`^gcloud iam roles create flightcrew\.gce\.read\.only`}, scripted.Calls)
+
+		written, err := os.ReadFile(ctl.args[gconst.KeyIAMFileRead])
+		require.NoError(t, err)
+		assert.Equal(t, custom, string(written))
+	})
+
+	mainT.Run("invalid custom role should fail validation", func(t *testing.T) {
+		scripted, err := runner.NewScripted(
+			runner.Response{Match: `^gcloud projects list`, Stdout: "project_id\nmy-project\n"},
+			enabledServices,
+			runner.Response{Match: `^gcloud projects get-ancestors`},
+		)
+		require.NoError(t, err)
+		runner.Default = scripted
+
+		params := newParams(t)
+		roleFile := filepath.Join(t.TempDir(), "role.yaml")
+		require.NoError(t, os.WriteFile(roleFile, []byte("title: Broken\nstage: GA\n"), 0600))
+		params.args[gconst.KeyIAMRoleFileRead] = roleFile
+
+		err = view.ValidateInputs(NewInputsController(params))
+		assert.ErrorContains(t, err, "Permissions: "+roleFile+": role definition needs includedPermissions")
+	})
+}
+
+// assertCalls asserts that the write commands were run in this order. Other commands
+// may be run in between.
+func assertCalls(t *testing.T, expected []string, calls []string) {
+	t.Helper()
+
+	i := 0
+	for _, call := range calls {
+		if i < len(expected) && regexp.MustCompile(expected[i]).MatchString(call) {
+			i++
+		}
+	}
+	if i < len(expected) {
+		assert.Fail(t, "missing call", "no call matching %q in:\n%v", expected[i], calls)
+	}
+}
+
+var ansiRegexp = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+
+// stripANSI removes the styling that glamour adds to the end description.
+func stripANSI(s string) string {
+	return ansiRegexp.ReplaceAllString(s, "")
+}
@@ -0,0 +1,69 @@
+package gcpinstall
+
+// tokenSecretStartupScript runs the Control Tower with the API token from Secret Manager,
+// when the token isn't in the container declaration. konlet starts the declared container
+// with every other env when the VM boots, so the script reads the latest version of the
+// secret and reruns the same container with FC_API_KEY added. update-container restarts
+// the VM, so a new version of the secret is picked up by the next upgrade.
+//
+// The service account's access token is passed to curl on stdin instead of in its args,
+// and the env file with the API token is only readable by root and removed once the
+// container is started.
+const tokenSecretStartupScript = `#!/bin/bash
+set -euo pipefail
+
+metadata() {
+	curl --silent --fail --header "Metadata-Flavor: Google" "http://metadata.google.internal/computeMetadata/v1/$1"
+}
+
+# json_field prints a string field of the JSON object on stdin.
+json_field() {
+	tr -d '\n' | sed -n 's/.*"'"$1"'"[[:space:]]*:[[:space:]]*"\([^"]*\)".*/\1/p'
+}
+
+docker rm --force flightcrew-control-tower >/dev/null 2>&1 || true
+docker system prune -af
+
+secret="$(metadata instance/attributes/flightcrew-token-secret)"
+FC_API_KEY=""
+for attempt in 1 2 3 4 5 6 7 8 9 10; do
+	access_token="$(metadata instance/service-accounts/default/token | json_field access_token)" &&
+		FC_API_KEY="$(printf 'header = "Authorization: Bearer %s"\n' "$access_token" |
+			curl --silent --fail --config - "https://secretmanager.googleapis.com/v1/${secret}:access" |
+			json_field data | base64 --decode)" &&
+		[ -n "$FC_API_KEY" ] && break
+	sleep 10
+done
+if [ -z "$FC_API_KEY" ]; then
+	echo "could not read the API token from ${secret}" >&2
+	exit 1
+fi
+
+container=""
+for attempt in $(seq 1 30); do
+	container="$(docker ps --all --quiet --filter=name=^klt- | head -n 1)"
+	[ -n "$container" ] && break
+	sleep 10
+done
+if [ -z "$container" ]; then
+	echo "could not find the container that konlet started" >&2
+	exit 1
+fi
+
+env_file="$(mktemp /run/flightcrew-env.XXXXXX)"
+trap 'rm -f "$env_file"' EXIT
+docker inspect --format='{{range .Config.Env}}{{println .}}{{end}}' "$container" | grep --invert-match '^$' > "$env_file"
+printf 'FC_API_KEY=%s\n' "$FC_API_KEY" >> "$env_file"
+image="$(docker inspect --format='{{.Config.Image}}' "$container")"
+entrypoint="$(docker inspect --format='{{join .Config.Entrypoint " "}}' "$container")"
+read -r -a args <<< "$(docker inspect --format='{{join .Config.Cmd " "}}' "$container")"
+
+docker rm --force "$container"
+docker run --detach --name=flightcrew-control-tower --restart=always --network=host \
+	--env-file="$env_file" \
+	--entrypoint="$entrypoint" \
+	--label="component=flightcrew" \
+	"$image" "${args[@]}"
+
+docker run -d -v /var/run/docker.sock:/var/run/docker.sock containrrr/watchtower --interval 300 --cleanup --include-restarting
+`
@@ -0,0 +1,175 @@
+package gcp
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"flightcrew.io/cli/internal/runner"
+	"gopkg.in/yaml.v3"
+)
+
+const containerDeclarationKey = "gce-container-declaration"
+
+// InstanceRunning is the status of a VM instance that is up.
+const InstanceRunning = "RUNNING"
+
+// Instance is the subset of a Compute Engine VM instance that the tower cares about.
+type Instance struct {
+	Name       string
+	Status     string
+	ExternalIP string
+	InternalIP string
+	// Private is whether the VM has no access config, so it has no external IP even when
+	// it's running.
+	Private bool
+	// Network is the resource path of the first network interface's network, e.g.
+	// projects/<project>/global/networks/<name>.
+	Network         string
+	ServiceAccounts []string
+	// Container is the container declaration that `create-with-container` and
+	// `update-container` write into the instance metadata. It's nil if there is none.
+	Container *Container
+	// Metadata has the other instance metadata items, e.g. the startup script.
+	Metadata map[string]string
+}
+
+// Container is the container running on a Container-Optimized OS VM instance.
+type Container struct {
+	Image   string
+	Command []string
+	Args    []string
+	// Env is the container's environment variables in the order that they are declared.
+	Env []EnvVar
+}
+
+type EnvVar struct {
+	Name  string `yaml:"name" json:"name"`
+	Value string `yaml:"value" json:"value"`
+}
+
+// GetEnv returns the value of the container's environment variable, and whether it's set.
+func (c Container) GetEnv(name string) (string, bool) {
+	for _, env := range c.Env {
+		if env.Name == name {
+			return env.Value, true
+		}
+	}
+	return "", false
+}
+
+// GetInstance describes the VM instance.
+func GetInstance(projectID string, zone string, vmName string) (*Instance, error) {
+	if API != nil {
+		return API.GetInstance(projectID, zone, vmName)
+	}
+
+	cmdStr := fmt.Sprintf(`gcloud compute instances describe "%s" --project="%s" --zone="%s" --format=json`, vmName, projectID, zone)
+	stdout, stderr, err := runner.Output(cmdStr)
+	if err != nil {
+		return nil, fmt.Errorf("gcloud compute instances describe: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	return parseInstanceJSON(stdout.Bytes())
+}
+
+type instanceJSON struct {
+	Name              string `json:"name"`
+	Status            string `json:"status"`
+	NetworkInterfaces []struct {
+		Network       string `json:"network"`
+		NetworkIP     string `json:"networkIP"`
+		AccessConfigs []struct {
+			NatIP string `json:"natIP"`
+		} `json:"accessConfigs"`
+	} `json:"networkInterfaces"`
+	Metadata struct {
+		Items []struct {
+			Key   string `json:"key"`
+			Value string `json:"value"`
+		} `json:"items"`
+	} `json:"metadata"`
+	ServiceAccounts []struct {
+		Email string `json:"email"`
+	} `json:"serviceAccounts"`
+}
+
+type containerDeclaration struct {
+	Spec struct {
+		Containers []struct {
+			Image   string   `yaml:"image"`
+			Command []string `yaml:"command"`
+			Args    []string `yaml:"args"`
+			Env     []EnvVar `yaml:"env"`
+		} `yaml:"containers"`
+	} `yaml:"spec"`
+}
+
+func parseInstanceJSON(data []byte) (*Instance, error) {
+	var raw instanceJSON
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("parse instance: %w", err)
+	}
+
+	instance := &Instance{
+		Name:   raw.Name,
+		Status: raw.Status,
+	}
+
+	instance.Private = true
+	for _, nic := range raw.NetworkInterfaces {
+		if len(nic.AccessConfigs) > 0 {
+			instance.Private = false
+		}
+		if len(instance.InternalIP) == 0 {
+			instance.InternalIP = nic.NetworkIP
+		}
+		if i := strings.Index(nic.Network, "projects/"); len(instance.Network) == 0 && i >= 0 {
+			instance.Network = nic.Network[i:]
+		}
+		for _, access := range nic.AccessConfigs {
+			if len(instance.ExternalIP) == 0 {
+				instance.ExternalIP = access.NatIP
+			}
+		}
+	}
+
+	for _, sa := range raw.ServiceAccounts {
+		instance.ServiceAccounts = append(instance.ServiceAccounts, sa.Email)
+	}
+
+	instance.Metadata = make(map[string]string)
+	for _, item := range raw.Metadata.Items {
+		if item.Key != containerDeclarationKey {
+			instance.Metadata[item.Key] = item.Value
+			continue
+		}
+
+		container, err := parseContainerDeclaration(item.Value)
+		if err != nil {
+			return nil, err
+		}
+		instance.Container = container
+	}
+
+	return instance, nil
+}
+
+func parseContainerDeclaration(value string) (*Container, error) {
+	var decl containerDeclaration
+	if err := yaml.Unmarshal([]byte(value), &decl); err != nil {
+		return nil, fmt.Errorf("parse container declaration: %w", err)
+	}
+
+	if len(decl.Spec.Containers) == 0 {
+		return nil, nil
+	}
+
+	c := decl.Spec.Containers[0]
+	return &Container{
+		Image:   c.Image,
+		Command: c.Command,
+		Args:    c.Args,
+		Env:     c.Env,
+	}, nil
+}
@@ -0,0 +1,63 @@
+package gcp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseInstanceJSON(mainT *testing.T) {
+	mainT.Run("parse container vm should succeed", func(t *testing.T) {
+		instance, err := parseInstanceJSON([]byte(`{
+  "name": "flightcrew-control-tower",
+  "status": "RUNNING",
+  "networkInterfaces": [
+    {
+      "network": "https://www.googleapis.com/compute/v1/projects/host-project/global/networks/shared-vpc",
+      "networkIP": "10.128.0.2",
+      "accessConfigs": [{"name": "external-nat", "natIP": "34.1.2.3"}]
+    }
+  ],
+  "metadata": {
+    "items": [
+      {"key": "google-logging-enabled", "value": "false"},
+      {"key": "gce-container-declaration", "value": "spec:\n  containers:\n  - name: flightcrew-control-tower\n    image: us-west1-docker.pkg.dev/flightcrew-artifacts/client/tower:0.2.15\n    command:\n    - /ko-app/tower\n    args:\n    - --debug=true\n    env:\n    - name: FC_API_KEY\n      value: example-token-1234\n    - name: CLOUD_PLATFORM\n      value: provider:gcp/platform:appengine/type:standard\n    - name: FC_PACKAGE_VERSION\n      value: 0.2.15\n  restartPolicy: Always\n"}
+    ]
+  },
+  "serviceAccounts": [{"email": "flightcrew-runner@project-id-1234.iam.gserviceaccount.com"}]
+}`))
+		require.NoError(t, err)
+		assert.Equal(t, "flightcrew-control-tower", instance.Name)
+		assert.Equal(t, "RUNNING", instance.Status)
+		assert.Equal(t, "34.1.2.3", instance.ExternalIP)
+		assert.Equal(t, "10.128.0.2", instance.InternalIP)
+		assert.False(t, instance.Private)
+		assert.Equal(t, "projects/host-project/global/networks/shared-vpc", instance.Network)
+		assert.Equal(t, []string{"flightcrew-runner@project-id-1234.iam.gserviceaccount.com"}, instance.ServiceAccounts)
+		assert.Equal(t, map[string]string{"google-logging-enabled": "false"}, instance.Metadata)
+
+		require.NotNil(t, instance.Container)
+		assert.Equal(t, "us-west1-docker.pkg.dev/flightcrew-artifacts/client/tower:0.2.15", instance.Container.Image)
+		assert.Equal(t, []string{"/ko-app/tower"}, instance.Container.Command)
+		version, ok := instance.Container.GetEnv("FC_PACKAGE_VERSION")
+		assert.True(t, ok)
+		assert.Equal(t, "0.2.15", version)
+		_, ok = instance.Container.GetEnv("TRAFFIC_ROUTER")
+		assert.False(t, ok)
+	})
+
+	mainT.Run("parse stopped vm without container or external ip should succeed", func(t *testing.T) {
+		instance, err := parseInstanceJSON([]byte(`{"name": "vm", "status": "TERMINATED", "networkInterfaces": [{"networkIP": "10.128.0.2"}]}`))
+		require.NoError(t, err)
+		assert.Equal(t, "TERMINATED", instance.Status)
+		assert.Empty(t, instance.ExternalIP)
+		assert.True(t, instance.Private)
+		assert.Nil(t, instance.Container)
+	})
+
+	mainT.Run("parse invalid json should error", func(t *testing.T) {
+		_, err := parseInstanceJSON([]byte(`ERROR: imagine something went wrong`))
+		assert.Error(t, err)
+	})
+}
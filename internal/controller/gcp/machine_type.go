@@ -0,0 +1,87 @@
+package gcp
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"flightcrew.io/cli/internal/runner"
+)
+
+// DefaultMachineType is the shape of the tower's VM if none is picked. The tower is small
+// enough to run on the smallest shared-core machine.
+const DefaultMachineType = "e2-micro"
+
+// MachineType is a Compute Engine machine type that VMs can be created with.
+type MachineType struct {
+	Name     string
+	CPUs     int64
+	MemoryMB int64
+}
+
+func (t MachineType) String() string {
+	cpus := "vCPUs"
+	if t.CPUs == 1 {
+		cpus = "vCPU"
+	}
+	return fmt.Sprintf("%d %s, %s GB memory", t.CPUs, cpus, strconv.FormatFloat(float64(t.MemoryMB)/1024, 'f', -1, 64))
+}
+
+// ListMachineTypes returns the machine types that are available in the zone.
+func ListMachineTypes(projectID string, zone string) ([]MachineType, error) {
+	if API != nil {
+		return API.ListMachineTypes(projectID, zone)
+	}
+
+	cmdStr := fmt.Sprintf(`gcloud compute machine-types list --project="%s" --zones="%s" --format="csv(name,guestCpus,memoryMb)"`, projectID, zone)
+	stdout, stderr, err := runner.Output(cmdStr)
+	if err != nil {
+		return nil, fmt.Errorf("gcloud compute machine-types list: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	return parseListMachineTypesCSV(stdout)
+}
+
+func parseListMachineTypesCSV(output io.Reader) ([]MachineType, error) {
+	records, err := csv.NewReader(output).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("parse machine type list: %w", err)
+	}
+	if len(records) == 0 {
+		return nil, errors.New("no header when reading csv for machine type list")
+	}
+
+	types := make([]MachineType, 0, len(records)-1)
+	for _, record := range records[1:] {
+		if len(record) < 3 || len(record[0]) == 0 {
+			continue
+		}
+		cpus, err := strconv.ParseInt(record[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("parse CPUs of machine type %s: %w", record[0], err)
+		}
+		memory, err := strconv.ParseInt(record[2], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("parse memory of machine type %s: %w", record[0], err)
+		}
+		types = append(types, MachineType{
+			Name:     record[0],
+			CPUs:     cpus,
+			MemoryMB: memory,
+		})
+	}
+	return types, nil
+}
+
+// FindMachineType returns the machine type with the name, or nil if the zone doesn't have it.
+func FindMachineType(types []MachineType, name string) *MachineType {
+	for i := range types {
+		if types[i].Name == name {
+			return &types[i]
+		}
+	}
+	return nil
+}
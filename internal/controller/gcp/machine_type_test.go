@@ -0,0 +1,23 @@
+package gcp
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMachineTypes(t *testing.T) {
+	types, err := parseListMachineTypesCSV(strings.NewReader("name,guest_cpus,memory_mb\ne2-micro,2,1024\nn1-standard-1,1,3840\n"))
+	require.NoError(t, err)
+
+	machineType := FindMachineType(types, "n1-standard-1")
+	require.NotNil(t, machineType)
+	assert.Equal(t, "1 vCPU, 3.75 GB memory", machineType.String())
+	assert.Equal(t, "2 vCPUs, 1 GB memory", FindMachineType(types, "e2-micro").String())
+	assert.Nil(t, FindMachineType(types, "e2-huge"))
+
+	_, err = parseListMachineTypesCSV(strings.NewReader("name,guest_cpus,memory_mb\ne2-micro,two,1024\n"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,93 @@
+package gcp
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// projectIDRE is the format of Google Cloud project IDs.
+var projectIDRE = regexp.MustCompile(`^[a-z][a-z0-9-]{4,28}[a-z0-9]$`)
+
+// MonitoredProject is another project that the tower's service account is bound to the
+// roles in.
+type MonitoredProject struct {
+	ID string
+	// RoleParent is where the roles are created (e.g. `organizations/1234`).
+	RoleParent string
+}
+
+// ParseMonitoredProjects splits the comma (or space) separated project IDs, and drops the
+// duplicates and the tower's own project, since its roles are always bound.
+func ParseMonitoredProjects(value string, towerProject string) ([]string, error) {
+	projects := make([]string, 0)
+	seen := map[string]struct{}{towerProject: {}}
+	for _, project := range strings.FieldsFunc(value, func(r rune) bool {
+		return r == ',' || r == ' '
+	}) {
+		if !projectIDRE.MatchString(project) {
+			return nil, fmt.Errorf("`%s` is not a valid project ID", project)
+		}
+		if _, ok := seen[project]; ok {
+			continue
+		}
+		seen[project] = struct{}{}
+		projects = append(projects, project)
+	}
+	return projects, nil
+}
+
+// GetMonitoredProjectRoles picks where the roles are for each monitored project: in the same
+// scope as the tower's project, so under its own organization if the tower's roles are in
+// one, or else in itself.
+func GetMonitoredProjectRoles(projects []string, inOrganization bool) []MonitoredProject {
+	monitored := make([]MonitoredProject, 0, len(projects))
+	for _, project := range projects {
+		parent := "projects/" + project
+		if inOrganization {
+			if orgID, err := GetOrganizationID(project); err == nil {
+				parent = "organizations/" + orgID
+			}
+		}
+		monitored = append(monitored, MonitoredProject{ID: project, RoleParent: parent})
+	}
+	return monitored
+}
+
+// FormatMonitoredProjectRoles joins a `<project ID>=<role parent>` pair for each monitored
+// project with commas, so that they can be passed around as an arg.
+func FormatMonitoredProjectRoles(projects []MonitoredProject) string {
+	pairs := make([]string, 0, len(projects))
+	for _, project := range projects {
+		pairs = append(pairs, project.ID+"="+project.RoleParent)
+	}
+	return strings.Join(pairs, ",")
+}
+
+// ParseMonitoredProjectRoles is the reverse of FormatMonitoredProjectRoles.
+func ParseMonitoredProjectRoles(value string) []MonitoredProject {
+	projects := make([]MonitoredProject, 0)
+	for _, pair := range strings.Split(value, ",") {
+		id, parent, ok := strings.Cut(pair, "=")
+		if !ok {
+			continue
+		}
+		projects = append(projects, MonitoredProject{ID: id, RoleParent: parent})
+	}
+	return projects
+}
+
+// RoleParents returns where the roles need to exist: under the tower project's organization
+// (or the project itself), and the same for each monitored project.
+func RoleParents(towerParent string, projects []MonitoredProject) []string {
+	parents := []string{towerParent}
+	seen := map[string]struct{}{towerParent: {}}
+	for _, project := range projects {
+		if _, ok := seen[project.RoleParent]; ok {
+			continue
+		}
+		seen[project.RoleParent] = struct{}{}
+		parents = append(parents, project.RoleParent)
+	}
+	return parents
+}
@@ -4,11 +4,16 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
-	"os/exec"
 	"strings"
+
+	"flightcrew.io/cli/internal/runner"
 )
 
 func GetOrganizationID(projectID string) (string, error) {
+	if API != nil {
+		return API.GetOrganizationID(projectID)
+	}
+
 	var stdout, stderr bytes.Buffer
 	err := bashGetAncestors(projectID, &stdout, &stderr)
 	if err != nil {
@@ -25,10 +30,7 @@ func GetOrganizationID(projectID string) (string, error) {
 
 func bashGetAncestors(projectID string, stdout, stderr *bytes.Buffer) error {
 	cmdStr := strings.Replace("gcloud projects get-ancestors ${PROJECT_ID} | awk '/organization/ {print $1}'", "${PROJECT_ID}", projectID, 1)
-	c := exec.Command("bash", "-c", cmdStr)
-	c.Stdout = stdout
-	c.Stderr = stderr
-	if err := c.Run(); err != nil {
+	if err := runner.Run(cmdStr, stdout, stderr); err != nil {
 		return fmt.Errorf("gcloud projects get-ancestors: %w", err)
 	}
 
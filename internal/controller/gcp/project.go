@@ -6,62 +6,243 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"os/exec"
+	"os"
+	"strings"
+
+	"flightcrew.io/cli/internal/debug"
+	"flightcrew.io/cli/internal/runner"
+	"flightcrew.io/cli/internal/view/selectinput"
+	"flightcrew.io/cli/internal/view/wrapinput"
 )
 
-func GetProjectFromEnvironment() (string, error) {
+// requiredServices are the APIs that the install uses in the tower's project.
+var requiredServices = []struct {
+	Name  string
+	Title string
+}{
+	{"compute.googleapis.com", "Compute Engine"},
+	{"iam.googleapis.com", "IAM"},
+}
+
+// Project is a Google Cloud project that the user can see.
+type Project struct {
+	ID   string
+	Name string
+}
+
+// ListProjects returns the active projects that the user can see, sorted by ID.
+func ListProjects() ([]Project, error) {
+	if API != nil {
+		return API.ListProjects()
+	}
+
 	var stdout, stderr bytes.Buffer
 	if err := bashListProjects(&stdout, &stderr); err != nil {
-		return "", err
+		return nil, err
 	}
 
-	res, err := parseListProjectsCSV(&stdout)
-	return res, err
+	return parseListProjectsCSV(&stdout)
 }
 
 func bashListProjects(stdout, stderr *bytes.Buffer) error {
-	c := exec.Command("bash", "-c", "gcloud projects list --format='csv(PROJECT_ID)'")
-	c.Stdout = stdout
-	c.Stderr = stderr
-	if err := c.Run(); err != nil {
+	if err := runner.Run("gcloud projects list --sort-by=projectId --format='csv(PROJECT_ID,NAME)'", stdout, stderr); err != nil {
 		return fmt.Errorf("gcloud projects list: %w", err)
 	}
 
 	return nil
 }
 
-func parseListProjectsCSV(output *bytes.Buffer) (string, error) {
+func parseListProjectsCSV(output *bytes.Buffer) ([]Project, error) {
 	r := csv.NewReader(output)
+	r.FieldsPerRecord = -1
 	columns, err := r.Read()
 	if err != nil {
-		return "", fmt.Errorf("no header when reading csv for project list: %w", err)
+		return nil, fmt.Errorf("no header when reading csv for project list: %w", err)
 	}
 
-	projectIndex := -1
+	projectIndex, nameIndex := -1, -1
 	for i, column := range columns {
-		if column == "project_id" {
+		switch column {
+		case "project_id":
 			projectIndex = i
-			break
+		case "name":
+			nameIndex = i
 		}
 	}
 
 	if projectIndex < 0 {
-		return "", fmt.Errorf("no project_id in csv headers: %+v", columns)
+		return nil, fmt.Errorf("no project_id in csv headers: %+v", columns)
 	}
 
+	projects := make([]Project, 0)
 	for {
 		record, err := r.Read()
 		if err == io.EOF {
 			break
+		} else if err != nil {
+			return nil, fmt.Errorf("read csv for project list: %w", err)
+		}
+
+		if len(record) <= projectIndex || len(record[projectIndex]) == 0 {
+			continue
+		}
+		project := Project{ID: record[projectIndex]}
+		if nameIndex >= 0 && len(record) > nameIndex {
+			project.Name = record[nameIndex]
+		}
+		projects = append(projects, project)
+	}
+
+	if len(projects) == 0 {
+		return nil, errors.New("not found")
+	}
+	return projects, nil
+}
+
+// GetConfigProject returns the project that gcloud uses by default, or empty if there is
+// none.
+func GetConfigProject() string {
+	for _, env := range []string{"CLOUDSDK_CORE_PROJECT", "GOOGLE_CLOUD_PROJECT"} {
+		if project := os.Getenv(env); len(project) > 0 {
+			return project
 		}
+	}
+	if API != nil {
+		return ""
+	}
+
+	stdout, _, err := runner.Output("gcloud config get-value project")
+	if err != nil {
+		debug.Output("gcloud config get-value project: %v", err)
+		return ""
+	}
+	return strings.TrimSpace(stdout.String())
+}
 
-		if len(record) > projectIndex {
-			if len(record[projectIndex]) > 0 {
-				// Limit to only one
-				return record[projectIndex], nil
-			}
+// ProjectOptions describes each project with its name, with the default project first.
+func ProjectOptions(projects []Project, defaultProject string) []selectinput.Option {
+	opts := make([]selectinput.Option, 0, len(projects))
+	for _, project := range projects {
+		opt := selectinput.Option{
+			Value: project.ID,
+			Label: project.Name,
+		}
+		if project.ID == defaultProject {
+			opts = append([]selectinput.Option{opt}, opts...)
+		} else {
+			opts = append(opts, opt)
 		}
 	}
+	return opts
+}
 
-	return "", errors.New("not found")
+// NewProjectInput lists the user's projects to pick from, starting with gcloud's default
+// project, or falls back to typing in the project if they can't be listed. The returned
+// projects are nil in that case.
+func NewProjectInput() (wrapinput.Model, []Project) {
+	configProject := GetConfigProject()
+	projects, err := ListProjects()
+	if err != nil || len(projects) == 0 {
+		debug.Output("list projects: %v", err)
+
+		input := wrapinput.NewFreeForm()
+		input.Freeform.CharLimit = 0
+		input.Freeform.Placeholder = "project-id-1234"
+		if len(configProject) > 0 {
+			input.Freeform.Placeholder = configProject
+		}
+		input.Title = "Project ID"
+		input.HelpText = "Project ID is the unique string identifier for your Google Cloud Platform project."
+		input.Required = true
+		return input, nil
+	}
+
+	input := wrapinput.NewSelect(ProjectOptions(projects, configProject))
+	input.Title = "Project ID"
+	input.HelpText = "Project ID is the unique string identifier for your Google Cloud Platform project. Type to search by ID or name, and use ←/→ to pick one."
+	input.Required = true
+	return input, projects
+}
+
+// CheckProject checks that the project is active and that the APIs that the install uses
+// are enabled in it. Projects that were listed are known to be active.
+func CheckProject(projectID string, listed []Project) error {
+	found := false
+	for _, project := range listed {
+		found = found || project.ID == projectID
+	}
+	if !found {
+		state, err := getProjectState(projectID)
+		if err != nil {
+			debug.Output("get project state: %v", err)
+			return fmt.Errorf("project '%s' doesn't exist or you don't have access to it", projectID)
+		}
+		if state != "ACTIVE" {
+			return fmt.Errorf("project '%s' is %s", projectID, state)
+		}
+	}
+
+	enabled, err := listEnabledServices(projectID)
+	if err != nil {
+		// The services can't be listed without serviceusage.services.list, so leave it to
+		// the commands to fail.
+		debug.Output("list enabled services: %v", err)
+		return nil
+	}
+
+	missing := make([]string, 0, len(requiredServices))
+	titles := make([]string, 0, len(requiredServices))
+	for _, service := range requiredServices {
+		if _, ok := enabled[service.Name]; !ok {
+			missing = append(missing, service.Name)
+			titles = append(titles, service.Title)
+		}
+	}
+	switch len(missing) {
+	case 0:
+		return nil
+	case 1:
+		return fmt.Errorf("the %s API isn't enabled; run `gcloud services enable %s --project=%s`", titles[0], missing[0], projectID)
+	}
+	return fmt.Errorf("the %s APIs aren't enabled; run `gcloud services enable %s --project=%s`", strings.Join(titles, " and "), strings.Join(missing, " "), projectID)
+}
+
+func requiredServiceNames() []string {
+	names := make([]string, 0, len(requiredServices))
+	for _, service := range requiredServices {
+		names = append(names, service.Name)
+	}
+	return names
+}
+
+func getProjectState(projectID string) (string, error) {
+	if API != nil {
+		return API.getProjectState(projectID)
+	}
+
+	cmdStr := fmt.Sprintf(`gcloud projects describe "%s" --format="value(lifecycleState)"`, projectID)
+	stdout, stderr, err := runner.Output(cmdStr)
+	if err != nil {
+		return "", fmt.Errorf("gcloud projects describe: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// listEnabledServices returns which of the required services are enabled in the project.
+func listEnabledServices(projectID string) (map[string]struct{}, error) {
+	if API != nil {
+		return API.listEnabledServices(projectID, requiredServiceNames())
+	}
+
+	cmdStr := fmt.Sprintf(`gcloud services list --enabled --project="%s" --filter="config.name:(%s)" --format="value(config.name)"`, projectID, strings.Join(requiredServiceNames(), " OR "))
+	stdout, stderr, err := runner.Output(cmdStr)
+	if err != nil {
+		return nil, fmt.Errorf("gcloud services list: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	enabled := make(map[string]struct{})
+	for _, service := range strings.Fields(stdout.String()) {
+		enabled[service] = struct{}{}
+	}
+	return enabled, nil
 }
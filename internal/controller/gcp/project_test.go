@@ -15,9 +15,19 @@ some-project
 yeah
 123-project
 `)
-		project, err := parseListProjectsCSV(buf)
+		projects, err := parseListProjectsCSV(buf)
 		assert.NoError(t, err)
-		assert.Equal(t, "project-1", project)
+		assert.Equal(t, []Project{{ID: "project-1"}, {ID: "some-project"}, {ID: "yeah"}, {ID: "123-project"}}, projects)
+	})
+
+	mainT.Run("parse names should succeed", func(t *testing.T) {
+		buf := bytes.NewBufferString(`project_id,name
+project-1,First Project
+project-2,"Second, Project"
+`)
+		projects, err := parseListProjectsCSV(buf)
+		assert.NoError(t, err)
+		assert.Equal(t, []Project{{ID: "project-1", Name: "First Project"}, {ID: "project-2", Name: "Second, Project"}}, projects)
 	})
 
 	mainT.Run("parse empty csv should fail", func(t *testing.T) {
@@ -38,9 +48,9 @@ yeah
 ,project-1
 123,project-2
 	`)
-		project, err := parseListProjectsCSV(buf)
+		projects, err := parseListProjectsCSV(buf)
 		assert.NoError(t, err)
-		assert.Equal(t, "project-1", project)
+		assert.Equal(t, []Project{{ID: "project-1"}, {ID: "project-2"}}, projects)
 	})
 
 	mainT.Run("parse invalid csv should error", func(t *testing.T) {
@@ -49,3 +59,9 @@ yeah
 		assert.Error(t, err)
 	})
 }
+
+func TestProjectOptions(t *testing.T) {
+	opts := ProjectOptions([]Project{{ID: "a-project", Name: "A"}, {ID: "b-project", Name: "B"}, {ID: "c-project"}}, "b-project")
+	assert.Equal(t, []string{"b-project", "a-project", "c-project"}, []string{opts[0].Value, opts[1].Value, opts[2].Value})
+	assert.Equal(t, "B", opts[0].Label)
+}
@@ -0,0 +1,185 @@
+package gcp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"flightcrew.io/cli/internal/runner"
+	"gopkg.in/yaml.v3"
+)
+
+const maxRoleDefinitionSize = 1 << 20
+
+var (
+	// permissionRE is the `service.resource.verb` format of IAM permissions.
+	permissionRE = regexp.MustCompile(`^[a-z][a-zA-Z0-9]*(\.[a-zA-Z0-9]+){2,}$`)
+	roleStages   = []string{"ALPHA", "BETA", "GA", "DEPRECATED", "DISABLED", "EAP"}
+	roleClient   = &http.Client{Timeout: 30 * time.Second}
+)
+
+// RoleDefinition is the YAML file that `gcloud iam roles create --file` takes.
+type RoleDefinition struct {
+	Title               string   `yaml:"title"`
+	Description         string   `yaml:"description"`
+	Stage               string   `yaml:"stage"`
+	IncludedPermissions []string `yaml:"includedPermissions"`
+}
+
+// ParseRoleDefinition parses and validates a role definition, so that mistakes are caught
+// before gcloud is run.
+func ParseRoleDefinition(data []byte) (*RoleDefinition, error) {
+	var role RoleDefinition
+	dec := yaml.NewDecoder(strings.NewReader(string(data)))
+	dec.KnownFields(true)
+	if err := dec.Decode(&role); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("role definition is empty")
+		}
+		return nil, fmt.Errorf("parse role definition: %w", err)
+	}
+
+	if len(strings.TrimSpace(role.Title)) == 0 {
+		return nil, errors.New("role definition needs a title")
+	}
+	if !isRoleStage(role.Stage) {
+		return nil, fmt.Errorf("role definition has stage `%s` (want one of: %s)", role.Stage, strings.Join(roleStages, ", "))
+	}
+	if len(role.IncludedPermissions) == 0 {
+		return nil, errors.New("role definition needs includedPermissions")
+	}
+
+	seen := make(map[string]struct{}, len(role.IncludedPermissions))
+	for _, perm := range role.IncludedPermissions {
+		if !permissionRE.MatchString(perm) {
+			return nil, fmt.Errorf("role definition has invalid permission `%s` (want `service.resource.verb`)", perm)
+		}
+		if _, ok := seen[perm]; ok {
+			return nil, fmt.Errorf("role definition has permission `%s` more than once", perm)
+		}
+		seen[perm] = struct{}{}
+	}
+
+	return &role, nil
+}
+
+func isRoleStage(stage string) bool {
+	for _, s := range roleStages {
+		if s == stage {
+			return true
+		}
+	}
+	return false
+}
+
+// Role is the subset of an existing custom IAM role that the tower cares about.
+type Role struct {
+	Name                string   `json:"name"`
+	Deleted             bool     `json:"deleted"`
+	IncludedPermissions []string `json:"includedPermissions"`
+}
+
+// GetRole returns the custom role under the parent (`projects/<id>` or `organizations/<id>`).
+// Roles that have been deleted but not purged are returned too.
+func GetRole(parent string, role string) (*Role, error) {
+	if API != nil {
+		res, err := API.getRole(parent, role)
+		if err != nil {
+			return nil, err
+		}
+		return &Role{
+			Name:                res.Name,
+			Deleted:             res.Deleted,
+			IncludedPermissions: res.IncludedPermissions,
+		}, nil
+	}
+
+	kind, id, ok := strings.Cut(parent, "/")
+	if !ok {
+		return nil, fmt.Errorf("invalid role parent `%s`", parent)
+	}
+	flag := "--project"
+	if kind == "organizations" {
+		flag = "--organization"
+	}
+
+	cmdStr := fmt.Sprintf(`gcloud iam roles describe "%s" %s="%s" --format=json`, role, flag, id)
+	stdout, stderr, err := runner.Output(cmdStr)
+	if err != nil {
+		return nil, fmt.Errorf("gcloud iam roles describe: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	var res Role
+	if err := json.Unmarshal(stdout.Bytes(), &res); err != nil {
+		return nil, fmt.Errorf("parse role: %w", err)
+	}
+	return &res, nil
+}
+
+// ReadRoleDefinitionFile returns the contents of a role definition file.
+func ReadRoleDefinitionFile(fn string) (string, error) {
+	data, err := os.ReadFile(fn)
+	if err != nil {
+		return "", fmt.Errorf("read role definition: %w", err)
+	}
+	return string(data), nil
+}
+
+// FetchRoleDefinition downloads a role definition. Only HTTPS URLs are allowed, since the
+// role decides what the tower has access to.
+func FetchRoleDefinition(url string) (string, error) {
+	if !strings.HasPrefix(url, "https://") {
+		return "", fmt.Errorf("role definition URL `%s` should start with https://", url)
+	}
+
+	resp, err := roleClient.Get(url)
+	if err != nil {
+		return "", fmt.Errorf("fetch role definition: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("fetch role definition: %s returned %s", url, resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxRoleDefinitionSize+1))
+	if err != nil {
+		return "", fmt.Errorf("fetch role definition: %w", err)
+	}
+	if len(data) > maxRoleDefinitionSize {
+		return "", fmt.Errorf("fetch role definition: %s is larger than 1MiB", url)
+	}
+	return string(data), nil
+}
+
+// DiffPermissions returns the permissions that are only in the `to` role (added), and the
+// ones that are only in the `from` role (removed), sorted.
+func DiffPermissions(from *RoleDefinition, to *RoleDefinition) (added []string, removed []string) {
+	fromSet := make(map[string]struct{}, len(from.IncludedPermissions))
+	for _, perm := range from.IncludedPermissions {
+		fromSet[perm] = struct{}{}
+	}
+	toSet := make(map[string]struct{}, len(to.IncludedPermissions))
+	for _, perm := range to.IncludedPermissions {
+		toSet[perm] = struct{}{}
+		if _, ok := fromSet[perm]; !ok {
+			added = append(added, perm)
+		}
+	}
+	for _, perm := range from.IncludedPermissions {
+		if _, ok := toSet[perm]; !ok {
+			removed = append(removed, perm)
+		}
+	}
+
+	sort.Strings(added)
+	sort.Strings(removed)
+	return added, removed
+}
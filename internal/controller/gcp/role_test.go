@@ -0,0 +1,81 @@
+package gcp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"flightcrew.io/cli/internal/constants"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRoleDefinition(mainT *testing.T) {
+	mainT.Run("built-in roles should be valid", func(t *testing.T) {
+		for platform, perms := range constants.PlatformPermissions {
+			for permissions, settings := range perms {
+				_, err := ParseRoleDefinition([]byte(settings.Content))
+				assert.NoError(t, err, "%s %s role", platform, permissions)
+			}
+		}
+	})
+
+	mainT.Run("invalid roles should fail", func(t *testing.T) {
+		tests := map[string]string{
+			"":         "empty",
+			"title: [": "parse role definition",
+			"stage: GA\nincludedPermissions: [a.b.c]":                       "needs a title",
+			"title: T\nstage: PROD\nincludedPermissions: [a.b.c]":           "stage `PROD`",
+			"title: T\nstage: GA":                                           "needs includedPermissions",
+			"title: T\nstage: GA\nincludedPermissions: [compute.instances]": "invalid permission `compute.instances`",
+			"title: T\nstage: GA\nincludedPermissions: [a.b.c, a.b.c]":      "more than once",
+			"title: T\nstage: GA\nincludedPermissions: [a.b.c]\netag: BwW=": "field etag not found",
+		}
+		for contents, want := range tests {
+			_, err := ParseRoleDefinition([]byte(contents))
+			assert.ErrorContains(t, err, want, contents)
+		}
+	})
+}
+
+func TestFetchRoleDefinition(mainT *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/role.yaml" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte("title: Trimmed\nstage: GA\nincludedPermissions:\n- compute.instances.get\n"))
+	}))
+	mainT.Cleanup(server.Close)
+
+	keepClient := roleClient
+	roleClient = server.Client()
+	mainT.Cleanup(func() {
+		roleClient = keepClient
+	})
+
+	mainT.Run("role should be downloaded", func(t *testing.T) {
+		content, err := FetchRoleDefinition(server.URL + "/role.yaml")
+		require.NoError(t, err)
+		assert.Contains(t, content, "compute.instances.get")
+	})
+
+	mainT.Run("missing role should fail", func(t *testing.T) {
+		_, err := FetchRoleDefinition(server.URL + "/other.yaml")
+		assert.ErrorContains(t, err, "404")
+	})
+
+	mainT.Run("plain HTTP should fail", func(t *testing.T) {
+		_, err := FetchRoleDefinition("http://example.com/role.yaml")
+		assert.ErrorContains(t, err, "should start with https://")
+	})
+}
+
+func TestDiffPermissions(t *testing.T) {
+	added, removed := DiffPermissions(
+		&RoleDefinition{IncludedPermissions: []string{"a.b.get", "a.b.list", "c.d.get"}},
+		&RoleDefinition{IncludedPermissions: []string{"e.f.get", "a.b.get", "d.e.get"}},
+	)
+	assert.Equal(t, []string{"d.e.get", "e.f.get"}, added)
+	assert.Equal(t, []string{"a.b.list", "c.d.get"}, removed)
+}
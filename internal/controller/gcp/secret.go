@@ -0,0 +1,37 @@
+package gcp
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// TokenSecretMetadataKey is the VM's metadata item with the Secret Manager version that its
+// startup script reads the API token from, and passes to the tower as FC_API_KEY.
+const TokenSecretMetadataKey = "flightcrew-token-secret"
+
+var secretNameRE = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,255}$`)
+
+// ValidateSecretName checks that the name can be used for a Secret Manager secret.
+func ValidateSecretName(name string) error {
+	if !secretNameRE.MatchString(name) {
+		return errors.New("can only have letters, numbers, `-` and `_` (up to 255)")
+	}
+	return nil
+}
+
+// TokenSecretRef is the latest version of the secret, which the VM reads when it boots so
+// that a new version is picked up when it restarts.
+func TokenSecretRef(projectID string, secret string) string {
+	return fmt.Sprintf("projects/%s/secrets/%s/versions/latest", projectID, secret)
+}
+
+// ParseTokenSecretRef returns the project and secret of a TokenSecretRef.
+func ParseTokenSecretRef(ref string) (string, string, error) {
+	parts := strings.Split(ref, "/")
+	if len(parts) != 6 || parts[0] != "projects" || parts[2] != "secrets" || parts[4] != "versions" {
+		return "", "", fmt.Errorf("`%s` should be projects/<project>/secrets/<secret>/versions/<version>", ref)
+	}
+	return parts[1], parts[3], nil
+}
@@ -0,0 +1,29 @@
+package gcp_test
+
+import (
+	"testing"
+
+	"flightcrew.io/cli/internal/controller/gcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenSecretRef(t *testing.T) {
+	ref := gcp.TokenSecretRef("my-project", "flightcrew-api-token")
+	assert.Equal(t, "projects/my-project/secrets/flightcrew-api-token/versions/latest", ref)
+
+	project, secret, err := gcp.ParseTokenSecretRef(ref)
+	require.NoError(t, err)
+	assert.Equal(t, "my-project", project)
+	assert.Equal(t, "flightcrew-api-token", secret)
+
+	_, _, err = gcp.ParseTokenSecretRef("projects/my-project/secrets/flightcrew-api-token")
+	assert.Error(t, err)
+}
+
+func TestValidateSecretName(t *testing.T) {
+	assert.NoError(t, gcp.ValidateSecretName("flightcrew_api-token"))
+	assert.Error(t, gcp.ValidateSecretName(""))
+	assert.Error(t, gcp.ValidateSecretName("api token"))
+	assert.Error(t, gcp.ValidateSecretName(`token"; rm -rf /`))
+}
@@ -0,0 +1,57 @@
+package gcpstatus
+
+import (
+	"errors"
+	"fmt"
+
+	"flightcrew.io/cli/internal/controller/gcp"
+	gconst "flightcrew.io/cli/internal/controller/gcp/constants"
+	"github.com/spf13/cobra"
+)
+
+const (
+	OutputTable = "table"
+	OutputJSON  = "json"
+)
+
+var (
+	// Declare the variables and then assign them in init() so that we don't have a cyclical dependency
+	// since the statusCmd references these variables, but we need to first instantiate the flags.
+	vmFlag, projectFlag, zoneFlag, outputFlag *string
+)
+
+type Params struct {
+	Project        string
+	Zone           string
+	VirtualMachine string
+	Output         string
+}
+
+func RegisterFlags(cmd *cobra.Command) {
+	vmFlag = cmd.Flags().String(gconst.FlagVirtualMachine, "flightcrew-control-tower", "The name of the Flightcrew tower VM.")
+	projectFlag = cmd.Flags().StringP(gconst.FlagProject, "p", "", "Specify your Google Project ID.")
+	zoneFlag = cmd.Flags().StringP(gconst.FlagZone, "l", "us-central1-c", "The zone that your Tower is in.")
+	outputFlag = cmd.Flags().StringP("output", "o", OutputTable, fmt.Sprintf("The output format. ('%s' or '%s')", OutputTable, OutputJSON))
+}
+
+func ParseFlags(cmd *cobra.Command) (Params, error) {
+	// Status only reads, so it doesn't need gcloud when it goes through the API client.
+	if gcp.API == nil && !gcp.HasGcloudInPath() {
+		return Params{}, errors.New("gcloud is not in path")
+	}
+
+	if len(*projectFlag) == 0 {
+		return Params{}, fmt.Errorf("--%s is required", gconst.FlagProject)
+	}
+
+	if *outputFlag != OutputTable && *outputFlag != OutputJSON {
+		return Params{}, fmt.Errorf("invalid --output flag: %s, %s", OutputTable, OutputJSON)
+	}
+
+	return Params{
+		Project:        *projectFlag,
+		Zone:           *zoneFlag,
+		VirtualMachine: *vmFlag,
+		Output:         *outputFlag,
+	}, nil
+}
@@ -0,0 +1,180 @@
+package gcpstatus
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"flightcrew.io/cli/internal/constants"
+	"flightcrew.io/cli/internal/controller/gcp"
+	"flightcrew.io/cli/internal/debug"
+	"flightcrew.io/cli/internal/semver"
+)
+
+// Status describes an installed tower.
+type Status struct {
+	Project        string `json:"project"`
+	Zone           string `json:"zone"`
+	VirtualMachine string `json:"vm"`
+	State          string `json:"state"`
+	ExternalIP     string `json:"externalIP,omitempty"`
+
+	Image         string `json:"image,omitempty"`
+	Version       string `json:"version,omitempty"`
+	Platform      string `json:"platform,omitempty"`
+	TrafficRouter string `json:"trafficRouter,omitempty"`
+
+	ServiceAccount       string        `json:"serviceAccount,omitempty"`
+	ServiceAccountExists bool          `json:"serviceAccountExists"`
+	Roles                []RoleBinding `json:"roles"`
+
+	LatestVersion   string `json:"latestVersion,omitempty"`
+	UpdateAvailable bool   `json:"updateAvailable"`
+}
+
+// RoleBinding is whether one of the Flightcrew custom IAM roles is bound to the tower's
+// service account.
+type RoleBinding struct {
+	Role        string `json:"role"`
+	Permissions string `json:"permissions"`
+	Bound       bool   `json:"bound"`
+}
+
+// GetStatus looks up the tower VM and the resources around it.
+func GetStatus(params Params) (*Status, error) {
+	instance, err := gcp.GetInstance(params.Project, params.Zone, params.VirtualMachine)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &Status{
+		Project:        params.Project,
+		Zone:           params.Zone,
+		VirtualMachine: instance.Name,
+		State:          instance.Status,
+		ExternalIP:     instance.ExternalIP,
+		Roles:          make([]RoleBinding, 0),
+	}
+
+	if container := instance.Container; container != nil {
+		status.Image = container.Image
+		status.Version, _ = container.GetEnv("FC_PACKAGE_VERSION")
+		status.Platform, _ = container.GetEnv("CLOUD_PLATFORM")
+		status.TrafficRouter, _ = container.GetEnv("TRAFFIC_ROUTER")
+	}
+
+	if len(instance.ServiceAccounts) > 0 {
+		status.ServiceAccount = instance.ServiceAccounts[0]
+		status.ServiceAccountExists = gcp.ServiceAccountExists(params.Project, status.ServiceAccount)
+	}
+
+	bindings, err := gcp.GetProjectIAMBindings(params.Project)
+	if err != nil {
+		debug.Output("get iam bindings: %v", err)
+	}
+	status.Roles = getRoleBindings(status.Platform, "serviceAccount:"+status.ServiceAccount, bindings)
+
+	if latest, err := gcp.GetTowerImageVersion("stable"); err == nil {
+		status.LatestVersion = latest
+		status.UpdateAvailable = isUpdateAvailable(status.Version, latest)
+	} else {
+		debug.Output("get latest tower version: %v", err)
+	}
+
+	return status, nil
+}
+
+// isUpdateAvailable is whether the latest version is newer than the running one. Versions
+// that aren't x.y.z can't be compared, so they never have an update.
+func isUpdateAvailable(running string, latest string) bool {
+	runningVersion, err := semver.Parse(running)
+	if err != nil {
+		return false
+	}
+	latestVersion, err := semver.Parse(latest)
+	if err != nil {
+		return false
+	}
+	return latestVersion.Compare(runningVersion) > 0
+}
+
+// getRoleBindings checks each of the platform's roles for whether it is bound to the member.
+// Roles can be either project or organization roles, so only the role name is compared.
+func getRoleBindings(platform string, member string, bindings []gcp.Binding) []RoleBinding {
+	res := make([]RoleBinding, 0)
+	perms, ok := constants.PlatformPermissions[platform]
+	if !ok {
+		return res
+	}
+
+	for _, permissions := range []string{constants.Read, constants.Write} {
+		settings, ok := perms[permissions]
+		if !ok {
+			continue
+		}
+
+		rb := RoleBinding{
+			Role:        settings.Role,
+			Permissions: permissions,
+		}
+		for _, binding := range bindings {
+			if strings.HasSuffix(binding.Role, "/roles/"+settings.Role) && binding.HasMember(member) {
+				rb.Bound = true
+				break
+			}
+		}
+		res = append(res, rb)
+	}
+
+	return res
+}
+
+// Write outputs the status in the given format.
+func (s Status) Write(w io.Writer, output string) error {
+	if output == OutputJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(s)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	row := func(name string, value string) {
+		if len(value) == 0 {
+			value = "-"
+		}
+		_, _ = fmt.Fprintf(tw, "%s\t%s\n", name, value)
+	}
+
+	row("Project", s.Project)
+	row("Zone", s.Zone)
+	row("VM", s.VirtualMachine)
+	row("State", s.State)
+	row("External IP", s.ExternalIP)
+	row("Image", s.Image)
+
+	version := s.Version
+	if s.UpdateAvailable {
+		version = fmt.Sprintf("%s (stable is %s)", s.Version, s.LatestVersion)
+	}
+	row("Version", version)
+	row("Platform", s.Platform)
+	row("Traffic Router", s.TrafficRouter)
+
+	serviceAccount := s.ServiceAccount
+	if len(serviceAccount) > 0 && !s.ServiceAccountExists {
+		serviceAccount += " (not found)"
+	}
+	row("Service Account", serviceAccount)
+
+	for _, rb := range s.Roles {
+		bound := "not bound"
+		if rb.Bound {
+			bound = "bound"
+		}
+		row(rb.Permissions+" Role", fmt.Sprintf("%s (%s)", rb.Role, bound))
+	}
+
+	return tw.Flush()
+}
@@ -0,0 +1,57 @@
+package gcpstatus
+
+import (
+	"strings"
+	"testing"
+
+	"flightcrew.io/cli/internal/constants"
+	"flightcrew.io/cli/internal/controller/gcp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetRoleBindings(t *testing.T) {
+	member := "serviceAccount:flightcrew-runner@project-id-1234.iam.gserviceaccount.com"
+	bindings := []gcp.Binding{
+		{
+			Role:    "organizations/1234567890/roles/flightcrew.gae_std.read.only",
+			Members: []string{"user:someone@example.com", member},
+		},
+		{
+			Role:    "projects/project-id-1234/roles/flightcrew.gae_std.read.write",
+			Members: []string{"user:someone@example.com"},
+		},
+	}
+
+	assert.Equal(t, []RoleBinding{
+		{Role: "flightcrew.gae_std.read.only", Permissions: constants.Read, Bound: true},
+		{Role: "flightcrew.gae_std.read.write", Permissions: constants.Write, Bound: false},
+	}, getRoleBindings(constants.GoogleAppEngineStdPlatform, member, bindings))
+
+	assert.Empty(t, getRoleBindings("unknown", member, bindings))
+}
+
+func TestIsUpdateAvailable(t *testing.T) {
+	assert.True(t, isUpdateAvailable("0.2.15", "0.3.0"))
+	assert.True(t, isUpdateAvailable("v0.2.15", "0.2.16"))
+	assert.False(t, isUpdateAvailable("0.3.0", "0.3.0"))
+	assert.False(t, isUpdateAvailable("0.10.0", "0.9.0"), "newer running version shouldn't have an update")
+	assert.False(t, isUpdateAvailable("0.3.0-rc.1", "0.2.16"))
+	assert.True(t, isUpdateAvailable("0.3.0-rc.1", "0.3.0"))
+	assert.False(t, isUpdateAvailable("", "0.3.0"))
+	assert.False(t, isUpdateAvailable("custom-build", "0.3.0"))
+}
+
+func TestWriteTable(t *testing.T) {
+	var b strings.Builder
+	err := Status{
+		Project:         "project-id-1234",
+		VirtualMachine:  "flightcrew-control-tower",
+		State:           "RUNNING",
+		Version:         "0.2.15",
+		LatestVersion:   "0.3.0",
+		UpdateAvailable: true,
+	}.Write(&b, OutputTable)
+	assert.NoError(t, err)
+	assert.Contains(t, b.String(), "Version          0.2.15 (stable is 0.3.0)\n")
+	assert.Contains(t, b.String(), "External IP      -\n")
+}
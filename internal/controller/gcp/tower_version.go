@@ -0,0 +1,119 @@
+package gcp
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"flightcrew.io/cli/internal/debug"
+	"flightcrew.io/cli/internal/view/selectinput"
+	"flightcrew.io/cli/internal/view/wrapinput"
+	registry "google.golang.org/api/artifactregistry/v1"
+)
+
+// TowerVersion is a tag of the tower image. Channel tags (e.g. `stable`) have the x.x.x
+// version that they point to.
+type TowerVersion struct {
+	Tag      string
+	Version  string
+	Uploaded time.Time
+}
+
+// ListTowerVersions returns the tags of the tower image, newest first.
+func ListTowerVersions() ([]TowerVersion, error) {
+	if ArtifactRegistryService == nil {
+		return nil, errors.New("no artifact registry client")
+	}
+
+	images, err := listDockerImages()
+	if err != nil {
+		return nil, err
+	}
+
+	return towerVersions(images), nil
+}
+
+func towerVersions(images []*registry.DockerImage) []TowerVersion {
+	versions := make([]TowerVersion, 0)
+	for _, image := range images {
+		version, ok := imageVersion(image)
+		if !ok {
+			continue
+		}
+
+		uploaded, err := time.Parse(time.RFC3339Nano, image.UploadTime)
+		if err != nil {
+			debug.Output("parse upload time of %s: %v", image.Name, err)
+		}
+
+		// The channel tags go before the version that they point to.
+		tags := make([]string, 0, len(image.Tags))
+		for _, tag := range image.Tags {
+			if tag != version {
+				tags = append(tags, tag)
+			}
+		}
+		tags = append(tags, version)
+
+		for _, tag := range tags {
+			versions = append(versions, TowerVersion{
+				Tag:      tag,
+				Version:  version,
+				Uploaded: uploaded,
+			})
+		}
+	}
+
+	sort.SliceStable(versions, func(i, j int) bool {
+		return versions[i].Uploaded.After(versions[j].Uploaded)
+	})
+	return versions
+}
+
+// TowerVersionOptions describes each tag with the version that it points to and when it was
+// uploaded. The tags with the running version are marked, if there is one.
+func TowerVersionOptions(versions []TowerVersion, running string) []selectinput.Option {
+	opts := make([]selectinput.Option, 0, len(versions))
+	for _, v := range versions {
+		details := make([]string, 0, 3)
+		if v.Tag != v.Version {
+			details = append(details, v.Version)
+		}
+		if !v.Uploaded.IsZero() {
+			details = append(details, fmt.Sprintf("uploaded %s", v.Uploaded.Format("2006-01-02")))
+		}
+		if len(running) > 0 && v.Version == running {
+			details = append(details, "running")
+		}
+
+		opts = append(opts, selectinput.Option{
+			Value: v.Tag,
+			Label: strings.Join(details, ", "),
+		})
+	}
+	return opts
+}
+
+// NewTowerVersionInput lists the tower's tags to pick from, or falls back to typing in the
+// version if they can't be listed (e.g. without Application Default Credentials).
+// The returned versions are nil in that case.
+func NewTowerVersionInput() (wrapinput.Model, []TowerVersion) {
+	versions, err := ListTowerVersions()
+	if err != nil || len(versions) == 0 {
+		debug.Output("list tower versions: %v", err)
+
+		input := wrapinput.NewFreeForm()
+		input.Freeform.Placeholder = "stable"
+		input.Freeform.CharLimit = 32
+		input.Title = "Tower Version"
+		input.HelpText = "Tower Version is the version of the Tower image that will be installed. (recommended: `stable`)"
+		return input, nil
+	}
+
+	input := wrapinput.NewSelect(TowerVersionOptions(versions, ""))
+	input.Title = "Tower Version"
+	input.HelpText = "Tower Version is the version of the Tower image that will be installed, newest first. Use ←/→ to pick one. (recommended: `stable`)"
+	return input, versions
+}
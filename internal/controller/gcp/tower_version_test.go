@@ -0,0 +1,49 @@
+package gcp
+
+import (
+	"testing"
+
+	"flightcrew.io/cli/internal/view/selectinput"
+	"github.com/stretchr/testify/assert"
+	registry "google.golang.org/api/artifactregistry/v1"
+)
+
+func TestTowerVersions(t *testing.T) {
+	images := []*registry.DockerImage{
+		{
+			Name:       "old",
+			Tags:       []string{"1.0.0"},
+			UploadTime: "2022-09-01T10:00:00.123456Z",
+		},
+		{
+			Name:       "newest",
+			Tags:       []string{"1.2.0", "latest"},
+			UploadTime: "2022-10-15T10:00:00Z",
+		},
+		{
+			Name: "untagged",
+			Tags: []string{"test-build"},
+		},
+		{
+			Name:       "stable",
+			Tags:       []string{"stable", "1.1.0"},
+			UploadTime: "2022-10-01T10:00:00Z",
+		},
+	}
+
+	versions := towerVersions(images)
+	tags := make([]string, 0, len(versions))
+	for _, v := range versions {
+		tags = append(tags, v.Tag)
+	}
+	assert.Equal(t, []string{"latest", "1.2.0", "stable", "1.1.0", "1.0.0"}, tags)
+	assert.Equal(t, "1.1.0", versions[2].Version)
+
+	assert.Equal(t, []selectinput.Option{
+		{Value: "latest", Label: "1.2.0, uploaded 2022-10-15"},
+		{Value: "1.2.0", Label: "uploaded 2022-10-15"},
+		{Value: "stable", Label: "1.1.0, uploaded 2022-10-01, running"},
+		{Value: "1.1.0", Label: "uploaded 2022-10-01, running"},
+		{Value: "1.0.0", Label: "uploaded 2022-09-01"},
+	}, TowerVersionOptions(versions, "1.1.0"))
+}
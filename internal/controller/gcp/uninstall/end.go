@@ -0,0 +1,54 @@
+package gcpuninstall
+
+import (
+	"strings"
+
+	"flightcrew.io/cli/internal/style"
+	"flightcrew.io/cli/internal/view/command"
+)
+
+type EndController struct {
+	replacer       *strings.Replacer
+	endDescription string
+	commands       []*command.Model
+}
+
+func NewEndController(commands []*command.Model, replacer *strings.Replacer) *EndController {
+	return &EndController{
+		commands: commands,
+		replacer: replacer,
+	}
+}
+
+func (ctl EndController) Commands() []*command.Model {
+	return ctl.commands
+}
+
+func (ctl EndController) Name() string {
+	return "Google Cloud Platform Uninstall"
+}
+
+func (ctl *EndController) EndDescription() string {
+	if len(ctl.endDescription) > 0 {
+		return ctl.endDescription
+	}
+
+	var description = `## Your Flightcrew tower is uninstalled. 👋
+
+The VM ${VIRTUAL_MACHINE} and the ${SERVICE_ACCOUNT} service account have been removed from ${GOOGLE_PROJECT_ID}.
+
+If you change your mind, you can always reinstall with:
+${CODE_START}
+crewcli gcp install --project=${GOOGLE_PROJECT_ID} --vm=${VIRTUAL_MACHINE} --zone=${ZONE}
+${CODE_END}
+
+For help, reach out to support@flightcrew.io.
+`
+
+	description = ctl.replacer.Replace(description)
+	description = strings.Replace(description, "${CODE_START}", "```sh", 1)
+	description = strings.Replace(description, "${CODE_END}", "```", 1)
+
+	ctl.endDescription, _ = style.Glamour.Render(description)
+	return ctl.endDescription
+}
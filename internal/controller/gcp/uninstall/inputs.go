@@ -0,0 +1,299 @@
+package gcpuninstall
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"flightcrew.io/cli/internal/controller"
+	"flightcrew.io/cli/internal/controller/gcp"
+	gconst "flightcrew.io/cli/internal/controller/gcp/constants"
+	"flightcrew.io/cli/internal/debug"
+	"flightcrew.io/cli/internal/view/wrapinput"
+)
+
+var (
+	initialInputKeys = []string{
+		gconst.KeyProject,
+		gconst.KeyVirtualMachine,
+		gconst.KeyZone,
+		gconst.KeyIAMServiceAccount,
+		gconst.KeyDeleteRoles,
+		gconst.KeyMonitoredProjects,
+		gconst.KeyNetwork,
+		gconst.KeyTokenSecret,
+		gconst.KeyConfirm,
+	}
+)
+
+type InputsController struct {
+	inputs    map[string]*wrapinput.Model
+	args      map[string]string
+	inputKeys []string
+}
+
+func NewInputsController(params Params) *InputsController {
+	ctl := &InputsController{
+		inputKeys: initialInputKeys,
+		inputs:    make(map[string]*wrapinput.Model),
+		args:      params.args,
+	}
+
+	if !contains(ctl.args, gconst.KeyVirtualMachine) {
+		ctl.args[gconst.KeyVirtualMachine] = "flightcrew-control-tower"
+	}
+	if !contains(ctl.args, gconst.KeyZone) {
+		ctl.args[gconst.KeyZone] = "us-central1-c"
+	}
+	if !contains(ctl.args, gconst.KeyIAMServiceAccount) {
+		ctl.args[gconst.KeyIAMServiceAccount] = "flightcrew-runner"
+	}
+
+	ctl.args[gconst.KeyProjectOrOrgFlag] = ""
+	ctl.args[gconst.KeyProjectOrOrgSlash] = ""
+
+	for _, key := range allKeys {
+		var input wrapinput.Model
+		maybeSetValue := func(key string) {
+			if val, ok := ctl.args[key]; ok {
+				input.SetValue(val)
+			}
+		}
+
+		switch key {
+		case gconst.KeyProject:
+			input, _ = gcp.NewProjectInput()
+			maybeSetValue(gconst.KeyProject)
+
+		case gconst.KeyVirtualMachine:
+			input = wrapinput.NewFreeForm()
+			input.Freeform.Placeholder = "flightcrew-control-tower"
+			input.Freeform.CharLimit = 64
+			input.Title = "VM Name"
+			input.Default = "flightcrew-control-tower"
+			input.Required = true
+			input.HelpText = "VM Name is the name of the Flightcrew virtual machine instance that will be deleted."
+			maybeSetValue(gconst.KeyVirtualMachine)
+
+		case gconst.KeyZone:
+			input = wrapinput.NewFreeForm()
+			input.Freeform.Placeholder = "us-central1-c"
+			input.Freeform.CharLimit = 32
+			input.Title = "Zone"
+			input.Default = "us-central1-c"
+			input.HelpText = "Zone is the Google zone where the Flightcrew virtual machine instance is located."
+			maybeSetValue(gconst.KeyZone)
+
+		case gconst.KeyIAMServiceAccount:
+			input = wrapinput.NewFreeForm()
+			input.Title = "Service Account"
+			input.Freeform.CharLimit = 64
+			input.Default = "flightcrew-runner"
+			input.HelpText = "Service Account is the name of the IAM service account that runs the Flightcrew Tower. Its role bindings will be removed before it is deleted."
+			maybeSetValue(gconst.KeyIAMServiceAccount)
+
+		case gconst.KeyDeleteRoles:
+			input = wrapinput.NewRadio([]string{
+				deleteRolesNo,
+				deleteRolesYes})
+			input.Title = "Delete Roles"
+			input.HelpText = "Delete Roles is whether the Flightcrew custom IAM roles should be deleted as well.\nIf the roles were created for your organization, other towers may still be using them."
+			maybeSetValue(gconst.KeyDeleteRoles)
+
+		case gconst.KeyMonitoredProjects:
+			input = wrapinput.NewFreeForm()
+			input.Title = "Monitored Projects"
+			input.Freeform.Placeholder = "project-id-5678,project-id-9012"
+			input.Freeform.CharLimit = 0
+			input.HelpText = "Monitored Projects are the other project IDs (comma-separated) that the Tower was installed with. The service account's role bindings in them are removed too, and with Delete Roles, so are the roles that were created for them."
+			maybeSetValue(gconst.KeyMonitoredProjects)
+
+		case gconst.KeyNetwork:
+			input = wrapinput.NewFreeForm()
+			input.Title = "Network"
+			input.Freeform.Placeholder = gcp.DefaultNetwork
+			input.Freeform.CharLimit = 128
+			input.HelpText = "Network is the VM's network, where the Cloud NAT, Cloud Router and IAP firewall rule for VMs without an external IP were created. Only the ones that Flightcrew named are deleted.\nLeave blank to read it from the VM's metadata, or to use the default network."
+			maybeSetValue(gconst.KeyNetwork)
+
+		case gconst.KeyTokenSecret:
+			input = wrapinput.NewFreeForm()
+			input.Title = "Token Secret"
+			input.Freeform.CharLimit = 255
+			input.HelpText = "Token Secret is the Secret Manager secret with the API token, if the Tower was installed with one. It's deleted along with the service account's access to it.\nLeave blank to read it from the VM's metadata."
+			maybeSetValue(gconst.KeyTokenSecret)
+
+		case gconst.KeyConfirm:
+			input = wrapinput.NewFreeForm()
+			input.Freeform.CharLimit = 0
+			input.Freeform.Placeholder = "project-id-1234"
+			input.Title = "Confirm Project ID"
+			input.Required = true
+			input.HelpText = "Type the Project ID again to confirm that the Flightcrew resources in it should be deleted."
+			maybeSetValue(gconst.KeyConfirm)
+
+		}
+
+		input.Blur()
+		ctl.inputs[key] = &input
+	}
+	return ctl
+}
+
+func (ctl InputsController) GetAllInputs() []*wrapinput.Model {
+	res := make([]*wrapinput.Model, 0, len(ctl.inputs))
+	for _, v := range ctl.inputs {
+		res = append(res, v)
+	}
+	return res
+}
+
+func (ctl *InputsController) Reset(inputs []*wrapinput.Model) {
+	for k := range ctl.inputs {
+		ctl.inputs[k].ResetValidation()
+	}
+}
+
+func (ctl *InputsController) Validate(inputs []*wrapinput.Model) bool {
+	// The VM is deleted first, so what it was installed with is looked up while it still
+	// exists.
+	instance, err := gcp.GetInstance(ctl.inputs[gconst.KeyProject].Value(), ctl.inputs[gconst.KeyZone].Value(), ctl.inputs[gconst.KeyVirtualMachine].Value())
+	if err != nil {
+		debug.Output("describe vm: %v", err)
+		instance = nil
+	}
+
+	hasErrors := false
+	for k, input := range ctl.inputs {
+		setError := func(err error) bool {
+			if err != nil {
+				input.SetError(err)
+				debug.Output(err.Error())
+				hasErrors = true
+				return true
+			}
+			return false
+		}
+
+		if input.Required && len(input.Value()) == 0 {
+			setError(errors.New("required"))
+			continue
+		}
+
+		switch k {
+		case gconst.KeyProject:
+			projectID := input.Value()
+			orgID, err := gcp.GetOrganizationID(projectID)
+			if err == nil && ctl.args[gconst.KeyRoleScope] == gconst.RoleScopeProject {
+				input.SetInfo("found organization ID '" + orgID + "', but the roles are in the project")
+				ctl.args[gconst.KeyProjectOrOrgFlag] = fmtFlagForReplace("project", projectID)
+				ctl.args[gconst.KeyProjectOrOrgSlash] = fmt.Sprintf(`projects/%s`, projectID)
+			} else if err != nil {
+				input.SetInfo("no organization found")
+				ctl.args[gconst.KeyProjectOrOrgFlag] = fmtFlagForReplace("project", projectID)
+				ctl.args[gconst.KeyProjectOrOrgSlash] = fmt.Sprintf(`projects/%s`, projectID)
+			} else {
+				input.SetInfo("found organization ID '" + orgID + "'")
+				ctl.args[gconst.KeyProjectOrOrgFlag] = fmtFlagForReplace("organization", orgID)
+				ctl.args[gconst.KeyProjectOrOrgSlash] = fmt.Sprintf(`organizations/%s`, orgID)
+			}
+
+		case gconst.KeyTokenSecret:
+			if len(input.Value()) > 0 {
+				setError(gcp.ValidateSecretName(input.Value()))
+				break
+			}
+
+			if instance == nil {
+				break
+			}
+			secretProject, secret, err := gcp.ParseTokenSecretRef(instance.Metadata[gcp.TokenSecretMetadataKey])
+			if err == nil && secretProject == ctl.inputs[gconst.KeyProject].Value() {
+				input.SetConverted(secret)
+				input.SetInfo("found on the VM")
+			}
+
+		case gconst.KeyMonitoredProjects:
+			ctl.args[gconst.KeyMonitoredProjectRoles] = ""
+			projects, err := gcp.ParseMonitoredProjects(input.Value(), ctl.inputs[gconst.KeyProject].Value())
+			if setError(err) || len(projects) == 0 {
+				break
+			}
+
+			// The roles were created in the same scope as the tower's, like the install does.
+			monitored := gcp.GetMonitoredProjectRoles(projects, ctl.args[gconst.KeyRoleScope] != gconst.RoleScopeProject)
+			infos := make([]string, 0, len(monitored))
+			for _, project := range monitored {
+				infos = append(infos, fmt.Sprintf("%s (roles in %s)", project.ID, project.RoleParent))
+			}
+			ctl.args[gconst.KeyMonitoredProjectRoles] = gcp.FormatMonitoredProjectRoles(monitored)
+			input.SetConverted(strings.Join(projects, ","))
+			input.SetInfo(strings.Join(infos, ", "))
+
+		case gconst.KeyNetwork:
+			if len(input.Value()) > 0 {
+				setError(gcp.ValidateNetwork(input.Value()))
+				break
+			}
+
+			if instance != nil && len(instance.Network) > 0 {
+				input.SetConverted(instance.Network)
+				input.SetInfo("found on the VM")
+			}
+
+		case gconst.KeyConfirm:
+			if input.Value() != ctl.inputs[gconst.KeyProject].Value() {
+				setError(errors.New("does not match the Project ID"))
+			}
+
+		}
+	}
+
+	return !hasErrors
+}
+
+func (ctl InputsController) GetRunController() controller.Run {
+	for _, k := range ctl.inputKeys {
+		ctl.args[k] = ctl.inputs[k].Value()
+	}
+
+	return NewRunController(ctl.args)
+}
+
+// ResumeRunController rebuilds the Run controller from the args of a saved run.
+func (ctl InputsController) ResumeRunController(args map[string]string) controller.Run {
+	for k, v := range args {
+		ctl.args[k] = v
+	}
+
+	return NewRunController(ctl.args)
+}
+
+func (ctl InputsController) GetName() string {
+	return "Google Cloud Platform Uninstall"
+}
+
+func (ctl *InputsController) GetInputs() []*wrapinput.Model {
+	inputs := make([]*wrapinput.Model, 0, len(ctl.inputKeys))
+	for _, k := range ctl.inputKeys {
+		inputs = append(inputs, ctl.inputs[k])
+	}
+	return inputs
+}
+
+func (ctl *InputsController) RecreateCommand() string {
+	for _, key := range ctl.inputKeys {
+		ctl.args[key] = ctl.inputs[key].Value()
+	}
+	return recreateCommand(ctl.args)
+}
+
+func contains(m map[string]string, key string) bool {
+	_, ok := m[key]
+	return ok
+}
+
+func fmtFlagForReplace(flag string, value string) string {
+	return fmt.Sprintf(`
+	--%s=%s \`, flag, value)
+}
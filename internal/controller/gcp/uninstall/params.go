@@ -0,0 +1,145 @@
+package gcpuninstall
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"flightcrew.io/cli/internal/constants"
+	"flightcrew.io/cli/internal/controller/gcp"
+	gconst "flightcrew.io/cli/internal/controller/gcp/constants"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Declare the variables and then assign them in init() so that we don't have a cyclical dependency
+	// since the uninstallCmd references these variables, but we need to first instantiate the flags.
+	vmFlag, projectFlag, zoneFlag, serviceAccountFlag, confirmFlag *string
+	roleScopeFlag, monitoredProjectsFlag                           *string
+	networkFlag, tokenSecretFlag                                   *string
+	deleteRolesFlag                                                *bool
+)
+
+var (
+	allKeys = []string{
+		gconst.KeyProject,
+		gconst.KeyZone,
+		gconst.KeyVirtualMachine,
+		gconst.KeyIAMServiceAccount,
+		gconst.KeyDeleteRoles,
+		gconst.KeyMonitoredProjects,
+		gconst.KeyNetwork,
+		gconst.KeyTokenSecret,
+		gconst.KeyConfirm,
+	}
+
+	// flagToKey only has the flags that should be recreated. The confirmation is left out
+	// on purpose so that the user has to type it again.
+	flagToKey = map[string]string{
+		gconst.FlagProject:           gconst.KeyProject,
+		gconst.FlagZone:              gconst.KeyZone,
+		gconst.FlagVirtualMachine:    gconst.KeyVirtualMachine,
+		gconst.FlagServiceAccount:    gconst.KeyIAMServiceAccount,
+		gconst.FlagDeleteRoles:       gconst.KeyDeleteRoles,
+		gconst.FlagRoleScope:         gconst.KeyRoleScope,
+		gconst.FlagMonitoredProjects: gconst.KeyMonitoredProjects,
+		gconst.FlagNetwork:           gconst.KeyNetwork,
+		gconst.FlagTokenSecret:       gconst.KeyTokenSecret,
+	}
+)
+
+const (
+	deleteRolesYes = "Yes"
+	deleteRolesNo  = "No"
+)
+
+type Params struct {
+	args map[string]string
+}
+
+func RegisterFlags(cmd *cobra.Command) {
+	vmFlag = cmd.Flags().String(gconst.FlagVirtualMachine, "flightcrew-control-tower", "The name of the Flightcrew tower VM to delete.")
+	projectFlag = cmd.Flags().StringP(gconst.FlagProject, "p", "", "Specify your Google Project ID.")
+	zoneFlag = cmd.Flags().StringP(gconst.FlagZone, "l", "us-central1-c", "The zone that your Tower is in.")
+	serviceAccountFlag = cmd.Flags().String(gconst.FlagServiceAccount, "flightcrew-runner", "The name of the IAM service account that runs the Flightcrew tower.")
+	deleteRolesFlag = cmd.Flags().Bool(gconst.FlagDeleteRoles, false, "Whether the Flightcrew custom IAM roles should be deleted as well. Other towers in the same organization may still use them.")
+	roleScopeFlag = cmd.Flags().String(gconst.FlagRoleScope, "", "Where the custom IAM roles were created ('organization' or 'project'), if the install fell back to the project. By default, the organization is used if there is one.")
+	monitoredProjectsFlag = cmd.Flags().String(gconst.FlagMonitoredProjects, "", "The other project IDs (comma-separated) that the tower was installed with, so that the service account's role bindings in them are removed too.")
+	networkFlag = cmd.Flags().String(gconst.FlagNetwork, "", "The VM's network, where the Cloud NAT, Cloud Router and IAP firewall rule for VMs without an external IP were created. By default, it's read from the VM, or the default network is used.")
+	tokenSecretFlag = cmd.Flags().String(gconst.FlagTokenSecret, "", "The Secret Manager secret with the API token, if the tower was installed with --token-secret. By default, it's read from the VM's metadata.")
+	confirmFlag = cmd.Flags().String(gconst.FlagConfirm, "", "The Google Project ID again to confirm the deletion (required with --non-interactive).")
+}
+
+func ParseFlags(cmd *cobra.Command) (Params, func(), error) {
+	// Changes are always made with gcloud, but the read-only checks can go through the
+	// API client without it (e.g. for --dry-run).
+	if !gcp.HasGcloudInPath() && gcp.API == nil {
+		return Params{}, nil, errors.New("gcloud is not in path")
+	}
+
+	params := Params{
+		args: make(map[string]string),
+	}
+
+	maybeAddEnv(params.args, gconst.KeyProject, *projectFlag)
+	maybeAddEnv(params.args, gconst.KeyZone, *zoneFlag)
+	maybeAddEnv(params.args, gconst.KeyVirtualMachine, *vmFlag)
+	maybeAddEnv(params.args, gconst.KeyIAMServiceAccount, *serviceAccountFlag)
+	maybeAddEnv(params.args, gconst.KeyMonitoredProjects, *monitoredProjectsFlag)
+	maybeAddEnv(params.args, gconst.KeyNetwork, *networkFlag)
+	maybeAddEnv(params.args, gconst.KeyTokenSecret, *tokenSecretFlag)
+	maybeAddEnv(params.args, gconst.KeyConfirm, *confirmFlag)
+
+	switch *roleScopeFlag {
+	case "", gconst.RoleScopeOrganization, gconst.RoleScopeProject:
+		maybeAddEnv(params.args, gconst.KeyRoleScope, *roleScopeFlag)
+	default:
+		return Params{}, nil, fmt.Errorf("invalid --%s flag: %s, %s", gconst.FlagRoleScope, gconst.RoleScopeOrganization, gconst.RoleScopeProject)
+	}
+
+	if *deleteRolesFlag {
+		params.args[gconst.KeyDeleteRoles] = deleteRolesYes
+	} else {
+		params.args[gconst.KeyDeleteRoles] = deleteRolesNo
+	}
+
+	return params, func() {}, nil
+}
+
+func maybeAddEnv(m map[string]string, key, value string) {
+	if len(value) > 0 {
+		m[key] = value
+	}
+}
+
+func recreateCommand(m map[string]string) string {
+	var commandName string
+	if len(os.Args) > 0 {
+		commandName = os.Args[0]
+	} else {
+		commandName = constants.CLIName
+	}
+
+	var buf strings.Builder
+	buf.WriteString(commandName)
+	buf.WriteString(" gcp uninstall")
+
+	for flagName, keyName := range flagToKey {
+		if val, ok := m[keyName]; ok && len(val) > 0 {
+			if keyName == gconst.KeyDeleteRoles {
+				if val != deleteRolesYes {
+					continue
+				}
+				val = "true"
+			}
+
+			buf.WriteString(" --")
+			buf.WriteString(flagName)
+			buf.WriteRune('=')
+			buf.WriteString(val)
+		}
+	}
+
+	return buf.String()
+}
@@ -0,0 +1,366 @@
+package gcpuninstall
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"flightcrew.io/cli/internal/constants"
+	"flightcrew.io/cli/internal/controller"
+	"flightcrew.io/cli/internal/controller/gcp"
+	gconst "flightcrew.io/cli/internal/controller/gcp/constants"
+	"flightcrew.io/cli/internal/view/command"
+)
+
+type RunController struct {
+	args     map[string]string
+	replacer *strings.Replacer
+	commands []*command.Model
+}
+
+func NewRunController(args map[string]string) *RunController {
+	roles := getRoles()
+
+	commands := make([]*command.Model, 0)
+	commands = append(commands, getVMCommands(args)...)
+	commands = append(commands, getPrivateNetworkCommands(args)...)
+	commands = append(commands, getTokenSecretCommands(args)...)
+	commands = append(commands, getUnbindIAMPolicyCommands(args, roles)...)
+	commands = append(commands, getServiceAccountCommands(args)...)
+	if args[gconst.KeyDeleteRoles] == deleteRolesYes {
+		commands = append(commands, getIAMRoleCommands(args, roles)...)
+	}
+
+	replaceArgs := make([]string, 0, 2*len(args))
+	for key, arg := range args {
+		replaceArgs = append(replaceArgs, key, arg)
+	}
+
+	replacer := strings.NewReplacer(replaceArgs...)
+	for _, cmd := range commands {
+		cmd.Replace(replacer)
+	}
+
+	return &RunController{
+		args:     args,
+		replacer: replacer,
+		commands: commands,
+	}
+}
+
+func (ctl RunController) Commands() []*command.Model {
+	return ctl.commands
+}
+
+func (ctl *RunController) GetEndController() controller.End {
+	return NewEndController(ctl.commands, ctl.replacer)
+}
+
+func (ctl RunController) RecreateCommand() string {
+	return recreateCommand(ctl.args)
+}
+
+func (ctl RunController) Args() map[string]string {
+	return ctl.args
+}
+
+// StateName is the project and the VM, which the default state file is named after.
+func (ctl RunController) StateName() string {
+	return ctl.args[gconst.KeyProject] + "-" + ctl.args[gconst.KeyVirtualMachine]
+}
+
+// getRoles returns every custom IAM role that `gcp install` could have created.
+func getRoles() []string {
+	roles := make([]string, 0)
+	for _, perms := range constants.PlatformPermissions {
+		for _, settings := range perms {
+			roles = append(roles, settings.Role)
+		}
+	}
+	sort.Strings(roles)
+	return roles
+}
+
+func getVMCommands(args map[string]string) []*command.Model {
+	checkVMExists := command.NewReadModel(command.Opts{
+		Check:       gcp.CheckInstanceExists(args[gconst.KeyProject], args[gconst.KeyZone], args[gconst.KeyVirtualMachine]),
+		Description: "Check if the Flightcrew VM exists.",
+		Command: `gcloud compute instances describe ${VIRTUAL_MACHINE} \
+	--project=${GOOGLE_PROJECT_ID} \
+	--zone=${ZONE} >/dev/null 2>&1`,
+		Message: map[command.State]string{
+			command.PassState: "Found the Flightcrew VM. Next step is to delete it.",
+			command.FailState: "No VM found. Nothing to delete.",
+		},
+	})
+
+	return []*command.Model{
+		checkVMExists,
+		command.NewWriteModel(command.Opts{
+			SkipIfFail:  checkVMExists,
+			Description: "Delete the VM instance that runs the Flightcrew Control Tower, along with its boot disk.",
+			Command: `gcloud compute instances delete ${VIRTUAL_MACHINE} \
+	--project=${GOOGLE_PROJECT_ID} \
+	--zone=${ZONE} \
+	--quiet`,
+		}),
+	}
+}
+
+// getPrivateNetworkCommands delete the firewall rule, Cloud NAT and Cloud Router that
+// `gcp install` creates for VMs without an external IP. Only the ones with Flightcrew's
+// names are deleted, so a NAT that the network already had is left alone.
+func getPrivateNetworkCommands(args map[string]string) []*command.Model {
+	networkProject, networkName := gcp.NetworkLocation(args[gconst.KeyNetwork], args[gconst.KeyProject])
+	replacer := strings.NewReplacer(
+		"${NETWORK_PROJECT}", networkProject,
+		"${NETWORK_NAME}", networkName,
+		"${REGION}", gcp.ZoneRegion(args[gconst.KeyZone]),
+		"${ROUTER}", gcp.NATRouterName,
+		"${NAT}", gcp.NATName,
+		"${FIREWALL_RULE}", gcp.IAPFirewallName,
+	)
+
+	checkFirewall := command.NewReadModel(command.Opts{
+		Description: "Check if the firewall rule that lets IAP SSH into the VM exists.",
+		Command:     `gcloud compute firewall-rules describe "${FIREWALL_RULE}" --project="${NETWORK_PROJECT}" >/dev/null 2>&1`,
+		Message: map[command.State]string{
+			command.PassState: "Found the firewall rule. Next step is to delete it.",
+			command.FailState: "No firewall rule found. Nothing to delete.",
+		},
+	})
+	checkNAT := command.NewReadModel(command.Opts{
+		Description: "Check if the Flightcrew Cloud NAT exists in ${REGION}.",
+		Command:     `gcloud compute routers nats describe "${NAT}" --router="${ROUTER}" --project="${NETWORK_PROJECT}" --region="${REGION}" >/dev/null 2>&1`,
+		Message: map[command.State]string{
+			command.PassState: "Found the Cloud NAT. Next step is to delete it.",
+			command.FailState: "No Cloud NAT found. Nothing to delete.",
+		},
+	})
+	checkRouter := command.NewReadModel(command.Opts{
+		Description: "Check if the Flightcrew Cloud Router exists in ${REGION}.",
+		Command:     `gcloud compute routers describe "${ROUTER}" --project="${NETWORK_PROJECT}" --region="${REGION}" >/dev/null 2>&1`,
+		Message: map[command.State]string{
+			command.PassState: "Found the Cloud Router. Next step is to delete it.",
+			command.FailState: "No Cloud Router found. Nothing to delete.",
+		},
+	})
+
+	commands := []*command.Model{
+		checkFirewall,
+		command.NewWriteModel(command.Opts{
+			SkipIfFail:  checkFirewall,
+			Description: "This command deletes the firewall rule that let IAP's TCP forwarding SSH into the VM.\n\nhttps://cloud.google.com/iap/docs/using-tcp-forwarding",
+			Command: `gcloud compute firewall-rules delete "${FIREWALL_RULE}" \
+	--project="${NETWORK_PROJECT}" \
+	--quiet`,
+		}),
+		checkNAT,
+		command.NewWriteModel(command.Opts{
+			SkipIfFail:  checkNAT,
+			Description: "This command deletes the Cloud NAT that let the VM reach Artifact Registry and the Flightcrew API without an external IP. Other VMs in the `${NETWORK_NAME}` network's subnetwork lose it too.\n\nhttps://cloud.google.com/nat/docs/overview",
+			Command: `gcloud compute routers nats delete "${NAT}" \
+	--project="${NETWORK_PROJECT}" \
+	--router="${ROUTER}" \
+	--region="${REGION}" \
+	--quiet`,
+		}),
+		checkRouter,
+		command.NewWriteModel(command.Opts{
+			SkipIfFail:  checkRouter,
+			Description: "This command deletes the Cloud Router that the Cloud NAT was on.\n\nhttps://cloud.google.com/nat/docs/gce-example",
+			Command: `gcloud compute routers delete "${ROUTER}" \
+	--project="${NETWORK_PROJECT}" \
+	--region="${REGION}" \
+	--quiet`,
+		}),
+	}
+	for _, cmd := range commands {
+		cmd.Replace(replacer)
+	}
+	return commands
+}
+
+// getTokenSecretCommands delete the secret with the API token and the service account's
+// access to it, if the tower read its token from one.
+func getTokenSecretCommands(args map[string]string) []*command.Model {
+	if len(args[gconst.KeyTokenSecret]) == 0 {
+		return nil
+	}
+
+	checkAccessor := command.NewReadModel(command.Opts{
+		Description: "Check if the service account can read the `${TOKEN_SECRET}` secret.",
+		Command:     `gcloud secrets get-iam-policy "${TOKEN_SECRET}" --project="${GOOGLE_PROJECT_ID}" --flatten=bindings --filter="bindings.role=roles/secretmanager.secretAccessor" --format="value(bindings.members)" 2>/dev/null | grep --quiet "serviceAccount:${SERVICE_ACCOUNT}@${GOOGLE_PROJECT_ID}.iam.gserviceaccount.com"`,
+		Message: map[command.State]string{
+			command.PassState: "Binding exists. Next step is to remove it.",
+			command.FailState: "Binding doesn't exist. Nothing to remove.",
+		},
+	})
+	checkSecret := command.NewReadModel(command.Opts{
+		Description: "Check if the `${TOKEN_SECRET}` secret exists.",
+		Command:     `gcloud secrets describe "${TOKEN_SECRET}" --project="${GOOGLE_PROJECT_ID}" >/dev/null 2>&1`,
+		Message: map[command.State]string{
+			command.PassState: "Found the secret. Next step is to delete it.",
+			command.FailState: "No secret found. Nothing to delete.",
+		},
+	})
+
+	return []*command.Model{
+		checkAccessor,
+		command.NewWriteModel(command.Opts{
+			SkipIfFail: checkAccessor,
+			Description: `This command removes the service account's access to the secret with the API token.
+
+https://cloud.google.com/secret-manager/docs/manage-access-to-secrets`,
+			Command: `gcloud secrets remove-iam-policy-binding "${TOKEN_SECRET}" \
+	--project="${GOOGLE_PROJECT_ID}" \
+	--member=serviceAccount:"${SERVICE_ACCOUNT}@${GOOGLE_PROJECT_ID}.iam.gserviceaccount.com" \
+	--role="roles/secretmanager.secretAccessor"`,
+		}),
+		checkSecret,
+		command.NewWriteModel(command.Opts{
+			SkipIfFail: checkSecret,
+			Description: `This command deletes the secret with the API token, along with all of its versions.
+
+https://cloud.google.com/secret-manager/docs/delete-secret`,
+			Command: `gcloud secrets delete "${TOKEN_SECRET}" \
+	--project="${GOOGLE_PROJECT_ID}" \
+	--quiet`,
+		}),
+	}
+}
+
+// getUnbindIAMPolicyCommands remove the service account's role bindings in the tower's
+// project, and in each monitored project like `gcp install` bound them.
+func getUnbindIAMPolicyCommands(args map[string]string, roles []string) []*command.Model {
+	commands := getUnbindIAMPolicyCommandsForProject(args, roles, args[gconst.KeyProject], args[gconst.KeyProjectOrOrgSlash])
+	for _, project := range gcp.ParseMonitoredProjectRoles(args[gconst.KeyMonitoredProjectRoles]) {
+		commands = append(commands, getUnbindIAMPolicyCommandsForProject(args, roles, project.ID, project.RoleParent)...)
+	}
+	return commands
+}
+
+// getUnbindIAMPolicyCommandsForProject removes the service account's bindings to the roles
+// under the parent (e.g. `organizations/1234`) in the project.
+func getUnbindIAMPolicyCommandsForProject(args map[string]string, roles []string, project string, parent string) []*command.Model {
+	commands := make([]*command.Model, 0, 2*len(roles))
+	for _, role := range roles {
+		replacer := strings.NewReplacer(
+			"${ROLE}", role,
+			"${BINDING_PROJECT_ID}", project,
+			"${ROLE_PARENT}", parent,
+		)
+
+		checkPolicy := command.NewReadModel(command.Opts{
+			Check:       gcp.CheckBindingExists(project, parent+"/roles/"+role, "serviceAccount:"+serviceAccountEmail(args)),
+			Description: "Check if the service account is bound to the `${ROLE}` IAM role in `${BINDING_PROJECT_ID}`.",
+			Command:     `gcloud projects get-iam-policy ${BINDING_PROJECT_ID} --filter="bindings.role=${ROLE_PARENT}/roles/${ROLE}"  --flatten=bindings --format="table(bindings.members,bindings.role)" | grep --quiet "'serviceAccount:${SERVICE_ACCOUNT}@${GOOGLE_PROJECT_ID}.iam.gserviceaccount.com'"`,
+			Message: map[command.State]string{
+				command.PassState: "Binding exists. Next step is to remove it.",
+				command.FailState: "Binding doesn't exist. Nothing to remove.",
+			},
+		})
+		checkPolicy.Replace(replacer)
+
+		removePolicy := command.NewWriteModel(command.Opts{
+			SkipIfFail: checkPolicy,
+			Description: `This command removes the ` + "`${ROLE}`" + ` IAM role in ` + "`${BINDING_PROJECT_ID}`" + ` from Flightcrew's service account.
+
+https://cloud.google.com/iam/docs/granting-changing-revoking-access`,
+			Command: `gcloud projects remove-iam-policy-binding "${BINDING_PROJECT_ID}" \
+	--member=serviceAccount:"${SERVICE_ACCOUNT}@${GOOGLE_PROJECT_ID}.iam.gserviceaccount.com" \
+	--role="${ROLE_PARENT}/roles/${ROLE}" \
+	--condition=None`,
+		})
+		removePolicy.Replace(replacer)
+
+		commands = append(commands, checkPolicy, removePolicy)
+	}
+
+	return commands
+}
+
+func getServiceAccountCommands(args map[string]string) []*command.Model {
+	checkServiceAccount := command.NewReadModel(command.Opts{
+		Check:       gcp.CheckServiceAccountExists(args[gconst.KeyProject], serviceAccountEmail(args)),
+		Description: "Check if the Flightcrew service account exists.",
+		Command:     `gcloud iam service-accounts describe --project="${GOOGLE_PROJECT_ID}" "${SERVICE_ACCOUNT}@${GOOGLE_PROJECT_ID}.iam.gserviceaccount.com" > /dev/null 2>&1`,
+		Message: map[command.State]string{
+			command.PassState: "Found the service account. Next step is to delete it.",
+			command.FailState: "No service account found. Nothing to delete.",
+		},
+	})
+
+	return []*command.Model{
+		checkServiceAccount,
+		command.NewWriteModel(command.Opts{
+			SkipIfFail: checkServiceAccount,
+			Description: `This command deletes the service account that ran the Flightcrew Control Tower.
+
+https://cloud.google.com/iam/docs/creating-managing-service-accounts#deleting`,
+			Command: `gcloud iam service-accounts delete "${SERVICE_ACCOUNT}@${GOOGLE_PROJECT_ID}.iam.gserviceaccount.com" \
+	--project="${GOOGLE_PROJECT_ID}" \
+	--quiet`,
+		}),
+	}
+}
+
+// getIAMRoleCommands delete the roles under the tower project's organization (or the
+// project itself), and the ones that were created for the monitored projects.
+func getIAMRoleCommands(args map[string]string, roles []string) []*command.Model {
+	commands := make([]*command.Model, 0)
+	for _, parent := range gcp.RoleParents(args[gconst.KeyProjectOrOrgSlash], gcp.ParseMonitoredProjectRoles(args[gconst.KeyMonitoredProjectRoles])) {
+		commands = append(commands, getIAMRoleCommandsForParent(roles, parent)...)
+	}
+	return commands
+}
+
+func getIAMRoleCommandsForParent(roles []string, parent string) []*command.Model {
+	commands := make([]*command.Model, 0, 2*len(roles))
+	for _, role := range roles {
+		replacer := strings.NewReplacer(
+			"${ROLE}", role,
+			"${PROJECT_OR_ORG_FLAG}", roleParentFlag(parent),
+		)
+
+		// Deleted roles can still be described, so check that it hasn't been deleted already.
+		checkRole := command.NewReadModel(command.Opts{
+			Check:       gcp.CheckRoleActive(parent, role),
+			Description: "Check if the `${ROLE}` IAM role exists.",
+			Command: `gcloud iam roles describe \${PROJECT_OR_ORG_FLAG}
+	"${ROLE}" --format="value(name,deleted)" 2>/dev/null | grep --quiet --invert-match "True"`,
+			Message: map[command.State]string{
+				command.PassState: "Found the IAM role. Next step is to delete it.",
+				command.FailState: "No IAM role found. Nothing to delete.",
+			},
+		})
+		checkRole.Replace(replacer)
+
+		deleteRole := command.NewWriteModel(command.Opts{
+			SkipIfFail: checkRole,
+			Description: `This command deletes the ` + "`${ROLE}`" + ` IAM role. Deleted roles can be undeleted within 7 days.
+
+https://cloud.google.com/iam/docs/creating-custom-roles#deleting-custom-role`,
+			Command: `gcloud iam roles delete ${ROLE} \${PROJECT_OR_ORG_FLAG}
+	--quiet`,
+		})
+		deleteRole.Replace(replacer)
+
+		commands = append(commands, checkRole, deleteRole)
+	}
+
+	return commands
+}
+
+// roleParentFlag is the gcloud flag for the role parent, like `${PROJECT_OR_ORG_FLAG}`.
+func roleParentFlag(parent string) string {
+	kind, id, _ := strings.Cut(parent, "/")
+	if kind == "organizations" {
+		return fmtFlagForReplace("organization", id)
+	}
+	return fmtFlagForReplace("project", id)
+}
+
+func serviceAccountEmail(args map[string]string) string {
+	return fmt.Sprintf("%s@%s.iam.gserviceaccount.com", args[gconst.KeyIAMServiceAccount], args[gconst.KeyProject])
+}
@@ -0,0 +1,245 @@
+package gcpuninstall
+
+import (
+	"bytes"
+	"regexp"
+	"testing"
+
+	gconst "flightcrew.io/cli/internal/controller/gcp/constants"
+	"flightcrew.io/cli/internal/runner"
+	"flightcrew.io/cli/internal/view"
+	"flightcrew.io/cli/internal/view/wrapinput"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUninstallFlow(mainT *testing.T) {
+	keepRunner := runner.Default
+	mainT.Cleanup(func() {
+		runner.Default = keepRunner
+	})
+
+	listProjects := runner.Response{Match: `^gcloud projects list`, Stdout: "project_id\nmy-project\n"}
+	newParams := func(deleteRoles string) Params {
+		return Params{
+			args: map[string]string{
+				gconst.KeyProject:           "my-project",
+				gconst.KeyVirtualMachine:    "flightcrew-control-tower",
+				gconst.KeyZone:              "us-central1-c",
+				gconst.KeyIAMServiceAccount: "flightcrew-runner",
+				gconst.KeyDeleteRoles:       deleteRoles,
+				gconst.KeyConfirm:           "my-project",
+			},
+		}
+	}
+
+	mainT.Run("existing tower should be deleted", func(t *testing.T) {
+		scripted, err := runner.NewScripted(
+			listProjects,
+			runner.Response{Match: `^gcloud projects get-ancestors`, Stdout: "1234\n"},
+			runner.Response{Match: `^gcloud `},
+		)
+		require.NoError(t, err)
+		runner.Default = scripted
+
+		var out bytes.Buffer
+		require.NoError(t, view.RunHeadless(NewInputsController(newParams(deleteRolesYes)), &out))
+
+		assertCalls(t, []string{
+			`^gcloud compute instances delete flightcrew-control-tower --project=my-project --zone=us-central1-c --quiet$`,
+			`^gcloud compute firewall-rules delete "flightcrew-allow-iap-ssh" --project="my-project" --quiet$`,
+			`^gcloud compute routers nats delete "flightcrew-nat" --project="my-project" --router="flightcrew-router" --region="us-central1" --quiet$`,
+			`^gcloud compute routers delete "flightcrew-router" --project="my-project" --region="us-central1" --quiet$`,
+			`^gcloud projects remove-iam-policy-binding "my-project" --member=serviceAccount:"flightcrew-runner@my-project\.iam\.gserviceaccount\.com" --role="organizations/1234/roles/`,
+			`^gcloud iam service-accounts delete "flightcrew-runner@my-project\.iam\.gserviceaccount\.com" --project="my-project" --quiet$`,
+			`^gcloud iam roles delete \S+ --organization=1234 --quiet$`,
+		}, scripted.Calls)
+	})
+
+	mainT.Run("missing resources should be skipped", func(t *testing.T) {
+		scripted, err := runner.NewScripted(
+			listProjects,
+			runner.Response{Match: `^gcloud projects get-ancestors`, ExitCode: 1},
+			runner.Response{Match: `^gcloud (compute instances describe|compute firewall-rules describe|compute routers (nats )?describe|projects get-iam-policy|iam service-accounts describe|iam roles describe) `, ExitCode: 1},
+			runner.Response{Match: `^gcloud `},
+		)
+		require.NoError(t, err)
+		runner.Default = scripted
+
+		var out bytes.Buffer
+		require.NoError(t, view.RunHeadless(NewInputsController(newParams(deleteRolesYes)), &out))
+
+		assert.Contains(t, out.String(), "No VM found. Nothing to delete.")
+		for _, call := range scripted.Calls {
+			assert.NotRegexp(t, `^gcloud (compute instances delete|compute firewall-rules delete|compute routers (nats )?delete|projects remove-iam-policy-binding|iam service-accounts delete|iam roles delete) `, call)
+		}
+	})
+
+	mainT.Run("roles should be kept unless they're deleted too", func(t *testing.T) {
+		scripted, err := runner.NewScripted(
+			listProjects,
+			runner.Response{Match: `^gcloud `},
+		)
+		require.NoError(t, err)
+		runner.Default = scripted
+
+		var out bytes.Buffer
+		require.NoError(t, view.RunHeadless(NewInputsController(newParams(deleteRolesNo)), &out))
+
+		for _, call := range scripted.Calls {
+			assert.NotRegexp(t, `^gcloud iam roles `, call)
+		}
+	})
+
+	mainT.Run("monitored projects should be unbound and their roles deleted", func(t *testing.T) {
+		scripted, err := runner.NewScripted(
+			listProjects,
+			runner.Response{Match: `^gcloud projects get-ancestors "?my-project"? `, Stdout: "1234\n"},
+			runner.Response{Match: `^gcloud projects get-ancestors "?other-project"? `, Stdout: "5678\n"},
+			runner.Response{Match: `^gcloud projects get-ancestors `, ExitCode: 1},
+			runner.Response{Match: `^gcloud `},
+		)
+		require.NoError(t, err)
+		runner.Default = scripted
+
+		params := newParams(deleteRolesYes)
+		params.args[gconst.KeyMonitoredProjects] = "other-project,no-org-project,my-project"
+		ctl := NewInputsController(params)
+		require.NoError(t, view.ValidateInputs(ctl))
+		assert.Contains(t, ctl.inputs[gconst.KeyMonitoredProjects].View(wrapinput.ViewParams{ShowValue: true}), "other-project (roles in organizations/5678), no-org-project (roles in projects/no-org-project)")
+
+		var out bytes.Buffer
+		require.NoError(t, view.RunHeadless(ctl, &out))
+		assertCalls(t, []string{
+			`^gcloud projects remove-iam-policy-binding "my-project" .* --role="organizations/1234/roles/`,
+			`^gcloud projects remove-iam-policy-binding "other-project" .* --role="organizations/5678/roles/`,
+			`^gcloud projects remove-iam-policy-binding "no-org-project" .* --role="projects/no-org-project/roles/`,
+			`^gcloud iam service-accounts delete `,
+			`^gcloud iam roles delete \S+ --organization=1234 --quiet$`,
+			`^gcloud iam roles delete \S+ --organization=5678 --quiet$`,
+			`^gcloud iam roles delete \S+ --project=no-org-project --quiet$`,
+		}, scripted.Calls)
+	})
+
+	mainT.Run("shared vpc network should be read from the vm", func(t *testing.T) {
+		scripted, err := runner.NewScripted(
+			listProjects,
+			runner.Response{Match: `^gcloud compute instances describe "flightcrew-control-tower" .*--format=json`, Stdout: `{
+				"name": "flightcrew-control-tower",
+				"networkInterfaces": [{"network": "https://www.googleapis.com/compute/v1/projects/host-project/global/networks/shared-vpc", "networkIP": "10.0.0.2"}]
+			}`},
+			runner.Response{Match: `^gcloud `},
+		)
+		require.NoError(t, err)
+		runner.Default = scripted
+
+		ctl := NewInputsController(newParams(deleteRolesNo))
+		require.NoError(t, view.ValidateInputs(ctl))
+		assert.Contains(t, ctl.RecreateCommand(), " --network=projects/host-project/global/networks/shared-vpc")
+
+		var out bytes.Buffer
+		require.NoError(t, view.RunHeadless(ctl, &out))
+		assertCalls(t, []string{
+			`^gcloud compute firewall-rules delete "flightcrew-allow-iap-ssh" --project="host-project" --quiet$`,
+			`^gcloud compute routers nats delete "flightcrew-nat" --project="host-project" --router="flightcrew-router" --region="us-central1" --quiet$`,
+			`^gcloud compute routers delete "flightcrew-router" --project="host-project" --region="us-central1" --quiet$`,
+		}, scripted.Calls)
+	})
+
+	mainT.Run("token secret should be deleted with its binding", func(t *testing.T) {
+		scripted, err := runner.NewScripted(
+			listProjects,
+			runner.Response{Match: `^gcloud compute instances describe "flightcrew-control-tower" .*--format=json`, Stdout: `{
+				"name": "flightcrew-control-tower",
+				"metadata": {"items": [{"key": "flightcrew-token-secret", "value": "projects/my-project/secrets/flightcrew-api-token/versions/latest"}]}
+			}`},
+			runner.Response{Match: `^gcloud `},
+		)
+		require.NoError(t, err)
+		runner.Default = scripted
+
+		ctl := NewInputsController(newParams(deleteRolesNo))
+		require.NoError(t, view.ValidateInputs(ctl))
+		assert.Contains(t, ctl.RecreateCommand(), " --token-secret=flightcrew-api-token")
+
+		var out bytes.Buffer
+		require.NoError(t, view.RunHeadless(ctl, &out))
+		assertCalls(t, []string{
+			`^gcloud compute instances delete flightcrew-control-tower `,
+			`^gcloud secrets remove-iam-policy-binding "flightcrew-api-token" --project="my-project" --member=serviceAccount:"flightcrew-runner@my-project\.iam\.gserviceaccount\.com" --role="roles/secretmanager\.secretAccessor"$`,
+			`^gcloud secrets delete "flightcrew-api-token" --project="my-project" --quiet$`,
+			`^gcloud iam service-accounts delete `,
+		}, scripted.Calls)
+	})
+
+	mainT.Run("missing token secret should be skipped", func(t *testing.T) {
+		scripted, err := runner.NewScripted(
+			listProjects,
+			runner.Response{Match: `^gcloud secrets (get-iam-policy|describe) `, ExitCode: 1},
+			runner.Response{Match: `^gcloud `},
+		)
+		require.NoError(t, err)
+		runner.Default = scripted
+
+		params := newParams(deleteRolesNo)
+		params.args[gconst.KeyTokenSecret] = "flightcrew-api-token"
+		var out bytes.Buffer
+		require.NoError(t, view.RunHeadless(NewInputsController(params), &out))
+
+		assert.Contains(t, out.String(), "No secret found. Nothing to delete.")
+		for _, call := range scripted.Calls {
+			assert.NotRegexp(t, `^gcloud secrets (remove-iam-policy-binding|delete) `, call)
+		}
+	})
+
+	mainT.Run("failed delete should stop the flow", func(t *testing.T) {
+		scripted, err := runner.NewScripted(
+			listProjects,
+			runner.Response{Match: `^gcloud compute instances delete `, ExitCode: 1},
+			runner.Response{Match: `^gcloud `},
+		)
+		require.NoError(t, err)
+		runner.Default = scripted
+
+		var out bytes.Buffer
+		require.Error(t, view.RunHeadless(NewInputsController(newParams(deleteRolesNo)), &out))
+
+		assert.Contains(t, out.String(), "To return to the same values:")
+		for _, call := range scripted.Calls {
+			assert.NotRegexp(t, `^gcloud (projects remove-iam-policy-binding|iam service-accounts delete) `, call)
+		}
+	})
+
+	mainT.Run("mismatched confirmation should fail validation", func(t *testing.T) {
+		scripted, err := runner.NewScripted(
+			listProjects,
+			runner.Response{Match: `^gcloud `},
+		)
+		require.NoError(t, err)
+		runner.Default = scripted
+
+		params := newParams(deleteRolesNo)
+		params.args[gconst.KeyConfirm] = "other-project"
+		var out bytes.Buffer
+		err = view.RunHeadless(NewInputsController(params), &out)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "does not match the Project ID")
+		for _, call := range scripted.Calls {
+			assert.NotRegexp(t, `^gcloud compute instances delete `, call)
+		}
+	})
+}
+
+func assertCalls(t *testing.T, expected []string, calls []string) {
+	t.Helper()
+
+	i := 0
+	for _, call := range calls {
+		if i < len(expected) && regexp.MustCompile(expected[i]).MatchString(call) {
+			i++
+		}
+	}
+	if i < len(expected) {
+		assert.Fail(t, "missing call", "no call matching %q in:\n%v", expected[i], calls)
+	}
+}
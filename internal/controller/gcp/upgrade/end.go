@@ -2,9 +2,9 @@ package gcpupgrade
 
 import (
 	"bytes"
-	"os/exec"
 	"strings"
 
+	"flightcrew.io/cli/internal/runner"
 	"flightcrew.io/cli/internal/style"
 	"flightcrew.io/cli/internal/view/command"
 )
@@ -14,12 +14,15 @@ type EndController struct {
 	endDescription string
 	commands       []*command.Model
 	vmIsUp         bool
+	// private is whether the VM has no external IP, so it's only reachable through IAP.
+	private bool
 }
 
-func NewEndController(commands []*command.Model, replacer *strings.Replacer) *EndController {
+func NewEndController(commands []*command.Model, replacer *strings.Replacer, private bool) *EndController {
 	return &EndController{
 		commands: commands,
 		replacer: replacer,
+		private:  private,
 	}
 }
 
@@ -35,12 +38,14 @@ func (ctl *EndController) EndDescription() string {
 	rerender := false
 	if !ctl.vmIsUp {
 		// Checks to see if the SSH port (22) is open for the VM. If it is, then the user
-		// should be able to SSH into the machine.
-		cmd := exec.Command("bash", "-c", `$(gcloud compute instances list --format="csv(NAME,EXTERNAL_IP,STATUS)" --project=${PROJECT_ID} --zones=${ZONE} | awk -F "," "/${VIRTUAL_MACHINE}/{print f(2)} function f(n){return (\$n==\"\" ? \"null\" : \$n)}" | nc -w 1 -z`)
+		// should be able to SSH into the machine. VMs without an external IP can't be reached
+		// from here, so they're up once they're running.
+		cmd := ctl.replacer.Replace(`nc -w 1 -z $(gcloud compute instances list --format="csv(NAME,EXTERNAL_IP,STATUS)" --project=${GOOGLE_PROJECT_ID} --zones=${ZONE} | awk -F "," "/${VIRTUAL_MACHINE}/{print f(2)} function f(n){return (\$n==\"\" ? \"null\" : \$n)}") 22`)
+		if ctl.private {
+			cmd = ctl.replacer.Replace(`gcloud compute instances describe ${VIRTUAL_MACHINE} --project=${GOOGLE_PROJECT_ID} --zone=${ZONE} --format="value(status)" | grep --quiet RUNNING`)
+		}
 		var b bytes.Buffer
-		cmd.Stdout = &b
-		cmd.Stderr = &b
-		err := cmd.Run()
+		err := runner.Run(cmd, &b, &b)
 		if err == nil {
 			ctl.vmIsUp = true
 			rerender = true
@@ -62,7 +67,7 @@ http://replace.me
 Alternatively, see your new VM in action:
 ${CODE_START}
 # SSH into the created VM.
-gcloud compute ssh ${VIRTUAL_MACHINE} --project ${GOOGLE_PROJECT_ID} --zone ${ZONE}
+gcloud compute ssh ${VIRTUAL_MACHINE} --project ${GOOGLE_PROJECT_ID} --zone ${ZONE}${SSH_FLAGS}
 # Follow the new container's logs.
 docker logs --follow $(docker ps -f name="${IMAGE_PATH}:${TOWER_VERSION}" --format="{{.ID}}")
 ${CODE_END}
@@ -70,14 +75,22 @@ ${CODE_END}
 Once your Tower is up, head on over to ${APP_URL} to see the info your Tower collected.
 `
 
+	// VMs without an external IP are SSHed into through IAP, which needs the IAP-secured
+	// Tunnel User role.
+	if ctl.private {
+		description = strings.Replace(description, "${SSH_FLAGS}", " --tunnel-through-iap", 1)
+	} else {
+		description = strings.Replace(description, "${SSH_FLAGS}", "", 1)
+	}
+
 	link = ctl.replacer.Replace(link)
 	description = ctl.replacer.Replace(description)
 	description = strings.Replace(description, "${CODE_START}", "```sh", 1)
 	description = strings.Replace(description, "${CODE_END}", "```", 1)
 	if ctl.vmIsUp {
-		description = strings.Replace(description, "${MESSAGE}", "⏱ Your VM is still starting up.", 1)
-	} else {
 		description = strings.Replace(description, "${MESSAGE}", "✅ Your VM is available and running!", 1)
+	} else {
+		description = strings.Replace(description, "${MESSAGE}", "⏱ Your VM is still starting up.", 1)
 	}
 
 	out, _ := style.Glamour.Render(description)
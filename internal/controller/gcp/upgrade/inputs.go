@@ -1,18 +1,19 @@
 package gcpupgrade
 
 import (
-	"bytes"
-	"encoding/csv"
 	"errors"
 	"fmt"
-	"io"
-	"os/exec"
+	"os"
+	"path/filepath"
 	"strings"
 
+	"flightcrew.io/cli/internal/apitoken"
+	"flightcrew.io/cli/internal/constants"
 	"flightcrew.io/cli/internal/controller"
 	"flightcrew.io/cli/internal/controller/gcp"
 	gconst "flightcrew.io/cli/internal/controller/gcp/constants"
 	"flightcrew.io/cli/internal/debug"
+	"flightcrew.io/cli/internal/semver"
 	"flightcrew.io/cli/internal/view/wrapinput"
 )
 
@@ -23,12 +24,47 @@ var (
 		gconst.KeyZone,
 		gconst.KeyTowerVersion,
 	}
+
+	containerEnvHelpTexts = map[string]string{
+		"CLOUD_PLATFORM":              "CLOUD_PLATFORM is the platform that the tower manages (e.g. `gae_std` or `gce`).",
+		"TRAFFIC_ROUTER":              "TRAFFIC_ROUTER is the platform that the tower routes traffic through, if it's different from CLOUD_PLATFORM.",
+		"METRIC_PROVIDERS":            "METRIC_PROVIDERS is where the tower reads metrics from (e.g. `stackdriver`).",
+		"APPENGINE_MAX_VERSION_COUNT": "APPENGINE_MAX_VERSION_COUNT is the number of App Engine versions that the tower keeps for each service.",
+		"APPENGINE_MAX_VERSION_AGE":   "APPENGINE_MAX_VERSION_AGE is the age (e.g. `720h`) after which the tower deletes App Engine versions that aren't serving traffic.",
+		"FC_RPC_CONNECT_HOST":         "FC_RPC_CONNECT_HOST is the Flightcrew host that the tower connects to.",
+		"FC_RPC_CONNECT_PORT":         "FC_RPC_CONNECT_PORT is the port of the Flightcrew host that the tower connects to.",
+		"FC_TOWER_PORT":               "FC_TOWER_PORT is the port that the tower listens on.",
+	}
 )
 
 type InputsController struct {
+	tempDir   string
 	inputs    map[string]*wrapinput.Model
 	args      map[string]string
 	inputKeys []string
+
+	// currentEnv is the container env of the VM when it was found, or nil if the VM hasn't
+	// been found yet or doesn't have a container declaration.
+	currentEnv map[string]string
+	// loadedVM is the project, zone and name of the VM that currentEnv was read from.
+	loadedVM string
+	// flagEnvs are the container envs that were passed in as flags, which are kept instead
+	// of the VM's values.
+	flagEnvs map[string]struct{}
+
+	// versions are the tower's tags to pick from, or nil if they couldn't be listed.
+	versions []gcp.TowerVersion
+	// runningVersion is the tower version that the VM is running, if it's known.
+	runningVersion string
+	// tokenSecretRef is the secret version that the VM reads its tower's API token from, or
+	// empty if the token is passed in as a container env.
+	tokenSecretRef string
+	// tokenFile has the new API token to add to the secret, if there is one.
+	tokenFile string
+
+	// versionWarning describes a change from the running version that has to be confirmed
+	// (e.g. a downgrade), or is empty if there isn't one.
+	versionWarning string
 }
 
 func NewInputsController(params Params) *InputsController {
@@ -36,6 +72,8 @@ func NewInputsController(params Params) *InputsController {
 		inputKeys: initialInputKeys,
 		inputs:    make(map[string]*wrapinput.Model),
 		args:      params.args,
+		tempDir:   params.tempDir,
+		flagEnvs:  make(map[string]struct{}),
 	}
 
 	if !contains(ctl.args, gconst.KeyVirtualMachine) {
@@ -60,15 +98,7 @@ func NewInputsController(params Params) *InputsController {
 
 		switch key {
 		case gconst.KeyProject:
-			input = wrapinput.NewFreeForm()
-			input.Freeform.CharLimit = 0
-			input.Freeform.Placeholder = "project-id-1234"
-			if project, err := gcp.GetProjectFromEnvironment(); err == nil && len(project) > 0 {
-				input.Freeform.Placeholder = project
-			}
-			input.Title = "Project ID"
-			input.HelpText = "Project ID is the unique string identifier for your Google Cloud Platform project."
-			input.Required = true
+			input, _ = gcp.NewProjectInput()
 			maybeSetValue(gconst.KeyProject)
 
 		case gconst.KeyVirtualMachine:
@@ -91,18 +121,45 @@ func NewInputsController(params Params) *InputsController {
 			maybeSetValue(gconst.KeyZone)
 
 		case gconst.KeyTowerVersion:
+			input, ctl.versions = gcp.NewTowerVersionInput()
+			maybeSetValue(gconst.KeyTowerVersion)
+
+		case gconst.KeyConfirmVersion:
 			input = wrapinput.NewFreeForm()
-			input.Freeform.Placeholder = "stable"
+			input.Freeform.Placeholder = "x.x.x"
 			input.Freeform.CharLimit = 32
-			input.Title = "Tower Version"
-			input.HelpText = "Tower Version is the version of the Tower image that will be installed. (recommended: `stable`)"
-			maybeSetValue(gconst.KeyTowerVersion)
+			input.Title = "Confirm Version"
+			input.HelpText = "Type the x.x.x version again to confirm the downgrade or major version upgrade of the tower. Going back to an older tower version may not be supported."
+			maybeSetValue(gconst.KeyConfirmVersion)
+
+		case gconst.KeyAPIToken:
+			input = wrapinput.NewFreeForm()
+			input.Freeform.Placeholder = "api-token"
+			input.Freeform.CharLimit = 0
+			input.Title = "New API Token"
+			input.HelpText = "New API Token is added as a new version of the Tower's Secret Manager secret, which it reads when it restarts.\nLeave blank to keep the current token."
+			maybeSetValue(gconst.KeyAPIToken)
 
 		}
 
 		input.Blur()
 		ctl.inputs[key] = &input
 	}
+
+	for _, name := range containerEnvs {
+		key := containerEnvKey(name)
+		input := wrapinput.NewFreeForm()
+		input.Freeform.CharLimit = 0
+		input.Title = name
+		input.HelpText = containerEnvHelpTexts[name] + " Clear it to remove it from the container."
+		if val, ok := ctl.args[key]; ok {
+			input.SetValue(val)
+			ctl.flagEnvs[name] = struct{}{}
+		}
+
+		input.Blur()
+		ctl.inputs[key] = &input
+	}
 	return ctl
 }
 
@@ -150,29 +207,246 @@ func (ctl *InputsController) Validate(inputs []*wrapinput.Model) bool {
 			debug.Output("convert tower version is %s", version)
 
 		case gconst.KeyVirtualMachine:
-			projectInput := ctl.inputs[gconst.KeyProject]
-			var err error
-			ctl.args[gconst.KeyVirtualMachineIP], err = ctl.getVirtualMachineIP(projectInput.Value(), ctl.inputs[gconst.KeyZone].Value(), input.Value())
+			projectID := ctl.inputs[gconst.KeyProject].Value()
+			zone := ctl.inputs[gconst.KeyZone].Value()
+			instance, err := ctl.getInstance(projectID, zone, input.Value())
 			if setError(err) {
 				break
 			}
 
-			if ipAddr := ctl.args[gconst.KeyVirtualMachineIP]; len(ipAddr) > 0 {
-				input.SetInfo(fmt.Sprintf("found VM with IP %s", ipAddr))
-			} else {
+			// VMs without an external IP are reached through IAP at their internal IP.
+			ctl.args[gconst.KeyVirtualMachineIP] = instance.ExternalIP
+			ctl.args[gconst.KeyExternalIP] = gconst.ExternalIPEphemeral
+			ctl.args[gconst.KeyNetwork] = instance.Network
+			if instance.Private {
+				ctl.args[gconst.KeyExternalIP] = gconst.ExternalIPNone
+				if instance.Status == gcp.InstanceRunning {
+					ctl.args[gconst.KeyVirtualMachineIP] = instance.InternalIP
+				}
+			}
+			if ipAddr := ctl.args[gconst.KeyVirtualMachineIP]; len(ipAddr) == 0 {
 				input.SetInfo("found stopped VM")
+			} else if instance.Private {
+				input.SetInfo(fmt.Sprintf("found VM with internal IP %s, which is reached through IAP", ipAddr))
+			} else {
+				input.SetInfo(fmt.Sprintf("found VM with IP %s", ipAddr))
 			}
+			ctl.loadContainerEnv(strings.Join([]string{projectID, zone, input.Value()}, "/"), instance.Container)
+			ctl.runningVersion = getRunningVersion(instance.Container)
+			ctl.tokenSecretRef = instance.Metadata[gcp.TokenSecretMetadataKey]
 
 		}
 	}
 
+	// The running version and container envs depend on which VM was found, so they're
+	// checked after it.
+	if versionInput := ctl.inputs[gconst.KeyTowerVersion]; len(ctl.runningVersion) > 0 {
+		if versionInput.Select != nil {
+			versionInput.Select.SetOptions(gcp.TowerVersionOptions(ctl.versions, ctl.runningVersion))
+		}
+		if len(versionInput.Error()) == 0 && versionInput.Value() == ctl.runningVersion {
+			versionInput.SetInfo(fmt.Sprintf("%s is already running", ctl.runningVersion))
+		}
+	}
+
+	ctl.versionWarning = ""
+	if versionInput := ctl.inputs[gconst.KeyTowerVersion]; len(versionInput.Error()) == 0 {
+		ctl.versionWarning = versionChangeWarning(ctl.runningVersion, versionInput.Value())
+	}
+	if len(ctl.versionWarning) > 0 {
+		confirmInput := ctl.inputs[gconst.KeyConfirmVersion]
+		target := ctl.inputs[gconst.KeyTowerVersion].Value()
+		if confirmInput.Value() != target {
+			confirmInput.SetError(fmt.Errorf("the %s needs to be confirmed with `%s`", ctl.versionWarning, target))
+			hasErrors = true
+		} else {
+			confirmInput.SetInfo(ctl.versionWarning)
+		}
+	}
+
+	if !ctl.validateToken() {
+		hasErrors = true
+	}
+
+	for _, name := range containerEnvs {
+		input := ctl.inputs[containerEnvKey(name)]
+		value := input.Value()
+		if strings.ContainsAny(value, "\"$`\\,\n") {
+			input.SetError(errors.New("can't contain quotes, commas, `$`, `\\` or backticks"))
+			hasErrors = true
+			continue
+		}
+
+		if ctl.currentEnv == nil {
+			continue
+		}
+
+		current, ok := ctl.currentEnv[name]
+		switch {
+		case !ok && len(value) > 0:
+			input.SetInfo("added")
+		case ok && len(value) == 0:
+			input.SetInfo(fmt.Sprintf("removed (was %s)", current))
+		case ok && value != current:
+			input.SetInfo(fmt.Sprintf("was %s", current))
+		}
+	}
+
 	return !hasErrors
 }
 
-func (ctl InputsController) GetRunController() controller.Run {
+// validateToken writes the new API token to a file for the secret, if there is one. The
+// token can only be rotated if the tower reads it from a secret, since it's left out of the
+// container envs.
+func (ctl *InputsController) validateToken() bool {
+	ctl.tokenFile = ""
+	input := ctl.inputs[gconst.KeyAPIToken]
+	token := input.Value()
+	if len(token) == 0 || len(ctl.inputs[gconst.KeyVirtualMachine].Error()) > 0 {
+		return true
+	}
+
+	if len(ctl.tokenSecretRef) == 0 {
+		input.SetError(fmt.Errorf("the Tower reads the token from its container env, so it can only be rotated after installing with --%s", gconst.FlagTokenSecret))
+		return false
+	}
+	if _, _, err := gcp.ParseTokenSecretRef(ctl.tokenSecretRef); err != nil {
+		input.SetError(err)
+		return false
+	}
+
+	// The new token is checked against the host that the tower will connect to.
+	host := constants.GetAPIHostName(constants.ProdBaseURL)
+	if hostInput, ok := ctl.inputs[containerEnvKey("FC_RPC_CONNECT_HOST")]; ok && len(hostInput.Value()) > 0 {
+		host = hostInput.Value()
+	}
+	info, err := apitoken.Check(host, token)
+	if err != nil {
+		input.SetError(err)
+		return false
+	}
+
+	fn := filepath.Join(ctl.tempDir, "api_token")
+	if err := os.WriteFile(fn, []byte(token), 0600); err != nil {
+		input.SetError(err)
+		return false
+	}
+	ctl.tokenFile = fn
+	input.SetInfo(fmt.Sprintf("%s, added as a new version of %s", info, strings.TrimSuffix(ctl.tokenSecretRef, "/versions/latest")))
+	return true
+}
+
+// versionChangeWarning returns why going from the running version to the target version
+// needs to be confirmed, or an empty string if it doesn't.
+func versionChangeWarning(running string, target string) string {
+	runningVersion, err := semver.Parse(running)
+	if err != nil {
+		return ""
+	}
+	targetVersion, err := semver.Parse(target)
+	if err != nil {
+		return ""
+	}
+
+	switch {
+	case targetVersion.Compare(runningVersion) < 0:
+		return fmt.Sprintf("downgrade from %s to %s", running, target)
+	case targetVersion.Major > runningVersion.Major:
+		return fmt.Sprintf("major version upgrade from %s to %s", running, target)
+	}
+	return ""
+}
+
+// getRunningVersion returns the tower version of the VM's container, from the version that
+// it was installed with or else the image's tag.
+func getRunningVersion(container *gcp.Container) string {
+	if container == nil {
+		return ""
+	}
+
+	if version, ok := container.GetEnv("FC_PACKAGE_VERSION"); ok && len(version) > 0 {
+		return version
+	}
+
+	if i := strings.LastIndex(container.Image, ":"); i >= 0 && !strings.Contains(container.Image[i:], "/") {
+		return container.Image[i+1:]
+	}
+	return ""
+}
+
+// loadContainerEnv fills in the container env inputs with the VM's values, unless they were
+// passed in as flags. The inputs are only filled in the first time that the VM is found, so
+// that edits aren't overwritten when validating again.
+func (ctl *InputsController) loadContainerEnv(vmID string, container *gcp.Container) {
+	if vmID == ctl.loadedVM {
+		return
+	}
+	ctl.loadedVM = vmID
+
+	if container == nil {
+		ctl.currentEnv = nil
+		return
+	}
+
+	ctl.currentEnv = make(map[string]string)
+	for _, env := range container.Env {
+		ctl.currentEnv[env.Name] = env.Value
+	}
+
+	for _, name := range containerEnvs {
+		if _, ok := ctl.flagEnvs[name]; ok {
+			continue
+		}
+		ctl.inputs[containerEnvKey(name)].SetValue(ctl.currentEnv[name])
+	}
+}
+
+// setArgs copies the inputs into the args. Only the container envs that are different from
+// the VM's are kept, so that update-container leaves the rest alone.
+func (ctl *InputsController) setArgs() {
 	for _, k := range ctl.inputKeys {
 		ctl.args[k] = ctl.inputs[k].Value()
 	}
+	ctl.args[gconst.KeyTokenSecretRef] = ctl.tokenSecretRef
+	ctl.args[gconst.KeyAPITokenFile] = ctl.tokenFile
+
+	if ctl.currentEnv == nil {
+		return
+	}
+
+	for _, name := range containerEnvs {
+		key := containerEnvKey(name)
+		value := ctl.inputs[key].Value()
+		if current, ok := ctl.currentEnv[name]; (ok && value != current) || (!ok && len(value) > 0) {
+			ctl.args[key] = value
+		} else {
+			delete(ctl.args, key)
+		}
+	}
+}
+
+func (ctl InputsController) GetRunController() controller.Run {
+	ctl.setArgs()
+
+	return NewRunController(ctl.args)
+}
+
+// ResumeRunController rebuilds the Run controller from the args of a saved run.
+func (ctl InputsController) ResumeRunController(args map[string]string) controller.Run {
+	for k, v := range args {
+		ctl.args[k] = v
+	}
+
+	// The API token file isn't saved, so it's written again from the token that was passed
+	// to resume with.
+	if len(ctl.args[gconst.KeyTokenSecretRef]) > 0 && len(ctl.args[gconst.KeyAPIToken]) > 0 {
+		fn := filepath.Join(ctl.tempDir, "api_token")
+		if err := os.WriteFile(fn, []byte(ctl.args[gconst.KeyAPIToken]), 0600); err != nil {
+			debug.Output("recreate api token file: %v", err)
+		} else {
+			ctl.args[gconst.KeyAPITokenFile] = fn
+		}
+	}
 
 	return NewRunController(ctl.args)
 }
@@ -182,7 +456,19 @@ func (ctl InputsController) GetName() string {
 }
 
 func (ctl *InputsController) GetInputs() []*wrapinput.Model {
-	ctl.inputKeys = initialInputKeys
+	ctl.inputKeys = make([]string, 0, len(initialInputKeys)+2+len(containerEnvs))
+	ctl.inputKeys = append(ctl.inputKeys, initialInputKeys...)
+	if len(ctl.versionWarning) > 0 {
+		ctl.inputKeys = append(ctl.inputKeys, gconst.KeyConfirmVersion)
+	}
+	if len(ctl.tokenSecretRef) > 0 || len(ctl.inputs[gconst.KeyAPIToken].Value()) > 0 {
+		ctl.inputKeys = append(ctl.inputKeys, gconst.KeyAPIToken)
+	}
+	if ctl.currentEnv != nil {
+		for _, name := range containerEnvs {
+			ctl.inputKeys = append(ctl.inputKeys, containerEnvKey(name))
+		}
+	}
 
 	inputs := make([]*wrapinput.Model, 0, len(ctl.inputKeys))
 	for _, k := range ctl.inputKeys {
@@ -192,9 +478,7 @@ func (ctl *InputsController) GetInputs() []*wrapinput.Model {
 }
 
 func (ctl *InputsController) RecreateCommand() string {
-	for _, key := range ctl.inputKeys {
-		ctl.args[key] = ctl.inputs[key].Value()
-	}
+	ctl.setArgs()
 	return recreateCommand(ctl.args)
 }
 
@@ -203,62 +487,11 @@ func contains(m map[string]string, key string) bool {
 	return ok
 }
 
-func (ctl *InputsController) getVirtualMachineIP(projectID string, zone string, vmName string) (string, error) {
-	var notFoundErr = errors.New("no VM with this name and location")
-
-	cmdStr := `gcloud compute instances list --format="csv(NAME,EXTERNAL_IP,STATUS)" --project=${GOOGLE_PROJECT_ID} --zones=${ZONE} --filter="name=${VIRTUAL_MACHINE}"`
-	cmdStr = strings.Replace(cmdStr, "${GOOGLE_PROJECT_ID}", projectID, 1)
-	cmdStr = strings.Replace(cmdStr, "${VIRTUAL_MACHINE}", vmName, 1)
-	cmdStr = strings.Replace(cmdStr, "${ZONE}", zone, 1)
-	debug.Output("running `%s`", cmdStr)
-	cmd := exec.Command("bash", "-c", cmdStr)
-
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
-
-	err := cmd.Run()
+func (ctl *InputsController) getInstance(projectID string, zone string, vmName string) (*gcp.Instance, error) {
+	instance, err := gcp.GetInstance(projectID, zone, vmName)
 	if err != nil {
-		return "", notFoundErr
+		debug.Output("get instance: %v", err)
+		return nil, errors.New("no VM with this name and location")
 	}
-	debug.Output(stdout.String())
-
-	r := csv.NewReader(&stdout)
-	headers, err := r.Read()
-	if err != nil {
-		return "", notFoundErr
-	}
-
-	nameIdx := -1
-	ipIdx := -1
-	for i, header := range headers {
-		switch header {
-		case "name":
-			nameIdx = i
-		case "external_ip":
-			ipIdx = i
-		}
-
-		if nameIdx >= 0 && ipIdx >= 0 {
-			break
-		}
-	}
-	if nameIdx < 0 || ipIdx < 0 {
-		return "", notFoundErr
-	}
-
-	for {
-		entry, err := r.Read()
-		if err == io.EOF {
-			break
-		} else if err != nil {
-			return "", notFoundErr
-		}
-
-		if entry[nameIdx] == vmName {
-			return entry[ipIdx], nil
-		}
-	}
-
-	return "", notFoundErr
+	return instance, nil
 }
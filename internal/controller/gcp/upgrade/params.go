@@ -2,9 +2,11 @@ package gcpupgrade
 
 import (
 	"errors"
+	"fmt"
 	"os"
 	"strings"
 
+	"flightcrew.io/cli/internal/config"
 	"flightcrew.io/cli/internal/constants"
 	"flightcrew.io/cli/internal/controller/gcp"
 	gconst "flightcrew.io/cli/internal/controller/gcp/constants"
@@ -14,7 +16,9 @@ import (
 var (
 	// Declare the variables and then assign them in init() so that we don't have a cyclical dependency
 	// since the installCmd references these variables, but we need to first instantiate the flags.
-	versionFlag, vmFlag, projectFlag, zoneFlag *string
+	versionFlag, vmFlag, projectFlag, zoneFlag, confirmVersionFlag, configFlag *string
+	tokenFlag                                                                  *string
+	containerEnvFlag, removeContainerEnvFlag                                   *[]string
 )
 
 var (
@@ -23,11 +27,40 @@ var (
 		gconst.KeyTowerVersion,
 		gconst.KeyZone,
 		gconst.KeyVirtualMachine,
+		gconst.KeyConfirmVersion,
+		gconst.KeyAPIToken,
+	}
+
+	// upgradeFlags are the flags that can be set from a config file. The install-only flags
+	// (e.g. the VM's shape) are rejected instead of being ignored, since an upgrade doesn't
+	// change them.
+	upgradeFlags = []string{
+		gconst.FlagProject,
+		gconst.FlagTowerVersion,
+		gconst.FlagZone,
+		gconst.FlagVirtualMachine,
+		gconst.FlagConfirmVersion,
+		gconst.FlagToken,
+	}
+
+	// containerEnvs are the tower's container environment variables that can be changed.
+	// FC_API_KEY and FC_PACKAGE_VERSION are left out, since they come from the token and
+	// the tower version.
+	containerEnvs = []string{
+		"CLOUD_PLATFORM",
+		"TRAFFIC_ROUTER",
+		"METRIC_PROVIDERS",
+		"APPENGINE_MAX_VERSION_COUNT",
+		"APPENGINE_MAX_VERSION_AGE",
+		"FC_RPC_CONNECT_HOST",
+		"FC_RPC_CONNECT_PORT",
+		"FC_TOWER_PORT",
 	}
 )
 
 type Params struct {
-	args map[string]string
+	args    map[string]string
+	tempDir string
 }
 
 func RegisterFlags(cmd *cobra.Command) {
@@ -35,10 +68,17 @@ func RegisterFlags(cmd *cobra.Command) {
 	vmFlag = cmd.Flags().String(gconst.FlagVirtualMachine, "flightcrew-control-tower", "The name of the VM that will be created for the Flightcrew tower in your project.")
 	projectFlag = cmd.Flags().StringP(gconst.FlagProject, "p", "", "Specify your Google Project ID.")
 	zoneFlag = cmd.Flags().StringP(gconst.FlagZone, "l", "us-central1-c", "The zone to put your Tower in.")
+	confirmVersionFlag = cmd.Flags().String(gconst.FlagConfirmVersion, "", "The x.x.x version again to confirm a downgrade or a major version upgrade of the tower (required with --non-interactive).")
+	tokenFlag = cmd.Flags().StringP(gconst.FlagToken, "t", "", "A new Flightcrew API token to add to the tower's Secret Manager secret, if it was installed with --token-secret.")
+	containerEnvFlag = cmd.Flags().StringArray(gconst.FlagContainerEnv, nil, "A NAME=VALUE environment variable to set on the tower's container. Can be repeated.")
+	removeContainerEnvFlag = cmd.Flags().StringArray(gconst.FlagRemoveContainerEnv, nil, "The name of an environment variable to remove from the tower's container. Can be repeated.")
+	configFlag = cmd.Flags().String(gconst.FlagConfig, "", "A YAML file of flag names to values. Flags passed in on the command line take precedence.")
 }
 
 func ParseFlags(cmd *cobra.Command) (Params, func(), error) {
-	if !gcp.HasGcloudInPath() {
+	// Changes are always made with gcloud, but the read-only checks can go through the
+	// API client without it (e.g. for --dry-run).
+	if !gcp.HasGcloudInPath() && gcp.API == nil {
 		return Params{}, nil, errors.New("gcloud is not in path")
 	}
 
@@ -46,12 +86,69 @@ func ParseFlags(cmd *cobra.Command) (Params, func(), error) {
 		args: make(map[string]string),
 	}
 
+	if len(*configFlag) > 0 {
+		if _, err := config.Load(cmd, *configFlag, allFlags()); err != nil {
+			return Params{}, nil, err
+		}
+		params.args[gconst.KeyConfigFile] = *configFlag
+	}
+
 	maybeAddEnv(params.args, gconst.KeyProject, *projectFlag)
 	maybeAddEnv(params.args, gconst.KeyZone, *zoneFlag)
 	maybeAddEnv(params.args, gconst.KeyTowerVersion, *versionFlag)
 	maybeAddEnv(params.args, gconst.KeyVirtualMachine, *vmFlag)
+	maybeAddEnv(params.args, gconst.KeyConfirmVersion, *confirmVersionFlag)
+	maybeAddEnv(params.args, gconst.KeyAPIToken, *tokenFlag)
 
-	return params, func() {}, nil
+	for _, env := range *containerEnvFlag {
+		name, value, ok := strings.Cut(env, "=")
+		if !ok {
+			return Params{}, nil, fmt.Errorf("--%s=%s should be NAME=VALUE", gconst.FlagContainerEnv, env)
+		}
+		if !isContainerEnv(name) {
+			return Params{}, nil, unknownContainerEnvError(name)
+		}
+		params.args[containerEnvKey(name)] = value
+	}
+	for _, name := range *removeContainerEnvFlag {
+		if !isContainerEnv(name) {
+			return Params{}, nil, unknownContainerEnvError(name)
+		}
+		params.args[containerEnvKey(name)] = ""
+	}
+
+	// A new API token is added to the secret from a file, so that it isn't in the commands.
+	dir, err := os.MkdirTemp("/tmp", "flightcrew-gcp-upgrade-*")
+	if err != nil {
+		return Params{}, nil, fmt.Errorf("create temp dir for upgrade: %v", err)
+	}
+	params.tempDir = dir
+
+	return params, func() {
+		err = os.RemoveAll(dir)
+		if err != nil {
+			fmt.Printf("delete temporary directory `%s`: %v\n", dir, err)
+		}
+	}, nil
+}
+
+// containerEnvKey is the arg for a changed container environment variable. An empty value
+// means that the variable is removed.
+func containerEnvKey(name string) string {
+	return "${CONTAINER_ENV_" + name + "}"
+}
+
+func isContainerEnv(name string) bool {
+	for _, env := range containerEnvs {
+		if env == name {
+			return true
+		}
+	}
+	return false
+}
+
+func unknownContainerEnvError(name string) error {
+	return fmt.Errorf("can't change container env %s (want one of: %s)", name, strings.Join(containerEnvs, ", "))
 }
 
 func maybeAddEnv(m map[string]string, key, value string) {
@@ -60,6 +157,10 @@ func maybeAddEnv(m map[string]string, key, value string) {
 	}
 }
 
+func allFlags() []string {
+	return upgradeFlags
+}
+
 func recreateCommand(m map[string]string) string {
 	var commandName string
 	if len(os.Args) > 0 {
@@ -72,12 +173,52 @@ func recreateCommand(m map[string]string) string {
 	buf.WriteString(commandName)
 	buf.WriteString(" gcp upgrade")
 
-	for flagName, keyName := range gconst.FlagToKey {
+	// Only the values that differ from the config file need to be passed in as flags.
+	fileValues := make(map[string]string)
+	if fn := m[gconst.KeyConfigFile]; len(fn) > 0 {
+		buf.WriteString(" --")
+		buf.WriteString(gconst.FlagConfig)
+		buf.WriteRune('=')
+		buf.WriteString(gcp.ShellQuote(fn))
+
+		if f, err := config.Read(fn, allFlags()); err == nil {
+			fileValues = f.Values
+		}
+	}
+
+	for _, flagName := range upgradeFlags {
+		keyName, ok := gconst.FlagToKey[flagName]
+		if !ok {
+			continue
+		}
 		if val, ok := m[keyName]; ok && len(val) > 0 {
+			if fileVal, ok := fileValues[flagName]; ok && fileVal == val {
+				continue
+			}
+
 			buf.WriteString(" --")
 			buf.WriteString(flagName)
 			buf.WriteRune('=')
-			buf.WriteString(val)
+			buf.WriteString(gcp.ShellQuote(val))
+		}
+	}
+
+	for _, name := range containerEnvs {
+		val, ok := m[containerEnvKey(name)]
+		if !ok {
+			continue
+		}
+
+		if len(val) > 0 {
+			buf.WriteString(" --")
+			buf.WriteString(gconst.FlagContainerEnv)
+			buf.WriteRune('=')
+			buf.WriteString(gcp.ShellQuote(name + "=" + val))
+		} else {
+			buf.WriteString(" --")
+			buf.WriteString(gconst.FlagRemoveContainerEnv)
+			buf.WriteRune('=')
+			buf.WriteString(name)
 		}
 	}
 
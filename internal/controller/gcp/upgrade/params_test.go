@@ -0,0 +1,25 @@
+package gcpupgrade
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"flightcrew.io/cli/internal/config"
+	gconst "flightcrew.io/cli/internal/controller/gcp/constants"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigFlags(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "tower.yaml")
+	require.NoError(t, os.WriteFile(fn, []byte("project: my-project\nversion: 1.2.3\nconfirm-version: 1.2.3\n"), 0600))
+	_, err := config.Read(fn, allFlags())
+	require.NoError(t, err)
+
+	for _, flagName := range []string{gconst.FlagWrite, gconst.FlagPlatform, gconst.FlagGAEMaxVersionCount, gconst.FlagMachineType} {
+		require.NoError(t, os.WriteFile(fn, []byte("project: my-project\n"+flagName+": true\n"), 0600))
+		_, err := config.Read(fn, allFlags())
+		assert.ErrorContains(t, err, "unknown keys: "+flagName, "install-only config keys should be rejected")
+	}
+}
@@ -3,7 +3,9 @@ package gcpupgrade
 import (
 	"strings"
 
+	"flightcrew.io/cli/internal/constants"
 	"flightcrew.io/cli/internal/controller"
+	"flightcrew.io/cli/internal/controller/gcp"
 	gconst "flightcrew.io/cli/internal/controller/gcp/constants"
 	"flightcrew.io/cli/internal/view/command"
 )
@@ -16,6 +18,7 @@ type RunController struct {
 
 func NewRunController(args map[string]string) *RunController {
 	commands := make([]*command.Model, 0)
+	commands = append(commands, getTokenCommands(args)...)
 	commands = append(commands, getVMCommands(args)...)
 
 	replaceArgs := make([]string, 0, 2*len(args))
@@ -40,13 +43,63 @@ func (ctl RunController) Commands() []*command.Model {
 }
 
 func (ctl *RunController) GetEndController() controller.End {
-	return NewEndController(ctl.commands, ctl.replacer)
+	return NewEndController(ctl.commands, ctl.replacer, ctl.args[gconst.KeyExternalIP] == gconst.ExternalIPNone)
 }
 
 func (ctl RunController) RecreateCommand() string {
 	return recreateCommand(ctl.args)
 }
 
+func (ctl RunController) Args() map[string]string {
+	return ctl.args
+}
+
+// StateName is the project and the VM, which the default state file is named after.
+func (ctl RunController) StateName() string {
+	return ctl.args[gconst.KeyProject] + "-" + ctl.args[gconst.KeyVirtualMachine]
+}
+
+// SecretArgs are the API token and the file that it's added to the secret from. Plans read
+// the token from an env var, and the file is written from the same one.
+func (ctl RunController) SecretArgs() map[string]string {
+	return map[string]string{
+		gconst.KeyAPIToken:     constants.APITokenEnv,
+		gconst.KeyAPITokenFile: "",
+	}
+}
+
+// SecretFiles returns the file that the new API token is added to the secret from, if there
+// is one.
+func (ctl RunController) SecretFiles() map[string]string {
+	files := make(map[string]string)
+	if fn := ctl.args[gconst.KeyAPITokenFile]; len(fn) > 0 {
+		files[fn] = constants.APITokenEnv
+	}
+	return files
+}
+
+// getTokenCommands add the new API token to the tower's secret. The VM reads the latest
+// version when update-container restarts it.
+func getTokenCommands(args map[string]string) []*command.Model {
+	if len(args[gconst.KeyAPITokenFile]) == 0 {
+		return nil
+	}
+
+	projectID, secret, err := gcp.ParseTokenSecretRef(args[gconst.KeyTokenSecretRef])
+	if err != nil {
+		return nil
+	}
+
+	cmd := command.NewWriteModel(command.Opts{
+		Description: "This command adds the new API token to the Tower's secret from `${API_TOKEN_FILE}`, which only you can read, so that the token isn't in the command.",
+		Command: `gcloud secrets versions add "${SECRET}" \
+	--project="${SECRET_PROJECT}" \
+	--data-file="${API_TOKEN_FILE}"`,
+	})
+	cmd.Replace(strings.NewReplacer("${SECRET}", secret, "${SECRET_PROJECT}", projectID))
+	return []*command.Model{cmd}
+}
+
 func getVMCommands(args map[string]string) []*command.Model {
 	commands := make([]*command.Model, 0)
 
@@ -59,6 +112,28 @@ func getVMCommands(args map[string]string) []*command.Model {
 				command.FailState: "SSH access is available. Next step is to prune images from the machine.",
 			},
 		})
+		// VMs without an external IP can only be reached through IAP. Trying to SSH in would
+		// already create SSH keys and add them to the metadata, so the check only looks at
+		// whether the VM is running and a firewall rule lets IAP reach it.
+		var sshFlags string
+		if args[gconst.KeyExternalIP] == gconst.ExternalIPNone {
+			sshFlags = ` \
+	--tunnel-through-iap`
+			networkProject, networkName := gcp.NetworkLocation(args[gconst.KeyNetwork], args[gconst.KeyProject])
+			checkSSH = command.NewReadModel(command.Opts{
+				Command:     `if gcloud compute instances describe ${VIRTUAL_MACHINE} --project=${GOOGLE_PROJECT_ID} --zone=${ZONE} --format="value(status)" | grep --quiet RUNNING && gcloud compute firewall-rules list --project="${NETWORK_PROJECT}" --filter='network~/networks/${NETWORK_NAME}$ AND direction=INGRESS AND sourceRanges:${IAP_RANGE} AND disabled=false' --format="value(name)" | grep --quiet .; then exit 1; else exit 0; fi`,
+				Description: "This command checks whether the virtual machine is running and a firewall rule lets IAP reach it, since it has no external IP and is reached through SSH by way of IAP.",
+				Message: map[command.State]string{
+					command.PassState: "VM isn't running or IAP can't reach it, skipping image pruning.",
+					command.FailState: "VM is running and IAP can reach it. Next step is to prune images from the machine.",
+				},
+			})
+			checkSSH.Replace(strings.NewReplacer(
+				"${NETWORK_PROJECT}", networkProject,
+				"${NETWORK_NAME}", networkName,
+				"${IAP_RANGE}", gcp.IAPSourceRange,
+			))
+		}
 
 		commands = append(commands,
 			checkSSH,
@@ -66,24 +141,44 @@ func getVMCommands(args map[string]string) []*command.Model {
 				SkipIfSucceed: checkSSH,
 				Command: `gcloud compute ssh ${VIRTUAL_MACHINE} \
 	--project ${GOOGLE_PROJECT_ID} \
-	--zone ${ZONE} \
+	--zone ${ZONE}` + sshFlags + ` \
 	--command 'docker system prune -f -a'`,
 				Description: "This command prunes old images on the virtual machine (through SSH) to save space before downloading a new one.",
 			}),
 		)
 	}
 
+	// update-container keeps the existing args / envs from when the VM was created, so only
+	// the envs that were changed are passed in.
+	var envFlags strings.Builder
+	for _, name := range containerEnvs {
+		value, ok := args[containerEnvKey(name)]
+		if !ok {
+			continue
+		}
+
+		if len(value) > 0 {
+			envFlags.WriteString(` \
+	--container-env="` + name + `=` + value + `"`)
+		} else {
+			envFlags.WriteString(` \
+	--remove-container-env="` + name + `"`)
+		}
+	}
+
+	description := "This command updates the VM to the newest stable Control Tower image."
+	if envFlags.Len() > 0 {
+		description = "This command updates the VM to the newest stable Control Tower image, and changes the container's environment variables."
+	}
+
 	commands = append(commands,
-		// update-container keeps the existing args / envs from when the VM was created,
-		// but they can be overwritten here.
-		// TODO(#12): Allow user to change environment variables as well.
 		command.NewWriteModel(command.Opts{
 			Command: `gcloud compute instances update-container ${VIRTUAL_MACHINE} \
 	--project=${GOOGLE_PROJECT_ID} \
 	--zone=${ZONE} \
 	--container-image="${IMAGE_PATH}:${TOWER_VERSION}" \
-	--container-env="FC_PACKAGE_VERSION=${TOWER_VERSION}"`,
-			Description: "This command updates the VM to the newest stable Control Tower image.",
+	--container-env="FC_PACKAGE_VERSION=${TOWER_VERSION}"` + envFlags.String(),
+			Description: description,
 		}),
 	)
 	return commands
@@ -0,0 +1,393 @@
+package gcpupgrade
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+
+	"flightcrew.io/cli/internal/apitoken"
+	"flightcrew.io/cli/internal/controller/gcp"
+	gconst "flightcrew.io/cli/internal/controller/gcp/constants"
+	"flightcrew.io/cli/internal/runner"
+	"flightcrew.io/cli/internal/view"
+	"flightcrew.io/cli/internal/view/wrapinput"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// describeInstance is the output of `gcloud compute instances describe` for a tower VM that
+// was created with the container declaration. Stopped VMs keep their access config, but
+// not its IP.
+func describeInstance(t *testing.T, ip string, status string) string {
+	accessConfig := map[string]string{"name": "external-nat"}
+	if len(ip) > 0 {
+		accessConfig["natIP"] = ip
+	}
+	accessConfigs := []map[string]string{accessConfig}
+
+	declaration := `spec:
+  containers:
+  - image: us-west1-docker.pkg.dev/flightcrew-artifacts/client/tower:1.0.0
+    env:
+    - name: FC_API_KEY
+      value: secret
+    - name: CLOUD_PLATFORM
+      value: gae_std
+    - name: APPENGINE_MAX_VERSION_COUNT
+      value: "20"
+    - name: FC_RPC_CONNECT_HOST
+      value: api.flightcrew.io
+`
+	out, err := json.Marshal(map[string]interface{}{
+		"name":   "flightcrew-control-tower",
+		"status": status,
+		"networkInterfaces": []map[string]interface{}{
+			{
+				"network":       "https://www.googleapis.com/compute/v1/projects/my-project/global/networks/default",
+				"networkIP":     "10.0.0.2",
+				"accessConfigs": accessConfigs,
+			},
+		},
+		"metadata": map[string]interface{}{
+			"items": []map[string]string{
+				{"key": "gce-container-declaration", "value": declaration},
+			},
+		},
+	})
+	require.NoError(t, err)
+	return string(out)
+}
+
+// withTokenSecret is the output of describeInstance for a tower VM that reads its API token
+// from a secret, so the token isn't in the container declaration.
+func withTokenSecret(describe string) string {
+	describe = strings.Replace(describe, `- name: FC_API_KEY\n      value: secret\n    `, "", 1)
+	return strings.Replace(describe, `"items":[`,
+		`"items":[{"key":"flightcrew-token-secret","value":"projects/my-project/secrets/flightcrew-api-token/versions/latest"},`, 1)
+}
+
+func TestUpgradeFlow(mainT *testing.T) {
+	keepARS := gcp.ArtifactRegistryService
+	gcp.ArtifactRegistryService = nil
+	keepRunner := runner.Default
+	keepEndpoint := apitoken.Endpoint
+	mainT.Cleanup(func() {
+		gcp.ArtifactRegistryService = keepARS
+		runner.Default = keepRunner
+		apitoken.Endpoint = keepEndpoint
+	})
+
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "Bearer revoked-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(`{"organizationName": "Acme"}`))
+	}))
+	mainT.Cleanup(tokenServer.Close)
+	apitoken.Endpoint = tokenServer.URL
+
+	newParams := func() Params {
+		return Params{
+			args: map[string]string{
+				gconst.KeyProject:        "my-project",
+				gconst.KeyTowerVersion:   "1.2.3",
+				gconst.KeyVirtualMachine: "flightcrew-control-tower",
+				gconst.KeyZone:           "us-central1-c",
+			},
+			tempDir: mainT.TempDir(),
+		}
+	}
+
+	mainT.Run("running VM should be pruned and updated", func(t *testing.T) {
+		scripted, err := runner.NewScripted(
+			runner.Response{Match: `^gcloud projects list`, Stdout: "project_id\nmy-project\n"},
+			runner.Response{Match: `^gcloud compute instances describe "flightcrew-control-tower"`, Stdout: describeInstance(t, "34.1.2.3", "RUNNING")},
+			runner.Response{Match: `^RETURN=\$\(nc -w 1 -z "34\.1\.2\.3" 22\)`, ExitCode: 1},
+			runner.Response{Match: `^gcloud compute (ssh|instances update-container) flightcrew-control-tower`},
+		)
+		require.NoError(t, err)
+		runner.Default = scripted
+
+		var out bytes.Buffer
+		require.NoError(t, view.RunHeadless(NewInputsController(newParams()), &out))
+
+		// The last call is the end screen checking whether the VM is up.
+		calls := scripted.Calls
+		require.GreaterOrEqual(t, len(calls), 3)
+		assert.Regexp(t, `^gcloud compute ssh flightcrew-control-tower`, calls[len(calls)-3])
+		assert.Regexp(t, `^gcloud compute instances update-container flightcrew-control-tower[^$]*--container-image="[^"]+:1\.2\.3"`, calls[len(calls)-2])
+		assert.NotContains(t, calls[len(calls)-2], "CLOUD_PLATFORM")
+	})
+
+	mainT.Run("VM without an external IP should be pruned through IAP", func(t *testing.T) {
+		private := strings.Replace(describeInstance(t, "", "RUNNING"), `"accessConfigs":[{"name":"external-nat"}],`, "", 1)
+		scripted, err := runner.NewScripted(
+			runner.Response{Match: `^gcloud projects list`, Stdout: "project_id\nmy-project\n"},
+			runner.Response{Match: `^gcloud compute instances describe "flightcrew-control-tower"`, Stdout: private},
+			runner.Response{Match: `^if gcloud compute instances describe flightcrew-control-tower --project=my-project --zone=us-central1-c --format="value\(status\)" \| grep --quiet RUNNING && gcloud compute firewall-rules list --project="my-project" --filter='network~/networks/default\$ AND direction=INGRESS AND sourceRanges:35\.235\.240\.0/20 AND disabled=false'`, ExitCode: 1},
+			runner.Response{Match: `^gcloud compute (ssh|instances update-container) flightcrew-control-tower`},
+			runner.Response{Match: `^gcloud compute instances describe flightcrew-control-tower .*--format="value\(status\)"`},
+		)
+		require.NoError(t, err)
+		runner.Default = scripted
+
+		ctl := NewInputsController(newParams())
+		require.NoError(t, view.ValidateInputs(ctl))
+		assert.Contains(t, ctl.inputs[gconst.KeyVirtualMachine].View(wrapinput.ViewParams{ShowValue: true}), "found VM with internal IP 10.0.0.2, which is reached through IAP")
+		assert.NotContains(t, ctl.RecreateCommand(), gconst.FlagNoExternalIP)
+
+		var out bytes.Buffer
+		require.NoError(t, view.RunHeadless(NewInputsController(newParams()), &out))
+		assertCallsInOrder(t, []string{
+			`^gcloud compute ssh flightcrew-control-tower --project my-project --zone us-central1-c --tunnel-through-iap --command 'docker system prune -f -a'`,
+			`^gcloud compute instances update-container flightcrew-control-tower`,
+		}, scripted.Calls)
+		for _, call := range scripted.Calls {
+			assert.NotRegexp(t, `nc -w 1 -z`, call)
+			assert.NotContains(t, call, "--command 'true'", "the check shouldn't SSH into the VM")
+		}
+		assert.Contains(t, out.String(), "--tunnel-through-iap")
+	})
+
+	mainT.Run("stopped VM should only be updated", func(t *testing.T) {
+		scripted, err := runner.NewScripted(
+			runner.Response{Match: `^gcloud projects list`, Stdout: "project_id\nmy-project\n"},
+			runner.Response{Match: `^gcloud compute instances describe "flightcrew-control-tower"`, Stdout: describeInstance(t, "", "TERMINATED")},
+			runner.Response{Match: `^gcloud compute instances update-container flightcrew-control-tower`},
+		)
+		require.NoError(t, err)
+		runner.Default = scripted
+
+		var out bytes.Buffer
+		require.NoError(t, view.RunHeadless(NewInputsController(newParams()), &out))
+
+		for _, call := range scripted.Calls {
+			assert.NotRegexp(t, `nc -w 1 -z "|^gcloud compute ssh`, call)
+		}
+		assert.Regexp(t, `^gcloud compute instances update-container`, scripted.Calls[len(scripted.Calls)-2])
+	})
+
+	mainT.Run("end should check the VM's SSH port in the project", func(t *testing.T) {
+		scripted, err := runner.NewScripted(runner.Response{Match: `^nc `, ExitCode: 1})
+		require.NoError(t, err)
+		runner.Default = scripted
+
+		endCtl := NewEndController(nil, strings.NewReplacer(
+			gconst.KeyProject, "my-project",
+			gconst.KeyZone, "us-central1-a",
+			gconst.KeyVirtualMachine, "flightcrew-control-tower",
+		), false)
+		assert.Contains(t, stripANSI(endCtl.EndDescription()), "Your VM is still starting up.")
+		require.Len(t, scripted.Calls, 1)
+		assert.Regexp(t, `^nc -w 1 -z \$\(gcloud compute instances list .* --project=my-project --zones=us-central1-a \| awk .*/flightcrew-control-tower/.*\) 22$`, scripted.Calls[0])
+
+		scripted, err = runner.NewScripted(runner.Response{Match: `^nc `})
+		require.NoError(t, err)
+		runner.Default = scripted
+		assert.Contains(t, stripANSI(endCtl.EndDescription()), "Your VM is available and running!")
+
+		// Once the VM is up, it isn't checked again.
+		endCtl.EndDescription()
+		assert.Len(t, scripted.Calls, 1)
+	})
+
+	mainT.Run("missing VM should fail validation", func(t *testing.T) {
+		scripted, err := runner.NewScripted(
+			runner.Response{Match: `^gcloud compute instances describe`, ExitCode: 1},
+		)
+		require.NoError(t, err)
+		runner.Default = scripted
+
+		var out bytes.Buffer
+		err = view.RunHeadless(NewInputsController(newParams()), &out)
+		assert.ErrorContains(t, err, "no VM with this name and location")
+		for _, call := range scripted.Calls {
+			assert.NotRegexp(t, `update-container`, call)
+		}
+	})
+
+	mainT.Run("changed container envs should be passed to update-container", func(t *testing.T) {
+		scripted, err := runner.NewScripted(
+			runner.Response{Match: `^gcloud projects list`, Stdout: "project_id\nmy-project\n"},
+			runner.Response{Match: `^gcloud compute instances describe "flightcrew-control-tower"`, Stdout: describeInstance(t, "", "TERMINATED")},
+			runner.Response{Match: `^gcloud compute instances update-container flightcrew-control-tower`},
+		)
+		require.NoError(t, err)
+		runner.Default = scripted
+
+		params := newParams()
+		params.args[containerEnvKey("APPENGINE_MAX_VERSION_COUNT")] = "30"
+		params.args[containerEnvKey("FC_RPC_CONNECT_HOST")] = ""
+		params.args[containerEnvKey("TRAFFIC_ROUTER")] = "gce"
+		params.args[containerEnvKey("METRIC_PROVIDERS")] = "stackdriver & prometheus"
+		ctl := NewInputsController(params)
+
+		require.NoError(t, view.ValidateInputs(ctl))
+		inputs := ctl.GetInputs()
+		require.Len(t, inputs, len(initialInputKeys)+len(containerEnvs))
+		assert.Equal(t, "gae_std", ctl.inputs[containerEnvKey("CLOUD_PLATFORM")].Value())
+		assert.Contains(t, ctl.RecreateCommand(), " --container-env=APPENGINE_MAX_VERSION_COUNT=30")
+		assert.Contains(t, ctl.RecreateCommand(), " --remove-container-env=FC_RPC_CONNECT_HOST")
+		assert.Contains(t, ctl.RecreateCommand(), ` --container-env='METRIC_PROVIDERS=stackdriver & prometheus'`)
+
+		var out bytes.Buffer
+		require.NoError(t, view.RunHeadless(ctl, &out))
+
+		update := scripted.Calls[len(scripted.Calls)-2]
+		assert.Regexp(t, `^gcloud compute instances update-container`, update)
+		assert.Contains(t, update, `--container-env="TRAFFIC_ROUTER=gce"`)
+		assert.Contains(t, update, `--container-env="APPENGINE_MAX_VERSION_COUNT=30"`)
+		assert.Contains(t, update, `--container-env="METRIC_PROVIDERS=stackdriver & prometheus"`)
+		assert.Contains(t, update, `--remove-container-env="FC_RPC_CONNECT_HOST"`)
+		assert.NotContains(t, update, "CLOUD_PLATFORM")
+		assert.NotContains(t, update, "FC_API_KEY")
+	})
+
+	mainT.Run("new token should be added to the secret", func(t *testing.T) {
+		withSecret := withTokenSecret(describeInstance(t, "", "TERMINATED"))
+		scripted, err := runner.NewScripted(
+			runner.Response{Match: `^gcloud projects list`, Stdout: "project_id\nmy-project\n"},
+			runner.Response{Match: `^gcloud compute instances describe "flightcrew-control-tower"`, Stdout: withSecret},
+			runner.Response{Match: `^gcloud secrets versions add`},
+			runner.Response{Match: `^gcloud compute instances update-container flightcrew-control-tower`},
+		)
+		require.NoError(t, err)
+		runner.Default = scripted
+
+		params := newParams()
+		params.args[gconst.KeyAPIToken] = "new-token"
+		ctl := NewInputsController(params)
+		require.NoError(t, view.ValidateInputs(ctl))
+		assert.Contains(t, ctl.inputs[gconst.KeyAPIToken].View(wrapinput.ViewParams{ShowValue: true}), "belongs to organization 'Acme', added as a new version of projects/my-project/secrets/flightcrew-api-token")
+
+		var out bytes.Buffer
+		require.NoError(t, view.RunHeadless(ctl, &out))
+		assertCallsInOrder(t, []string{
+			`^gcloud secrets versions add "flightcrew-api-token" --project="my-project" --data-file="\S+/api_token"`,
+			`^gcloud compute instances update-container`,
+		}, scripted.Calls)
+		for _, call := range scripted.Calls {
+			assert.NotContains(t, call, "new-token")
+		}
+
+		written, err := os.ReadFile(ctl.args[gconst.KeyAPITokenFile])
+		require.NoError(t, err)
+		assert.Equal(t, "new-token", string(written))
+	})
+
+	mainT.Run("revoked new token should fail validation", func(t *testing.T) {
+		withSecret := withTokenSecret(describeInstance(t, "", "TERMINATED"))
+		scripted, err := runner.NewScripted(
+			runner.Response{Match: `^gcloud compute instances describe "flightcrew-control-tower"`, Stdout: withSecret},
+		)
+		require.NoError(t, err)
+		runner.Default = scripted
+
+		params := newParams()
+		params.args[gconst.KeyAPIToken] = "revoked-token"
+		err = view.ValidateInputs(NewInputsController(params))
+		assert.ErrorContains(t, err, "New API Token: the token is invalid or has been revoked")
+	})
+
+	mainT.Run("new token should need a secret", func(t *testing.T) {
+		scripted, err := runner.NewScripted(
+			runner.Response{Match: `^gcloud compute instances describe "flightcrew-control-tower"`, Stdout: describeInstance(t, "", "TERMINATED")},
+		)
+		require.NoError(t, err)
+		runner.Default = scripted
+
+		params := newParams()
+		params.args[gconst.KeyAPIToken] = "new-token"
+		err = view.ValidateInputs(NewInputsController(params))
+		assert.ErrorContains(t, err, "New API Token: the Tower reads the token from its container env, so it can only be rotated after installing with --token-secret")
+	})
+
+	mainT.Run("invalid container env should fail validation", func(t *testing.T) {
+		scripted, err := runner.NewScripted(
+			runner.Response{Match: `^gcloud compute instances describe "flightcrew-control-tower"`, Stdout: describeInstance(t, "", "TERMINATED")},
+		)
+		require.NoError(t, err)
+		runner.Default = scripted
+
+		params := newParams()
+		params.args[containerEnvKey("CLOUD_PLATFORM")] = `gce" --zone="other`
+		err = view.ValidateInputs(NewInputsController(params))
+		assert.ErrorContains(t, err, "CLOUD_PLATFORM: can't contain quotes")
+	})
+
+	mainT.Run("running version should be shown", func(t *testing.T) {
+		scripted, err := runner.NewScripted(
+			runner.Response{Match: `^gcloud compute instances describe "flightcrew-control-tower"`, Stdout: describeInstance(t, "", "TERMINATED")},
+		)
+		require.NoError(t, err)
+		runner.Default = scripted
+
+		params := newParams()
+		params.args[gconst.KeyTowerVersion] = "1.0.0"
+		ctl := NewInputsController(params)
+		require.NoError(t, view.ValidateInputs(ctl))
+		assert.Equal(t, "1.0.0", ctl.runningVersion)
+		assert.Contains(t, ctl.inputs[gconst.KeyTowerVersion].View(wrapinput.ViewParams{ShowValue: true}), "1.0.0 is already running")
+	})
+
+	mainT.Run("downgrade should need to be confirmed", func(t *testing.T) {
+		scripted, err := runner.NewScripted(
+			runner.Response{Match: `^gcloud compute instances describe "flightcrew-control-tower"`, Stdout: describeInstance(t, "", "TERMINATED")},
+			runner.Response{Match: `^gcloud compute instances update-container flightcrew-control-tower`},
+		)
+		require.NoError(t, err)
+		runner.Default = scripted
+
+		params := newParams()
+		params.args[gconst.KeyTowerVersion] = "0.9.0"
+		err = view.ValidateInputs(NewInputsController(params))
+		assert.ErrorContains(t, err, "Confirm Version: the downgrade from 1.0.0 to 0.9.0 needs to be confirmed with `0.9.0`")
+
+		params.args[gconst.KeyConfirmVersion] = "0.9.0"
+		var out bytes.Buffer
+		require.NoError(t, view.RunHeadless(NewInputsController(params), &out))
+		assert.Regexp(t, `^gcloud compute instances update-container[^$]*:0\.9\.0"`, scripted.Calls[len(scripted.Calls)-2])
+	})
+
+	mainT.Run("major version upgrade should need to be confirmed", func(t *testing.T) {
+		scripted, err := runner.NewScripted(
+			runner.Response{Match: `^gcloud compute instances describe "flightcrew-control-tower"`, Stdout: describeInstance(t, "", "TERMINATED")},
+		)
+		require.NoError(t, err)
+		runner.Default = scripted
+
+		params := newParams()
+		params.args[gconst.KeyTowerVersion] = "2.0.0"
+		err = view.ValidateInputs(NewInputsController(params))
+		assert.ErrorContains(t, err, "major version upgrade from 1.0.0 to 2.0.0")
+	})
+}
+
+var ansiRegexp = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+
+// stripANSI removes the styling that glamour adds to the end description.
+func stripANSI(s string) string {
+	return ansiRegexp.ReplaceAllString(s, "")
+}
+
+// assertCallsInOrder asserts that the commands were run in this order. Other commands may be
+// run in between.
+func assertCallsInOrder(t *testing.T, expected []string, calls []string) {
+	t.Helper()
+
+	i := 0
+	for _, call := range calls {
+		if i < len(expected) && regexp.MustCompile(expected[i]).MatchString(call) {
+			i++
+		}
+	}
+	if i < len(expected) {
+		assert.Fail(t, "missing call", "no call matching %q in:\n%v", expected[i], calls)
+	}
+}
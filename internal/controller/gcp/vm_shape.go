@@ -0,0 +1,167 @@
+package gcp
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	minBootDiskSizeGB = 10
+	maxBootDiskSizeGB = 65536
+
+	// DefaultNetwork is the network that VMs are created in if none is picked.
+	DefaultNetwork = "default"
+	// IAPSourceRange is where IAP's TCP forwarding connects to VMs from.
+	// https://cloud.google.com/iap/docs/using-tcp-forwarding#create-firewall-rule
+	IAPSourceRange = "35.235.240.0/20"
+
+	// The router, NAT and firewall rule that are created for VMs without an external IP.
+	NATRouterName   = "flightcrew-router"
+	NATName         = "flightcrew-nat"
+	IAPFirewallName = "flightcrew-allow-iap-ssh"
+)
+
+var (
+	// resourceNameRE is the RFC 1035 format of Compute Engine resource names and network tags.
+	resourceNameRE = regexp.MustCompile(`^[a-z]([-a-z0-9]{0,61}[a-z0-9])?$`)
+	// networkPathRE and subnetPathRE are the resource paths that Shared VPC networks are
+	// passed in as.
+	networkPathRE = regexp.MustCompile(`^projects/([^/]+)/global/networks/([^/]+)$`)
+	subnetPathRE  = regexp.MustCompile(`^projects/[^/]+/regions/([^/]+)/subnetworks/([^/]+)$`)
+	labelKeyRE    = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,62}$`)
+	labelValueRE  = regexp.MustCompile(`^[a-z0-9_-]{0,63}$`)
+
+	BootDiskTypes = []string{"pd-balanced", "pd-ssd", "pd-standard"}
+)
+
+// ValidateBootDiskSize checks that the size in GB is big enough for Container-Optimized OS.
+func ValidateBootDiskSize(size string) error {
+	n, err := strconv.Atoi(size)
+	if err != nil || n < minBootDiskSizeGB || n > maxBootDiskSizeGB {
+		return fmt.Errorf("must be a number of GB from %d to %d", minBootDiskSizeGB, maxBootDiskSizeGB)
+	}
+	return nil
+}
+
+// ValidateBootDiskType checks that the disk type can be a boot disk for any machine type.
+func ValidateBootDiskType(diskType string) error {
+	for _, t := range BootDiskTypes {
+		if t == diskType {
+			return nil
+		}
+	}
+	return fmt.Errorf("must be one of: %s", strings.Join(BootDiskTypes, ", "))
+}
+
+// ValidateNetwork checks that the network is a name, or a resource path for Shared VPC.
+func ValidateNetwork(network string) error {
+	name := network
+	if m := networkPathRE.FindStringSubmatch(network); m != nil {
+		name = m[2]
+	}
+	if !resourceNameRE.MatchString(name) {
+		return fmt.Errorf("`%s` should be a network name or projects/<project>/global/networks/<name>", network)
+	}
+	return nil
+}
+
+// NetworkLocation returns the project and the name of the network, which is in the host
+// project for Shared VPC. Blank networks are the project's default network.
+func NetworkLocation(network string, projectID string) (string, string) {
+	if m := networkPathRE.FindStringSubmatch(network); m != nil {
+		return m[1], m[2]
+	}
+	if len(network) == 0 {
+		return projectID, DefaultNetwork
+	}
+	return projectID, network
+}
+
+// SubnetName returns the name of the VM's subnetwork. Without one, the VM is in the region's
+// subnetwork of an auto mode network, which has the same name as the network.
+func SubnetName(subnet string, network string) string {
+	if m := subnetPathRE.FindStringSubmatch(subnet); m != nil {
+		return m[2]
+	}
+	if len(subnet) > 0 {
+		return subnet
+	}
+	_, name := NetworkLocation(network, "")
+	return name
+}
+
+// ValidateSubnet checks that the subnetwork is a name, or a resource path in the zone's
+// region for Shared VPC.
+func ValidateSubnet(subnet string, zone string) error {
+	name := subnet
+	if m := subnetPathRE.FindStringSubmatch(subnet); m != nil {
+		if region := ZoneRegion(zone); m[1] != region {
+			return fmt.Errorf("subnetwork is in %s, but the zone is in %s", m[1], region)
+		}
+		name = m[2]
+	}
+	if !resourceNameRE.MatchString(name) {
+		return fmt.Errorf("`%s` should be a subnetwork name or projects/<project>/regions/<region>/subnetworks/<name>", subnet)
+	}
+	return nil
+}
+
+// ZoneRegion returns the region that the zone is in (e.g. us-central1 for us-central1-c).
+func ZoneRegion(zone string) string {
+	if i := strings.LastIndex(zone, "-"); i > 0 {
+		return zone[:i]
+	}
+	return zone
+}
+
+// ParseNetworkTags splits the comma-separated tags and checks that they are valid.
+func ParseNetworkTags(tags string) ([]string, error) {
+	parsed := make([]string, 0)
+	for _, tag := range strings.Split(tags, ",") {
+		tag = strings.TrimSpace(tag)
+		if len(tag) == 0 {
+			continue
+		}
+		if !resourceNameRE.MatchString(tag) {
+			return nil, fmt.Errorf("`%s` is not a valid network tag (lowercase letters, numbers and `-`)", tag)
+		}
+		parsed = append(parsed, tag)
+	}
+	return parsed, nil
+}
+
+// ParseLabels splits the comma-separated `key=value` labels and checks that they are valid.
+// The `component` label is reserved for `component=flightcrew`.
+func ParseLabels(labels string) ([]string, error) {
+	parsed := make([]string, 0)
+	seen := make(map[string]struct{})
+	for _, label := range strings.Split(labels, ",") {
+		label = strings.TrimSpace(label)
+		if len(label) == 0 {
+			continue
+		}
+
+		key, value, ok := strings.Cut(label, "=")
+		if !ok {
+			return nil, fmt.Errorf("label `%s` should be key=value", label)
+		}
+		if !labelKeyRE.MatchString(key) {
+			return nil, fmt.Errorf("label key `%s` should start with a lowercase letter, and only have lowercase letters, numbers, `_` and `-`", key)
+		}
+		if !labelValueRE.MatchString(value) {
+			return nil, fmt.Errorf("label value `%s` can only have lowercase letters, numbers, `_` and `-`", value)
+		}
+		if key == "component" {
+			return nil, errors.New("label `component` is always `flightcrew`")
+		}
+		if _, ok := seen[key]; ok {
+			return nil, fmt.Errorf("label `%s` is set more than once", key)
+		}
+		seen[key] = struct{}{}
+		parsed = append(parsed, key+"="+value)
+	}
+	return parsed, nil
+}
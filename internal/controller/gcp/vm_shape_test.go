@@ -0,0 +1,64 @@
+package gcp_test
+
+import (
+	"testing"
+
+	"flightcrew.io/cli/internal/controller/gcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateBootDisk(t *testing.T) {
+	assert.NoError(t, gcp.ValidateBootDiskSize("20"))
+	assert.Error(t, gcp.ValidateBootDiskSize("5"))
+	assert.Error(t, gcp.ValidateBootDiskSize("20GB"))
+
+	assert.NoError(t, gcp.ValidateBootDiskType("pd-ssd"))
+	assert.Error(t, gcp.ValidateBootDiskType("local-ssd"))
+}
+
+func TestValidateNetwork(t *testing.T) {
+	assert.NoError(t, gcp.ValidateNetwork("tower-vpc"))
+	assert.NoError(t, gcp.ValidateNetwork("projects/host-project/global/networks/shared-vpc"))
+	assert.Error(t, gcp.ValidateNetwork("Tower VPC"))
+	assert.Error(t, gcp.ValidateNetwork("projects/host-project/regions/us-central1/subnetworks/tower"))
+
+	for network, expected := range map[string][2]string{
+		"":          {"my-project", "default"},
+		"tower-vpc": {"my-project", "tower-vpc"},
+		"projects/host-project/global/networks/shared-vpc": {"host-project", "shared-vpc"},
+	} {
+		project, name := gcp.NetworkLocation(network, "my-project")
+		assert.Equal(t, expected, [2]string{project, name}, network)
+	}
+
+	assert.Equal(t, "tower", gcp.SubnetName("projects/host-project/regions/us-central1/subnetworks/tower", "projects/host-project/global/networks/shared-vpc"))
+	assert.Equal(t, "tower-subnet", gcp.SubnetName("tower-subnet", "tower-network"))
+	assert.Equal(t, "tower-network", gcp.SubnetName("", "tower-network"))
+	assert.Equal(t, gcp.DefaultNetwork, gcp.SubnetName("", ""))
+
+	assert.NoError(t, gcp.ValidateSubnet("tower-subnet", "us-central1-c"))
+	assert.NoError(t, gcp.ValidateSubnet("projects/host-project/regions/us-central1/subnetworks/tower", "us-central1-c"))
+	err := gcp.ValidateSubnet("projects/host-project/regions/europe-west1/subnetworks/tower", "us-central1-c")
+	assert.EqualError(t, err, "subnetwork is in europe-west1, but the zone is in us-central1")
+}
+
+func TestParseNetworkTags(t *testing.T) {
+	tags, err := gcp.ParseNetworkTags(" http-server, allow-iap ,")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"http-server", "allow-iap"}, tags)
+
+	_, err = gcp.ParseNetworkTags("http_server")
+	assert.Error(t, err)
+}
+
+func TestParseLabels(t *testing.T) {
+	labels, err := gcp.ParseLabels("team=platform, env=")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"team=platform", "env="}, labels)
+
+	for _, invalid := range []string{"team", "Team=platform", "team=Platform", "component=other", "team=a,team=b"} {
+		_, err := gcp.ParseLabels(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
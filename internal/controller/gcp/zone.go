@@ -0,0 +1,160 @@
+package gcp
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"flightcrew.io/cli/internal/debug"
+	"flightcrew.io/cli/internal/runner"
+	"flightcrew.io/cli/internal/view/selectinput"
+	"flightcrew.io/cli/internal/view/wrapinput"
+)
+
+// DefaultZone is where the tower is installed if no zone is picked.
+const DefaultZone = "us-central1-c"
+
+// ZoneUp is the status of a zone that VMs can be created in.
+const ZoneUp = "UP"
+
+// appEngineLocationRE is an App Engine location without the region's number, which the
+// older locations leave out (e.g. `us-central` is us-central1).
+var appEngineLocationRE = regexp.MustCompile(`^[a-z]+-[a-z]+$`)
+
+// Zone is a Compute Engine zone.
+type Zone struct {
+	Name   string
+	Region string
+	Status string
+}
+
+// ListZones returns the zones that the project can create VMs in, sorted by name.
+func ListZones(projectID string) ([]Zone, error) {
+	if API != nil {
+		return API.ListZones(projectID)
+	}
+
+	cmdStr := fmt.Sprintf(`gcloud compute zones list --project="%s" --sort-by=name --format="csv(name,region.basename(),status)"`, projectID)
+	stdout, stderr, err := runner.Output(cmdStr)
+	if err != nil {
+		return nil, fmt.Errorf("gcloud compute zones list: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	return parseListZonesCSV(stdout)
+}
+
+func parseListZonesCSV(output io.Reader) ([]Zone, error) {
+	records, err := csv.NewReader(output).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("parse zone list: %w", err)
+	}
+	if len(records) == 0 {
+		return nil, errors.New("no header when reading csv for zone list")
+	}
+
+	zones := make([]Zone, 0, len(records)-1)
+	for _, record := range records[1:] {
+		if len(record) < 3 || len(record[0]) == 0 {
+			continue
+		}
+		zones = append(zones, Zone{
+			Name:   record[0],
+			Region: record[1],
+			Status: record[2],
+		})
+	}
+	return zones, nil
+}
+
+// FindZone returns the zone with the name, or nil if the project doesn't have it.
+func FindZone(zones []Zone, name string) *Zone {
+	for i := range zones {
+		if zones[i].Name == name {
+			return &zones[i]
+		}
+	}
+	return nil
+}
+
+// ZoneOptions describes each zone with its region, and its status if VMs can't be created
+// in it.
+func ZoneOptions(zones []Zone) []selectinput.Option {
+	opts := make([]selectinput.Option, 0, len(zones))
+	for _, zone := range zones {
+		label := zone.Region
+		if zone.Status != ZoneUp {
+			label += ", " + zone.Status
+		}
+		opts = append(opts, selectinput.Option{
+			Value: zone.Name,
+			Label: label,
+		})
+	}
+	return opts
+}
+
+// NewZoneInput lists the project's zones to pick from, or falls back to typing in the zone
+// if they can't be listed. The returned zones are nil in that case.
+func NewZoneInput(projectID string) (wrapinput.Model, []Zone) {
+	var zones []Zone
+	var err error
+	if len(projectID) > 0 {
+		zones, err = ListZones(projectID)
+	}
+	if err != nil || len(zones) == 0 {
+		debug.Output("list zones: %v", err)
+
+		input := wrapinput.NewFreeForm()
+		input.Freeform.Placeholder = DefaultZone
+		input.Freeform.CharLimit = 32
+		input.Title = "Zone"
+		input.Default = DefaultZone
+		input.HelpText = "Zone is the Google zone where the (to be installed) Flightcrew virtual machine instance will be located."
+		return input, nil
+	}
+
+	input := wrapinput.NewSelect(ZoneOptions(zones))
+	input.Title = "Zone"
+	input.HelpText = "Zone is the Google zone where the (to be installed) Flightcrew virtual machine instance will be located. Type to search, and use ←/→ to pick one."
+	return input, zones
+}
+
+// GetAppEngineRegion returns the region of the project's App Engine app.
+func GetAppEngineRegion(projectID string) (string, error) {
+	var location string
+	if API != nil {
+		var err error
+		if location, err = API.GetAppEngineLocation(projectID); err != nil {
+			return "", err
+		}
+	} else {
+		cmdStr := fmt.Sprintf(`gcloud app describe --project="%s" --format="value(locationId)"`, projectID)
+		stdout, stderr, err := runner.Output(cmdStr)
+		if err != nil {
+			return "", fmt.Errorf("gcloud app describe: %w: %s", err, strings.TrimSpace(stderr.String()))
+		}
+		location = strings.TrimSpace(stdout.String())
+	}
+
+	if len(location) == 0 {
+		return "", errors.New("not found: App Engine location")
+	}
+	if appEngineLocationRE.MatchString(location) {
+		location += "1"
+	}
+	return location, nil
+}
+
+// SuggestZone returns the first zone in the region that VMs can be created in, or empty if
+// there is none.
+func SuggestZone(zones []Zone, region string) string {
+	for _, zone := range zones {
+		if zone.Region == region && zone.Status == ZoneUp {
+			return zone.Name
+		}
+	}
+	return ""
+}
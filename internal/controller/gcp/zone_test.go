@@ -0,0 +1,35 @@
+package gcp
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestZones(mainT *testing.T) {
+	zones, err := parseListZonesCSV(strings.NewReader("name,region,status\nus-central1-a,us-central1,UP\nus-central1-b,us-central1,DOWN\nus-east1-b,us-east1,UP\n"))
+	require.NoError(mainT, err)
+	require.Len(mainT, zones, 3)
+
+	mainT.Run("zones should be found by name", func(t *testing.T) {
+		zone := FindZone(zones, "us-central1-b")
+		require.NotNil(t, zone)
+		assert.Equal(t, "DOWN", zone.Status)
+		assert.Nil(t, FindZone(zones, "mars-north1-a"))
+	})
+
+	mainT.Run("options should show the region and a status that isn't up", func(t *testing.T) {
+		opts := ZoneOptions(zones)
+		require.Len(t, opts, 3)
+		assert.Equal(t, "us-central1", opts[0].Label)
+		assert.Equal(t, "us-central1, DOWN", opts[1].Label)
+	})
+
+	mainT.Run("suggested zone should be up and in the region", func(t *testing.T) {
+		assert.Equal(t, "us-central1-a", SuggestZone(zones, "us-central1"))
+		assert.Equal(t, "", SuggestZone(zones[1:2], "us-central1"))
+		assert.Equal(t, "us-east1-b", SuggestZone(zones, "us-east1"))
+	})
+}
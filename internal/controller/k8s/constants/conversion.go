@@ -0,0 +1,15 @@
+package constants
+
+var (
+	FlagToKey = map[string]string{
+		FlagNamespace:    KeyNamespace,
+		FlagDeployment:   KeyDeployment,
+		FlagPlatform:     KeyPlatform,
+		FlagToken:        KeyAPIToken,
+		FlagTowerVersion: KeyTowerVersion,
+		FlagWrite:        KeyPermissions,
+		FlagRPCHost:      KeyRPCHost,
+		FlagContext:      KeyContext,
+		FlagManifestsDir: KeyManifestsDir,
+	}
+)
@@ -0,0 +1,14 @@
+package constants
+
+const (
+	FlagNamespace    = "namespace"
+	FlagDeployment   = "deployment"
+	FlagTowerVersion = "version"
+	FlagToken        = "token"
+	FlagPlatform     = "platform"
+	FlagWrite        = "write"
+	FlagRPCHost      = "rpc-host"
+	FlagContext      = "context"
+	FlagManifestsDir = "manifests-dir"
+	FlagConfig       = "config"
+)
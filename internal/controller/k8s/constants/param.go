@@ -0,0 +1,21 @@
+package constants
+
+const (
+	KeyNamespace       = "${NAMESPACE}"
+	KeyDeployment      = "${DEPLOYMENT}"
+	KeyTowerVersion    = "${TOWER_VERSION}"
+	KeyAPIToken        = "${API_TOKEN}"
+	KeyAPITokenFile    = "${API_TOKEN_FILE}"
+	KeyPermissions     = "${PERMISSIONS}"
+	KeyRPCHost         = "${RPC_HOST}"
+	KeyAppURL          = "${APP_URL}"
+	KeyPlatform        = "${PLATFORM}"
+	KeyMetricProviders = "${METRIC_PROVIDERS}"
+	KeyTrafficRouter   = "${TRAFFIC_ROUTER}"
+	KeyImagePath       = "${IMAGE_PATH}"
+	KeyContext         = "${KUBE_CONTEXT}"
+	KeyContextFlag     = "${KUBE_CONTEXT_FLAG}"
+	KeyManifestsDir    = "${MANIFESTS_DIR}"
+	KeyOutputDir       = "${OUTPUT_DIR}"
+	KeyConfigFile      = "${CONFIG_FILE}"
+)
@@ -0,0 +1,79 @@
+package k8sinstall
+
+import (
+	"bytes"
+	"strings"
+
+	"flightcrew.io/cli/internal/runner"
+	"flightcrew.io/cli/internal/style"
+	"flightcrew.io/cli/internal/view/command"
+)
+
+type EndController struct {
+	replacer       *strings.Replacer
+	endDescription string
+	commands       []*command.Model
+	towerIsUp      bool
+}
+
+func NewEndController(commands []*command.Model, replacer *strings.Replacer) *EndController {
+	return &EndController{
+		commands: commands,
+		replacer: replacer,
+	}
+}
+
+func (ctl EndController) Commands() []*command.Model {
+	return ctl.commands
+}
+
+func (ctl EndController) Name() string {
+	return "Kubernetes Installation"
+}
+
+func (ctl *EndController) EndDescription() string {
+	rerender := false
+	if !ctl.towerIsUp {
+		// Checks to see if the tower's pod is up, which takes a bit while the image is pulled.
+		cmd := ctl.replacer.Replace(`kubectl get deployment "${DEPLOYMENT}" --namespace="${NAMESPACE}"${KUBE_CONTEXT_FLAG} --output=jsonpath="{.status.availableReplicas}" | grep --quiet '[1-9]'`)
+		var b bytes.Buffer
+		err := runner.Run(cmd, &b, &b)
+		if err == nil {
+			ctl.towerIsUp = true
+			rerender = true
+		}
+	}
+
+	if !rerender && len(ctl.endDescription) > 0 {
+		return ctl.endDescription
+	}
+
+	var description = `## Welcome to Flightcrew! 🕊
+
+${MESSAGE}
+
+Check on your new tower:
+${CODE_START}
+# See the tower's pod.
+kubectl get pods --namespace=${NAMESPACE}${KUBE_CONTEXT_FLAG} --selector=app.kubernetes.io/name=${DEPLOYMENT}
+# Follow the tower's logs.
+kubectl logs --follow --namespace=${NAMESPACE}${KUBE_CONTEXT_FLAG} deployment/${DEPLOYMENT}
+${CODE_END}
+
+Once your Tower is up, head on over to ${APP_URL} to see the info your Tower collected.
+`
+
+	description = ctl.replacer.Replace(description)
+	description = strings.Replace(description, "${CODE_START}", "```sh", 1)
+	description = strings.Replace(description, "${CODE_END}", "```", 1)
+	if ctl.towerIsUp {
+		description = strings.Replace(description, "${MESSAGE}", "✅ Your tower is running!", 1)
+	} else {
+		description = strings.Replace(description, "${MESSAGE}", "⏱ Your tower is still starting up.", 1)
+	}
+
+	out, _ := style.Glamour.Render(description)
+	ctl.endDescription = out
+
+	return out
+}
@@ -0,0 +1,370 @@
+package k8sinstall
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"flightcrew.io/cli/internal/apitoken"
+	"flightcrew.io/cli/internal/constants"
+	"flightcrew.io/cli/internal/controller"
+	"flightcrew.io/cli/internal/controller/gcp"
+	"flightcrew.io/cli/internal/controller/k8s"
+	kconst "flightcrew.io/cli/internal/controller/k8s/constants"
+	"flightcrew.io/cli/internal/debug"
+	"flightcrew.io/cli/internal/view/wrapinput"
+)
+
+var (
+	// dnsLabelRE is what Kubernetes allows for namespace and most object names (RFC 1123).
+	dnsLabelRE = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+	hostRE     = regexp.MustCompile(`^[a-zA-Z0-9]([-a-zA-Z0-9.]*[a-zA-Z0-9])?$`)
+
+	initialInputKeys = []string{
+		kconst.KeyContext,
+		kconst.KeyNamespace,
+		kconst.KeyDeployment,
+		kconst.KeyAPIToken,
+		kconst.KeyPlatform,
+		kconst.KeyPermissions,
+		kconst.KeyTowerVersion,
+		kconst.KeyRPCHost,
+	}
+)
+
+type InputsController struct {
+	outputDir     string
+	manifestsOnly bool
+	inputs        map[string]*wrapinput.Model
+	args          map[string]string
+	inputKeys     []string
+}
+
+func NewInputsController(params Params) *InputsController {
+	ctl := &InputsController{
+		inputKeys: initialInputKeys,
+		inputs:    make(map[string]*wrapinput.Model),
+		args:      params.args,
+		outputDir: params.tempDir,
+	}
+	if len(params.manifestsDir) > 0 {
+		ctl.outputDir = params.manifestsDir
+		ctl.manifestsOnly = true
+	}
+
+	if !contains(ctl.args, kconst.KeyNamespace) {
+		ctl.args[kconst.KeyNamespace] = "flightcrew"
+	}
+	if !contains(ctl.args, kconst.KeyDeployment) {
+		ctl.args[kconst.KeyDeployment] = "flightcrew-control-tower"
+	}
+	if !contains(ctl.args, kconst.KeyTowerVersion) {
+		ctl.args[kconst.KeyTowerVersion] = "stable"
+	}
+	if !contains(ctl.args, kconst.KeyContext) {
+		if kubeContext, err := k8s.GetCurrentContext(); err == nil {
+			ctl.args[kconst.KeyContext] = kubeContext
+		}
+	}
+
+	baseURL := gcp.GetHostBaseURL("", "")
+	ctl.args[kconst.KeyAppURL] = constants.GetAppHostName(baseURL)
+	if !contains(ctl.args, kconst.KeyRPCHost) {
+		ctl.args[kconst.KeyRPCHost] = constants.GetAPIHostName(baseURL)
+	}
+	ctl.args[kconst.KeyTrafficRouter] = ""
+	ctl.args[kconst.KeyImagePath] = gcp.ImagePath
+	ctl.args[kconst.KeyOutputDir] = ctl.outputDir
+
+	for _, key := range allKeys {
+		var input wrapinput.Model
+		maybeSetValue := func(key string) {
+			if val, ok := ctl.args[key]; ok {
+				input.SetValue(val)
+			}
+		}
+
+		switch key {
+		case kconst.KeyContext:
+			input = wrapinput.NewFreeForm()
+			input.Freeform.Placeholder = "my-cluster"
+			input.Freeform.CharLimit = 0
+			input.Title = "Context"
+			input.HelpText = "Context is the kubeconfig context of the cluster to install into. Leave it empty to use kubectl's current context."
+			maybeSetValue(kconst.KeyContext)
+
+		case kconst.KeyNamespace:
+			input = wrapinput.NewFreeForm()
+			input.Freeform.Placeholder = "flightcrew"
+			input.Freeform.CharLimit = 63
+			input.Title = "Namespace"
+			input.Default = "flightcrew"
+			input.Required = true
+			input.HelpText = "Namespace is the (to be created) Kubernetes namespace for the Flightcrew tower."
+			maybeSetValue(kconst.KeyNamespace)
+
+		case kconst.KeyDeployment:
+			input = wrapinput.NewFreeForm()
+			input.Freeform.Placeholder = "flightcrew-control-tower"
+			input.Freeform.CharLimit = 63
+			input.Title = "Deployment"
+			input.Default = "flightcrew-control-tower"
+			input.Required = true
+			input.HelpText = "Deployment is the name of the (to be created) Deployment, ServiceAccount and Secret for the Flightcrew tower."
+			maybeSetValue(kconst.KeyDeployment)
+
+		case kconst.KeyTowerVersion:
+			input, _ = gcp.NewTowerVersionInput()
+			maybeSetValue(kconst.KeyTowerVersion)
+
+		case kconst.KeyAPIToken:
+			input = wrapinput.NewFreeForm()
+			input.Freeform.Placeholder = "api-token"
+			input.Freeform.CharLimit = 0
+			input.Title = "API Token"
+			input.Required = true
+			input.HelpText = "API token is the value provided by Flightcrew to identify your organization. It's stored in a Secret."
+			maybeSetValue(kconst.KeyAPIToken)
+
+		case kconst.KeyRPCHost:
+			input = wrapinput.NewFreeForm()
+			input.Freeform.CharLimit = 0
+			input.Title = "RPC Host"
+			input.Required = true
+			input.HelpText = "RPC Host is the Flightcrew API host that the tower connects to."
+			maybeSetValue(kconst.KeyRPCHost)
+
+		case kconst.KeyPlatform:
+			input = wrapinput.NewRadio([]string{
+				constants.GoogleAppEngineStdDisplay,
+				constants.GoogleComputeEngineDisplay,
+				constants.AmazonEC2Display})
+			input.Title = "Platform"
+			input.HelpText = "Platform is which cloud resources Flightcrew will read in."
+			maybeSetValue(kconst.KeyPlatform)
+
+		case kconst.KeyPermissions:
+			input = wrapinput.NewRadio([]string{
+				constants.Read,
+				constants.Write})
+			input.Title = "Permissions"
+			input.HelpText = "Permissions is whether Flightcrew will only read in your resources, or if Flightcrew can modify (if you ask us to) your resources."
+			maybeSetValue(kconst.KeyPermissions)
+
+		}
+
+		input.Blur()
+		ctl.inputs[key] = &input
+	}
+	return ctl
+}
+
+func (ctl InputsController) GetAllInputs() []*wrapinput.Model {
+	res := make([]*wrapinput.Model, 0, len(ctl.inputs))
+	for _, v := range ctl.inputs {
+		res = append(res, v)
+	}
+	return res
+}
+
+func (ctl *InputsController) Reset(inputs []*wrapinput.Model) {
+	for k := range ctl.inputs {
+		ctl.inputs[k].ResetValidation()
+	}
+}
+
+func (ctl *InputsController) Validate(inputs []*wrapinput.Model) bool {
+	hasErrors := false
+	for k, input := range ctl.inputs {
+		setError := func(err error) bool {
+			if err != nil {
+				input.SetError(err)
+				debug.Output(err.Error())
+				hasErrors = true
+				return true
+			}
+			return false
+		}
+
+		if input.Required && len(input.Value()) == 0 {
+			setError(errors.New("required"))
+			continue
+		}
+
+		switch k {
+		case kconst.KeyContext:
+			if strings.ContainsAny(input.Value(), "\"`$\\") {
+				setError(errors.New("must not have quotes, '$' or '\\'"))
+			}
+
+		case kconst.KeyNamespace, kconst.KeyDeployment:
+			if len(input.Value()) > 63 || !dnsLabelRE.MatchString(input.Value()) {
+				setError(errors.New("must be at most 63 lowercase letters, numbers or '-', and start and end with a letter or number"))
+			}
+
+		case kconst.KeyRPCHost:
+			if !hostRE.MatchString(input.Value()) {
+				setError(errors.New("must be a host name (e.g. " + constants.GetAPIHostName(constants.ProdBaseURL) + ")"))
+			}
+
+		case kconst.KeyTowerVersion:
+			version, err := gcp.GetTowerImageVersion(input.Value())
+			if setError(err) {
+				debug.Output("convert tower version got error: %v", err)
+				break
+			}
+
+			input.SetConverted(version)
+			debug.Output("convert tower version is %s", version)
+
+		case kconst.KeyPlatform:
+			displayName := input.Value()
+			platform, ok := displayToPlatform(displayName)
+			if !ok {
+				setError(errors.New("invalid platform"))
+				break
+			}
+
+			input.SetConverted(platform)
+
+			if _, ok := constants.AWSDisplayToPlatform[displayName]; ok {
+				ctl.args[kconst.KeyMetricProviders] = "cloudwatch"
+			} else {
+				ctl.args[kconst.KeyMetricProviders] = "stackdriver"
+			}
+
+			if ctl.inputs[kconst.KeyPermissions].Value() == constants.Write {
+				ctl.args[kconst.KeyTrafficRouter] = fmtEnvForReplace("TRAFFIC_ROUTER", platform)
+			} else {
+				ctl.args[kconst.KeyTrafficRouter] = ""
+			}
+
+		}
+	}
+
+	if hasErrors {
+		return false
+	}
+
+	// The token is checked against the RPC host, so only once the host is valid.
+	tokenInput := ctl.inputs[kconst.KeyAPIToken]
+	info, err := apitoken.Check(ctl.inputs[kconst.KeyRPCHost].Value(), tokenInput.Value())
+	if err != nil {
+		debug.Output(err.Error())
+		tokenInput.SetError(err)
+		return false
+	}
+	tokenInput.SetInfo(info)
+
+	// The manifests need every input, so they can only be written once they are all valid.
+	if err := ctl.writeManifests(); err != nil {
+		debug.Output("write manifests: %v", err)
+		ctl.inputs[kconst.KeyDeployment].SetError(err)
+		return false
+	}
+
+	return true
+}
+
+// writeManifests (over)writes every manifest, since they can change between validations.
+// When they're applied, the Secret is created from a token file instead of its manifest, so
+// that the token isn't copied into plans and saved runs with the other manifests. Only the
+// current user can read them either way.
+func (ctl *InputsController) writeManifests() error {
+	values := make(map[string]string, len(ctl.args))
+	for k, v := range ctl.args {
+		values[k] = v
+	}
+	for _, k := range ctl.inputKeys {
+		values[k] = ctl.inputs[k].Value()
+	}
+
+	for _, m := range manifests {
+		if m.secret && !ctl.manifestsOnly {
+			continue
+		}
+
+		fn := filepath.Join(ctl.outputDir, m.filename)
+		if err := os.WriteFile(fn, []byte(renderManifest(m, values)), 0600); err != nil {
+			return err
+		}
+	}
+
+	if ctl.manifestsOnly {
+		return nil
+	}
+	fn, err := ctl.writeTokenFile(values[kconst.KeyAPIToken])
+	if err != nil {
+		return err
+	}
+	ctl.args[kconst.KeyAPITokenFile] = fn
+	return nil
+}
+
+func (ctl *InputsController) writeTokenFile(token string) (string, error) {
+	fn := filepath.Join(ctl.outputDir, "api_token")
+	if err := os.WriteFile(fn, []byte(token), 0600); err != nil {
+		return "", err
+	}
+	return fn, nil
+}
+
+func (ctl InputsController) GetRunController() controller.Run {
+	for _, k := range ctl.inputKeys {
+		ctl.args[k] = ctl.inputs[k].Value()
+	}
+
+	if kubeContext := ctl.args[kconst.KeyContext]; len(kubeContext) > 0 {
+		ctl.args[kconst.KeyContextFlag] = ` --context="` + kubeContext + `"`
+	} else {
+		ctl.args[kconst.KeyContextFlag] = ""
+	}
+
+	return NewRunController(ctl.args)
+}
+
+// ResumeRunController rebuilds the Run controller from the args of a saved run.
+func (ctl InputsController) ResumeRunController(args map[string]string) controller.Run {
+	for k, v := range args {
+		ctl.args[k] = v
+	}
+
+	// The token file isn't saved with the manifests, so it's written again from the token
+	// that was passed in.
+	if token := ctl.args[kconst.KeyAPIToken]; len(token) > 0 {
+		fn, err := ctl.writeTokenFile(token)
+		if err != nil {
+			debug.Output("recreate api token file: %v", err)
+		} else {
+			ctl.args[kconst.KeyAPITokenFile] = fn
+		}
+	}
+
+	return NewRunController(ctl.args)
+}
+
+func (ctl InputsController) GetName() string {
+	return "Kubernetes Installation"
+}
+
+func (ctl *InputsController) GetInputs() []*wrapinput.Model {
+	inputs := make([]*wrapinput.Model, 0, len(ctl.inputKeys))
+	for _, k := range ctl.inputKeys {
+		inputs = append(inputs, ctl.inputs[k])
+	}
+	return inputs
+}
+
+func (ctl *InputsController) RecreateCommand() string {
+	for _, key := range ctl.inputKeys {
+		ctl.args[key] = ctl.inputs[key].Value()
+	}
+	return recreateCommand(ctl.args)
+}
+
+func contains(m map[string]string, key string) bool {
+	_, ok := m[key]
+	return ok
+}
@@ -0,0 +1,133 @@
+package k8sinstall
+
+import (
+	"encoding/json"
+	"strings"
+
+	kconst "flightcrew.io/cli/internal/controller/k8s/constants"
+)
+
+// manifest is a Kubernetes object that is written to its own file, so that each one can
+// be applied (and skipped) separately.
+type manifest struct {
+	kind     string
+	filename string
+	template string
+	// secret is whether the manifest has the API token. It's only written out for
+	// --manifests-dir, and the Secret is created from a token file when it's applied.
+	secret bool
+}
+
+// manifests are in the order that they need to be applied, and the filenames keep that
+// order for `kubectl apply --filename=<dir>`.
+var manifests = []manifest{
+	{
+		kind:     "namespace",
+		filename: "00-namespace.yaml",
+		template: `apiVersion: v1
+kind: Namespace
+metadata:
+  name: ${NAMESPACE}
+  labels:
+    app.kubernetes.io/component: flightcrew
+`,
+	},
+	{
+		kind:     "serviceaccount",
+		filename: "01-serviceaccount.yaml",
+		template: `apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: ${DEPLOYMENT}
+  namespace: ${NAMESPACE}
+  labels:
+    app.kubernetes.io/component: flightcrew
+`,
+	},
+	{
+		kind:     "secret",
+		filename: "02-secret.yaml",
+		template: `apiVersion: v1
+kind: Secret
+metadata:
+  name: ${DEPLOYMENT}
+  namespace: ${NAMESPACE}
+  labels:
+    app.kubernetes.io/component: flightcrew
+type: Opaque
+stringData:
+  FC_API_KEY: ${API_TOKEN}
+`,
+		secret: true,
+	},
+	{
+		kind:     "deployment",
+		filename: "03-deployment.yaml",
+		template: `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: ${DEPLOYMENT}
+  namespace: ${NAMESPACE}
+  labels:
+    app.kubernetes.io/name: ${DEPLOYMENT}
+    app.kubernetes.io/component: flightcrew
+spec:
+  replicas: 1
+  selector:
+    matchLabels:
+      app.kubernetes.io/name: ${DEPLOYMENT}
+  template:
+    metadata:
+      labels:
+        app.kubernetes.io/name: ${DEPLOYMENT}
+        app.kubernetes.io/component: flightcrew
+    spec:
+      serviceAccountName: ${DEPLOYMENT}
+      containers:
+        - name: tower
+          image: "${IMAGE_PATH}:${TOWER_VERSION}"
+          command: ["/ko-app/tower"]
+          args: ["--debug=true"]
+          ports:
+            - containerPort: 8080
+          env:
+            - name: FC_API_KEY
+              valueFrom:
+                secretKeyRef:
+                  name: ${DEPLOYMENT}
+                  key: FC_API_KEY
+            - name: CLOUD_PLATFORM
+              value: "${PLATFORM}"${TRAFFIC_ROUTER}
+            - name: FC_PACKAGE_VERSION
+              value: "${TOWER_VERSION}"
+            - name: METRIC_PROVIDERS
+              value: "${METRIC_PROVIDERS}"
+            - name: FC_RPC_CONNECT_HOST
+              value: "${RPC_HOST}"
+            - name: FC_RPC_CONNECT_PORT
+              value: "443"
+            - name: FC_TOWER_PORT
+              value: "8080"
+`,
+	},
+}
+
+// renderManifest fills in the manifest's template. The API token is quoted since it's
+// the only value that isn't validated to be safe in YAML.
+func renderManifest(m manifest, args map[string]string) string {
+	replaceArgs := make([]string, 0, 2*len(args))
+	for key, arg := range args {
+		if key == kconst.KeyAPIToken {
+			quoted, _ := json.Marshal(arg)
+			arg = string(quoted)
+		}
+		replaceArgs = append(replaceArgs, key, arg)
+	}
+	return strings.NewReplacer(replaceArgs...).Replace(m.template)
+}
+
+func fmtEnvForReplace(env string, value string) string {
+	return `
+            - name: ` + env + `
+              value: "` + value + `"`
+}
@@ -0,0 +1,231 @@
+package k8sinstall
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"flightcrew.io/cli/internal/config"
+	"flightcrew.io/cli/internal/constants"
+	"flightcrew.io/cli/internal/controller/k8s"
+	kconst "flightcrew.io/cli/internal/controller/k8s/constants"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Declare the variables and then assign them in init() so that we don't have a cyclical dependency
+	// since the installCmd references these variables, but we need to first instantiate the flags.
+	tokenFlag, versionFlag, namespaceFlag, deploymentFlag, platformFlag, rpcHostFlag, contextFlag *string
+	manifestsDirFlag, configFlag                                                                  *string
+	writeFlag                                                                                     *bool
+)
+
+var (
+	allKeys = []string{
+		kconst.KeyNamespace,
+		kconst.KeyDeployment,
+		kconst.KeyTowerVersion,
+		kconst.KeyAPIToken,
+		kconst.KeyPermissions,
+		kconst.KeyPlatform,
+		kconst.KeyRPCHost,
+		kconst.KeyContext,
+	}
+)
+
+type Params struct {
+	args         map[string]string
+	tempDir      string
+	manifestsDir string
+}
+
+// ManifestsDir is where the manifests should be written to instead of being applied.
+// It's empty if they should be applied.
+func (p Params) ManifestsDir() string {
+	return p.manifestsDir
+}
+
+func RegisterFlags(cmd *cobra.Command) {
+	tokenFlag = cmd.Flags().StringP(kconst.FlagToken, "t", "", "The Flightcrew API token to identify your organization.")
+	versionFlag = cmd.Flags().StringP(kconst.FlagTowerVersion, "v", "stable", "The Flightcrew image version to install.")
+	namespaceFlag = cmd.Flags().StringP(kconst.FlagNamespace, "n", "flightcrew", "The namespace that the Flightcrew tower will be created in.")
+	deploymentFlag = cmd.Flags().String(kconst.FlagDeployment, "flightcrew-control-tower", "The name of the Deployment (and its ServiceAccount and Secret) for the Flightcrew tower.")
+	writeFlag = cmd.Flags().BoolP(kconst.FlagWrite, "w", false, "Whether the Flightcrew tower should be read-only (false) or read-write (true).")
+	platformFlag = cmd.Flags().String(kconst.FlagPlatform, constants.GoogleComputeEngineKey, fmt.Sprintf("specify what type of cloud resources you want to manage. (%s)", strings.Join(platformKeys(), ", ")))
+	rpcHostFlag = cmd.Flags().String(kconst.FlagRPCHost, "", "The Flightcrew API host that the tower connects to. (default: "+constants.GetAPIHostName(constants.ProdBaseURL)+")")
+	contextFlag = cmd.Flags().String(kconst.FlagContext, "", "The kubeconfig context to install into. (default: the current context)")
+	manifestsDirFlag = cmd.Flags().String(kconst.FlagManifestsDir, "", "Only write the manifests to this directory instead of applying them. Inputs come from flags.")
+	configFlag = cmd.Flags().String(kconst.FlagConfig, "", "A YAML file of flag names to values. Flags passed in on the command line take precedence.")
+}
+
+func ParseFlags(cmd *cobra.Command) (Params, func(), error) {
+	params := Params{
+		args: make(map[string]string),
+	}
+
+	if len(*configFlag) > 0 {
+		if _, err := config.Load(cmd, *configFlag, allFlags()); err != nil {
+			return Params{}, nil, err
+		}
+		params.args[kconst.KeyConfigFile] = *configFlag
+	}
+
+	// kubectl isn't needed to only write out the manifests.
+	params.manifestsDir = *manifestsDirFlag
+	if len(params.manifestsDir) == 0 && !k8s.HasKubectlInPath() {
+		return Params{}, nil, errors.New("kubectl is not in path")
+	}
+
+	maybeAddEnv(params.args, kconst.KeyNamespace, *namespaceFlag)
+	maybeAddEnv(params.args, kconst.KeyDeployment, *deploymentFlag)
+	maybeAddEnv(params.args, kconst.KeyTowerVersion, *versionFlag)
+	maybeAddEnv(params.args, kconst.KeyAPIToken, *tokenFlag)
+	maybeAddEnv(params.args, kconst.KeyRPCHost, *rpcHostFlag)
+	maybeAddEnv(params.args, kconst.KeyContext, *contextFlag)
+	maybeAddEnv(params.args, kconst.KeyManifestsDir, *manifestsDirFlag)
+
+	if *writeFlag {
+		params.args[kconst.KeyPermissions] = constants.Write
+	} else {
+		params.args[kconst.KeyPermissions] = constants.Read
+	}
+
+	displayName, ok := platformKeyToDisplay()[*platformFlag]
+	if !ok {
+		return Params{}, nil, fmt.Errorf("invalid --platform flag: %s", strings.Join(platformKeys(), ", "))
+	}
+
+	maybeAddEnv(params.args, kconst.KeyPlatform, displayName)
+
+	if len(params.manifestsDir) > 0 {
+		if err := os.MkdirAll(params.manifestsDir, 0700); err != nil {
+			return Params{}, nil, fmt.Errorf("create manifests dir: %v", err)
+		}
+		return params, func() {}, nil
+	}
+
+	dir, err := os.MkdirTemp("/tmp", "flightcrew-k8s-install-*")
+	if err != nil {
+		return Params{}, nil, fmt.Errorf("create temp dir for installation: %v", err)
+	}
+	params.tempDir = dir
+
+	return params, func() {
+		err = os.RemoveAll(dir)
+		if err != nil {
+			fmt.Printf("delete temporary directory `%s`: %v\n", dir, err)
+		}
+	}, nil
+}
+
+func maybeAddEnv(m map[string]string, key, value string) {
+	if len(value) > 0 {
+		m[key] = value
+	}
+}
+
+func allFlags() []string {
+	flags := make([]string, 0, len(kconst.FlagToKey))
+	for flagName := range kconst.FlagToKey {
+		flags = append(flags, flagName)
+	}
+	return flags
+}
+
+// platformKeyToDisplay has the platforms of every cloud provider, since a tower in
+// Kubernetes can manage any of them.
+func platformKeyToDisplay() map[string]string {
+	res := make(map[string]string, len(constants.KeyToDisplay)+len(constants.AWSKeyToDisplay))
+	for key, display := range constants.KeyToDisplay {
+		res[key] = display
+	}
+	for key, display := range constants.AWSKeyToDisplay {
+		res[key] = display
+	}
+	return res
+}
+
+func platformKeys() []string {
+	keys := make([]string, 0)
+	for key := range platformKeyToDisplay() {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// displayToPlatform converts the platform's display name into the platform.
+func displayToPlatform(display string) (string, bool) {
+	if platform, ok := constants.DisplayToPlatform[display]; ok {
+		return platform, true
+	}
+	platform, ok := constants.AWSDisplayToPlatform[display]
+	return platform, ok
+}
+
+// getPlatformKey converts the platform's display name, or the platform once it has been
+// validated, back into its flag value.
+func getPlatformKey(text string) string {
+	for key, display := range platformKeyToDisplay() {
+		if platform, _ := displayToPlatform(display); text == display || text == platform {
+			return key
+		}
+	}
+	return text
+}
+
+func recreateCommand(m map[string]string) string {
+	var commandName string
+	if len(os.Args) > 0 {
+		commandName = os.Args[0]
+	} else {
+		commandName = constants.CLIName
+	}
+
+	var buf strings.Builder
+	buf.WriteString(commandName)
+	buf.WriteString(" k8s install")
+
+	// Only the values that differ from the config file need to be passed in as flags.
+	fileValues := make(map[string]string)
+	if fn := m[kconst.KeyConfigFile]; len(fn) > 0 {
+		buf.WriteString(" --")
+		buf.WriteString(kconst.FlagConfig)
+		buf.WriteRune('=')
+		buf.WriteString(fn)
+
+		if f, err := config.Read(fn, allFlags()); err == nil {
+			fileValues = f.Values
+		}
+	}
+
+	for flagName, keyName := range kconst.FlagToKey {
+		if val, ok := m[keyName]; ok && len(val) > 0 {
+			switch keyName {
+			case kconst.KeyPermissions:
+				if val == constants.Read {
+					val = "false"
+				} else {
+					val = "true"
+				}
+			case kconst.KeyPlatform:
+				val = getPlatformKey(val)
+			}
+
+			if fileVal, ok := fileValues[flagName]; ok && fileVal == val {
+				continue
+			} else if !ok && flagName == kconst.FlagWrite && val == "false" {
+				continue
+			}
+
+			buf.WriteString(" --")
+			buf.WriteString(flagName)
+			buf.WriteRune('=')
+			buf.WriteString(val)
+		}
+	}
+
+	return buf.String()
+}
@@ -0,0 +1,169 @@
+package k8sinstall
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"flightcrew.io/cli/internal/constants"
+	"flightcrew.io/cli/internal/controller"
+	kconst "flightcrew.io/cli/internal/controller/k8s/constants"
+	"flightcrew.io/cli/internal/view/command"
+)
+
+type RunController struct {
+	args     map[string]string
+	replacer *strings.Replacer
+	commands []*command.Model
+}
+
+func NewRunController(args map[string]string) *RunController {
+	commands := make([]*command.Model, 0)
+	for _, m := range manifests {
+		if m.secret {
+			commands = append(commands, getSecretCommands()...)
+			continue
+		}
+		commands = append(commands, getApplyCommands(m)...)
+	}
+
+	replaceArgs := make([]string, 0, 2*len(args))
+	for key, arg := range args {
+		replaceArgs = append(replaceArgs, key, arg)
+	}
+
+	replacer := strings.NewReplacer(replaceArgs...)
+	for _, cmd := range commands {
+		cmd.Replace(replacer)
+	}
+
+	return &RunController{
+		args:     args,
+		replacer: replacer,
+		commands: commands,
+	}
+}
+
+func (ctl RunController) Commands() []*command.Model {
+	return ctl.commands
+}
+
+func (ctl *RunController) GetEndController() controller.End {
+	return NewEndController(ctl.commands, ctl.replacer)
+}
+
+func (ctl RunController) RecreateCommand() string {
+	return recreateCommand(ctl.args)
+}
+
+func (ctl RunController) Args() map[string]string {
+	return ctl.args
+}
+
+// StateName is the cluster context, the namespace and the deployment, which the default
+// state file is named after.
+func (ctl RunController) StateName() string {
+	return ctl.args[kconst.KeyContext] + "-" + ctl.args[kconst.KeyNamespace] + "-" + ctl.args[kconst.KeyDeployment]
+}
+
+// SecretArgs are the API token and the file that the Secret is created from. Plans read the
+// token from an env var, and the file is written from the same one.
+func (ctl RunController) SecretArgs() map[string]string {
+	return map[string]string{
+		kconst.KeyAPIToken:     constants.APITokenEnv,
+		kconst.KeyAPITokenFile: "",
+	}
+}
+
+// Files returns the manifests that are applied by the commands. The Secret is created from
+// the token file instead.
+func (ctl RunController) Files() map[string]string {
+	files := make(map[string]string)
+	for _, m := range manifests {
+		if m.secret {
+			continue
+		}
+
+		fn := filepath.Join(ctl.args[kconst.KeyOutputDir], m.filename)
+		contents, err := os.ReadFile(fn)
+		if err != nil {
+			continue
+		}
+		files[fn] = string(contents)
+	}
+	return files
+}
+
+// SecretFiles returns the file that the Secret is created from, so that it isn't copied into
+// plans with the manifests.
+func (ctl RunController) SecretFiles() map[string]string {
+	files := make(map[string]string)
+	if fn := ctl.args[kconst.KeyAPITokenFile]; len(fn) > 0 {
+		files[fn] = constants.APITokenEnv
+	}
+	return files
+}
+
+var applyDescriptions = map[string]string{
+	"namespace":      "This command creates the namespace that every other Flightcrew object is created in.\n\nhttps://kubernetes.io/docs/concepts/overview/working-with-objects/namespaces/",
+	"serviceaccount": "This command creates a ServiceAccount for the tower's pod, which is how you can grant it access to your cloud resources (e.g. Workload Identity on GKE or IAM roles for service accounts on EKS).\n\nhttps://kubernetes.io/docs/concepts/security/service-accounts/",
+	"deployment":     "This command creates a Deployment that runs the Control Tower image `${IMAGE_PATH}:${TOWER_VERSION}` with the ${PERMISSIONS} settings.\n\nhttps://kubernetes.io/docs/concepts/workloads/controllers/deployment/",
+}
+
+// getApplyCommands checks if the manifest's object already exists, and applies the
+// manifest if it doesn't.
+func getApplyCommands(m manifest) []*command.Model {
+	name := "${DEPLOYMENT}"
+	namespaceFlag := ` --namespace="${NAMESPACE}"`
+	if m.kind == "namespace" {
+		name = "${NAMESPACE}"
+		namespaceFlag = ""
+	}
+
+	checkExists := command.NewReadModel(command.Opts{
+		Description: "Check if the Flightcrew " + m.kind + " already exists or needs to be created.",
+		Command:     `kubectl get ` + m.kind + ` "` + name + `"` + namespaceFlag + `${KUBE_CONTEXT_FLAG} >/dev/null 2>&1`,
+		Message: map[command.State]string{
+			command.PassState: "The " + m.kind + " already exists.",
+			command.FailState: "No " + m.kind + " found. Next step is to create it.",
+		},
+	})
+
+	return []*command.Model{
+		checkExists,
+		command.NewWriteModel(command.Opts{
+			SkipIfSucceed: checkExists,
+			Description:   applyDescriptions[m.kind],
+			Command:       `kubectl apply${KUBE_CONTEXT_FLAG} --filename="${OUTPUT_DIR}/` + m.filename + `"`,
+			Undo:          `kubectl delete${KUBE_CONTEXT_FLAG} --filename="${OUTPUT_DIR}/` + m.filename + `"`,
+		}),
+	}
+}
+
+// getSecretCommands checks if the Secret already exists, and creates it from the token file
+// if it doesn't, so that the token isn't in the command or a manifest.
+func getSecretCommands() []*command.Model {
+	checkExists := command.NewReadModel(command.Opts{
+		Description: "Check if the Flightcrew secret already exists or needs to be created.",
+		Command:     `kubectl get secret "${DEPLOYMENT}" --namespace="${NAMESPACE}"${KUBE_CONTEXT_FLAG} >/dev/null 2>&1`,
+		Message: map[command.State]string{
+			command.PassState: "The secret already exists.",
+			command.FailState: "No secret found. Next step is to create it.",
+		},
+	})
+
+	return []*command.Model{
+		checkExists,
+		command.NewWriteModel(command.Opts{
+			SkipIfSucceed: checkExists,
+			Description:   "This command creates a Secret with your API token from `${API_TOKEN_FILE}`, which only you can read, and the tower reads it in as `FC_API_KEY`.\n\nhttps://kubernetes.io/docs/concepts/configuration/secret/",
+			Command: `kubectl create secret generic "${DEPLOYMENT}" \
+	--namespace="${NAMESPACE}"${KUBE_CONTEXT_FLAG} \
+	--from-file=FC_API_KEY="${API_TOKEN_FILE}" && \
+kubectl label secret "${DEPLOYMENT}" \
+	--namespace="${NAMESPACE}"${KUBE_CONTEXT_FLAG} \
+	app.kubernetes.io/component=flightcrew`,
+			Undo: `kubectl delete secret "${DEPLOYMENT}" --namespace="${NAMESPACE}"${KUBE_CONTEXT_FLAG}`,
+		}),
+	}
+}
@@ -0,0 +1,182 @@
+package k8sinstall
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"flightcrew.io/cli/internal/apitoken"
+	"flightcrew.io/cli/internal/constants"
+	"flightcrew.io/cli/internal/controller/gcp"
+	kconst "flightcrew.io/cli/internal/controller/k8s/constants"
+	"flightcrew.io/cli/internal/runner"
+	"flightcrew.io/cli/internal/view"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInstallFlow(mainT *testing.T) {
+	keepARS := gcp.ArtifactRegistryService
+	gcp.ArtifactRegistryService = nil
+	keepRunner := runner.Default
+	keepEndpoint := apitoken.Endpoint
+	mainT.Cleanup(func() {
+		gcp.ArtifactRegistryService = keepARS
+		runner.Default = keepRunner
+		apitoken.Endpoint = keepEndpoint
+	})
+
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "Bearer revoked-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(`{"organizationName": "Acme"}`))
+	}))
+	mainT.Cleanup(tokenServer.Close)
+	apitoken.Endpoint = tokenServer.URL
+
+	newParams := func(t *testing.T, permissions string, platform string) Params {
+		return Params{
+			args: map[string]string{
+				kconst.KeyNamespace:    "flightcrew",
+				kconst.KeyDeployment:   "flightcrew-control-tower",
+				kconst.KeyTowerVersion: "1.2.3",
+				kconst.KeyAPIToken:     `to"ken`,
+				kconst.KeyPermissions:  permissions,
+				kconst.KeyPlatform:     platform,
+			},
+			tempDir: t.TempDir(),
+		}
+	}
+
+	mainT.Run("fresh cluster should apply everything", func(t *testing.T) {
+		scripted, err := runner.NewScripted(
+			runner.Response{Match: `^kubectl config current-context`, Stdout: "kind-test\n"},
+			runner.Response{Match: `^kubectl get `, ExitCode: 1},
+			runner.Response{Match: `^kubectl (apply|create secret) `},
+		)
+		require.NoError(t, err)
+		runner.Default = scripted
+
+		params := newParams(t, constants.Write, constants.AmazonEC2Display)
+		ctl := NewInputsController(params)
+		var out bytes.Buffer
+		require.NoError(t, view.RunHeadless(ctl, &out))
+
+		expected := []string{
+			`^kubectl get namespace "flightcrew" --context="kind-test"`,
+			`^kubectl apply --context="kind-test" --filename=".*/00-namespace.yaml"`,
+			`^kubectl get serviceaccount "flightcrew-control-tower" --namespace="flightcrew" --context="kind-test"`,
+			`^kubectl apply --context="kind-test" --filename=".*/01-serviceaccount.yaml"`,
+			`^kubectl get secret "flightcrew-control-tower" --namespace="flightcrew"`,
+			`^kubectl create secret generic "flightcrew-control-tower" --namespace="flightcrew" --context="kind-test" --from-file=FC_API_KEY="\S+/api_token" && kubectl label secret "flightcrew-control-tower" --namespace="flightcrew" --context="kind-test" app\.kubernetes\.io/component=flightcrew$`,
+			`^kubectl get deployment "flightcrew-control-tower" --namespace="flightcrew"`,
+			`^kubectl apply --context="kind-test" --filename=".*/03-deployment.yaml"`,
+		}
+		i := 0
+		for _, call := range scripted.Calls {
+			if i < len(expected) && regexp.MustCompile(expected[i]).MatchString(call) {
+				i++
+			}
+		}
+		if i < len(expected) {
+			assert.Fail(t, "missing call", "no call matching %q in:\n%v", expected[i], scripted.Calls)
+		}
+
+		// The Secret is created from the token file instead of a manifest.
+		assert.NoFileExists(t, filepath.Join(params.tempDir, "02-secret.yaml"))
+		token, err := os.ReadFile(filepath.Join(params.tempDir, "api_token"))
+		require.NoError(t, err)
+		assert.Equal(t, `to"ken`, string(token))
+		for _, call := range scripted.Calls {
+			assert.NotContains(t, call, `to"ken`)
+		}
+
+		deployment, err := os.ReadFile(filepath.Join(params.tempDir, "03-deployment.yaml"))
+		require.NoError(t, err)
+		assert.Contains(t, string(deployment), `image: "`+gcp.ImagePath+`:1.2.3"`)
+		assert.Contains(t, string(deployment), "- name: TRAFFIC_ROUTER\n              value: \""+constants.AmazonEC2Platform+`"`)
+		assert.Contains(t, string(deployment), "- name: METRIC_PROVIDERS\n              value: \"cloudwatch\"")
+		assert.NotContains(t, string(deployment), "to\\\"ken")
+	})
+
+	mainT.Run("existing objects should be skipped", func(t *testing.T) {
+		scripted, err := runner.NewScripted(
+			runner.Response{Match: `^kubectl config current-context`, ExitCode: 1},
+			runner.Response{Match: `^kubectl get `},
+		)
+		require.NoError(t, err)
+		runner.Default = scripted
+
+		params := newParams(t, constants.Read, constants.GoogleComputeEngineDisplay)
+		var out bytes.Buffer
+		require.NoError(t, view.RunHeadless(NewInputsController(params), &out))
+
+		for _, call := range scripted.Calls {
+			assert.NotRegexp(t, `^kubectl apply`, call)
+			assert.NotContains(t, call, "--context")
+		}
+
+		deployment, err := os.ReadFile(filepath.Join(params.tempDir, "03-deployment.yaml"))
+		require.NoError(t, err)
+		assert.NotContains(t, string(deployment), "TRAFFIC_ROUTER")
+		assert.Contains(t, string(deployment), "- name: METRIC_PROVIDERS\n              value: \"stackdriver\"")
+	})
+
+	mainT.Run("plan should not have the token", func(t *testing.T) {
+		scripted, err := runner.NewScripted(runner.Response{Match: `^kubectl config current-context`, ExitCode: 1})
+		require.NoError(t, err)
+		runner.Default = scripted
+
+		params := newParams(t, constants.Read, constants.GoogleComputeEngineDisplay)
+		params.args[kconst.KeyAPIToken] = "my-secret-token"
+		var plan bytes.Buffer
+		require.NoError(t, view.WritePlan(NewInputsController(params), &plan))
+		assert.NotContains(t, plan.String(), "my-secret-token")
+		assert.NotContains(t, plan.String(), "02-secret.yaml")
+		assert.Contains(t, plan.String(), "(umask 077 && printf %s \"$FLIGHTCREW_API_TOKEN\" > '"+filepath.Join(params.tempDir, "api_token")+"')")
+	})
+
+	mainT.Run("manifests dir should have the secret manifest", func(t *testing.T) {
+		scripted, err := runner.NewScripted(runner.Response{Match: `^kubectl config current-context`, ExitCode: 1})
+		require.NoError(t, err)
+		runner.Default = scripted
+
+		params := newParams(t, constants.Read, constants.GoogleComputeEngineDisplay)
+		params.manifestsDir = t.TempDir()
+		require.NoError(t, view.ValidateInputs(NewInputsController(params)))
+
+		secret, err := os.ReadFile(filepath.Join(params.manifestsDir, "02-secret.yaml"))
+		require.NoError(t, err)
+		assert.Contains(t, string(secret), `FC_API_KEY: "to\"ken"`)
+		assert.NoFileExists(t, filepath.Join(params.manifestsDir, "api_token"))
+	})
+
+	mainT.Run("revoked token should fail validation", func(t *testing.T) {
+		scripted, err := runner.NewScripted(runner.Response{Match: `^kubectl config current-context`, ExitCode: 1})
+		require.NoError(t, err)
+		runner.Default = scripted
+
+		params := newParams(t, constants.Read, constants.GoogleComputeEngineDisplay)
+		params.args[kconst.KeyAPIToken] = "revoked-token"
+		err = view.ValidateInputs(NewInputsController(params))
+		assert.ErrorContains(t, err, "API Token: the token is invalid or has been revoked")
+	})
+
+	mainT.Run("invalid names should fail validation", func(t *testing.T) {
+		scripted, err := runner.NewScripted()
+		require.NoError(t, err)
+		runner.Default = scripted
+
+		params := newParams(t, constants.Read, constants.GoogleComputeEngineDisplay)
+		params.args[kconst.KeyNamespace] = "Flightcrew_NS"
+		err = view.ValidateInputs(NewInputsController(params))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "Namespace")
+	})
+}
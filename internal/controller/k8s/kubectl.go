@@ -0,0 +1,42 @@
+package k8s
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+
+	"flightcrew.io/cli/internal/runner"
+)
+
+func HasKubectlInPath() bool {
+	var b bytes.Buffer
+	hasKubectlInPath := runner.Run(`which kubectl`, &b, &b) == nil
+
+	if !hasKubectlInPath {
+		fmt.Printf(`The "kubectl" CLI tool is a pre-requisite to run this script.
+
+		If you haven't yet, please install the tool: https://kubernetes.io/docs/tasks/tools/
+
+		If you already have, please add it to your path:
+		  export PATH=<where it is>:$PATH
+
+		`)
+	}
+	return hasKubectlInPath
+}
+
+// GetCurrentContext returns the kubeconfig context that kubectl uses by default.
+func GetCurrentContext() (string, error) {
+	stdout, _, err := runner.Output(`kubectl config current-context`)
+	if err != nil {
+		return "", fmt.Errorf("kubectl config current-context: %w", err)
+	}
+
+	kubeContext := strings.TrimSpace(stdout.String())
+	if len(kubeContext) == 0 {
+		return "", errors.New("not found")
+	}
+
+	return kubeContext, nil
+}
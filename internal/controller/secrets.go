@@ -0,0 +1,97 @@
+package controller
+
+import (
+	"os"
+	"sort"
+	"strings"
+)
+
+// Redacted replaces secrets in what's written about a run.
+const Redacted = "<redacted>"
+
+// SecretValues returns the values of the run's secret args that are set, keyed by arg.
+func SecretValues(run Run) map[string]string {
+	values := make(map[string]string)
+	secretsCtl, ok := run.(Secrets)
+	if !ok {
+		return values
+	}
+	checkpointer, ok := run.(Checkpointer)
+	if !ok {
+		return values
+	}
+
+	args := checkpointer.Args()
+	for key := range secretsCtl.SecretArgs() {
+		if value := args[key]; len(value) > 0 {
+			values[key] = value
+		}
+	}
+	return values
+}
+
+// NewRedactor replaces the values of the run's secret args, and the contents of its secret
+// files, with Redacted.
+func NewRedactor(run Run) *strings.Replacer {
+	replacer, _ := newSecretReplacer(run, false)
+	return replacer
+}
+
+// NewPlanRedactor is like NewRedactor, but replaces the secret args that have an env var with
+// a reference to it (e.g. ${FLIGHTCREW_API_TOKEN}) so that the plan can still run. It also
+// returns the env vars, which the plan has to check are set.
+func NewPlanRedactor(run Run) (*strings.Replacer, []string) {
+	return newSecretReplacer(run, true)
+}
+
+func newSecretReplacer(run Run, withEnv bool) (*strings.Replacer, []string) {
+	type secret struct {
+		value       string
+		replacement string
+	}
+
+	secretFiles := make(map[string]string)
+	if filesCtl, ok := run.(SecretFiles); ok {
+		secretFiles = filesCtl.SecretFiles()
+	}
+
+	secrets := make([]secret, 0)
+	envs := make(map[string]bool)
+	if secretsCtl, ok := run.(Secrets); ok {
+		keys := secretsCtl.SecretArgs()
+		for key, value := range SecretValues(run) {
+			// Plans write the secret files themselves, so the commands can keep their paths.
+			if _, ok := secretFiles[value]; withEnv && ok {
+				continue
+			}
+
+			replacement := Redacted
+			if env := keys[key]; withEnv && len(env) > 0 {
+				replacement = "${" + env + "}"
+				envs[env] = true
+			}
+			secrets = append(secrets, secret{value: value, replacement: replacement})
+		}
+	}
+	for fn := range secretFiles {
+		if contents, err := os.ReadFile(fn); err == nil && len(contents) > 0 {
+			secrets = append(secrets, secret{value: string(contents), replacement: Redacted})
+		}
+	}
+
+	// Redact the longest secrets first in case one contains another. The args go first for
+	// the same length, so that a file with only the token is replaced with its env var.
+	sort.SliceStable(secrets, func(i, j int) bool {
+		return len(secrets[i].value) > len(secrets[j].value)
+	})
+	replaceArgs := make([]string, 0, 2*len(secrets))
+	for _, s := range secrets {
+		replaceArgs = append(replaceArgs, s.value, s.replacement)
+	}
+	envNames := make([]string, 0, len(envs))
+	for env := range envs {
+		envNames = append(envNames, env)
+	}
+	sort.Strings(envNames)
+	return strings.NewReplacer(replaceArgs...), envNames
+}
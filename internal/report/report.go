@@ -0,0 +1,122 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"flightcrew.io/cli/internal/controller"
+	"flightcrew.io/cli/internal/debug"
+	"flightcrew.io/cli/internal/version"
+	"flightcrew.io/cli/internal/view/command"
+)
+
+var (
+	reportFile string
+)
+
+// Report is the machine-readable result of a run (e.g. for a change-management system).
+type Report struct {
+	Flow       string            `json:"flow"`
+	CLIVersion string            `json:"cliVersion"`
+	Inputs     map[string]string `json:"inputs"`
+	Commands   []Command         `json:"commands"`
+}
+
+type Command struct {
+	Type        command.Type  `json:"type"`
+	Description string        `json:"description"`
+	Command     string        `json:"command"`
+	State       command.State `json:"state"`
+	Message     string        `json:"message,omitempty"`
+	Log         string        `json:"log,omitempty"`
+	Started     *time.Time    `json:"started,omitempty"`
+	Finished    *time.Time    `json:"finished,omitempty"`
+	ExitCode    *int          `json:"exitCode,omitempty"`
+}
+
+// Enable writes the report to the file at every Save, so that it has the latest state
+// however the run ends.
+func Enable(fn string) func() {
+	reportFile = fn
+	return func() {
+		reportFile = ""
+	}
+}
+
+// Save writes the run's report to the file, if it's enabled. Errors only go to the debug
+// output, since a run shouldn't stop because its report couldn't be written.
+func Save(run controller.Run) {
+	if len(reportFile) == 0 {
+		return
+	}
+
+	if err := Write(reportFile, New(run)); err != nil {
+		debug.Output("write report to %s: %v", reportFile, err)
+	}
+}
+
+// New creates the report for the run. The secrets that the run lists (e.g. the API token and
+// the contents of the file it's read from) are redacted from the inputs, commands and output
+// logs.
+func New(run controller.Run) Report {
+	r := Report{
+		Flow:       run.GetEndController().Name(),
+		CLIVersion: version.Version(),
+		Inputs:     make(map[string]string),
+		Commands:   make([]Command, 0, len(run.Commands())),
+	}
+
+	secrets := controller.SecretValues(run)
+	if checkpointer, ok := run.(controller.Checkpointer); ok {
+		for key, value := range checkpointer.Args() {
+			if _, ok := secrets[key]; ok {
+				value = controller.Redacted
+			}
+			r.Inputs[strings.TrimSuffix(strings.TrimPrefix(key, "${"), "}")] = value
+		}
+	}
+	redactor := controller.NewRedactor(run)
+
+	for _, cmd := range run.Commands() {
+		snapshot := cmd.Snapshot()
+		r.Commands = append(r.Commands, Command{
+			Type:        cmd.Type(),
+			Description: redactor.Replace(cmd.Description()),
+			Command:     redactor.Replace(cmd.Command()),
+			State:       snapshot.State,
+			Message:     redactor.Replace(snapshot.Message),
+			Log:         redactor.Replace(snapshot.Log),
+			Started:     snapshot.Started,
+			Finished:    snapshot.Finished,
+			ExitCode:    snapshot.ExitCode,
+		})
+	}
+
+	return r
+}
+
+func Write(fn string, r Report) error {
+	if dir := filepath.Dir(fn); len(dir) > 0 {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return err
+		}
+	}
+
+	// The commands are full of '>' and '&', so don't escape them for HTML.
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(r); err != nil {
+		return err
+	}
+	// Only the user can read the report, in case a secret wasn't listed by the run.
+	if err := os.WriteFile(fn, b.Bytes(), 0600); err != nil {
+		return err
+	}
+	return os.Chmod(fn, 0600)
+}
@@ -0,0 +1,125 @@
+package report
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"flightcrew.io/cli/internal/controller"
+	"flightcrew.io/cli/internal/runner"
+	"flightcrew.io/cli/internal/view/command"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeRun struct {
+	args        map[string]string
+	secretFiles map[string]string
+	commands    []*command.Model
+}
+
+func (r fakeRun) Commands() []*command.Model       { return r.commands }
+func (r fakeRun) RecreateCommand() string          { return "" }
+func (r fakeRun) GetEndController() controller.End { return fakeEnd{r.commands} }
+func (r fakeRun) Args() map[string]string          { return r.args }
+func (r fakeRun) StateName() string                { return r.args["${PROJECT_ID}"] }
+func (r fakeRun) SecretArgs() map[string]string {
+	return map[string]string{"${API_TOKEN}": "FLIGHTCREW_API_TOKEN", "${API_TOKEN_FILE}": ""}
+}
+func (r fakeRun) SecretFiles() map[string]string { return r.secretFiles }
+
+type fakeEnd struct {
+	commands []*command.Model
+}
+
+func (e fakeEnd) Name() string               { return "Fake Installation" }
+func (e fakeEnd) EndDescription() string     { return "" }
+func (e fakeEnd) Commands() []*command.Model { return e.commands }
+
+func TestReport(t *testing.T) {
+	scripted, err := runner.NewScripted(
+		runner.Response{Match: `^check `, ExitCode: 1},
+		runner.Response{Match: `^create `, Stdout: "created with secret-token from file-token\n", ExitCode: 2},
+	)
+	require.NoError(t, err)
+	keepRunner := runner.Default
+	runner.Default = scripted
+	t.Cleanup(func() {
+		runner.Default = keepRunner
+	})
+
+	check := command.NewReadModel(command.Opts{
+		Description: "Check if it exists.",
+		Command:     "check thing",
+	})
+	create := command.NewWriteModel(command.Opts{
+		SkipIfSucceed: check,
+		Description:   "Create the thing.",
+		Command: `create thing \
+	--token="secret-token"`,
+	})
+	notRun := command.NewWriteModel(command.Opts{
+		Description: "Never gets here.",
+		Command:     "create other",
+	})
+
+	require.False(t, check.ShouldPrompt())
+	require.True(t, create.ShouldPrompt())
+	require.Error(t, create.GetCommandToRun().Run())
+
+	tokenFile := filepath.Join(t.TempDir(), "api_token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("file-token"), 0600))
+	run := fakeRun{
+		args: map[string]string{
+			"${PROJECT_ID}":     "my-project",
+			"${API_TOKEN}":      "secret-token",
+			"${API_TOKEN_FILE}": tokenFile,
+			"${TOKEN_SECRET}":   "flightcrew-api-token",
+		},
+		secretFiles: map[string]string{tokenFile: "FLIGHTCREW_API_TOKEN"},
+		commands:    []*command.Model{check, create, notRun},
+	}
+
+	fn := filepath.Join(t.TempDir(), "output", "report.json")
+	require.NoError(t, Write(fn, New(run)))
+
+	info, err := os.Stat(fn)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	contents, err := os.ReadFile(fn)
+	require.NoError(t, err)
+	assert.NotContains(t, string(contents), "secret-token")
+	assert.NotContains(t, string(contents), "file-token")
+
+	var r Report
+	require.NoError(t, json.Unmarshal(contents, &r))
+	assert.Equal(t, "Fake Installation", r.Flow)
+	assert.Equal(t, map[string]string{
+		"PROJECT_ID":     "my-project",
+		"API_TOKEN":      "<redacted>",
+		"API_TOKEN_FILE": "<redacted>",
+		"TOKEN_SECRET":   "flightcrew-api-token",
+	}, r.Inputs)
+	require.Len(t, r.Commands, 3)
+
+	assert.Equal(t, command.ReadType, r.Commands[0].Type)
+	assert.Equal(t, command.FailState, r.Commands[0].State)
+	require.NotNil(t, r.Commands[0].ExitCode)
+	assert.Equal(t, 1, *r.Commands[0].ExitCode)
+
+	assert.Equal(t, command.WriteType, r.Commands[1].Type)
+	assert.Equal(t, `create thing --token="<redacted>"`, r.Commands[1].Command)
+	assert.Equal(t, command.FailState, r.Commands[1].State)
+	assert.Equal(t, "created with <redacted> from <redacted>\n", r.Commands[1].Log)
+	require.NotNil(t, r.Commands[1].ExitCode)
+	assert.Equal(t, 2, *r.Commands[1].ExitCode)
+	require.NotNil(t, r.Commands[1].Started)
+	require.NotNil(t, r.Commands[1].Finished)
+	assert.False(t, r.Commands[1].Finished.Before(*r.Commands[1].Started))
+
+	assert.Equal(t, command.NoneState, r.Commands[2].State)
+	assert.Nil(t, r.Commands[2].Started)
+	assert.Nil(t, r.Commands[2].ExitCode)
+}
@@ -0,0 +1,65 @@
+package runner
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os/exec"
+)
+
+// Runner runs a bash command. Every command that the CLI shells out for goes through
+// a Runner so that the flows can be run against a fake in tests.
+type Runner interface {
+	Run(command string, stdin io.Reader, stdout io.Writer, stderr io.Writer) error
+}
+
+// Default is the Runner that is used to run all commands.
+var Default Runner = Bash{}
+
+// Run runs the command with the Default runner.
+func Run(command string, stdout io.Writer, stderr io.Writer) error {
+	return Default.Run(command, nil, stdout, stderr)
+}
+
+// Output runs the command with the Default runner and returns its stdout and stderr.
+func Output(command string) (*bytes.Buffer, *bytes.Buffer, error) {
+	var stdout, stderr bytes.Buffer
+	err := Default.Run(command, nil, &stdout, &stderr)
+	return &stdout, &stderr, err
+}
+
+// ExitCode returns the exit code of the error returned from a Runner.
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+
+	if exitErr, ok := err.(interface{ ExitCode() int }); ok {
+		return exitErr.ExitCode()
+	}
+	return -1
+}
+
+// Bash runs commands through `bash -c`.
+type Bash struct{}
+
+func (Bash) Run(command string, stdin io.Reader, stdout io.Writer, stderr io.Writer) error {
+	c := exec.Command("bash", "-c", command) //nolint:gosec
+	c.Stdin = stdin
+	c.Stdout = stdout
+	c.Stderr = stderr
+	return c.Run()
+}
+
+// ExitError is returned by the fake runners when a command exits with a non-zero code.
+type ExitError struct {
+	Code int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.Code)
+}
+
+func (e *ExitError) ExitCode() int {
+	return e.Code
+}
@@ -0,0 +1,83 @@
+package runner_test
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"flightcrew.io/cli/internal/runner"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScripted(mainT *testing.T) {
+	mainT.Run("first matching response should be used", func(t *testing.T) {
+		scripted, err := runner.NewScripted(
+			runner.Response{Match: `^gcloud projects list`, Stdout: "project_id\nproject-1\n"},
+			runner.Response{Match: `^gcloud`, ExitCode: 2},
+		)
+		require.NoError(t, err)
+
+		var stdout bytes.Buffer
+		assert.NoError(t, scripted.Run("gcloud projects list --format='csv(PROJECT_ID)'", nil, &stdout, nil))
+		assert.Equal(t, "project_id\nproject-1\n", stdout.String())
+
+		err = scripted.Run("gcloud iam roles describe role", nil, nil, nil)
+		assert.Equal(t, 2, runner.ExitCode(err))
+
+		assert.Equal(t, []string{
+			"gcloud projects list --format='csv(PROJECT_ID)'",
+			"gcloud iam roles describe role",
+		}, scripted.Calls)
+	})
+
+	mainT.Run("once responses should only match once", func(t *testing.T) {
+		scripted, err := runner.NewScripted(
+			runner.Response{Match: `describe`, ExitCode: 1, Once: true},
+			runner.Response{Match: `describe`},
+		)
+		require.NoError(t, err)
+
+		assert.Equal(t, 1, runner.ExitCode(scripted.Run("describe", nil, nil, nil)))
+		assert.Equal(t, 0, runner.ExitCode(scripted.Run("describe", nil, nil, nil)))
+		assert.Equal(t, 0, runner.ExitCode(scripted.Run("describe", nil, nil, nil)))
+	})
+
+	mainT.Run("unmatched commands should exit 127", func(t *testing.T) {
+		scripted, err := runner.NewScripted()
+		require.NoError(t, err)
+
+		var stderr bytes.Buffer
+		err = scripted.Run("which gcloud", nil, nil, &stderr)
+		assert.Equal(t, 127, runner.ExitCode(err))
+		assert.Contains(t, stderr.String(), "which gcloud")
+	})
+
+	mainT.Run("invalid match should error", func(t *testing.T) {
+		_, err := runner.NewScripted(runner.Response{Match: `(`})
+		assert.Error(t, err)
+	})
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	recorder := runner.NewRecorder(runner.Bash{})
+	var stdout bytes.Buffer
+	assert.NoError(t, recorder.Run("echo hello", nil, &stdout, nil))
+	assert.Equal(t, 3, runner.ExitCode(recorder.Run("echo first; exit 3", nil, nil, nil)))
+	assert.NoError(t, recorder.Run("echo second", nil, nil, nil))
+	assert.Equal(t, "hello\n", stdout.String())
+
+	fn := filepath.Join(t.TempDir(), "transcript.json")
+	require.NoError(t, recorder.WriteTranscript(fn))
+
+	replay, err := runner.Replay(fn)
+	require.NoError(t, err)
+
+	stdout.Reset()
+	assert.NoError(t, replay.Run("echo hello", nil, &stdout, nil))
+	assert.Equal(t, "hello\n", stdout.String())
+	assert.Equal(t, 3, runner.ExitCode(replay.Run("echo first; exit 3", nil, nil, nil)))
+
+	// Each recorded command is only replayed once.
+	assert.Equal(t, 127, runner.ExitCode(replay.Run("echo hello", nil, nil, nil)))
+}
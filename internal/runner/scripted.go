@@ -0,0 +1,84 @@
+package runner
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sync"
+)
+
+// Response is the canned result for the commands that match it.
+type Response struct {
+	// Match is a regular expression that the command is matched against.
+	Match    string `json:"match"`
+	Stdout   string `json:"stdout,omitempty"`
+	Stderr   string `json:"stderr,omitempty"`
+	ExitCode int    `json:"exitCode,omitempty"`
+	// Once makes the response only match a single time, so that the same command can
+	// have different results over the course of a flow.
+	Once bool `json:"once,omitempty"`
+
+	re   *regexp.Regexp
+	used bool
+}
+
+// Scripted is a fake Runner that matches each command to the first Response that matches
+// it. Commands without a matching Response exit with code 127, like a missing command.
+type Scripted struct {
+	mu        sync.Mutex
+	responses []*Response
+
+	// Calls are the commands that were run, in order.
+	Calls []string
+}
+
+func NewScripted(responses ...Response) (*Scripted, error) {
+	s := &Scripted{
+		responses: make([]*Response, 0, len(responses)),
+	}
+
+	for i := range responses {
+		resp := responses[i]
+		re, err := regexp.Compile(resp.Match)
+		if err != nil {
+			return nil, fmt.Errorf("compile response %d: %w", i, err)
+		}
+		resp.re = re
+		s.responses = append(s.responses, &resp)
+	}
+
+	return s, nil
+}
+
+func (s *Scripted) Run(command string, stdin io.Reader, stdout io.Writer, stderr io.Writer) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.Calls = append(s.Calls, command)
+	for _, resp := range s.responses {
+		if resp.used || !resp.re.MatchString(command) {
+			continue
+		}
+
+		if resp.Once {
+			resp.used = true
+		}
+
+		if stdout != nil {
+			_, _ = io.WriteString(stdout, resp.Stdout)
+		}
+		if stderr != nil {
+			_, _ = io.WriteString(stderr, resp.Stderr)
+		}
+
+		if resp.ExitCode != 0 {
+			return &ExitError{Code: resp.ExitCode}
+		}
+		return nil
+	}
+
+	if stderr != nil {
+		_, _ = fmt.Fprintf(stderr, "no scripted response for: %s\n", command)
+	}
+	return &ExitError{Code: 127}
+}
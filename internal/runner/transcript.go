@@ -0,0 +1,101 @@
+package runner
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sync"
+)
+
+// Entry is a single command that was run, along with its result.
+type Entry struct {
+	Command  string `json:"command"`
+	Stdout   string `json:"stdout,omitempty"`
+	Stderr   string `json:"stderr,omitempty"`
+	ExitCode int    `json:"exitCode"`
+}
+
+// Recorder wraps a Runner and records every command that goes through it, so that the
+// transcript of a real session can be replayed later.
+type Recorder struct {
+	runner Runner
+
+	mu      sync.Mutex
+	entries []Entry
+}
+
+func NewRecorder(r Runner) *Recorder {
+	return &Recorder{
+		runner: r,
+	}
+}
+
+func (r *Recorder) Run(command string, stdin io.Reader, stdout io.Writer, stderr io.Writer) error {
+	var outBuf, errBuf bytes.Buffer
+	err := r.runner.Run(command, stdin, teeWriter(stdout, &outBuf), teeWriter(stderr, &errBuf))
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries = append(r.entries, Entry{
+		Command:  command,
+		Stdout:   outBuf.String(),
+		Stderr:   errBuf.String(),
+		ExitCode: ExitCode(err),
+	})
+	return err
+}
+
+// Entries returns the commands that have been recorded so far.
+func (r *Recorder) Entries() []Entry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Entry{}, r.entries...)
+}
+
+// WriteTranscript writes the recorded commands as JSON. The transcript can contain
+// secrets that were passed into the commands, so the file is only readable by the user.
+func (r *Recorder) WriteTranscript(fn string) error {
+	data, err := json.MarshalIndent(r.Entries(), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(fn, data, 0600)
+}
+
+// Replay reads a transcript written by a Recorder and returns a fake Runner that answers
+// each command with its recorded result, in the order that they were recorded.
+func Replay(fn string) (*Scripted, error) {
+	data, err := os.ReadFile(fn)
+	if err != nil {
+		return nil, fmt.Errorf("read transcript: %w", err)
+	}
+
+	var entries []Entry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("parse transcript %s: %w", fn, err)
+	}
+
+	responses := make([]Response, 0, len(entries))
+	for _, entry := range entries {
+		responses = append(responses, Response{
+			Match:    "^" + regexp.QuoteMeta(entry.Command) + "$",
+			Stdout:   entry.Stdout,
+			Stderr:   entry.Stderr,
+			ExitCode: entry.ExitCode,
+			Once:     true,
+		})
+	}
+
+	return NewScripted(responses...)
+}
+
+func teeWriter(w io.Writer, buf *bytes.Buffer) io.Writer {
+	if w == nil {
+		return buf
+	}
+	return io.MultiWriter(w, buf)
+}
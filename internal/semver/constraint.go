@@ -0,0 +1,225 @@
+package semver
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ops are the operators that a constraint can start with, longest first so that `>=` isn't
+// read as `>`.
+var ops = []string{">=", "<=", "!=", ">", "<", "=", "~", "^"}
+
+type comparator struct {
+	op string
+	v  Version
+}
+
+func (c comparator) check(v Version) bool {
+	cmp := v.Compare(c.v)
+	switch c.op {
+	case "=":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	}
+	return false
+}
+
+// Constraint is a set of version ranges, like the ones used by npm:
+//
+//	1.2.3, =1.2.3    exactly 1.2.3
+//	2.1, 2.1.x       >=2.1.0 <2.2.0
+//	~2.1.3           >=2.1.3 <2.2.0
+//	^2.1             >=2.1.0 <3.0.0
+//	>=2.1.0 <3       both have to match (a comma works too)
+//	^1 || ^2         either has to match
+//
+// Prereleases (e.g. 2.1.0-rc.1) only match if a comparator asks for a prerelease of the
+// same x.y.z, so that test builds aren't picked up by accident.
+type Constraint struct {
+	raw  string
+	alts [][]comparator
+}
+
+func ParseConstraint(s string) (Constraint, error) {
+	c := Constraint{raw: s}
+	for _, alt := range strings.Split(s, "||") {
+		comparators, err := parseRange(alt)
+		if err != nil {
+			return Constraint{}, fmt.Errorf("constraint `%s`: %w", s, err)
+		}
+		c.alts = append(c.alts, comparators)
+	}
+	return c, nil
+}
+
+func parseRange(s string) ([]comparator, error) {
+	fields := strings.Fields(strings.ReplaceAll(s, ",", " "))
+	if len(fields) == 0 {
+		return nil, errors.New("empty range")
+	}
+
+	comparators := make([]comparator, 0, len(fields))
+	for i := 0; i < len(fields); i++ {
+		term := fields[i]
+		// Allow a space between the operator and the version (e.g. `>= 2.1`).
+		if isOp(term) && i+1 < len(fields) {
+			i++
+			term += fields[i]
+		}
+
+		cs, err := parseTerm(term)
+		if err != nil {
+			return nil, err
+		}
+		comparators = append(comparators, cs...)
+	}
+	return comparators, nil
+}
+
+func isOp(s string) bool {
+	for _, op := range ops {
+		if s == op {
+			return true
+		}
+	}
+	return false
+}
+
+// parseTerm turns a single operator and (partial) version into the comparators for its
+// range.
+func parseTerm(term string) ([]comparator, error) {
+	op := ""
+	for _, candidate := range ops {
+		if strings.HasPrefix(term, candidate) {
+			op = candidate
+			break
+		}
+	}
+
+	p, err := parsePartial(strings.TrimPrefix(term, op))
+	if err != nil {
+		return nil, err
+	}
+	lower := p.Version
+
+	if p.parts == 0 {
+		switch op {
+		case "", "=", ">=", "~", "^":
+			return nil, nil
+		}
+		return nil, fmt.Errorf("`%s` needs a version", term)
+	}
+
+	switch op {
+	case "", "=":
+		if p.parts == 3 {
+			return []comparator{{"=", lower}}, nil
+		}
+		return []comparator{{">=", lower}, {"<", p.next()}}, nil
+
+	case "!=":
+		if p.parts < 3 {
+			return nil, fmt.Errorf("`%s` needs an x.y.z version", term)
+		}
+		return []comparator{{"!=", lower}}, nil
+
+	case ">":
+		if p.parts == 3 {
+			return []comparator{{">", lower}}, nil
+		}
+		return []comparator{{">=", p.next()}}, nil
+
+	case ">=":
+		return []comparator{{">=", lower}}, nil
+
+	case "<":
+		return []comparator{{"<", lower}}, nil
+
+	case "<=":
+		if p.parts == 3 {
+			return []comparator{{"<=", lower}}, nil
+		}
+		return []comparator{{"<", p.next()}}, nil
+
+	case "~":
+		upper := Version{Major: lower.Major, Minor: lower.Minor + 1}
+		if p.parts == 1 {
+			upper = Version{Major: lower.Major + 1}
+		}
+		return []comparator{{">=", lower}, {"<", upper}}, nil
+
+	case "^":
+		var upper Version
+		switch {
+		case lower.Major > 0 || p.parts == 1:
+			upper = Version{Major: lower.Major + 1}
+		case lower.Minor > 0 || p.parts == 2:
+			upper = Version{Minor: lower.Minor + 1}
+		default:
+			upper = Version{Patch: lower.Patch + 1}
+		}
+		return []comparator{{">=", lower}, {"<", upper}}, nil
+	}
+
+	return nil, fmt.Errorf("unknown operator in `%s`", term)
+}
+
+func (c Constraint) String() string {
+	return c.raw
+}
+
+// Check returns whether the version is in any of the constraint's ranges.
+func (c Constraint) Check(v Version) bool {
+	for _, alt := range c.alts {
+		if checkRange(alt, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func checkRange(comparators []comparator, v Version) bool {
+	for _, c := range comparators {
+		if !c.check(v) {
+			return false
+		}
+	}
+
+	if !v.IsPrerelease() {
+		return true
+	}
+
+	for _, c := range comparators {
+		if c.v.IsPrerelease() && c.v.sameRelease(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// Highest returns the highest of the versions that match the constraint, and false if none
+// of them do.
+func (c Constraint) Highest(versions []Version) (Version, bool) {
+	var highest Version
+	found := false
+	for _, v := range versions {
+		if !c.Check(v) {
+			continue
+		}
+		if !found || v.Compare(highest) > 0 {
+			highest = v
+			found = true
+		}
+	}
+	return highest, found
+}
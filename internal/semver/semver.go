@@ -0,0 +1,169 @@
+// Package semver parses tower versions and the constraints that they can be picked by
+// (e.g. `~2.1`, `^2` or `>=2.1.0 <3`).
+package semver
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Version is a semantic version. Build metadata is dropped, since it doesn't affect
+// precedence.
+type Version struct {
+	Major      uint64
+	Minor      uint64
+	Patch      uint64
+	Prerelease string
+}
+
+// Parse parses a full `x.y.z[-prerelease][+build]` version, with an optional leading `v`.
+func Parse(s string) (Version, error) {
+	p, err := parsePartial(s)
+	if err != nil {
+		return Version{}, err
+	}
+	if p.parts < 3 {
+		return Version{}, fmt.Errorf("version `%s` should be x.y.z", s)
+	}
+	return p.Version, nil
+}
+
+func (v Version) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if len(v.Prerelease) > 0 {
+		s += "-" + v.Prerelease
+	}
+	return s
+}
+
+func (v Version) IsPrerelease() bool {
+	return len(v.Prerelease) > 0
+}
+
+// Compare returns -1, 0 or 1 if v is lower than, equal to or higher than o. A prerelease is
+// lower than its release.
+func (v Version) Compare(o Version) int {
+	if c := compareInt(v.Major, o.Major); c != 0 {
+		return c
+	}
+	if c := compareInt(v.Minor, o.Minor); c != 0 {
+		return c
+	}
+	if c := compareInt(v.Patch, o.Patch); c != 0 {
+		return c
+	}
+
+	switch {
+	case v.Prerelease == o.Prerelease:
+		return 0
+	case len(v.Prerelease) == 0:
+		return 1
+	case len(o.Prerelease) == 0:
+		return -1
+	}
+	return comparePrerelease(v.Prerelease, o.Prerelease)
+}
+
+func (v Version) sameRelease(o Version) bool {
+	return v.Major == o.Major && v.Minor == o.Minor && v.Patch == o.Patch
+}
+
+func compareInt(a, b uint64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// comparePrerelease compares the dot-separated identifiers in order. Numeric identifiers are
+// lower than alphanumeric ones, and a shorter list is lower if all of its identifiers match.
+func comparePrerelease(a, b string) int {
+	as := strings.Split(a, ".")
+	bs := strings.Split(b, ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		an, aErr := strconv.ParseUint(as[i], 10, 64)
+		bn, bErr := strconv.ParseUint(bs[i], 10, 64)
+		switch {
+		case aErr == nil && bErr == nil:
+			if c := compareInt(an, bn); c != 0 {
+				return c
+			}
+		case aErr == nil:
+			return -1
+		case bErr == nil:
+			return 1
+		default:
+			if c := strings.Compare(as[i], bs[i]); c != 0 {
+				return c
+			}
+		}
+	}
+	return compareInt(uint64(len(as)), uint64(len(bs)))
+}
+
+// partial is a version that may leave out the minor and patch numbers, or replace them with
+// `x` or `*` (e.g. `2`, `2.1` or `2.x`).
+type partial struct {
+	Version
+	parts int
+}
+
+func parsePartial(s string) (partial, error) {
+	var p partial
+	s = strings.TrimPrefix(s, "v")
+	if i := strings.IndexByte(s, '+'); i >= 0 {
+		s = s[:i]
+	}
+	if i := strings.IndexByte(s, '-'); i >= 0 {
+		p.Prerelease = s[i+1:]
+		s = s[:i]
+		if len(p.Prerelease) == 0 {
+			return partial{}, errors.New("empty prerelease")
+		}
+	}
+
+	if len(s) == 0 {
+		return partial{}, errors.New("empty version")
+	}
+
+	fields := strings.Split(s, ".")
+	if len(fields) > 3 {
+		return partial{}, fmt.Errorf("version `%s` has too many parts", s)
+	}
+
+	nums := []*uint64{&p.Major, &p.Minor, &p.Patch}
+	for i, field := range fields {
+		if field == "x" || field == "X" || field == "*" {
+			break
+		}
+
+		n, err := strconv.ParseUint(field, 10, 64)
+		if err != nil {
+			return partial{}, fmt.Errorf("version `%s` should only have numbers: %w", s, err)
+		}
+		*nums[i] = n
+		p.parts = i + 1
+	}
+
+	if len(p.Prerelease) > 0 && p.parts < 3 {
+		return partial{}, fmt.Errorf("version `%s` needs to be x.y.z to have a prerelease", s)
+	}
+	return p, nil
+}
+
+// next returns the lowest version that is higher than every version that the partial
+// version matches (e.g. 2.2.0 for 2.1).
+func (p partial) next() Version {
+	switch p.parts {
+	case 1:
+		return Version{Major: p.Major + 1}
+	case 2:
+		return Version{Major: p.Major, Minor: p.Minor + 1}
+	}
+	return Version{Major: p.Major, Minor: p.Minor, Patch: p.Patch + 1}
+}
@@ -0,0 +1,118 @@
+package semver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(mainT *testing.T) {
+	mainT.Run("full versions should parse", func(t *testing.T) {
+		v, err := Parse("v12.1.1351-test-not-stable+build.5")
+		require.NoError(t, err)
+		assert.Equal(t, Version{Major: 12, Minor: 1, Patch: 1351, Prerelease: "test-not-stable"}, v)
+		assert.Equal(t, "12.1.1351-test-not-stable", v.String())
+		assert.True(t, v.IsPrerelease())
+	})
+
+	mainT.Run("partial or invalid versions should fail", func(t *testing.T) {
+		for _, s := range []string{"", "2.1", "stable", "1.2.3.4", "1.a.3", "1.2.3-"} {
+			_, err := Parse(s)
+			assert.Error(t, err, s)
+		}
+	})
+}
+
+func TestCompare(t *testing.T) {
+	ordered := []string{
+		"1.0.0-alpha",
+		"1.0.0-alpha.1",
+		"1.0.0-alpha.beta",
+		"1.0.0-beta.2",
+		"1.0.0-beta.11",
+		"1.0.0-rc.1",
+		"1.0.0",
+		"1.0.1",
+		"1.2.0",
+		"2.0.0",
+	}
+	for i := 1; i < len(ordered); i++ {
+		lower, err := Parse(ordered[i-1])
+		require.NoError(t, err)
+		higher, err := Parse(ordered[i])
+		require.NoError(t, err)
+
+		assert.Equal(t, -1, lower.Compare(higher), "%s < %s", lower, higher)
+		assert.Equal(t, 1, higher.Compare(lower), "%s > %s", higher, lower)
+		assert.Equal(t, 0, higher.Compare(higher))
+	}
+}
+
+func TestConstraint(t *testing.T) {
+	versions := []string{"0.1.2", "0.2.0", "1.9.0", "2.0.0", "2.1.0", "2.1.7", "2.2.0", "2.3.0-rc.1", "3.0.0", "12.1.1351-test-not-stable"}
+
+	tests := []struct {
+		constraint string
+		want       []string
+	}{
+		{"2.1.0", []string{"2.1.0"}},
+		{"2.1", []string{"2.1.0", "2.1.7"}},
+		{"2.x", []string{"2.0.0", "2.1.0", "2.1.7", "2.2.0"}},
+		{"~2.1", []string{"2.1.0", "2.1.7"}},
+		{"~2.1.3", []string{"2.1.7"}},
+		{"~2", []string{"2.0.0", "2.1.0", "2.1.7", "2.2.0"}},
+		{"^2", []string{"2.0.0", "2.1.0", "2.1.7", "2.2.0"}},
+		{"^2.1.5", []string{"2.1.7", "2.2.0"}},
+		{"^0.1", []string{"0.1.2"}},
+		{">=2.1.0 <3", []string{"2.1.0", "2.1.7", "2.2.0"}},
+		{">= 2.1.0, < 3", []string{"2.1.0", "2.1.7", "2.2.0"}},
+		{">2.1", []string{"2.2.0", "3.0.0"}},
+		{"<=2.1", []string{"0.1.2", "0.2.0", "1.9.0", "2.0.0", "2.1.0", "2.1.7"}},
+		{"^0.2 || ^3", []string{"0.2.0", "3.0.0"}},
+		{"^2 != 2.1.0", []string{"2.0.0", "2.1.7", "2.2.0"}},
+		{">=2.3.0-rc.0 <3", []string{"2.3.0-rc.1"}},
+		{">=12.1.1351-test", []string{"12.1.1351-test-not-stable"}},
+		{"*", []string{"0.1.2", "0.2.0", "1.9.0", "2.0.0", "2.1.0", "2.1.7", "2.2.0", "3.0.0"}},
+	}
+
+	for _, tt := range tests {
+		c, err := ParseConstraint(tt.constraint)
+		require.NoError(t, err, tt.constraint)
+
+		got := make([]string, 0)
+		for _, s := range versions {
+			v, err := Parse(s)
+			require.NoError(t, err)
+			if c.Check(v) {
+				got = append(got, s)
+			}
+		}
+		assert.Equal(t, tt.want, got, tt.constraint)
+	}
+
+	for _, s := range []string{"", "stable", "!=2.1", ">", "~>2", "2.1 ||"} {
+		_, err := ParseConstraint(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestHighest(t *testing.T) {
+	versions := make([]Version, 0)
+	for _, s := range []string{"2.1.0", "2.2.0", "2.1.7", "3.0.0-rc.1"} {
+		v, err := Parse(s)
+		require.NoError(t, err)
+		versions = append(versions, v)
+	}
+
+	c, err := ParseConstraint("^2")
+	require.NoError(t, err)
+	highest, ok := c.Highest(versions)
+	assert.True(t, ok)
+	assert.Equal(t, "2.2.0", highest.String())
+
+	c, err = ParseConstraint("^4")
+	require.NoError(t, err)
+	_, ok = c.Highest(versions)
+	assert.False(t, ok)
+}
@@ -2,10 +2,11 @@ package command
 
 import (
 	"bytes"
-	"os/exec"
 	"strings"
+	"time"
 
 	"flightcrew.io/cli/internal/debug"
+	"flightcrew.io/cli/internal/runner"
 	"flightcrew.io/cli/internal/style"
 	"github.com/charmbracelet/lipgloss"
 )
@@ -46,14 +47,29 @@ const (
 type Output struct {
 	Log     string
 	Message string
+
+	// Started and Finished are zero until the command is run.
+	Started  time.Time
+	Finished time.Time
+	// ExitCode is the command's exit code. Read-only commands that are answered by a
+	// Check exit with 0 if they pass and 1 if they fail, as if they were run.
+	ExitCode int
 }
 
 type Opts struct {
 	// If this read-only command succeeds, then we should not run the actual command.
 	SkipIfSucceed *Model
-	Message       map[State]string
-	Command       string
-	Description   string
+	// If this read-only command fails, then we should not run the actual command.
+	SkipIfFail  *Model
+	Message     map[State]string
+	Command     string
+	Description string
+	// Check is run instead of the Command for read-only commands if it's set. The command
+	// passes if Check returns no error.
+	Check func() error
+	// Undo reverts the change of a write command, so that it can be rolled back if a later
+	// command fails.
+	Undo string
 }
 
 type Model struct {
@@ -61,6 +77,11 @@ type Model struct {
 	commandType Type
 	opts        Opts
 	output      Output
+
+	// ran is whether the command was run in this session, as opposed to restored.
+	ran bool
+	// undoes is the command that this command rolls back.
+	undoes *Model
 }
 
 func NewReadModel(opts Opts) *Model {
@@ -101,11 +122,107 @@ func (m *Model) Complete(pass bool) {
 	if msg := m.opts.Message[m.state]; len(msg) > 0 {
 		m.output.Message = msg
 	}
+
+	// Once the change is rolled back, the command needs to be run again.
+	if pass && m.undoes != nil {
+		m.undoes.state = NoneState
+		m.undoes.ran = false
+		m.undoes.output = Output{}
+	}
+}
+
+// UndoModel returns the command that rolls back this command's change, or nil if there is
+// nothing to roll back because it didn't change anything in this session.
+func (m *Model) UndoModel() *Model {
+	if len(m.opts.Undo) == 0 || !m.ran || m.state != PassState {
+		return nil
+	}
+
+	return &Model{
+		state:       NoneState,
+		commandType: WriteType,
+		undoes:      m,
+		opts: Opts{
+			Command:     m.opts.Undo,
+			Description: "This command rolls back the change from this step:\n\n" + m.opts.Description,
+		},
+	}
+}
+
+// RollbackCommands returns the commands that roll back the changes of the given commands
+// in the reverse order that they were made.
+func RollbackCommands(commands []*Model) []*Model {
+	undos := make([]*Model, 0)
+	for i := len(commands) - 1; i >= 0; i-- {
+		if undo := commands[i].UndoModel(); undo != nil {
+			undos = append(undos, undo)
+		}
+	}
+	return undos
+}
+
+// Snapshot is the part of a Model that changes as it's run, so that the progress can be
+// saved and restored later.
+type Snapshot struct {
+	State   State  `json:"state"`
+	Log     string `json:"log,omitempty"`
+	Message string `json:"message,omitempty"`
+	// Started, Finished and ExitCode are only set if the command was run.
+	Started  *time.Time `json:"started,omitempty"`
+	Finished *time.Time `json:"finished,omitempty"`
+	ExitCode *int       `json:"exitCode,omitempty"`
+}
+
+func (m Model) Snapshot() Snapshot {
+	s := Snapshot{
+		State:   m.state,
+		Log:     m.output.Log,
+		Message: m.output.Message,
+	}
+	if !m.output.Started.IsZero() {
+		started, exitCode := m.output.Started, m.output.ExitCode
+		s.Started = &started
+		s.ExitCode = &exitCode
+	}
+	if !m.output.Finished.IsZero() {
+		finished := m.output.Finished
+		s.Finished = &finished
+	}
+	return s
+}
+
+func (m *Model) Restore(s Snapshot) {
+	m.state = s.State
+	m.output.Log = s.Log
+	m.output.Message = s.Message
+	if s.Started != nil {
+		m.output.Started = *s.Started
+	}
+	if s.Finished != nil {
+		m.output.Finished = *s.Finished
+	}
+	if s.ExitCode != nil {
+		m.output.ExitCode = *s.ExitCode
+	}
+}
+
+func (m Model) Type() Type {
+	return m.commandType
+}
+
+func (m Model) Description() string {
+	return m.opts.Description
+}
+
+// Command returns the command as it's run, on a single line.
+func (m Model) Command() string {
+	return sanitizeForExec(m.opts.Command)
 }
 
 func (m *Model) Replace(replacer *strings.Replacer) {
 	m.opts.Command = replacer.Replace(m.opts.Command)
 	m.opts.Description = replacer.Replace(m.opts.Description)
+	m.opts.Undo = replacer.Replace(m.opts.Undo)
 }
 
 func (m Model) State() State {
@@ -127,35 +244,55 @@ func (m *Model) Prompt() {
 
 func (m *Model) ShouldPrompt() bool {
 	if m.IsRead() {
-		bashCommand := sanitizeForExec(m.opts.Command)
-		c := exec.Command("bash", "-c", bashCommand) //nolint:gosec
-		var b bytes.Buffer
-		c.Stdout = &b
-		c.Stderr = &b
-		debug.Output("run `%s`", bashCommand)
-		debug.Output("output: %s", b.String())
-		err := c.Run()
+		var err error
+		m.output.Started = time.Now()
+		if m.opts.Check != nil {
+			debug.Output("check `%s` through the API", m.opts.Description)
+			err = m.opts.Check()
+			if err != nil {
+				m.output.ExitCode = 1
+				m.SetOutputLog(err.Error())
+			}
+		} else {
+			bashCommand := sanitizeForExec(m.opts.Command)
+			var b bytes.Buffer
+			debug.Output("run `%s`", bashCommand)
+			err = runner.Run(bashCommand, &b, &b)
+			debug.Output("output: %s", b.String())
+			m.output.ExitCode = runner.ExitCode(err)
+			m.SetOutputLog(b.String())
+		}
+		m.output.Finished = time.Now()
 		m.Complete(err == nil)
 		debug.Output("error: %v", err)
 		return false
 	}
 
-	if m.opts.SkipIfSucceed == nil {
-		m.state = PromptState
-		return true
+	if m.skipFor(m.opts.SkipIfSucceed, PassState) || m.skipFor(m.opts.SkipIfFail, FailState) {
+		return false
 	}
 
-	state := m.opts.SkipIfSucceed.State()
-	if state == PassState {
-		m.output.Message = m.opts.SkipIfSucceed.opts.Message[PassState]
-		m.state = SkipState
+	m.state = PromptState
+	return true
+}
+
+// skipFor moves the command into the SkipState if the prereq command ended up in the
+// given state.
+func (m *Model) skipFor(prereq *Model, skipState State) bool {
+	if prereq == nil {
 		return false
 	}
 
+	state := prereq.State()
 	if state == NoneState {
 		panic("prereq commands should go before the dependent commands")
 	}
 
-	m.state = PromptState
+	if state != skipState {
+		return false
+	}
+
+	m.output.Message = prereq.opts.Message[skipState]
+	m.state = SkipState
 	return true
 }
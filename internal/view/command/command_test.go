@@ -0,0 +1,98 @@
+package command
+
+import (
+	"errors"
+	"testing"
+
+	"flightcrew.io/cli/internal/runner"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadCheck(t *testing.T) {
+	scripted, err := runner.NewScripted()
+	require.NoError(t, err)
+	keepRunner := runner.Default
+	runner.Default = scripted
+	t.Cleanup(func() {
+		runner.Default = keepRunner
+	})
+
+	pass := NewReadModel(Opts{
+		Command: "gcloud iam roles describe role",
+		Check:   func() error { return nil },
+	})
+	assert.False(t, pass.ShouldPrompt())
+	assert.Equal(t, PassState, pass.State())
+
+	fail := NewReadModel(Opts{
+		Command: "gcloud iam roles describe role",
+		Check:   func() error { return errors.New("not found") },
+	})
+	assert.False(t, fail.ShouldPrompt())
+	assert.Equal(t, FailState, fail.State())
+	assert.Equal(t, "not found", fail.Snapshot().Log)
+
+	assert.Empty(t, scripted.Calls)
+}
+
+func TestReadCommandLog(t *testing.T) {
+	scripted, err := runner.NewScripted(
+		runner.Response{Match: `^gcloud iam roles describe`, Stdout: "ERROR: role not found\n", ExitCode: 1},
+	)
+	require.NoError(t, err)
+	keepRunner := runner.Default
+	runner.Default = scripted
+	t.Cleanup(func() {
+		runner.Default = keepRunner
+	})
+
+	check := NewReadModel(Opts{Command: "gcloud iam roles describe role"})
+	assert.False(t, check.ShouldPrompt())
+	assert.Equal(t, FailState, check.State())
+	assert.Equal(t, "ERROR: role not found\n", check.Snapshot().Log)
+}
+
+func TestRollbackCommands(t *testing.T) {
+	scripted, err := runner.NewScripted(
+		runner.Response{Match: `^check`, ExitCode: 1},
+		runner.Response{Match: `^create third`, ExitCode: 1},
+		runner.Response{Match: `^(create|delete) `},
+	)
+	require.NoError(t, err)
+	keepRunner := runner.Default
+	runner.Default = scripted
+	t.Cleanup(func() {
+		runner.Default = keepRunner
+	})
+
+	check := NewReadModel(Opts{Command: "check"})
+	first := NewWriteModel(Opts{SkipIfSucceed: check, Command: "create first", Undo: "delete first"})
+	noUndo := NewWriteModel(Opts{SkipIfSucceed: check, Command: "create second"})
+	third := NewWriteModel(Opts{SkipIfSucceed: check, Command: "create third", Undo: "delete third"})
+	commands := []*Model{check, first, noUndo, third}
+
+	for _, cmd := range commands {
+		if cmd.ShouldPrompt() {
+			wc := cmd.GetCommandToRun()
+			cmd.Complete(wc.Run() == nil)
+		}
+	}
+	require.Equal(t, FailState, third.State())
+
+	// Only the changes that were made are rolled back.
+	rollback := RollbackCommands(commands)
+	require.Len(t, rollback, 1)
+	assert.Equal(t, "delete first", rollback[0].Command())
+
+	require.True(t, rollback[0].ShouldPrompt())
+	rollback[0].Complete(rollback[0].GetCommandToRun().Run() == nil)
+	assert.Equal(t, PassState, rollback[0].State())
+	assert.Equal(t, NoneState, first.State(), "rolled back commands need to be run again")
+	assert.Empty(t, RollbackCommands(commands))
+
+	// Restored commands weren't run in this session.
+	restored := NewWriteModel(Opts{Command: "create first", Undo: "delete first"})
+	restored.Restore(Snapshot{State: PassState})
+	assert.Nil(t, restored.UndoModel())
+}
@@ -3,43 +3,60 @@ package command
 import (
 	"bytes"
 	"io"
-	"os/exec"
 	"strings"
+	"time"
 
 	"flightcrew.io/cli/internal/debug"
+	"flightcrew.io/cli/internal/runner"
 )
 
+// WrappedCommand runs a command.Model through the runner, and implements tea.ExecCommand
+// so that the command can take over the terminal while it runs.
 type WrappedCommand struct {
 	model          *Model
-	cmd            *exec.Cmd
+	command        string
+	stdin          io.Reader
+	stdout         io.Writer
+	stderr         io.Writer
 	combinedOutput bytes.Buffer
 }
 
 func newWrappedCommand(m *Model) *WrappedCommand {
 	bashCommand := sanitizeForExec(m.opts.Command)
-	c := exec.Command("bash", "-c", bashCommand)
 	debug.Output("new wrapped command:\n  %s", bashCommand)
 	return &WrappedCommand{
-		cmd:   c,
-		model: m,
+		command: bashCommand,
+		model:   m,
 	}
 }
 func (wc *WrappedCommand) Run() error {
 	debug.Output("run command: %s", wc.model.opts.Command)
-	err := wc.cmd.Run()
+	wc.model.output.Started = time.Now()
+	wc.model.ran = true
+	err := runner.Default.Run(wc.command, wc.stdin, wc.writer(wc.stdout), wc.writer(wc.stderr))
+	wc.model.output.Finished = time.Now()
+	wc.model.output.ExitCode = runner.ExitCode(err)
 	wc.model.SetOutputLog(wc.combinedOutput.String())
 	wc.model.SetMessage(err)
 	debug.Output("err: %v\ncombined (%d): %s\n", err, len(wc.model.output.Log), wc.model.output.Log)
 	return err
 }
 func (wc *WrappedCommand) SetStdin(r io.Reader) {
-	wc.cmd.Stdin = r
+	wc.stdin = r
 }
 func (wc *WrappedCommand) SetStdout(w io.Writer) {
-	wc.cmd.Stdout = io.MultiWriter(w, &wc.combinedOutput)
+	wc.stdout = w
 }
 func (wc *WrappedCommand) SetStderr(w io.Writer) {
-	wc.cmd.Stderr = io.MultiWriter(w, &wc.combinedOutput)
+	wc.stderr = w
+}
+
+// writer makes sure that the output is always captured, even if it isn't going anywhere else.
+func (wc *WrappedCommand) writer(w io.Writer) io.Writer {
+	if w == nil {
+		return &wc.combinedOutput
+	}
+	return io.MultiWriter(w, &wc.combinedOutput)
 }
 
 // sanitizeForExec takes a command that can be formatted as something like this:
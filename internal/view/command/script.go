@@ -0,0 +1,70 @@
+package command
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// WriteScript writes the commands as a runnable bash script. Read commands are run once
+// and their result is stored, so that the write commands that depend on them can be
+// guarded the same way SkipIfSucceed and SkipIfFail work when running interactively.
+func WriteScript(w io.Writer, commands []*Model) error {
+	var b strings.Builder
+	checks := make(map[*Model]string)
+	for _, cmd := range commands {
+		b.WriteRune('\n')
+		writeComment(&b, cmd.opts.Description)
+
+		command := sanitizeForExec(cmd.opts.Command)
+		if cmd.IsRead() {
+			// Run the check in a subshell since it's run in its own bash process otherwise.
+			name := fmt.Sprintf("CHECK_%d", len(checks)+1)
+			checks[cmd] = name
+			b.WriteString(fmt.Sprintf("if ( %s ); then\n  %s=0\nelse\n  %s=1\nfi\n", command, name, name))
+			continue
+		}
+
+		conditions := make([]string, 0, 2)
+		for _, prereq := range []struct {
+			cmd *Model
+			op  string
+		}{
+			{cmd: cmd.opts.SkipIfSucceed, op: "-ne"},
+			{cmd: cmd.opts.SkipIfFail, op: "-eq"},
+		} {
+			if prereq.cmd == nil {
+				continue
+			}
+
+			name, ok := checks[prereq.cmd]
+			if !ok {
+				return fmt.Errorf("prereq commands should go before the dependent commands: %s", command)
+			}
+			conditions = append(conditions, fmt.Sprintf("[ \"${%s}\" %s 0 ]", name, prereq.op))
+		}
+
+		if len(conditions) == 0 {
+			b.WriteString(command)
+			b.WriteRune('\n')
+			continue
+		}
+
+		b.WriteString(fmt.Sprintf("if %s; then\n  %s\nfi\n", strings.Join(conditions, " && "), command))
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeComment(b *strings.Builder, text string) {
+	for _, line := range strings.Split(strings.TrimSpace(text), "\n") {
+		if line = strings.TrimRight(line, " \t"); len(line) > 0 {
+			b.WriteString("# ")
+			b.WriteString(line)
+		} else {
+			b.WriteRune('#')
+		}
+		b.WriteRune('\n')
+	}
+}
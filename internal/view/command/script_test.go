@@ -0,0 +1,70 @@
+package command
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteScript(t *testing.T) {
+	check := NewReadModel(Opts{
+		Description: "Check if the thing exists.",
+		Command:     `gcloud thing describe "thing" >/dev/null 2>&1`,
+	})
+	commands := []*Model{
+		check,
+		NewWriteModel(Opts{
+			SkipIfSucceed: check,
+			Description:   "Create the thing.\n\nhttps://example.com",
+			Command: `gcloud thing create "thing" \
+	--project=project-id-1234`,
+		}),
+		NewWriteModel(Opts{
+			SkipIfFail:  check,
+			Description: "Update the thing.",
+			Command:     `gcloud thing update "thing"`,
+		}),
+		NewWriteModel(Opts{
+			Description: "Always run.",
+			Command:     `ls`,
+		}),
+	}
+
+	var b strings.Builder
+	assert.NoError(t, WriteScript(&b, commands))
+	assert.Equal(t, `
+# Check if the thing exists.
+if ( gcloud thing describe "thing" >/dev/null 2>&1 ); then
+  CHECK_1=0
+else
+  CHECK_1=1
+fi
+
+# Create the thing.
+#
+# https://example.com
+if [ "${CHECK_1}" -ne 0 ]; then
+  gcloud thing create "thing" --project=project-id-1234
+fi
+
+# Update the thing.
+if [ "${CHECK_1}" -eq 0 ]; then
+  gcloud thing update "thing"
+fi
+
+# Always run.
+ls
+`, b.String())
+}
+
+func TestWriteScriptOutOfOrder(t *testing.T) {
+	check := NewReadModel(Opts{Command: `true`})
+	commands := []*Model{
+		NewWriteModel(Opts{SkipIfSucceed: check, Command: `ls`}),
+		check,
+	}
+
+	var b strings.Builder
+	assert.Error(t, WriteScript(&b, commands))
+}
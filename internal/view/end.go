@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"flightcrew.io/cli/internal/controller"
+	"flightcrew.io/cli/internal/report"
 	"flightcrew.io/cli/internal/style"
 	"flightcrew.io/cli/internal/view/button"
 	"flightcrew.io/cli/internal/view/command"
@@ -16,46 +17,70 @@ import (
 	tea "github.com/charmbracelet/bubbletea"
 )
 
+const (
+	formatText = "text"
+	formatJSON = "json"
+)
+
 type EndModel struct {
 	controller controller.End
+	run        controller.Run
 
 	// All of the commands that were run that should be displayed and maybe printed.
 	commands []*command.Model
 
-	yesButton  *button.Button
-	noButton   *button.Button
-	writeInput wrapinput.Model
-	wrote      bool
-	userInput  bool
+	yesButton   *button.Button
+	noButton    *button.Button
+	writeInput  wrapinput.Model
+	formatInput wrapinput.Model
+	outputDir   string
+	wrote       bool
+	userInput   bool
 
-	confirming bool
+	confirming    bool
+	formatFocused bool
 }
 
-func NewEndModel(ctl controller.End) *EndModel {
+func NewEndModel(run controller.Run) *EndModel {
+	ctl := run.GetEndController()
 	yesButton, _ := button.New("Print", 10)
 	noButton, _ := button.New("Edit", 10)
+
+	fInput := wrapinput.NewRadio([]string{formatText, formatJSON})
+	fInput.Title = "  Format"
+
 	wInput := wrapinput.NewFreeForm()
 	wInput.Title = "  Print output of commands to file"
-	input := wInput.Freeform
-	defaultFile := fmt.Sprintf("/tmp/%s_%d/output/log",
-		strings.Replace(strings.ToLower(ctl.Name()), " ", "_", -1),
-		time.Now().Unix())
-	input.Placeholder = defaultFile
-	wInput.Default = defaultFile
 	wInput.Focus()
 
 	m := &EndModel{
 		controller: ctl,
+		run:        run,
 		commands:   ctl.Commands(),
 		yesButton:  yesButton,
 		noButton:   noButton,
 		writeInput: wInput,
-		wrote:      false,
+		outputDir: fmt.Sprintf("/tmp/%s_%d/output",
+			strings.Replace(strings.ToLower(ctl.Name()), " ", "_", -1),
+			time.Now().Unix()),
+		formatInput: fInput,
+		wrote:       false,
 	}
+	m.updateDefaultFile()
 
 	return m
 }
 
+// updateDefaultFile changes the default file to match the format.
+func (m *EndModel) updateDefaultFile() {
+	defaultFile := filepath.Join(m.outputDir, "log")
+	if m.formatInput.Value() == formatJSON {
+		defaultFile = filepath.Join(m.outputDir, "report.json")
+	}
+	m.writeInput.Freeform.Placeholder = defaultFile
+	m.writeInput.Default = defaultFile
+}
+
 func (m *EndModel) Init() tea.Cmd {
 	return textinput.Blink
 }
@@ -72,11 +97,18 @@ func (m *EndModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			if !m.confirming {
 				m.confirming = true
 				m.writeInput.Blur()
+				m.formatInput.Blur()
 				return m, nil
 			}
 
 			if m.userInput {
-				if err := m.printCommands(m.writeInput.Value()); err != nil {
+				var err error
+				if m.formatInput.Value() == formatJSON {
+					err = report.Write(m.writeInput.Value(), report.New(m.run))
+				} else {
+					err = m.printCommands(m.writeInput.Value())
+				}
+				if err != nil {
 					m.writeInput.SetError(err)
 					return m, nil
 				}
@@ -87,14 +119,29 @@ func (m *EndModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 			m.userInput = false
 			m.confirming = false
+			if m.formatFocused {
+				m.formatInput.Focus()
+				return m, nil
+			}
 			return m, m.writeInput.Focus()
 
-		case "tab":
+		case "tab", "up", "down":
 			if m.confirming {
-				m.userInput = !m.userInput
+				if s == "tab" {
+					m.userInput = !m.userInput
+				}
 				return m, nil
 			}
 
+			m.formatFocused = !m.formatFocused
+			if m.formatFocused {
+				m.writeInput.Blur()
+				m.formatInput.Focus()
+				return m, nil
+			}
+			m.formatInput.Blur()
+			return m, m.writeInput.Focus()
+
 		case "left":
 			if m.confirming {
 				m.userInput = true
@@ -110,6 +157,12 @@ func (m *EndModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	}
 
 	var cmd tea.Cmd
+	if m.formatFocused {
+		m.formatInput, cmd = m.formatInput.Update(msg)
+		m.updateDefaultFile()
+		return m, cmd
+	}
+
 	m.writeInput, cmd = m.writeInput.Update(msg)
 	return m, cmd
 }
@@ -118,6 +171,8 @@ func (m EndModel) View() string {
 	var b strings.Builder
 	b.WriteString(m.controller.EndDescription())
 	b.WriteRune('\n')
+	b.WriteString(m.formatInput.View(wrapinput.ViewParams{ShowValue: m.confirming}))
+	b.WriteRune('\n')
 	b.WriteString(m.writeInput.View(wrapinput.ViewParams{ShowValue: m.confirming}))
 	b.WriteRune('\n')
 	if m.confirming {
@@ -130,7 +185,7 @@ func (m EndModel) View() string {
 	} else {
 		b.WriteRune('\n')
 		b.WriteRune('\n')
-		b.WriteString(style.Help("ctrl+c/esc: quit • enter: confirm"))
+		b.WriteString(style.Help("ctrl+c/esc: quit • ↑/↓/tab: nav • enter: confirm"))
 	}
 
 	return b.String()
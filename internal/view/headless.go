@@ -0,0 +1,91 @@
+package view
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"flightcrew.io/cli/internal/checkpoint"
+	"flightcrew.io/cli/internal/controller"
+	"flightcrew.io/cli/internal/view/command"
+)
+
+// RunHeadless goes through the same Inputs -> Run -> End flow as the interactive views,
+// but without a terminal UI. The inputs are expected to be populated already (e.g. from
+// flags), and every write command that isn't skipped is run without prompting.
+// A plain-text transcript of the commands is written to out.
+func RunHeadless(ctl controller.Inputs, out io.Writer) error {
+	if err := ValidateInputs(ctl); err != nil {
+		return err
+	}
+
+	runCtl := ctl.GetRunController()
+	_, _ = fmt.Fprintf(out, "Running %s\n\n", ctl.GetName())
+
+	return runCommandsHeadless(runCtl, out)
+}
+
+// ResumeHeadless continues a Run controller that was rebuilt from a saved state file, and
+// only runs the commands that haven't finished yet.
+func ResumeHeadless(runCtl controller.Run, out io.Writer) error {
+	_, _ = fmt.Fprintf(out, "Resuming %s\n\n", runCtl.GetEndController().Name())
+
+	return runCommandsHeadless(runCtl, out)
+}
+
+func runCommandsHeadless(runCtl controller.Run, out io.Writer) error {
+	commands := runCtl.Commands()
+	for i, cmd := range commands {
+		if cmd.State() != command.NoneState {
+			continue
+		}
+
+		if cmd.ShouldPrompt() {
+			wc := cmd.GetCommandToRun()
+			wc.SetStdout(io.Discard)
+			wc.SetStderr(io.Discard)
+			cmd.Complete(wc.Run() == nil)
+		}
+		saveProgress(runCtl)
+
+		_, _ = fmt.Fprintln(out, "--------------------")
+		_, _ = fmt.Fprintln(out, cmd.String())
+
+		if !cmd.IsRead() && cmd.State() == command.FailState {
+			if rollback := command.RollbackCommands(commands[:i]); len(rollback) > 0 {
+				_, _ = fmt.Fprintln(out, "\nTo roll back the changes from this run:")
+				for _, undo := range rollback {
+					_, _ = fmt.Fprintln(out, undo.Command())
+				}
+			}
+			_, _ = fmt.Fprintf(out, "\nTo return to the same values:\n%s\n", runCtl.RecreateCommand())
+			if hint := checkpoint.ResumeHint(); len(hint) > 0 {
+				_, _ = fmt.Fprintf(out, "\nTo continue from where you left off:\n%s\n", hint)
+			}
+			return errors.New("command failed")
+		}
+	}
+
+	checkpoint.Remove()
+	endCtl := runCtl.GetEndController()
+	_, _ = fmt.Fprintln(out, "--------------------")
+	_, _ = fmt.Fprintln(out, endCtl.EndDescription())
+	return nil
+}
+
+// ValidateInputs validates the inputs without a terminal UI, and returns an error listing
+// every invalid input.
+func ValidateInputs(ctl controller.Inputs) error {
+	if ctl.Validate(ctl.GetInputs()) {
+		return nil
+	}
+
+	var b strings.Builder
+	for _, input := range ctl.GetAllInputs() {
+		if msg := input.Error(); len(msg) > 0 {
+			b.WriteString(fmt.Sprintf("\n  %s: %s", strings.TrimSpace(input.Title), msg))
+		}
+	}
+	return fmt.Errorf("invalid inputs:%s", b.String())
+}
@@ -0,0 +1,246 @@
+package view
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"flightcrew.io/cli/internal/constants"
+	"flightcrew.io/cli/internal/controller"
+	"flightcrew.io/cli/internal/runner"
+	"flightcrew.io/cli/internal/view/command"
+	"flightcrew.io/cli/internal/view/wrapinput"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	keyProject   = "${PROJECT_ID}"
+	keyToken     = "${API_TOKEN}"
+	keyTokenFile = "${API_TOKEN_FILE}"
+)
+
+// fakeInputs has a required project and an API token, and builds its Run controller from
+// newRun.
+type fakeInputs struct {
+	inputs []*wrapinput.Model
+	newRun func(args map[string]string) controller.Run
+}
+
+func newFakeInputs(project string, token string, newRun func(args map[string]string) controller.Run) *fakeInputs {
+	projectInput := wrapinput.NewFreeForm()
+	projectInput.Title = "Project"
+	projectInput.Required = true
+	projectInput.SetValue(project)
+	tokenInput := wrapinput.NewFreeForm()
+	tokenInput.Title = "API Token"
+	tokenInput.SetValue(token)
+	return &fakeInputs{
+		inputs: []*wrapinput.Model{&projectInput, &tokenInput},
+		newRun: newRun,
+	}
+}
+
+func (ctl fakeInputs) GetName() string                  { return "fake install" }
+func (ctl fakeInputs) GetInputs() []*wrapinput.Model    { return ctl.inputs }
+func (ctl fakeInputs) GetAllInputs() []*wrapinput.Model { return ctl.inputs }
+func (ctl fakeInputs) Reset(inputs []*wrapinput.Model)  {}
+func (ctl fakeInputs) RecreateCommand() string {
+	return "fake install --token=" + ctl.inputs[1].Value()
+}
+func (ctl fakeInputs) GetRunController() controller.Run { return ctl.newRun(ctl.args()) }
+func (ctl fakeInputs) args() map[string]string {
+	return map[string]string{keyProject: ctl.inputs[0].Value(), keyToken: ctl.inputs[1].Value()}
+}
+
+func (ctl fakeInputs) Validate(inputs []*wrapinput.Model) bool {
+	ok := true
+	for _, input := range inputs {
+		if input.Required && len(input.Value()) == 0 {
+			input.SetError(errors.New("required"))
+			ok = false
+		}
+	}
+	return ok
+}
+
+type fakeRun struct {
+	args     map[string]string
+	files    map[string]string
+	commands []*command.Model
+}
+
+func (r fakeRun) Commands() []*command.Model       { return r.commands }
+func (r fakeRun) RecreateCommand() string          { return "fake install --token=" + r.args[keyToken] }
+func (r fakeRun) GetEndController() controller.End { return fakeEnd{commands: r.commands} }
+func (r fakeRun) Args() map[string]string          { return r.args }
+func (r fakeRun) StateName() string                { return r.args[keyProject] }
+func (r fakeRun) Files() map[string]string         { return r.files }
+
+func (r fakeRun) SecretArgs() map[string]string {
+	return map[string]string{keyToken: constants.APITokenEnv, keyTokenFile: ""}
+}
+
+func (r fakeRun) SecretFiles() map[string]string {
+	files := make(map[string]string)
+	if fn := r.args[keyTokenFile]; len(fn) > 0 {
+		files[fn] = constants.APITokenEnv
+	}
+	return files
+}
+
+type fakeEnd struct {
+	commands []*command.Model
+}
+
+func (e fakeEnd) Name() string               { return "fake-install" }
+func (e fakeEnd) EndDescription() string     { return "Installed." }
+func (e fakeEnd) Commands() []*command.Model { return e.commands }
+
+// newSecretRun checks for the secret, adds the token to it unless it exists, and then
+// creates the tower.
+func newSecretRun(args map[string]string) controller.Run {
+	replacer := strings.NewReplacer(keyProject, args[keyProject])
+	check := command.NewReadModel(command.Opts{
+		Command:     `gcloud secrets describe token --project="${PROJECT_ID}"`,
+		Description: "Check for the secret.",
+		Message:     map[command.State]string{command.PassState: "The secret already exists."},
+	})
+	check.Replace(replacer)
+	add := command.NewWriteModel(command.Opts{
+		Command:       `gcloud secrets create token --project="${PROJECT_ID}"`,
+		Description:   "Create the secret.",
+		SkipIfSucceed: check,
+	})
+	add.Replace(replacer)
+	create := command.NewWriteModel(command.Opts{
+		Command:     `gcloud compute instances create-with-container tower --project="${PROJECT_ID}"`,
+		Description: "Create the tower.",
+	})
+	create.Replace(replacer)
+	return fakeRun{args: args, commands: []*command.Model{check, add, create}}
+}
+
+func TestRunHeadless(mainT *testing.T) {
+	keepRunner := runner.Default
+	mainT.Cleanup(func() {
+		runner.Default = keepRunner
+	})
+
+	mainT.Run("invalid inputs should be listed without running anything", func(t *testing.T) {
+		scripted, err := runner.NewScripted()
+		require.NoError(t, err)
+		runner.Default = scripted
+
+		ctl := newFakeInputs("", "my-secret-token", newSecretRun)
+		ctl.inputs[1].Required = true
+		ctl.inputs[1].SetValue("")
+
+		var out bytes.Buffer
+		err = RunHeadless(ctl, &out)
+		require.Error(t, err)
+		assert.Equal(t, "invalid inputs:\n  Project: required\n  API Token: required", err.Error())
+		assert.Empty(t, out.String())
+		assert.Empty(t, scripted.Calls)
+	})
+
+	mainT.Run("valid inputs should pass validation", func(t *testing.T) {
+		assert.NoError(t, ValidateInputs(newFakeInputs("my-project", "my-secret-token", newSecretRun)))
+	})
+
+	mainT.Run("write should be skipped if its check succeeds", func(t *testing.T) {
+		scripted, err := runner.NewScripted(
+			runner.Response{Match: `^gcloud `},
+		)
+		require.NoError(t, err)
+		runner.Default = scripted
+
+		var out bytes.Buffer
+		require.NoError(t, RunHeadless(newFakeInputs("my-project", "my-secret-token", newSecretRun), &out))
+
+		assert.Equal(t, []string{
+			`gcloud secrets describe token --project="my-project"`,
+			`gcloud compute instances create-with-container tower --project="my-project"`,
+		}, scripted.Calls)
+		assert.Contains(t, out.String(), "Running fake install\n")
+		assert.Contains(t, out.String(), "[SKIPPED] The secret already exists.\n")
+		assert.True(t, strings.HasSuffix(out.String(), "--------------------\nInstalled.\n"))
+	})
+
+	mainT.Run("write should run if its check fails", func(t *testing.T) {
+		scripted, err := runner.NewScripted(
+			runner.Response{Match: `^gcloud secrets describe`, Stdout: "NOT_FOUND\n", ExitCode: 1},
+			runner.Response{Match: `^gcloud `},
+		)
+		require.NoError(t, err)
+		runner.Default = scripted
+
+		var out bytes.Buffer
+		require.NoError(t, RunHeadless(newFakeInputs("my-project", "my-secret-token", newSecretRun), &out))
+
+		assert.Equal(t, []string{
+			`gcloud secrets describe token --project="my-project"`,
+			`gcloud secrets create token --project="my-project"`,
+			`gcloud compute instances create-with-container tower --project="my-project"`,
+		}, scripted.Calls)
+		assert.Contains(t, out.String(), "NOT_FOUND\n")
+	})
+
+	mainT.Run("failed write should stop the run", func(t *testing.T) {
+		scripted, err := runner.NewScripted(
+			runner.Response{Match: `^gcloud secrets describe`, ExitCode: 1},
+			runner.Response{Match: `^gcloud secrets create`, Stdout: "PERMISSION_DENIED\n", ExitCode: 3},
+			runner.Response{Match: `^gcloud `},
+		)
+		require.NoError(t, err)
+		runner.Default = scripted
+
+		var run controller.Run
+		ctl := newFakeInputs("my-project", "my-secret-token", func(args map[string]string) controller.Run {
+			run = newSecretRun(args)
+			return run
+		})
+
+		var out bytes.Buffer
+		err = RunHeadless(ctl, &out)
+		require.EqualError(t, err, "command failed")
+		assert.Len(t, scripted.Calls, 2, "commands after the failed write should not run")
+
+		commands := run.Commands()
+		assert.Equal(t, command.FailState, commands[1].State())
+		assert.Equal(t, command.NoneState, commands[2].State())
+		assert.Contains(t, out.String(), "⛔️ [ERROR] ")
+		assert.Contains(t, out.String(), "PERMISSION_DENIED\n")
+		assert.Contains(t, out.String(), "\nTo return to the same values:\nfake install --token=my-secret-token\n")
+		assert.NotContains(t, out.String(), "Installed.")
+	})
+}
+
+func TestResumeHeadless(t *testing.T) {
+	keepRunner := runner.Default
+	t.Cleanup(func() {
+		runner.Default = keepRunner
+	})
+	scripted, err := runner.NewScripted(
+		runner.Response{Match: `^gcloud `},
+	)
+	require.NoError(t, err)
+	runner.Default = scripted
+
+	// The check failed and the secret was created before the run stopped.
+	run := newSecretRun(map[string]string{keyProject: "my-project"})
+	commands := run.Commands()
+	commands[0].Complete(false)
+	commands[1].Complete(true)
+
+	var out bytes.Buffer
+	require.NoError(t, ResumeHeadless(run, &out))
+
+	assert.Equal(t, []string{
+		`gcloud compute instances create-with-container tower --project="my-project"`,
+	}, scripted.Calls, "only the commands that haven't finished should run")
+	assert.True(t, strings.HasPrefix(out.String(), "Resuming fake-install\n\n"))
+	assert.Equal(t, command.PassState, commands[2].State())
+	assert.True(t, strings.HasSuffix(out.String(), "--------------------\nInstalled.\n"))
+}
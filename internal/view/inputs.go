@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"strings"
 
+	"flightcrew.io/cli/internal/checkpoint"
 	"flightcrew.io/cli/internal/controller"
 	"flightcrew.io/cli/internal/style"
 	"flightcrew.io/cli/internal/view/button"
@@ -116,6 +117,12 @@ func printRecreatedCommand(cmd string) {
 	fmt.Println()
 	fmt.Println("To return to the same values:")
 	fmt.Println(cmd)
+
+	if hint := checkpoint.ResumeHint(); len(hint) > 0 {
+		fmt.Println()
+		fmt.Println("To continue from where you left off:")
+		fmt.Println(hint)
+	}
 }
 
 func (m InputsModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
@@ -140,6 +147,9 @@ func (m InputsModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					if !m.confirming {
 						m.confirming = true
 						m.hasErrors = !m.controller.Validate(m.inputs)
+						// Validating can show more inputs (e.g. once a resource is found).
+						m.inputs = m.controller.GetInputs()
+						m.index = len(m.inputs)
 						if m.hasErrors {
 							m.index = len(m.inputs) + 1
 						}
@@ -0,0 +1,75 @@
+package view
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"flightcrew.io/cli/internal/constants"
+	"flightcrew.io/cli/internal/controller"
+	"flightcrew.io/cli/internal/view/command"
+)
+
+// WritePlan validates the inputs and writes every command that the flow would run into
+// a bash script, without running any of the write commands.
+func WritePlan(ctl controller.Inputs, w io.Writer) error {
+	if err := ValidateInputs(ctl); err != nil {
+		return err
+	}
+
+	runCtl := ctl.GetRunController()
+	redactor := controller.NewRedactor(runCtl)
+	planRedactor, envs := controller.NewPlanRedactor(runCtl)
+
+	// The commands read the secrets from env vars instead, so that they aren't in the plan.
+	var script strings.Builder
+	if err := command.WriteScript(&script, runCtl.Commands()); err != nil {
+		return err
+	}
+	commands := planRedactor.Replace(script.String())
+
+	var b strings.Builder
+	b.WriteString("#!/usr/bin/env bash\n#\n")
+	b.WriteString(fmt.Sprintf("# %s plan generated by %s.\n", ctl.GetName(), constants.CLIName))
+	b.WriteString(fmt.Sprintf("# To return to the same values: %s\n\n", redactor.Replace(runCtl.RecreateCommand())))
+	b.WriteString("set -e\n")
+	for _, env := range envs {
+		if strings.Contains(commands, "${"+env+"}") {
+			b.WriteString(fmt.Sprintf(": \"${%s:?set %s to run the commands below}\"\n", env, env))
+		}
+	}
+
+	if filesCtl, ok := runCtl.(controller.Files); ok {
+		files := filesCtl.Files()
+		paths := make([]string, 0, len(files))
+		for path := range files {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+
+		for _, path := range paths {
+			b.WriteString(fmt.Sprintf("\n# Recreate the local file referenced by the commands below.\nmkdir -p \"$(dirname '%s')\"\ncat > '%s' <<'CREWCLI_EOF'\n%s\nCREWCLI_EOF\n", path, path, strings.TrimRight(redactor.Replace(files[path]), "\n")))
+		}
+	}
+
+	// Secret files are written from env vars too, so that they aren't in the plan. The umask
+	// keeps the files readable by only the user, and printf doesn't add a newline.
+	if secretsCtl, ok := runCtl.(controller.SecretFiles); ok {
+		files := secretsCtl.SecretFiles()
+		paths := make([]string, 0, len(files))
+		for path := range files {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+
+		for _, path := range paths {
+			env := files[path]
+			b.WriteString(fmt.Sprintf("\n# Write the secret file referenced by the commands below from $%s.\n: \"${%s:?set %s to write '%s'}\"\nmkdir -p \"$(dirname '%s')\"\n(umask 077 && printf %%s \"$%s\" > '%s')\n", env, env, env, path, path, env, path))
+		}
+	}
+
+	b.WriteString(commands)
+	_, err := io.WriteString(w, b.String())
+	return err
+}
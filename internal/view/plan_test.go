@@ -0,0 +1,73 @@
+package view
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"flightcrew.io/cli/internal/controller"
+	"flightcrew.io/cli/internal/view/command"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const keyRoleFile = "${ROLE_FILE}"
+
+func TestWritePlan(mainT *testing.T) {
+	mainT.Run("token should be read from an env var", func(t *testing.T) {
+		tokenFile := filepath.Join(t.TempDir(), "api_token")
+		require.NoError(t, os.WriteFile(tokenFile, []byte("my-secret-token"), 0600))
+		roleFile := filepath.Join(t.TempDir(), "role.yaml")
+
+		ctl := newFakeInputs("my-project", "my-secret-token", func(args map[string]string) controller.Run {
+			args[keyTokenFile] = tokenFile
+			args[keyRoleFile] = roleFile
+			replacer := strings.NewReplacer(keyProject, args[keyProject], keyToken, args[keyToken], keyTokenFile, tokenFile)
+			check := command.NewReadModel(command.Opts{
+				Command:     `gcloud secrets describe token --project="${PROJECT_ID}"`,
+				Description: "Check for the secret.",
+			})
+			check.Replace(replacer)
+			add := command.NewWriteModel(command.Opts{
+				Command:       `gcloud secrets versions add token --data-file="${API_TOKEN_FILE}"`,
+				Description:   "Add the token (${API_TOKEN}) to the secret.",
+				SkipIfSucceed: check,
+			})
+			add.Replace(replacer)
+			create := command.NewWriteModel(command.Opts{
+				Command: `gcloud compute instances create-with-container tower --container-env="FC_API_KEY=${API_TOKEN}"`,
+			})
+			create.Replace(replacer)
+			return fakeRun{
+				args:     args,
+				files:    map[string]string{roleFile: "title: role\ntoken: my-secret-token\n"},
+				commands: []*command.Model{check, add, create},
+			}
+		})
+
+		var plan bytes.Buffer
+		require.NoError(t, WritePlan(ctl, &plan))
+		assert.NotContains(t, plan.String(), "my-secret-token")
+		assert.Contains(t, plan.String(), "# To return to the same values: fake install --token=<redacted>\n")
+		assert.Contains(t, plan.String(), `: "${FLIGHTCREW_API_TOKEN:?set FLIGHTCREW_API_TOKEN to run the commands below}"`)
+		assert.Contains(t, plan.String(), "title: role\ntoken: <redacted>\nCREWCLI_EOF\n")
+		assert.Contains(t, plan.String(), "(umask 077 && printf %s \"$FLIGHTCREW_API_TOKEN\" > '"+tokenFile+"')\n")
+		assert.Contains(t, plan.String(), "# Add the token (${FLIGHTCREW_API_TOKEN}) to the secret.\n")
+		assert.Contains(t, plan.String(), `--container-env="FC_API_KEY=${FLIGHTCREW_API_TOKEN}"`)
+		assert.Contains(t, plan.String(), `--data-file="`+tokenFile+`"`)
+	})
+
+	mainT.Run("invalid inputs should not write a plan", func(t *testing.T) {
+		ctl := newFakeInputs("", "my-secret-token", func(args map[string]string) controller.Run {
+			return fakeRun{args: args}
+		})
+
+		var plan bytes.Buffer
+		err := WritePlan(ctl, &plan)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "Project: required")
+		assert.Empty(t, plan.String())
+	})
+}
@@ -1,10 +1,13 @@
 package view
 
 import (
+	"fmt"
 	"strings"
 
+	"flightcrew.io/cli/internal/checkpoint"
 	"flightcrew.io/cli/internal/controller"
 	"flightcrew.io/cli/internal/debug"
+	"flightcrew.io/cli/internal/report"
 	"flightcrew.io/cli/internal/style"
 	"flightcrew.io/cli/internal/view/button"
 	"flightcrew.io/cli/internal/view/command"
@@ -28,6 +31,11 @@ type RunModel struct {
 	index      int
 
 	userInput bool
+
+	// rollback are the commands that undo the changes from this session, which are offered
+	// when a write command fails.
+	rollback    []*command.Model
+	rollingBack bool
 }
 
 func NewRunModel(controller controller.Run) *RunModel {
@@ -54,6 +62,7 @@ func NewRunModel(controller controller.Run) *RunModel {
 	m.paginator = p
 
 	m.nextCommand()
+	saveProgress(controller)
 
 	return m
 }
@@ -73,6 +82,7 @@ func (m *RunModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		switch msg.String() {
 		// Allow user to quit at any time.
 		case "ctrl+c", "esc":
+			saveProgress(m.controller)
 			printRecreatedCommand(m.controller.RecreateCommand())
 			return m, tea.Quit
 
@@ -104,7 +114,9 @@ func (m *RunModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 				if cmd.State() == command.PromptState && m.userInput {
 					m.userInput = false
-					return m, tea.Exec(cmd.GetCommandToRun(), func(err error) tea.Msg {
+					wc := cmd.GetCommandToRun()
+					saveProgress(m.controller)
+					return m, tea.Exec(wc, func(err error) tea.Msg {
 						return cmdFinishedErr{err}
 					})
 				}
@@ -131,6 +143,10 @@ func (m *RunModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		case cmdFinishedErr:
 			cmd := m.commands[m.index]
 			cmd.Complete(msg.err == nil)
+			saveProgress(m.controller)
+			if cmd.State() == command.FailState && !m.rollingBack {
+				m.rollback = command.RollbackCommands(m.commands[:m.index])
+			}
 			return m, nil
 		}
 		return m, nil
@@ -138,17 +154,45 @@ func (m *RunModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case command.PassState:
 		switch msg.(type) {
 		case tea.KeyMsg:
-			if !m.nextCommand() {
-				return NewEndModel(m.controller.GetEndController()), nil
+			more := m.nextCommand()
+			saveProgress(m.controller)
+			if !more && m.rollingBack {
+				fmt.Println("\n\nRolled back the changes from this run.")
+				printRecreatedCommand(m.controller.RecreateCommand())
+				return m, tea.Quit
+			} else if !more {
+				checkpoint.Remove()
+				return NewEndModel(m.controller), nil
 			}
 
 			return m, nil
 		}
 
 	case command.FailState:
-		switch msg.(type) {
+		switch msg := msg.(type) {
 		case tea.KeyMsg:
-			return m, tea.Quit
+			if len(m.rollback) == 0 {
+				return m, tea.Quit
+			}
+
+			// Offer to roll back with the same controls as running a command.
+			switch msg.String() {
+			case "enter":
+				if !m.userInput {
+					printRecreatedCommand(m.controller.RecreateCommand())
+					return m, tea.Quit
+				}
+				m.startRollback()
+
+			case "tab":
+				m.userInput = !m.userInput
+
+			case "left":
+				m.userInput = true
+
+			case "right":
+				m.userInput = false
+			}
 		}
 	}
 
@@ -175,7 +219,15 @@ func (m *RunModel) View() string {
 		b.WriteRune('\n')
 	}
 
-	if cmd.State() == command.FailState && !cmd.IsRead() {
+	if cmd.State() == command.FailState && !cmd.IsRead() && len(m.rollback) > 0 {
+		b.WriteString(style.Action("[ACTION REQUIRED]"))
+		b.WriteString(fmt.Sprintf(" Roll back the %d change(s) from this run? ", len(m.rollback)))
+		b.WriteString(m.yesButton.View(m.userInput))
+		b.WriteString("  ")
+		b.WriteString(m.noButton.View(!m.userInput))
+		b.WriteRune('\n')
+		b.WriteString(style.Help("ctrl+c/esc: quit • ←/→/enter: roll back"))
+	} else if cmd.State() == command.FailState && !cmd.IsRead() {
 		b.WriteString(style.Help("(press any key to quit)"))
 	} else if cmd.State() == command.PassState {
 		b.WriteString(style.Help("(press any key to continue)"))
@@ -189,6 +241,18 @@ func (m *RunModel) View() string {
 	return b.String()
 }
 
+// startRollback replaces the commands with the ones that undo the changes from this run,
+// which are then prompted for one by one.
+func (m *RunModel) startRollback() {
+	m.commands = m.rollback
+	m.rollback = nil
+	m.rollingBack = true
+	m.userInput = false
+	m.index = 0
+	m.paginator.SetTotalPages(len(m.commands))
+	m.nextCommand()
+}
+
 func (m *RunModel) nextCommand() bool {
 	for ; m.index < len(m.commands); m.index++ {
 		m.paginator.Page = m.index
@@ -204,3 +268,10 @@ func (m *RunModel) nextCommand() bool {
 
 	return false
 }
+
+// saveProgress saves the state of the run for --resume and its report for --report, if
+// they are enabled.
+func saveProgress(run controller.Run) {
+	checkpoint.Save(run)
+	report.Save(run)
+}
@@ -0,0 +1,205 @@
+package selectinput
+
+import (
+	"fmt"
+	"strings"
+
+	"flightcrew.io/cli/internal/style"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// shownAfter is how many of the following options are shown next to the selected one, so
+// that the list fits on one line.
+const shownAfter = 2
+
+// Option is a value that can be selected. The label is shown next to the value to describe
+// it (e.g. which version a tag points to).
+type Option struct {
+	Value string
+	Label string
+}
+
+// Model is a list of options that is too long to show all at once like a radio input.
+// Typing narrows the options down to the ones that contain the search text.
+type Model struct {
+	prevKeys     map[string]struct{}
+	nextKeys     map[string]struct{}
+	options      []Option
+	currentIndex int
+	focused      bool
+
+	search string
+	// matches are the indexes of the options that match the search, in order.
+	matches []int
+}
+
+func NewModel(opts []Option) Model {
+	m := Model{
+		options:      opts,
+		currentIndex: 0,
+		prevKeys:     map[string]struct{}{"left": {}},
+		nextKeys:     map[string]struct{}{"right": {}},
+		focused:      false,
+	}
+	m.setSearch("")
+	return m
+}
+
+func (m Model) Value() string {
+	if len(m.options) == 0 {
+		return ""
+	}
+	return m.options[m.currentIndex].Value
+}
+
+// SetValue selects the option with the value. Unlike a radio input, values that aren't
+// options are added to the front of the list, since they can come from flags.
+func (m *Model) SetValue(val string) {
+	for i := 0; i < len(m.options); i++ {
+		if m.options[i].Value == val {
+			m.currentIndex = i
+			return
+		}
+	}
+
+	m.options = append([]Option{{Value: val}}, m.options...)
+	m.currentIndex = 0
+	m.setSearch(m.search)
+}
+
+// SetOptions replaces the options, and keeps the current value selected.
+func (m *Model) SetOptions(opts []Option) {
+	val := m.Value()
+	m.options = opts
+	m.currentIndex = 0
+	if len(val) > 0 {
+		m.SetValue(val)
+	}
+	m.setSearch(m.search)
+}
+
+// setSearch keeps the options that contain the search text in their value or label, and
+// moves to the first one if the current option doesn't.
+func (m *Model) setSearch(search string) {
+	m.search = search
+	m.matches = nil
+	query := strings.ToLower(search)
+	current := false
+	for i, opt := range m.options {
+		if strings.Contains(strings.ToLower(opt.Value), query) || strings.Contains(strings.ToLower(opt.Label), query) {
+			m.matches = append(m.matches, i)
+			current = current || i == m.currentIndex
+		}
+	}
+
+	if !current && len(m.matches) > 0 {
+		m.currentIndex = m.matches[0]
+	}
+}
+
+// matchIndex is where the current option is in the matches, or -1 if it doesn't match.
+func (m Model) matchIndex() int {
+	for i, index := range m.matches {
+		if index == m.currentIndex {
+			return i
+		}
+	}
+	return -1
+}
+
+func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
+	if !m.focused || len(m.options) == 0 {
+		return m, nil
+	}
+
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch msg.Type {
+		case tea.KeyRunes, tea.KeySpace:
+			m.setSearch(m.search + string(msg.Runes))
+			return m, nil
+		case tea.KeyBackspace:
+			if runes := []rune(m.search); len(runes) > 0 {
+				m.setSearch(string(runes[:len(runes)-1]))
+			}
+			return m, nil
+		}
+
+		i := m.matchIndex()
+		if len(m.matches) == 0 || i < 0 {
+			return m, nil
+		}
+
+		s := msg.String()
+		if _, ok := m.prevKeys[s]; ok {
+			i--
+		} else if _, ok := m.nextKeys[s]; ok {
+			i++
+		}
+
+		if i < 0 {
+			i = len(m.matches) - 1
+		} else if i >= len(m.matches) {
+			i = 0
+		}
+		m.currentIndex = m.matches[i]
+	}
+
+	return m, nil
+}
+
+func (m Model) View() string {
+	var b strings.Builder
+	if m.focused {
+		b.WriteString(style.Focused.Render("> "))
+	} else {
+		b.WriteString("> ")
+	}
+
+	if len(m.options) == 0 {
+		return b.String()
+	}
+
+	current := m.options[m.currentIndex]
+	text := current.Value
+	if len(current.Label) > 0 {
+		text = fmt.Sprintf("%s (%s)", current.Value, current.Label)
+	}
+	if m.focused {
+		b.WriteString(style.Highlight(text))
+	} else {
+		b.WriteString(style.BlurHighlight(text))
+	}
+
+	if !m.focused {
+		return b.String()
+	}
+
+	if len(m.search) > 0 && len(m.matches) == 0 {
+		b.WriteString(style.Blurred.Render(fmt.Sprintf(" [no match for \"%s\"]", m.search)))
+		return b.String()
+	}
+
+	i := m.matchIndex()
+	for j := i + 1; i >= 0 && j < len(m.matches) && j <= i+shownAfter; j++ {
+		b.WriteString(" • ")
+		b.WriteString(style.Blurred.Render(m.options[m.matches[j]].Value))
+	}
+	if len(m.search) > 0 {
+		b.WriteString(style.Blurred.Render(fmt.Sprintf(" [%d/%d matching \"%s\"]", i+1, len(m.matches), m.search)))
+	} else {
+		b.WriteString(style.Blurred.Render(fmt.Sprintf(" [%d/%d]", i+1, len(m.matches))))
+	}
+
+	return b.String()
+}
+
+func (m *Model) Focus() {
+	m.focused = true
+}
+
+// Blur clears the search, but keeps the option that was found.
+func (m *Model) Blur() {
+	m.focused = false
+	m.setSearch("")
+}
@@ -0,0 +1,119 @@
+package selectinput
+
+import (
+	"regexp"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearch(t *testing.T) {
+	m := NewModel([]Option{
+		{Value: "us-central1-a", Label: "us-central1"},
+		{Value: "us-east1-b", Label: "us-east1"},
+		{Value: "europe-west1-b", Label: "europe-west1"},
+		{Value: "europe-west1-c", Label: "europe-west1"},
+	})
+	m.Focus()
+
+	for _, r := range "west" {
+		m, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+	}
+	assert.Equal(t, "europe-west1-b", m.Value())
+	assert.Contains(t, m.View(), `[1/2 matching "west"]`)
+
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyRight})
+	assert.Equal(t, "europe-west1-c", m.Value())
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyRight})
+	assert.Equal(t, "europe-west1-b", m.Value())
+
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("x")})
+	assert.Equal(t, "europe-west1-b", m.Value())
+	assert.Contains(t, m.View(), `[no match for "westx"]`)
+
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyBackspace})
+	m.Blur()
+	assert.Equal(t, "europe-west1-b", m.Value())
+
+	m.Focus()
+	assert.Contains(t, m.View(), "[3/4]")
+}
+
+func TestCursor(t *testing.T) {
+	m := NewModel([]Option{{Value: "1.2.0"}, {Value: "1.1.0"}, {Value: "1.0.0"}})
+	assert.Equal(t, "1.2.0", m.Value())
+
+	// Keys are ignored until the input is focused.
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyRight})
+	assert.Equal(t, "1.2.0", m.Value())
+
+	m.Focus()
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyLeft})
+	assert.Equal(t, "1.0.0", m.Value(), "left from the first option should wrap around")
+	assert.Contains(t, m.View(), "[3/3]")
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyRight})
+	assert.Equal(t, "1.2.0", m.Value(), "right from the last option should wrap around")
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyRight})
+	assert.Equal(t, "1.1.0", m.Value())
+	assert.Contains(t, m.View(), "[2/3]")
+
+	empty := NewModel(nil)
+	empty.Focus()
+	empty, _ = empty.Update(tea.KeyMsg{Type: tea.KeyRight})
+	assert.Empty(t, empty.Value())
+	assert.Equal(t, "> ", stripStyle(empty.View()))
+}
+
+func TestFilter(t *testing.T) {
+	m := NewModel([]Option{
+		{Value: "stable", Label: "1.2.0"},
+		{Value: "1.2.0"},
+		{Value: "1.1.0", Label: "Old"},
+	})
+	m.Focus()
+
+	// Labels are searched too, without case.
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("OLD")})
+	assert.Equal(t, "1.1.0", m.Value())
+	assert.Contains(t, m.View(), `[1/1 matching "OLD"]`)
+
+	for range "OLD" {
+		m, _ = m.Update(tea.KeyMsg{Type: tea.KeyBackspace})
+	}
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("1.2")})
+	assert.Equal(t, "stable", m.Value(), "current option should move to the first match")
+	assert.Contains(t, m.View(), `[1/2 matching "1.2"]`)
+
+	// Only the matches are cycled through.
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyRight})
+	assert.Equal(t, "1.2.0", m.Value())
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyRight})
+	assert.Equal(t, "stable", m.Value())
+}
+
+func TestSelection(t *testing.T) {
+	m := NewModel([]Option{{Value: "us-central1-a"}, {Value: "us-central1-b"}})
+
+	m.SetValue("us-central1-b")
+	assert.Equal(t, "us-central1-b", m.Value())
+
+	// Values that aren't options (e.g. from flags) are added to the front.
+	m.SetValue("us-west1-a")
+	assert.Equal(t, "us-west1-a", m.Value())
+	m.Focus()
+	assert.Contains(t, m.View(), "[1/3]")
+
+	// The selected value is kept when the options change.
+	m.SetOptions([]Option{{Value: "us-east1-b"}, {Value: "us-west1-a"}})
+	assert.Equal(t, "us-west1-a", m.Value())
+	assert.Contains(t, m.View(), "[2/2]")
+
+	m.Blur()
+	assert.NotContains(t, m.View(), "[2/2]", "position is only shown while focused")
+}
+
+// stripStyle removes the terminal styling from the view.
+func stripStyle(s string) string {
+	return regexp.MustCompile(`\x1b\[[0-9;]*m`).ReplaceAllString(s, "")
+}
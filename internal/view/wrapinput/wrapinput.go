@@ -5,6 +5,7 @@ import (
 
 	"flightcrew.io/cli/internal/style"
 	"flightcrew.io/cli/internal/view/radioinput"
+	"flightcrew.io/cli/internal/view/selectinput"
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
 )
@@ -19,6 +20,7 @@ type Model struct {
 	// Types of inputs. Pointers so that we can tell which one is being used.
 	Freeform *textinput.Model
 	Radio    *radioinput.Model
+	Select   *selectinput.Model
 
 	Title    string
 	HelpText string
@@ -37,6 +39,13 @@ func NewRadio(options []string) Model {
 	}
 }
 
+func NewSelect(options []selectinput.Option) Model {
+	var sel = selectinput.NewModel(options)
+	return Model{
+		Select: &sel,
+	}
+}
+
 func NewFreeForm() Model {
 	var freeform = textinput.New()
 	freeform.CursorStyle = style.Focused.Copy()
@@ -64,6 +73,8 @@ func (m Model) View(params ViewParams) string {
 	if params.ShowValue {
 		if m.Radio != nil {
 			b.WriteString(m.Radio.Value())
+		} else if m.Select != nil {
+			b.WriteString(m.Select.Value())
 		} else if m.Freeform != nil {
 			if val := m.Freeform.Value(); len(val) > 0 {
 				b.WriteString(m.Freeform.Value())
@@ -86,6 +97,8 @@ func (m Model) View(params ViewParams) string {
 	} else {
 		if m.Radio != nil {
 			b.WriteString(m.Radio.View())
+		} else if m.Select != nil {
+			b.WriteString(m.Select.View())
 		} else {
 			b.WriteString(m.Freeform.View())
 		}
@@ -98,6 +111,9 @@ func (m *Model) Focus() tea.Cmd {
 	if m.Radio != nil {
 		m.Radio.Focus()
 		return nil
+	} else if m.Select != nil {
+		m.Select.Focus()
+		return nil
 	} else if m.Freeform != nil {
 		cmd := m.Freeform.Focus()
 		m.Freeform.PromptStyle = style.Focused
@@ -111,6 +127,8 @@ func (m *Model) Focus() tea.Cmd {
 func (m *Model) Blur() {
 	if m.Radio != nil {
 		m.Radio.Blur()
+	} else if m.Select != nil {
+		m.Select.Blur()
 	} else if m.Freeform != nil {
 		m.Freeform.Blur()
 		m.Freeform.PromptStyle = style.None
@@ -122,6 +140,8 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 	var cmd tea.Cmd
 	if m.Radio != nil {
 		*m.Radio, cmd = m.Radio.Update(msg)
+	} else if m.Select != nil {
+		*m.Select, cmd = m.Select.Update(msg)
 	} else if m.Freeform != nil {
 		*m.Freeform, cmd = m.Freeform.Update(msg)
 	}
@@ -134,6 +154,11 @@ func (m *Model) SetValue(val string) {
 		return
 	}
 
+	if m.Select != nil {
+		m.Select.SetValue(val)
+		return
+	}
+
 	if m.Freeform != nil {
 		m.Freeform.SetValue(val)
 		return
@@ -151,6 +176,12 @@ func (m Model) Value() string {
 		}
 	}
 
+	if m.Select != nil {
+		if val := m.Select.Value(); len(val) > 0 {
+			return val
+		}
+	}
+
 	if m.Freeform != nil {
 		if val := m.Freeform.Value(); len(val) > 0 {
 			return val
@@ -177,6 +208,11 @@ func (m *Model) SetError(err error) {
 	}
 }
 
+// Error returns the validation error message, if any.
+func (m Model) Error() string {
+	return m.validation.ErrorMessage
+}
+
 func (m *Model) ResetValidation() {
 	m.validating = false
 	m.validation = ValidateParams{}